@@ -0,0 +1,602 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+type FakeBackend struct {
+	RestoreReportStub        func() linux_backend.RestoreReport
+	restoreReportMutex       sync.RWMutex
+	restoreReportArgsForCall []struct{}
+	restoreReportReturns     struct {
+		result1 linux_backend.RestoreReport
+	}
+	QuarantinedStub        func() ([]linux_backend.QuarantinedSnapshot, error)
+	quarantinedMutex       sync.RWMutex
+	quarantinedArgsForCall []struct{}
+	quarantinedReturns     struct {
+		result1 []linux_backend.QuarantinedSnapshot
+		result2 error
+	}
+	RestoreQuarantinedStub        func(id string) (garden.Container, error)
+	restoreQuarantinedMutex       sync.RWMutex
+	restoreQuarantinedArgsForCall []struct {
+		id string
+	}
+	restoreQuarantinedReturns struct {
+		result1 garden.Container
+		result2 error
+	}
+	DiscardQuarantinedStub        func(id string) error
+	discardQuarantinedMutex       sync.RWMutex
+	discardQuarantinedArgsForCall []struct {
+		id string
+	}
+	discardQuarantinedReturns struct {
+		result1 error
+	}
+	SubscribeStub        func() *event_hub.Subscription
+	subscribeMutex       sync.RWMutex
+	subscribeArgsForCall []struct{}
+	subscribeReturns     struct {
+		result1 *event_hub.Subscription
+	}
+	CapacityReportStub        func() (capacity.Report, error)
+	capacityReportMutex       sync.RWMutex
+	capacityReportArgsForCall []struct{}
+	capacityReportReturns     struct {
+		result1 capacity.Report
+		result2 error
+	}
+	PauseStub        func(handle string) error
+	pauseMutex       sync.RWMutex
+	pauseArgsForCall []struct {
+		handle string
+	}
+	pauseReturns struct {
+		result1 error
+	}
+	ResumeStub        func(handle string) error
+	resumeMutex       sync.RWMutex
+	resumeArgsForCall []struct {
+		handle string
+	}
+	resumeReturns struct {
+		result1 error
+	}
+	StopContainerStub        func(handle string, gracePeriod time.Duration) error
+	stopContainerMutex       sync.RWMutex
+	stopContainerArgsForCall []struct {
+		handle      string
+		gracePeriod time.Duration
+	}
+	stopContainerReturns struct {
+		result1 error
+	}
+	LimitLinuxStub        func(handle string, limits linux_backend.LinuxLimits) error
+	limitLinuxMutex       sync.RWMutex
+	limitLinuxArgsForCall []struct {
+		handle string
+		limits linux_backend.LinuxLimits
+	}
+	limitLinuxReturns struct {
+		result1 error
+	}
+	CurrentLinuxLimitsStub        func(handle string) (linux_backend.LinuxLimits, error)
+	currentLinuxLimitsMutex       sync.RWMutex
+	currentLinuxLimitsArgsForCall []struct {
+		handle string
+	}
+	currentLinuxLimitsReturns struct {
+		result1 linux_backend.LinuxLimits
+		result2 error
+	}
+	LinuxMetricsStub        func(handle string) (linux_backend.LinuxMetrics, error)
+	linuxMetricsMutex       sync.RWMutex
+	linuxMetricsArgsForCall []struct {
+		handle string
+	}
+	linuxMetricsReturns struct {
+		result1 linux_backend.LinuxMetrics
+		result2 error
+	}
+	MetricsHistoryStub        func(handle string) (linux_backend.MetricsHistory, error)
+	metricsHistoryMutex       sync.RWMutex
+	metricsHistoryArgsForCall []struct {
+		handle string
+	}
+	metricsHistoryReturns struct {
+		result1 linux_backend.MetricsHistory
+		result2 error
+	}
+	OOMRecordsStub        func(handle string) ([]linux_backend.OOMRecord, error)
+	oOMRecordsMutex       sync.RWMutex
+	oOMRecordsArgsForCall []struct {
+		handle string
+	}
+	oOMRecordsReturns struct {
+		result1 []linux_backend.OOMRecord
+		result2 error
+	}
+	SignalProcessStub        func(handle string, processID uint32, signal garden.Signal, scope linux_backend.SignalScope) error
+	signalProcessMutex       sync.RWMutex
+	signalProcessArgsForCall []struct {
+		handle    string
+		processID uint32
+		signal    garden.Signal
+		scope     linux_backend.SignalScope
+	}
+	signalProcessReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeBackend) RestoreReport() linux_backend.RestoreReport {
+	fake.restoreReportMutex.Lock()
+	fake.restoreReportArgsForCall = append(fake.restoreReportArgsForCall, struct{}{})
+	fake.restoreReportMutex.Unlock()
+	if fake.RestoreReportStub != nil {
+		return fake.RestoreReportStub()
+	} else {
+		return fake.restoreReportReturns.result1
+	}
+}
+
+func (fake *FakeBackend) RestoreReportCallCount() int {
+	fake.restoreReportMutex.RLock()
+	defer fake.restoreReportMutex.RUnlock()
+	return len(fake.restoreReportArgsForCall)
+}
+
+func (fake *FakeBackend) RestoreReportReturns(result1 linux_backend.RestoreReport) {
+	fake.RestoreReportStub = nil
+	fake.restoreReportReturns = struct {
+		result1 linux_backend.RestoreReport
+	}{result1}
+}
+
+func (fake *FakeBackend) Quarantined() ([]linux_backend.QuarantinedSnapshot, error) {
+	fake.quarantinedMutex.Lock()
+	fake.quarantinedArgsForCall = append(fake.quarantinedArgsForCall, struct{}{})
+	fake.quarantinedMutex.Unlock()
+	if fake.QuarantinedStub != nil {
+		return fake.QuarantinedStub()
+	} else {
+		return fake.quarantinedReturns.result1, fake.quarantinedReturns.result2
+	}
+}
+
+func (fake *FakeBackend) QuarantinedCallCount() int {
+	fake.quarantinedMutex.RLock()
+	defer fake.quarantinedMutex.RUnlock()
+	return len(fake.quarantinedArgsForCall)
+}
+
+func (fake *FakeBackend) QuarantinedReturns(result1 []linux_backend.QuarantinedSnapshot, result2 error) {
+	fake.QuarantinedStub = nil
+	fake.quarantinedReturns = struct {
+		result1 []linux_backend.QuarantinedSnapshot
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) RestoreQuarantined(id string) (garden.Container, error) {
+	fake.restoreQuarantinedMutex.Lock()
+	fake.restoreQuarantinedArgsForCall = append(fake.restoreQuarantinedArgsForCall, struct {
+		id string
+	}{id})
+	fake.restoreQuarantinedMutex.Unlock()
+	if fake.RestoreQuarantinedStub != nil {
+		return fake.RestoreQuarantinedStub(id)
+	} else {
+		return fake.restoreQuarantinedReturns.result1, fake.restoreQuarantinedReturns.result2
+	}
+}
+
+func (fake *FakeBackend) RestoreQuarantinedCallCount() int {
+	fake.restoreQuarantinedMutex.RLock()
+	defer fake.restoreQuarantinedMutex.RUnlock()
+	return len(fake.restoreQuarantinedArgsForCall)
+}
+
+func (fake *FakeBackend) RestoreQuarantinedArgsForCall(i int) string {
+	fake.restoreQuarantinedMutex.RLock()
+	defer fake.restoreQuarantinedMutex.RUnlock()
+	return fake.restoreQuarantinedArgsForCall[i].id
+}
+
+func (fake *FakeBackend) RestoreQuarantinedReturns(result1 garden.Container, result2 error) {
+	fake.RestoreQuarantinedStub = nil
+	fake.restoreQuarantinedReturns = struct {
+		result1 garden.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) DiscardQuarantined(id string) error {
+	fake.discardQuarantinedMutex.Lock()
+	fake.discardQuarantinedArgsForCall = append(fake.discardQuarantinedArgsForCall, struct {
+		id string
+	}{id})
+	fake.discardQuarantinedMutex.Unlock()
+	if fake.DiscardQuarantinedStub != nil {
+		return fake.DiscardQuarantinedStub(id)
+	} else {
+		return fake.discardQuarantinedReturns.result1
+	}
+}
+
+func (fake *FakeBackend) DiscardQuarantinedCallCount() int {
+	fake.discardQuarantinedMutex.RLock()
+	defer fake.discardQuarantinedMutex.RUnlock()
+	return len(fake.discardQuarantinedArgsForCall)
+}
+
+func (fake *FakeBackend) DiscardQuarantinedArgsForCall(i int) string {
+	fake.discardQuarantinedMutex.RLock()
+	defer fake.discardQuarantinedMutex.RUnlock()
+	return fake.discardQuarantinedArgsForCall[i].id
+}
+
+func (fake *FakeBackend) DiscardQuarantinedReturns(result1 error) {
+	fake.DiscardQuarantinedStub = nil
+	fake.discardQuarantinedReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) Subscribe() *event_hub.Subscription {
+	fake.subscribeMutex.Lock()
+	fake.subscribeArgsForCall = append(fake.subscribeArgsForCall, struct{}{})
+	fake.subscribeMutex.Unlock()
+	if fake.SubscribeStub != nil {
+		return fake.SubscribeStub()
+	} else {
+		return fake.subscribeReturns.result1
+	}
+}
+
+func (fake *FakeBackend) SubscribeCallCount() int {
+	fake.subscribeMutex.RLock()
+	defer fake.subscribeMutex.RUnlock()
+	return len(fake.subscribeArgsForCall)
+}
+
+func (fake *FakeBackend) SubscribeReturns(result1 *event_hub.Subscription) {
+	fake.SubscribeStub = nil
+	fake.subscribeReturns = struct {
+		result1 *event_hub.Subscription
+	}{result1}
+}
+
+func (fake *FakeBackend) CapacityReport() (capacity.Report, error) {
+	fake.capacityReportMutex.Lock()
+	fake.capacityReportArgsForCall = append(fake.capacityReportArgsForCall, struct{}{})
+	fake.capacityReportMutex.Unlock()
+	if fake.CapacityReportStub != nil {
+		return fake.CapacityReportStub()
+	} else {
+		return fake.capacityReportReturns.result1, fake.capacityReportReturns.result2
+	}
+}
+
+func (fake *FakeBackend) CapacityReportCallCount() int {
+	fake.capacityReportMutex.RLock()
+	defer fake.capacityReportMutex.RUnlock()
+	return len(fake.capacityReportArgsForCall)
+}
+
+func (fake *FakeBackend) CapacityReportReturns(result1 capacity.Report, result2 error) {
+	fake.CapacityReportStub = nil
+	fake.capacityReportReturns = struct {
+		result1 capacity.Report
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) Pause(handle string) error {
+	fake.pauseMutex.Lock()
+	fake.pauseArgsForCall = append(fake.pauseArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.pauseMutex.Unlock()
+	if fake.PauseStub != nil {
+		return fake.PauseStub(handle)
+	} else {
+		return fake.pauseReturns.result1
+	}
+}
+
+func (fake *FakeBackend) PauseCallCount() int {
+	fake.pauseMutex.RLock()
+	defer fake.pauseMutex.RUnlock()
+	return len(fake.pauseArgsForCall)
+}
+
+func (fake *FakeBackend) PauseArgsForCall(i int) string {
+	fake.pauseMutex.RLock()
+	defer fake.pauseMutex.RUnlock()
+	return fake.pauseArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) PauseReturns(result1 error) {
+	fake.PauseStub = nil
+	fake.pauseReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) Resume(handle string) error {
+	fake.resumeMutex.Lock()
+	fake.resumeArgsForCall = append(fake.resumeArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.resumeMutex.Unlock()
+	if fake.ResumeStub != nil {
+		return fake.ResumeStub(handle)
+	} else {
+		return fake.resumeReturns.result1
+	}
+}
+
+func (fake *FakeBackend) ResumeCallCount() int {
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
+	return len(fake.resumeArgsForCall)
+}
+
+func (fake *FakeBackend) ResumeArgsForCall(i int) string {
+	fake.resumeMutex.RLock()
+	defer fake.resumeMutex.RUnlock()
+	return fake.resumeArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) ResumeReturns(result1 error) {
+	fake.ResumeStub = nil
+	fake.resumeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) StopContainer(handle string, gracePeriod time.Duration) error {
+	fake.stopContainerMutex.Lock()
+	fake.stopContainerArgsForCall = append(fake.stopContainerArgsForCall, struct {
+		handle      string
+		gracePeriod time.Duration
+	}{handle, gracePeriod})
+	fake.stopContainerMutex.Unlock()
+	if fake.StopContainerStub != nil {
+		return fake.StopContainerStub(handle, gracePeriod)
+	} else {
+		return fake.stopContainerReturns.result1
+	}
+}
+
+func (fake *FakeBackend) StopContainerCallCount() int {
+	fake.stopContainerMutex.RLock()
+	defer fake.stopContainerMutex.RUnlock()
+	return len(fake.stopContainerArgsForCall)
+}
+
+func (fake *FakeBackend) StopContainerArgsForCall(i int) (string, time.Duration) {
+	fake.stopContainerMutex.RLock()
+	defer fake.stopContainerMutex.RUnlock()
+	return fake.stopContainerArgsForCall[i].handle, fake.stopContainerArgsForCall[i].gracePeriod
+}
+
+func (fake *FakeBackend) StopContainerReturns(result1 error) {
+	fake.StopContainerStub = nil
+	fake.stopContainerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) LimitLinux(handle string, limits linux_backend.LinuxLimits) error {
+	fake.limitLinuxMutex.Lock()
+	fake.limitLinuxArgsForCall = append(fake.limitLinuxArgsForCall, struct {
+		handle string
+		limits linux_backend.LinuxLimits
+	}{handle, limits})
+	fake.limitLinuxMutex.Unlock()
+	if fake.LimitLinuxStub != nil {
+		return fake.LimitLinuxStub(handle, limits)
+	} else {
+		return fake.limitLinuxReturns.result1
+	}
+}
+
+func (fake *FakeBackend) LimitLinuxCallCount() int {
+	fake.limitLinuxMutex.RLock()
+	defer fake.limitLinuxMutex.RUnlock()
+	return len(fake.limitLinuxArgsForCall)
+}
+
+func (fake *FakeBackend) LimitLinuxArgsForCall(i int) (string, linux_backend.LinuxLimits) {
+	fake.limitLinuxMutex.RLock()
+	defer fake.limitLinuxMutex.RUnlock()
+	return fake.limitLinuxArgsForCall[i].handle, fake.limitLinuxArgsForCall[i].limits
+}
+
+func (fake *FakeBackend) LimitLinuxReturns(result1 error) {
+	fake.LimitLinuxStub = nil
+	fake.limitLinuxReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) CurrentLinuxLimits(handle string) (linux_backend.LinuxLimits, error) {
+	fake.currentLinuxLimitsMutex.Lock()
+	fake.currentLinuxLimitsArgsForCall = append(fake.currentLinuxLimitsArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.currentLinuxLimitsMutex.Unlock()
+	if fake.CurrentLinuxLimitsStub != nil {
+		return fake.CurrentLinuxLimitsStub(handle)
+	} else {
+		return fake.currentLinuxLimitsReturns.result1, fake.currentLinuxLimitsReturns.result2
+	}
+}
+
+func (fake *FakeBackend) CurrentLinuxLimitsCallCount() int {
+	fake.currentLinuxLimitsMutex.RLock()
+	defer fake.currentLinuxLimitsMutex.RUnlock()
+	return len(fake.currentLinuxLimitsArgsForCall)
+}
+
+func (fake *FakeBackend) CurrentLinuxLimitsArgsForCall(i int) string {
+	fake.currentLinuxLimitsMutex.RLock()
+	defer fake.currentLinuxLimitsMutex.RUnlock()
+	return fake.currentLinuxLimitsArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) CurrentLinuxLimitsReturns(result1 linux_backend.LinuxLimits, result2 error) {
+	fake.CurrentLinuxLimitsStub = nil
+	fake.currentLinuxLimitsReturns = struct {
+		result1 linux_backend.LinuxLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) LinuxMetrics(handle string) (linux_backend.LinuxMetrics, error) {
+	fake.linuxMetricsMutex.Lock()
+	fake.linuxMetricsArgsForCall = append(fake.linuxMetricsArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.linuxMetricsMutex.Unlock()
+	if fake.LinuxMetricsStub != nil {
+		return fake.LinuxMetricsStub(handle)
+	} else {
+		return fake.linuxMetricsReturns.result1, fake.linuxMetricsReturns.result2
+	}
+}
+
+func (fake *FakeBackend) LinuxMetricsCallCount() int {
+	fake.linuxMetricsMutex.RLock()
+	defer fake.linuxMetricsMutex.RUnlock()
+	return len(fake.linuxMetricsArgsForCall)
+}
+
+func (fake *FakeBackend) LinuxMetricsArgsForCall(i int) string {
+	fake.linuxMetricsMutex.RLock()
+	defer fake.linuxMetricsMutex.RUnlock()
+	return fake.linuxMetricsArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) LinuxMetricsReturns(result1 linux_backend.LinuxMetrics, result2 error) {
+	fake.LinuxMetricsStub = nil
+	fake.linuxMetricsReturns = struct {
+		result1 linux_backend.LinuxMetrics
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) MetricsHistory(handle string) (linux_backend.MetricsHistory, error) {
+	fake.metricsHistoryMutex.Lock()
+	fake.metricsHistoryArgsForCall = append(fake.metricsHistoryArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.metricsHistoryMutex.Unlock()
+	if fake.MetricsHistoryStub != nil {
+		return fake.MetricsHistoryStub(handle)
+	} else {
+		return fake.metricsHistoryReturns.result1, fake.metricsHistoryReturns.result2
+	}
+}
+
+func (fake *FakeBackend) MetricsHistoryCallCount() int {
+	fake.metricsHistoryMutex.RLock()
+	defer fake.metricsHistoryMutex.RUnlock()
+	return len(fake.metricsHistoryArgsForCall)
+}
+
+func (fake *FakeBackend) MetricsHistoryArgsForCall(i int) string {
+	fake.metricsHistoryMutex.RLock()
+	defer fake.metricsHistoryMutex.RUnlock()
+	return fake.metricsHistoryArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) MetricsHistoryReturns(result1 linux_backend.MetricsHistory, result2 error) {
+	fake.MetricsHistoryStub = nil
+	fake.metricsHistoryReturns = struct {
+		result1 linux_backend.MetricsHistory
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) OOMRecords(handle string) ([]linux_backend.OOMRecord, error) {
+	fake.oOMRecordsMutex.Lock()
+	fake.oOMRecordsArgsForCall = append(fake.oOMRecordsArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.oOMRecordsMutex.Unlock()
+	if fake.OOMRecordsStub != nil {
+		return fake.OOMRecordsStub(handle)
+	} else {
+		return fake.oOMRecordsReturns.result1, fake.oOMRecordsReturns.result2
+	}
+}
+
+func (fake *FakeBackend) OOMRecordsCallCount() int {
+	fake.oOMRecordsMutex.RLock()
+	defer fake.oOMRecordsMutex.RUnlock()
+	return len(fake.oOMRecordsArgsForCall)
+}
+
+func (fake *FakeBackend) OOMRecordsArgsForCall(i int) string {
+	fake.oOMRecordsMutex.RLock()
+	defer fake.oOMRecordsMutex.RUnlock()
+	return fake.oOMRecordsArgsForCall[i].handle
+}
+
+func (fake *FakeBackend) OOMRecordsReturns(result1 []linux_backend.OOMRecord, result2 error) {
+	fake.OOMRecordsStub = nil
+	fake.oOMRecordsReturns = struct {
+		result1 []linux_backend.OOMRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) SignalProcess(handle string, processID uint32, signal garden.Signal, scope linux_backend.SignalScope) error {
+	fake.signalProcessMutex.Lock()
+	fake.signalProcessArgsForCall = append(fake.signalProcessArgsForCall, struct {
+		handle    string
+		processID uint32
+		signal    garden.Signal
+		scope     linux_backend.SignalScope
+	}{handle, processID, signal, scope})
+	fake.signalProcessMutex.Unlock()
+	if fake.SignalProcessStub != nil {
+		return fake.SignalProcessStub(handle, processID, signal, scope)
+	} else {
+		return fake.signalProcessReturns.result1
+	}
+}
+
+func (fake *FakeBackend) SignalProcessCallCount() int {
+	fake.signalProcessMutex.RLock()
+	defer fake.signalProcessMutex.RUnlock()
+	return len(fake.signalProcessArgsForCall)
+}
+
+func (fake *FakeBackend) SignalProcessArgsForCall(i int) (string, uint32, garden.Signal, linux_backend.SignalScope) {
+	fake.signalProcessMutex.RLock()
+	defer fake.signalProcessMutex.RUnlock()
+	return fake.signalProcessArgsForCall[i].handle, fake.signalProcessArgsForCall[i].processID, fake.signalProcessArgsForCall[i].signal, fake.signalProcessArgsForCall[i].scope
+}
+
+func (fake *FakeBackend) SignalProcessReturns(result1 error) {
+	fake.SignalProcessStub = nil
+	fake.signalProcessReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ admin.Backend = new(FakeBackend)
@@ -0,0 +1,78 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	"github.com/cloudfoundry-incubator/garden-linux/drift"
+)
+
+type FakeDriftAuditor struct {
+	AuditStub        func() (drift.Report, error)
+	auditMutex       sync.RWMutex
+	auditArgsForCall []struct{}
+	auditReturns     struct {
+		result1 drift.Report
+		result2 error
+	}
+	RepairStub        func() (drift.RepairReport, error)
+	repairMutex       sync.RWMutex
+	repairArgsForCall []struct{}
+	repairReturns     struct {
+		result1 drift.RepairReport
+		result2 error
+	}
+}
+
+func (fake *FakeDriftAuditor) Audit() (drift.Report, error) {
+	fake.auditMutex.Lock()
+	fake.auditArgsForCall = append(fake.auditArgsForCall, struct{}{})
+	fake.auditMutex.Unlock()
+	if fake.AuditStub != nil {
+		return fake.AuditStub()
+	} else {
+		return fake.auditReturns.result1, fake.auditReturns.result2
+	}
+}
+
+func (fake *FakeDriftAuditor) AuditCallCount() int {
+	fake.auditMutex.RLock()
+	defer fake.auditMutex.RUnlock()
+	return len(fake.auditArgsForCall)
+}
+
+func (fake *FakeDriftAuditor) AuditReturns(result1 drift.Report, result2 error) {
+	fake.AuditStub = nil
+	fake.auditReturns = struct {
+		result1 drift.Report
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeDriftAuditor) Repair() (drift.RepairReport, error) {
+	fake.repairMutex.Lock()
+	fake.repairArgsForCall = append(fake.repairArgsForCall, struct{}{})
+	fake.repairMutex.Unlock()
+	if fake.RepairStub != nil {
+		return fake.RepairStub()
+	} else {
+		return fake.repairReturns.result1, fake.repairReturns.result2
+	}
+}
+
+func (fake *FakeDriftAuditor) RepairCallCount() int {
+	fake.repairMutex.RLock()
+	defer fake.repairMutex.RUnlock()
+	return len(fake.repairArgsForCall)
+}
+
+func (fake *FakeDriftAuditor) RepairReturns(result1 drift.RepairReport, result2 error) {
+	fake.RepairStub = nil
+	fake.repairReturns = struct {
+		result1 drift.RepairReport
+		result2 error
+	}{result1, result2}
+}
+
+var _ admin.DriftAuditor = new(FakeDriftAuditor)
@@ -0,0 +1,468 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/drift"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/rata"
+)
+
+//go:generate counterfeiter -o fakes/fake_backend.go . Backend
+type Backend interface {
+	RestoreReport() linux_backend.RestoreReport
+
+	Quarantined() ([]linux_backend.QuarantinedSnapshot, error)
+	RestoreQuarantined(id string) (garden.Container, error)
+	DiscardQuarantined(id string) error
+
+	Subscribe() *event_hub.Subscription
+
+	CapacityReport() (capacity.Report, error)
+
+	Pause(handle string) error
+	Resume(handle string) error
+	StopContainer(handle string, gracePeriod time.Duration) error
+
+	LimitLinux(handle string, limits linux_backend.LinuxLimits) error
+	CurrentLinuxLimits(handle string) (linux_backend.LinuxLimits, error)
+	LinuxMetrics(handle string) (linux_backend.LinuxMetrics, error)
+	MetricsHistory(handle string) (linux_backend.MetricsHistory, error)
+	OOMRecords(handle string) ([]linux_backend.OOMRecord, error)
+
+	SignalProcess(handle string, processID uint32, signal garden.Signal, scope linux_backend.SignalScope) error
+}
+
+// StopRequest is the body of a StopContainer request. GracePeriodInSeconds
+// is how long the container's processes are given to exit after being sent
+// TERM before they are killed; 0 kills them straight away. It must be given.
+type StopRequest struct {
+	GracePeriodInSeconds *uint64
+}
+
+// SignalRequest is the body of a SignalProcess request. Signal is a name,
+// such as "HUP" or "SIGUSR1", or a number. Scope says which other processes
+// the signal is sent to; if it is empty, the signal is sent to the process
+// alone.
+type SignalRequest struct {
+	Signal string
+	Scope  linux_backend.SignalScope
+}
+
+//go:generate counterfeiter -o fakes/fake_drift_auditor.go . DriftAuditor
+type DriftAuditor interface {
+	Audit() (drift.Report, error)
+	Repair() (drift.RepairReport, error)
+}
+
+type handler struct {
+	logger  lager.Logger
+	backend Backend
+	auditor DriftAuditor
+}
+
+// New returns a handler exposing operator-facing backend state, such as the
+// outcome of restoring containers on start, for use alongside the debug
+// server.
+func New(logger lager.Logger, backend Backend, auditor DriftAuditor) (http.Handler, error) {
+	h := &handler{
+		logger:  logger.Session("admin"),
+		backend: backend,
+		auditor: auditor,
+	}
+
+	return rata.NewRouter(Routes, rata.Handlers{
+		RestoreReport: http.HandlerFunc(h.restoreReport),
+
+		ListQuarantined:    http.HandlerFunc(h.listQuarantined),
+		RestoreQuarantined: http.HandlerFunc(h.restoreQuarantined),
+		DiscardQuarantined: http.HandlerFunc(h.discardQuarantined),
+
+		StreamEvents: http.HandlerFunc(h.streamEvents),
+
+		AuditDrift:  http.HandlerFunc(h.auditDrift),
+		RepairDrift: http.HandlerFunc(h.repairDrift),
+
+		CommittedCapacity: http.HandlerFunc(h.committedCapacity),
+
+		PauseContainer:  http.HandlerFunc(h.pauseContainer),
+		ResumeContainer: http.HandlerFunc(h.resumeContainer),
+		StopContainer:   http.HandlerFunc(h.stopContainer),
+
+		ContainerLimits:  http.HandlerFunc(h.containerLimits),
+		LimitContainer:   http.HandlerFunc(h.limitContainer),
+		ContainerMetrics: http.HandlerFunc(h.containerMetrics),
+		MetricsHistory:   http.HandlerFunc(h.metricsHistory),
+		ContainerOOMs:    http.HandlerFunc(h.containerOOMs),
+
+		SignalProcess: http.HandlerFunc(h.signalProcess),
+	})
+}
+
+func (h *handler) restoreReport(w http.ResponseWriter, r *http.Request) {
+	h.writeResponse(w, h.backend.RestoreReport())
+}
+
+func (h *handler) listQuarantined(w http.ResponseWriter, r *http.Request) {
+	hLog := h.logger.Session("list-quarantined")
+
+	quarantined, err := h.backend.Quarantined()
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, quarantined)
+}
+
+func (h *handler) restoreQuarantined(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue(":id")
+
+	hLog := h.logger.Session("restore-quarantined", lager.Data{
+		"id": id,
+	})
+
+	container, err := h.backend.RestoreQuarantined(id)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, map[string]string{
+		"handle": container.Handle(),
+	})
+}
+
+func (h *handler) discardQuarantined(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue(":id")
+
+	hLog := h.logger.Session("discard-quarantined", lager.Data{
+		"id": id,
+	})
+
+	err := h.backend.DiscardQuarantined(id)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, struct{}{})
+}
+
+// streamEvents writes each container event as a line of JSON until the
+// client goes away. The stream ends early if the client falls too far
+// behind, in which case it should reconnect and resynchronize.
+func (h *handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	hLog := h.logger.Session("stream-events")
+
+	subscription := h.backend.Subscribe()
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	encoder := json.NewEncoder(w)
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				hLog.Error("subscription-closed", subscription.Err())
+				return
+			}
+
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+
+			if canFlush {
+				flusher.Flush()
+			}
+
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (h *handler) auditDrift(w http.ResponseWriter, r *http.Request) {
+	hLog := h.logger.Session("audit-drift")
+
+	report, err := h.auditor.Audit()
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, report)
+}
+
+func (h *handler) repairDrift(w http.ResponseWriter, r *http.Request) {
+	hLog := h.logger.Session("repair-drift")
+
+	report, err := h.auditor.Repair()
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, report)
+}
+
+func (h *handler) committedCapacity(w http.ResponseWriter, r *http.Request) {
+	hLog := h.logger.Session("committed-capacity")
+
+	report, err := h.backend.CapacityReport()
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, report)
+}
+
+func (h *handler) pauseContainer(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("pause-container", lager.Data{
+		"handle": handle,
+	})
+
+	err := h.backend.Pause(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, struct{}{})
+}
+
+func (h *handler) resumeContainer(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("resume-container", lager.Data{
+		"handle": handle,
+	})
+
+	err := h.backend.Resume(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, struct{}{})
+}
+
+func (h *handler) stopContainer(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("stop-container", lager.Data{
+		"handle": handle,
+	})
+
+	var request StopRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		h.writeBadRequest(w, err, hLog)
+		return
+	}
+
+	if request.GracePeriodInSeconds == nil {
+		h.writeBadRequest(w, errors.New("GracePeriodInSeconds must be given"), hLog)
+		return
+	}
+
+	err = h.backend.StopContainer(handle, time.Duration(*request.GracePeriodInSeconds)*time.Second)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, struct{}{})
+}
+
+func (h *handler) containerLimits(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("container-limits", lager.Data{
+		"handle": handle,
+	})
+
+	limits, err := h.backend.CurrentLinuxLimits(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, limits)
+}
+
+// limitContainer applies the limits given in the body, leaving any it omits
+// as they are.
+func (h *handler) limitContainer(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("limit-container", lager.Data{
+		"handle": handle,
+	})
+
+	var limits linux_backend.LinuxLimits
+	err := json.NewDecoder(r.Body).Decode(&limits)
+	if err != nil {
+		h.writeBadRequest(w, err, hLog)
+		return
+	}
+
+	err = h.backend.LimitLinux(handle, limits)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	limits, err = h.backend.CurrentLinuxLimits(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, limits)
+}
+
+func (h *handler) containerMetrics(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("container-metrics", lager.Data{
+		"handle": handle,
+	})
+
+	metrics, err := h.backend.LinuxMetrics(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, metrics)
+}
+
+func (h *handler) metricsHistory(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("metrics-history", lager.Data{
+		"handle": handle,
+	})
+
+	history, err := h.backend.MetricsHistory(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, history)
+}
+
+func (h *handler) containerOOMs(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+
+	hLog := h.logger.Session("container-ooms", lager.Data{
+		"handle": handle,
+	})
+
+	records, err := h.backend.OOMRecords(handle)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, records)
+}
+
+func (h *handler) signalProcess(w http.ResponseWriter, r *http.Request) {
+	handle := r.FormValue(":handle")
+	id := r.FormValue(":id")
+
+	hLog := h.logger.Session("signal-process", lager.Data{
+		"handle":  handle,
+		"process": id,
+	})
+
+	processID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		h.writeBadRequest(w, err, hLog)
+		return
+	}
+
+	var request SignalRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		h.writeBadRequest(w, err, hLog)
+		return
+	}
+
+	signal, err := process_tracker.ParseSignal(request.Signal)
+	if err != nil {
+		h.writeBadRequest(w, err, hLog)
+		return
+	}
+
+	err = h.backend.SignalProcess(handle, uint32(processID), signal, request.Scope)
+	if err != nil {
+		h.writeError(w, err, hLog)
+		return
+	}
+
+	h.writeResponse(w, struct{}{})
+}
+
+func (h *handler) writeBadRequest(w http.ResponseWriter, err error, logger lager.Logger) {
+	logger.Error("bad-request", err)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(err.Error()))
+}
+
+func (h *handler) writeError(w http.ResponseWriter, err error, logger lager.Logger) {
+	logger.Error("failed", err)
+
+	statusCode := http.StatusInternalServerError
+	switch err.(type) {
+	case linux_backend.UnknownSignalScopeError:
+		statusCode = http.StatusBadRequest
+	case linux_backend.SnapshotNotQuarantinedError, garden.ContainerNotFoundError,
+		linux_backend.NoMetricsSamplesError, process_tracker.UnknownProcessError:
+		statusCode = http.StatusNotFound
+	case linux_container.InvalidStateTransitionError, linux_backend.PauseNotSupportedError,
+		linux_backend.LinuxExtensionsNotSupportedError, linux_backend.MetricsSamplingDisabledError,
+		linux_backend.CreatesInFlightError:
+		statusCode = http.StatusConflict
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(err.Error()))
+}
+
+func (h *handler) writeResponse(w http.ResponseWriter, msg interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
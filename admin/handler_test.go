@@ -0,0 +1,721 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	fake_admin "github.com/cloudfoundry-incubator/garden-linux/admin/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/drift"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/cloudfoundry-incubator/garden/fakes"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/rata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var fakeBackend *fake_admin.FakeBackend
+	var fakeAuditor *fake_admin.FakeDriftAuditor
+	var handler http.Handler
+	var recorder *httptest.ResponseRecorder
+
+	BeforeEach(func() {
+		fakeBackend = new(fake_admin.FakeBackend)
+		fakeAuditor = new(fake_admin.FakeDriftAuditor)
+
+		var err error
+		handler, err = admin.New(lagertest.NewTestLogger("test"), fakeBackend, fakeAuditor)
+		Expect(err).ToNot(HaveOccurred())
+
+		recorder = httptest.NewRecorder()
+	})
+
+	request := func(name string, params rata.Params) {
+		req, err := rata.NewRequestGenerator("", admin.Routes).CreateRequest(name, params, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		handler.ServeHTTP(recorder, req)
+	}
+
+	Describe("getting the restore report", func() {
+		It("returns the backend's report", func() {
+			fakeBackend.RestoreReportReturns(linux_backend.RestoreReport{
+				Restored: []string{"some-id"},
+				Failed:   []linux_backend.QuarantinedSnapshot{{ID: "some-other-id", Error: "oh no"}},
+				Pruned:   []string{"some-pruned-id"},
+			})
+
+			request(admin.RestoreReport, nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var report linux_backend.RestoreReport
+			err := json.NewDecoder(recorder.Body).Decode(&report)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Restored).To(Equal([]string{"some-id"}))
+			Expect(report.Failed).To(HaveLen(1))
+			Expect(report.Failed[0].ID).To(Equal("some-other-id"))
+			Expect(report.Failed[0].Error).To(Equal("oh no"))
+			Expect(report.Pruned).To(Equal([]string{"some-pruned-id"}))
+		})
+	})
+
+	Describe("listing quarantined snapshots", func() {
+		It("returns them", func() {
+			quarantinedAt := time.Unix(123, 0).UTC()
+
+			fakeBackend.QuarantinedReturns([]linux_backend.QuarantinedSnapshot{
+				{ID: "some-id", Error: "oh no", QuarantinedAt: quarantinedAt},
+			}, nil)
+
+			request(admin.ListQuarantined, nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var quarantined []linux_backend.QuarantinedSnapshot
+			err := json.NewDecoder(recorder.Body).Decode(&quarantined)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(quarantined).To(Equal([]linux_backend.QuarantinedSnapshot{
+				{ID: "some-id", Error: "oh no", QuarantinedAt: quarantinedAt},
+			}))
+		})
+
+		Context("when listing fails", func() {
+			It("returns 500 with the error", func() {
+				fakeBackend.QuarantinedReturns(nil, errors.New("oh no"))
+
+				request(admin.ListQuarantined, nil)
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(Equal("oh no"))
+			})
+		})
+	})
+
+	Describe("restoring a quarantined snapshot", func() {
+		It("restores it and returns the container's handle", func() {
+			container := new(fakes.FakeContainer)
+			container.HandleReturns("some-handle")
+
+			fakeBackend.RestoreQuarantinedReturns(container, nil)
+
+			request(admin.RestoreQuarantined, rata.Params{"id": "some-id"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.RestoreQuarantinedCallCount()).To(Equal(1))
+			Expect(fakeBackend.RestoreQuarantinedArgsForCall(0)).To(Equal("some-id"))
+
+			var response map[string]string
+			err := json.NewDecoder(recorder.Body).Decode(&response)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response).To(Equal(map[string]string{"handle": "some-handle"}))
+		})
+
+		Context("when the snapshot is not quarantined", func() {
+			It("returns 404", func() {
+				fakeBackend.RestoreQuarantinedReturns(nil, linux_backend.SnapshotNotQuarantinedError{ID: "some-id"})
+
+				request(admin.RestoreQuarantined, rata.Params{"id": "some-id"})
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when restoring fails", func() {
+			It("returns 500 with the error", func() {
+				fakeBackend.RestoreQuarantinedReturns(nil, errors.New("oh no"))
+
+				request(admin.RestoreQuarantined, rata.Params{"id": "some-id"})
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(Equal("oh no"))
+			})
+		})
+	})
+
+	Describe("discarding a quarantined snapshot", func() {
+		It("discards it", func() {
+			request(admin.DiscardQuarantined, rata.Params{"id": "some-id"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.DiscardQuarantinedCallCount()).To(Equal(1))
+			Expect(fakeBackend.DiscardQuarantinedArgsForCall(0)).To(Equal("some-id"))
+		})
+
+		Context("when the snapshot is not quarantined", func() {
+			It("returns 404", func() {
+				fakeBackend.DiscardQuarantinedReturns(linux_backend.SnapshotNotQuarantinedError{ID: "some-id"})
+
+				request(admin.DiscardQuarantined, rata.Params{"id": "some-id"})
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("streaming events", func() {
+		var hub *event_hub.Hub
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			hub = event_hub.New(lagertest.NewTestLogger("test"), 10)
+			fakeBackend.SubscribeStub = hub.Subscribe
+
+			server = httptest.NewServer(handler)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("streams each event as a line of JSON", func() {
+			response, err := http.Get(server.URL + "/events")
+			Expect(err).ToNot(HaveOccurred())
+			defer response.Body.Close()
+
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+			Expect(fakeBackend.SubscribeCallCount()).To(Equal(1))
+
+			someTime := time.Unix(123, 0).UTC()
+
+			hub.Emit(event_hub.Event{Type: event_hub.Created, Handle: "some-handle", Time: someTime})
+			hub.Emit(event_hub.Event{
+				Type:   event_hub.ProcessExited,
+				Handle: "some-handle",
+				Time:   someTime,
+				Data:   map[string]string{"exit_status": "0"},
+			})
+
+			decoder := json.NewDecoder(response.Body)
+
+			var event event_hub.Event
+			Expect(decoder.Decode(&event)).To(Succeed())
+			Expect(event).To(Equal(event_hub.Event{Type: event_hub.Created, Handle: "some-handle", Time: someTime}))
+
+			Expect(decoder.Decode(&event)).To(Succeed())
+			Expect(event.Type).To(Equal(event_hub.ProcessExited))
+			Expect(event.Data).To(Equal(map[string]string{"exit_status": "0"}))
+		})
+
+		Context("when the subscription is closed", func() {
+			It("ends the stream", func() {
+				subscription := hub.Subscribe()
+				fakeBackend.SubscribeReturns(subscription)
+				fakeBackend.SubscribeStub = nil
+
+				response, err := http.Get(server.URL + "/events")
+				Expect(err).ToNot(HaveOccurred())
+				defer response.Body.Close()
+
+				subscription.Close()
+
+				_, err = ioutil.ReadAll(response.Body)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("auditing drift", func() {
+		It("returns the mismatches between the repository and the host", func() {
+			fakeAuditor.AuditReturns(drift.Report{
+				Mismatches: []drift.Mismatch{
+					{Kind: drift.Bridge, Name: "w0b-abc", Problem: drift.Orphaned},
+				},
+			}, nil)
+
+			request(admin.AuditDrift, nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var report drift.Report
+			err := json.NewDecoder(recorder.Body).Decode(&report)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Mismatches).To(Equal([]drift.Mismatch{
+				{Kind: drift.Bridge, Name: "w0b-abc", Problem: drift.Orphaned},
+			}))
+		})
+
+		Context("when auditing fails", func() {
+			It("returns 500 with the error", func() {
+				fakeAuditor.AuditReturns(drift.Report{}, errors.New("oh no"))
+
+				request(admin.AuditDrift, nil)
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(Equal("oh no"))
+			})
+		})
+	})
+
+	Describe("repairing drift", func() {
+		It("repairs it and returns what was done", func() {
+			fakeAuditor.RepairReturns(drift.RepairReport{
+				Repaired: []drift.Mismatch{
+					{Kind: drift.Cgroup, Name: "/cgroup/memory/instance-abc", ContainerID: "abc", Problem: drift.Orphaned},
+				},
+			}, nil)
+
+			request(admin.RepairDrift, nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(fakeAuditor.RepairCallCount()).To(Equal(1))
+
+			var report drift.RepairReport
+			err := json.NewDecoder(recorder.Body).Decode(&report)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Repaired).To(HaveLen(1))
+			Expect(report.Repaired[0].ContainerID).To(Equal("abc"))
+		})
+
+		Context("when repairing fails", func() {
+			It("returns 500 with the error", func() {
+				fakeAuditor.RepairReturns(drift.RepairReport{}, errors.New("oh no"))
+
+				request(admin.RepairDrift, nil)
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		Context("when containers are being created", func() {
+			It("returns 409", func() {
+				fakeAuditor.RepairReturns(drift.RepairReport{}, linux_backend.CreatesInFlightError{Count: 2})
+
+				request(admin.RepairDrift, nil)
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+	Describe("pausing a container", func() {
+		It("pauses it", func() {
+			request(admin.PauseContainer, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.PauseCallCount()).To(Equal(1))
+			Expect(fakeBackend.PauseArgsForCall(0)).To(Equal("some-handle"))
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns 404", func() {
+				fakeBackend.PauseReturns(garden.ContainerNotFoundError{Handle: "some-handle"})
+
+				request(admin.PauseContainer, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when the container is not in a state it can be paused from", func() {
+			It("returns 409", func() {
+				fakeBackend.PauseReturns(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateStopped,
+					To:     linux_container.StatePaused,
+				})
+
+				request(admin.PauseContainer, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+				Expect(recorder.Body.String()).To(Equal("container some-handle cannot become paused while stopped"))
+			})
+		})
+	})
+
+	Describe("resuming a container", func() {
+		It("resumes it", func() {
+			request(admin.ResumeContainer, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.ResumeCallCount()).To(Equal(1))
+			Expect(fakeBackend.ResumeArgsForCall(0)).To(Equal("some-handle"))
+		})
+
+		Context("when resuming fails", func() {
+			It("returns 500 with the error", func() {
+				fakeBackend.ResumeReturns(errors.New("oh no"))
+
+				request(admin.ResumeContainer, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+
+	Describe("stopping a container", func() {
+		stop := func(body string) {
+			req, err := rata.NewRequestGenerator("", admin.Routes).CreateRequest(
+				admin.StopContainer,
+				rata.Params{"handle": "some-handle"},
+				strings.NewReader(body),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			handler.ServeHTTP(recorder, req)
+		}
+
+		It("stops it with the grace period in the body", func() {
+			stop(`{"GracePeriodInSeconds":30}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.StopContainerCallCount()).To(Equal(1))
+
+			handle, gracePeriod := fakeBackend.StopContainerArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(gracePeriod).To(Equal(30 * time.Second))
+		})
+
+		It("kills it straight away with a grace period of 0", func() {
+			stop(`{"GracePeriodInSeconds":0}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			_, gracePeriod := fakeBackend.StopContainerArgsForCall(0)
+			Expect(gracePeriod).To(BeZero())
+		})
+
+		Context("when no grace period is given", func() {
+			It("returns 400 without stopping anything", func() {
+				stop(`{}`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.StopContainerCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the body is not valid JSON", func() {
+			It("returns 400 without stopping anything", func() {
+				stop(`{`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.StopContainerCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the container is not in a state it can be stopped from", func() {
+			It("returns 409", func() {
+				fakeBackend.StopContainerReturns(linux_container.InvalidStateTransitionError{
+					From: linux_container.StatePaused,
+					To:   linux_container.StateStopping,
+				})
+
+				stop(`{"GracePeriodInSeconds":30}`)
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+
+	Describe("getting a container's linux limits", func() {
+		It("returns the limits currently in effect", func() {
+			fakeBackend.CurrentLinuxLimitsReturns(linux_backend.LinuxLimits{
+				CPUQuota: &linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds:  50000,
+					PeriodInMicroseconds: 100000,
+				},
+			}, nil)
+
+			request(admin.ContainerLimits, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.CurrentLinuxLimitsArgsForCall(0)).To(Equal("some-handle"))
+
+			var limits linux_backend.LinuxLimits
+			err := json.NewDecoder(recorder.Body).Decode(&limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(limits.CPUQuota).To(Equal(&linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 100000,
+			}))
+		})
+
+		Context("when the container does not support linux limits", func() {
+			It("returns 409", func() {
+				fakeBackend.CurrentLinuxLimitsReturns(linux_backend.LinuxLimits{}, linux_backend.LinuxExtensionsNotSupportedError{Handle: "some-handle"})
+
+				request(admin.ContainerLimits, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+
+	Describe("limiting a container", func() {
+		limit := func(body string) {
+			req, err := rata.NewRequestGenerator("", admin.Routes).CreateRequest(
+				admin.LimitContainer,
+				rata.Params{"handle": "some-handle"},
+				strings.NewReader(body),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			handler.ServeHTTP(recorder, req)
+		}
+
+		It("applies the limits in the body and returns those in effect", func() {
+			fakeBackend.CurrentLinuxLimitsReturns(linux_backend.LinuxLimits{
+				CPUQuota: &linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds:  50000,
+					PeriodInMicroseconds: 100000,
+				},
+			}, nil)
+
+			limit(`{"CPUQuota":{"QuotaInMicroseconds":50000}}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.LimitLinuxCallCount()).To(Equal(1))
+
+			handle, limits := fakeBackend.LimitLinuxArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(limits.CPUQuota).To(Equal(&linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds: 50000,
+			}))
+
+			var current linux_backend.LinuxLimits
+			err := json.NewDecoder(recorder.Body).Decode(&current)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current.CPUQuota.PeriodInMicroseconds).To(Equal(uint64(100000)))
+		})
+
+		Context("when the body is not valid JSON", func() {
+			It("returns 400 without applying anything", func() {
+				limit(`{`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.LimitLinuxCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when limiting fails", func() {
+			It("returns 500 with the error", func() {
+				fakeBackend.LimitLinuxReturns(errors.New("oh no"))
+
+				limit(`{}`)
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(Equal("oh no"))
+			})
+		})
+	})
+
+	Describe("getting a container's linux metrics", func() {
+		It("returns them", func() {
+			fakeBackend.LinuxMetricsReturns(linux_backend.LinuxMetrics{
+				Metrics: garden.Metrics{
+					CPUStat: garden.ContainerCPUStat{Usage: 42},
+				},
+				CPUThrottlingStat: linux_backend.CPUThrottlingStat{
+					Periods:          10,
+					ThrottledPeriods: 3,
+					ThrottledTime:    4500,
+				},
+			}, nil)
+
+			request(admin.ContainerMetrics, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.LinuxMetricsArgsForCall(0)).To(Equal("some-handle"))
+
+			var metrics linux_backend.LinuxMetrics
+			err := json.NewDecoder(recorder.Body).Decode(&metrics)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(metrics.CPUStat.Usage).To(Equal(uint64(42)))
+			Expect(metrics.CPUThrottlingStat.ThrottledPeriods).To(Equal(uint64(3)))
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns 404", func() {
+				fakeBackend.LinuxMetricsReturns(linux_backend.LinuxMetrics{}, garden.ContainerNotFoundError{Handle: "some-handle"})
+
+				request(admin.ContainerMetrics, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("getting a container's metrics history", func() {
+		It("returns the sampled history", func() {
+			fakeBackend.MetricsHistoryReturns(linux_backend.MetricsHistory{
+				Handle: "some-handle",
+				Rates: linux_backend.MetricsRates{
+					CPUPercent: 12.5,
+				},
+				History: []linux_backend.MetricsPoint{
+					{MetricsRates: linux_backend.MetricsRates{CPUPercent: 12.5}},
+				},
+			}, nil)
+
+			request(admin.MetricsHistory, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.MetricsHistoryArgsForCall(0)).To(Equal("some-handle"))
+
+			var history linux_backend.MetricsHistory
+			err := json.NewDecoder(recorder.Body).Decode(&history)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(history.Rates.CPUPercent).To(Equal(12.5))
+			Expect(history.History).To(HaveLen(1))
+		})
+
+		Context("when nothing has been sampled for the container yet", func() {
+			It("returns 404", func() {
+				fakeBackend.MetricsHistoryReturns(linux_backend.MetricsHistory{}, linux_backend.NoMetricsSamplesError{Handle: "some-handle"})
+
+				request(admin.MetricsHistory, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when sampling is disabled", func() {
+			It("returns 409", func() {
+				fakeBackend.MetricsHistoryReturns(linux_backend.MetricsHistory{}, linux_backend.MetricsSamplingDisabledError{})
+
+				request(admin.MetricsHistory, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+
+	Describe("getting a container's OOM records", func() {
+		It("returns them", func() {
+			fakeBackend.OOMRecordsReturns([]linux_backend.OOMRecord{
+				{Policy: linux_backend.OOMPolicyRecord, MemoryLimitInBytes: 1024},
+			}, nil)
+
+			request(admin.ContainerOOMs, rata.Params{"handle": "some-handle"})
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.OOMRecordsArgsForCall(0)).To(Equal("some-handle"))
+
+			var records []linux_backend.OOMRecord
+			err := json.NewDecoder(recorder.Body).Decode(&records)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Policy).To(Equal(linux_backend.OOMPolicyRecord))
+			Expect(records[0].MemoryLimitInBytes).To(Equal(uint64(1024)))
+		})
+
+		Context("when the container does not support them", func() {
+			It("returns 409", func() {
+				fakeBackend.OOMRecordsReturns(nil, linux_backend.LinuxExtensionsNotSupportedError{Handle: "some-handle"})
+
+				request(admin.ContainerOOMs, rata.Params{"handle": "some-handle"})
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			})
+		})
+	})
+
+	Describe("signalling a process", func() {
+		signal := func(id string, body string) {
+			req, err := rata.NewRequestGenerator("", admin.Routes).CreateRequest(
+				admin.SignalProcess,
+				rata.Params{"handle": "some-handle", "id": id},
+				strings.NewReader(body),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			handler.ServeHTTP(recorder, req)
+		}
+
+		It("sends the named signal to the process", func() {
+			signal("42", `{"Signal":"SIGHUP"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeBackend.SignalProcessCallCount()).To(Equal(1))
+
+			handle, processID, sent, scope := fakeBackend.SignalProcessArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(processID).To(Equal(uint32(42)))
+			Expect(sent).To(Equal(process_tracker.SignalHangup))
+			Expect(scope).To(BeEmpty())
+		})
+
+		It("sends a signal given by number to the processes in the scope", func() {
+			signal("42", `{"Signal":"10","Scope":"tree"}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			_, _, sent, scope := fakeBackend.SignalProcessArgsForCall(0)
+			Expect(sent).To(Equal(process_tracker.SignalUser1))
+			Expect(scope).To(Equal(linux_backend.SignalScopeTree))
+		})
+
+		Context("when the scope is unknown", func() {
+			It("returns 400", func() {
+				fakeBackend.SignalProcessReturns(linux_backend.UnknownSignalScopeError{Scope: "universe"})
+
+				signal("42", `{"Signal":"HUP","Scope":"universe"}`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("when the signal is unknown", func() {
+			It("returns 400 without signalling anything", func() {
+				signal("42", `{"Signal":"SIGBOGUS"}`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.SignalProcessCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the process ID is not a number", func() {
+			It("returns 400 without signalling anything", func() {
+				signal("bogus", `{"Signal":"HUP"}`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.SignalProcessCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the body is not valid JSON", func() {
+			It("returns 400 without signalling anything", func() {
+				signal("42", `{`)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+				Expect(fakeBackend.SignalProcessCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the process does not exist", func() {
+			It("returns 404", func() {
+				fakeBackend.SignalProcessReturns(process_tracker.UnknownProcessError{ProcessID: 42})
+
+				signal("42", `{"Signal":"HUP"}`)
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+	})
+
+	Describe("getting the committed capacity", func() {
+		It("returns the backend's capacity report", func() {
+			fakeBackend.CapacityReportReturns(capacity.Report{
+				Total:           capacity.Resources{MemoryInBytes: 1000, DiskInBytes: 2000, CPUShares: 1024},
+				Committed:       capacity.Resources{MemoryInBytes: 500},
+				Allowed:         &capacity.Resources{MemoryInBytes: 2000, DiskInBytes: 4000, CPUShares: 2048},
+				OvercommitRatio: 2,
+			}, nil)
+
+			request(admin.CommittedCapacity, nil)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var report capacity.Report
+			err := json.NewDecoder(recorder.Body).Decode(&report)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Committed.MemoryInBytes).To(Equal(uint64(500)))
+			Expect(report.Allowed).To(Equal(&capacity.Resources{MemoryInBytes: 2000, DiskInBytes: 4000, CPUShares: 2048}))
+		})
+
+		Context("when getting the report fails", func() {
+			It("returns 500 with the error", func() {
+				fakeBackend.CapacityReportReturns(capacity.Report{}, errors.New("oh no"))
+
+				request(admin.CommittedCapacity, nil)
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).To(Equal("oh no"))
+			})
+		})
+	})
+})
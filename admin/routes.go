@@ -0,0 +1,57 @@
+package admin
+
+import "github.com/tedsuo/rata"
+
+const (
+	RestoreReport = "RestoreReport"
+
+	ListQuarantined    = "ListQuarantined"
+	RestoreQuarantined = "RestoreQuarantined"
+	DiscardQuarantined = "DiscardQuarantined"
+
+	StreamEvents = "StreamEvents"
+
+	AuditDrift  = "AuditDrift"
+	RepairDrift = "RepairDrift"
+
+	CommittedCapacity = "CommittedCapacity"
+
+	PauseContainer  = "PauseContainer"
+	ResumeContainer = "ResumeContainer"
+	StopContainer   = "StopContainer"
+
+	ContainerLimits  = "ContainerLimits"
+	LimitContainer   = "LimitContainer"
+	ContainerMetrics = "ContainerMetrics"
+	MetricsHistory   = "MetricsHistory"
+	ContainerOOMs    = "ContainerOOMs"
+
+	SignalProcess = "SignalProcess"
+)
+
+var Routes = rata.Routes{
+	{Path: "/restore-report", Method: "GET", Name: RestoreReport},
+
+	{Path: "/quarantine", Method: "GET", Name: ListQuarantined},
+	{Path: "/quarantine/:id/restore", Method: "POST", Name: RestoreQuarantined},
+	{Path: "/quarantine/:id", Method: "DELETE", Name: DiscardQuarantined},
+
+	{Path: "/events", Method: "GET", Name: StreamEvents},
+
+	{Path: "/drift", Method: "GET", Name: AuditDrift},
+	{Path: "/drift/repair", Method: "POST", Name: RepairDrift},
+
+	{Path: "/capacity", Method: "GET", Name: CommittedCapacity},
+
+	{Path: "/containers/:handle/pause", Method: "POST", Name: PauseContainer},
+	{Path: "/containers/:handle/resume", Method: "POST", Name: ResumeContainer},
+	{Path: "/containers/:handle/stop", Method: "POST", Name: StopContainer},
+
+	{Path: "/containers/:handle/limits", Method: "GET", Name: ContainerLimits},
+	{Path: "/containers/:handle/limits", Method: "PUT", Name: LimitContainer},
+	{Path: "/containers/:handle/metrics", Method: "GET", Name: ContainerMetrics},
+	{Path: "/containers/:handle/metrics/history", Method: "GET", Name: MetricsHistory},
+	{Path: "/containers/:handle/ooms", Method: "GET", Name: ContainerOOMs},
+
+	{Path: "/containers/:handle/processes/:id/signal", Method: "POST", Name: SignalProcess},
+}
@@ -0,0 +1,117 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+)
+
+type FakeCommitter struct {
+	CommitStub        func(handle string, resource capacity.Resource, amount uint64) error
+	commitMutex       sync.RWMutex
+	commitArgsForCall []struct {
+		handle   string
+		resource capacity.Resource
+		amount   uint64
+	}
+	commitReturns struct {
+		result1 error
+	}
+	ForceCommitStub        func(handle string, resource capacity.Resource, amount uint64)
+	forceCommitMutex       sync.RWMutex
+	forceCommitArgsForCall []struct {
+		handle   string
+		resource capacity.Resource
+		amount   uint64
+	}
+	ReleaseStub        func(handle string)
+	releaseMutex       sync.RWMutex
+	releaseArgsForCall []struct {
+		handle string
+	}
+}
+
+func (fake *FakeCommitter) Commit(handle string, resource capacity.Resource, amount uint64) error {
+	fake.commitMutex.Lock()
+	fake.commitArgsForCall = append(fake.commitArgsForCall, struct {
+		handle   string
+		resource capacity.Resource
+		amount   uint64
+	}{handle, resource, amount})
+	fake.commitMutex.Unlock()
+	if fake.CommitStub != nil {
+		return fake.CommitStub(handle, resource, amount)
+	} else {
+		return fake.commitReturns.result1
+	}
+}
+
+func (fake *FakeCommitter) CommitCallCount() int {
+	fake.commitMutex.RLock()
+	defer fake.commitMutex.RUnlock()
+	return len(fake.commitArgsForCall)
+}
+
+func (fake *FakeCommitter) CommitArgsForCall(i int) (string, capacity.Resource, uint64) {
+	fake.commitMutex.RLock()
+	defer fake.commitMutex.RUnlock()
+	return fake.commitArgsForCall[i].handle, fake.commitArgsForCall[i].resource, fake.commitArgsForCall[i].amount
+}
+
+func (fake *FakeCommitter) CommitReturns(result1 error) {
+	fake.CommitStub = nil
+	fake.commitReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCommitter) ForceCommit(handle string, resource capacity.Resource, amount uint64) {
+	fake.forceCommitMutex.Lock()
+	fake.forceCommitArgsForCall = append(fake.forceCommitArgsForCall, struct {
+		handle   string
+		resource capacity.Resource
+		amount   uint64
+	}{handle, resource, amount})
+	fake.forceCommitMutex.Unlock()
+	if fake.ForceCommitStub != nil {
+		fake.ForceCommitStub(handle, resource, amount)
+	}
+}
+
+func (fake *FakeCommitter) ForceCommitCallCount() int {
+	fake.forceCommitMutex.RLock()
+	defer fake.forceCommitMutex.RUnlock()
+	return len(fake.forceCommitArgsForCall)
+}
+
+func (fake *FakeCommitter) ForceCommitArgsForCall(i int) (string, capacity.Resource, uint64) {
+	fake.forceCommitMutex.RLock()
+	defer fake.forceCommitMutex.RUnlock()
+	return fake.forceCommitArgsForCall[i].handle, fake.forceCommitArgsForCall[i].resource, fake.forceCommitArgsForCall[i].amount
+}
+
+func (fake *FakeCommitter) Release(handle string) {
+	fake.releaseMutex.Lock()
+	fake.releaseArgsForCall = append(fake.releaseArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.releaseMutex.Unlock()
+	if fake.ReleaseStub != nil {
+		fake.ReleaseStub(handle)
+	}
+}
+
+func (fake *FakeCommitter) ReleaseCallCount() int {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return len(fake.releaseArgsForCall)
+}
+
+func (fake *FakeCommitter) ReleaseArgsForCall(i int) string {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return fake.releaseArgsForCall[i].handle
+}
+
+var _ capacity.Committer = new(FakeCommitter)
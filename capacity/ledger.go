@@ -0,0 +1,356 @@
+package capacity
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info"
+)
+
+type Resource string
+
+const (
+	Memory Resource = "memory"
+	Disk   Resource = "disk"
+	CPU    Resource = "cpu"
+)
+
+var resources = []Resource{Memory, Disk, CPU}
+
+type Resources struct {
+	MemoryInBytes uint64
+	DiskInBytes   uint64
+	CPUShares     uint64
+}
+
+func (r Resources) Get(resource Resource) uint64 {
+	switch resource {
+	case Memory:
+		return r.MemoryInBytes
+	case Disk:
+		return r.DiskInBytes
+	case CPU:
+		return r.CPUShares
+	}
+
+	return 0
+}
+
+func (r *Resources) set(resource Resource, amount uint64) {
+	switch resource {
+	case Memory:
+		r.MemoryInBytes = amount
+	case Disk:
+		r.DiskInBytes = amount
+	case CPU:
+		r.CPUShares = amount
+	}
+}
+
+// Report describes the cell's resources and how much of them has been
+// committed to containers through their limits.
+type Report struct {
+	Total     Resources
+	Committed Resources
+
+	// Available is how much of each resource can still be committed: the
+	// amount allowed by the overcommit ratio less what has been committed,
+	// or the totals less what has been committed when admission control is
+	// disabled.
+	Available Resources
+
+	// Allowed is how much of each resource may be committed, i.e. the total
+	// scaled by the overcommit ratio. It is omitted when admission control is
+	// disabled.
+	Allowed         *Resources `json:",omitempty"`
+	OvercommitRatio float64
+
+	// Reserved is how many containers have been admitted but not yet
+	// created.
+	Reserved int
+
+	// Cores describes the cores set aside for pinned containers. It is
+	// omitted when there are none.
+	Cores *CoresReport `json:",omitempty"`
+}
+
+type CoresReport struct {
+	Pinnable    int
+	Unallocated []int
+}
+
+// A CorePool hands out cores to pinned containers.
+type CorePool interface {
+	Size() int
+	Unallocated() []int
+}
+
+type InsufficientCapacityError struct {
+	Resource  Resource
+	Requested uint64
+	Committed uint64
+	Allowed   uint64
+}
+
+func (e InsufficientCapacityError) Error() string {
+	return fmt.Sprintf(
+		"insufficient %s capacity: cannot commit %d with %d of %d already committed",
+		e.Resource, e.Requested, e.Committed, e.Allowed,
+	)
+}
+
+type CapacityExhaustedError struct {
+	Resource  Resource
+	Committed uint64
+	Allowed   uint64
+}
+
+func (e CapacityExhaustedError) Error() string {
+	return fmt.Sprintf("%s capacity exhausted: %d of %d committed", e.Resource, e.Committed, e.Allowed)
+}
+
+//go:generate counterfeiter -o fakes/fake_committer.go . Committer
+type Committer interface {
+	// Commit records that the container has been given amount of the
+	// resource, replacing its previous commitment, unless doing so would
+	// oversubscribe the cell.
+	Commit(handle string, resource Resource, amount uint64) error
+
+	// ForceCommit records the commitment regardless of the cell's capacity,
+	// e.g. for a restored container which already holds the resource.
+	ForceCommit(handle string, resource Resource, amount uint64)
+
+	// Release forgets every commitment made to the container.
+	Release(handle string)
+}
+
+// Ledger tracks the memory, disk and CPU shares committed to each container.
+// Memory and disk are measured against the host's totals; CPU shares, which
+// are relative, against totalCPUShares.
+//
+// With an overcommit ratio of 0 commitments are only tracked, never refused.
+type Ledger struct {
+	systemInfo      system_info.Provider
+	totalCPUShares  uint64
+	overcommitRatio float64
+	cores           CorePool
+
+	commitments map[string]Resources
+	reserved    int
+	mutex       sync.Mutex
+}
+
+func NewLedger(systemInfo system_info.Provider, totalCPUShares uint64, overcommitRatio float64, cores CorePool) *Ledger {
+	return &Ledger{
+		systemInfo:      systemInfo,
+		totalCPUShares:  totalCPUShares,
+		overcommitRatio: overcommitRatio,
+		cores:           cores,
+
+		commitments: map[string]Resources{},
+	}
+}
+
+func (l *Ledger) Commit(handle string, resource Resource, amount uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	previous := l.commitments[handle].Get(resource)
+
+	// giving a container less never oversubscribes the cell any further
+	if l.overcommitRatio > 0 && amount > previous {
+		allowed, err := l.allowed()
+		if err != nil {
+			return err
+		}
+
+		committed := l.committed().Get(resource) - previous
+		if committed+amount > allowed.Get(resource) {
+			return InsufficientCapacityError{
+				Resource:  resource,
+				Requested: amount,
+				Committed: committed,
+				Allowed:   allowed.Get(resource),
+			}
+		}
+	}
+
+	l.record(handle, resource, amount)
+
+	return nil
+}
+
+func (l *Ledger) ForceCommit(handle string, resource Resource, amount uint64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.record(handle, resource, amount)
+}
+
+func (l *Ledger) Release(handle string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.commitments, handle)
+}
+
+// A Reservation admits a container while it is created.
+type Reservation struct {
+	ledger   *Ledger
+	released sync.Once
+}
+
+// Reserve admits a new container, returning an error if any resource is
+// already fully committed. The check is made under the same lock as every
+// commitment, so that no commitment can slip in between it and the
+// reservation. The reservation must be released once the container has been
+// created, or has failed to be.
+func (l *Ledger) Reserve() (*Reservation, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	err := l.checkAvailable()
+	if err != nil {
+		return nil, err
+	}
+
+	l.reserved++
+
+	return &Reservation{ledger: l}, nil
+}
+
+func (r *Reservation) Release() {
+	r.released.Do(func() {
+		r.ledger.mutex.Lock()
+		defer r.ledger.mutex.Unlock()
+
+		r.ledger.reserved--
+	})
+}
+
+func (l *Ledger) available(total Resources, committed Resources) Resources {
+	limit := total
+	if l.overcommitRatio > 0 {
+		limit = scale(total, l.overcommitRatio)
+	}
+
+	available := Resources{}
+	for _, resource := range resources {
+		if committed.Get(resource) < limit.Get(resource) {
+			available.set(resource, limit.Get(resource)-committed.Get(resource))
+		}
+	}
+
+	return available
+}
+
+func (l *Ledger) checkAvailable() error {
+	if l.overcommitRatio <= 0 {
+		return nil
+	}
+
+	allowed, err := l.allowed()
+	if err != nil {
+		return err
+	}
+
+	committed := l.committed()
+
+	for _, resource := range resources {
+		if committed.Get(resource) >= allowed.Get(resource) && committed.Get(resource) > 0 {
+			return CapacityExhaustedError{
+				Resource:  resource,
+				Committed: committed.Get(resource),
+				Allowed:   allowed.Get(resource),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *Ledger) Report() (Report, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	total, err := l.total()
+	if err != nil {
+		return Report{}, err
+	}
+
+	committed := l.committed()
+
+	report := Report{
+		Total:           total,
+		Committed:       committed,
+		Available:       l.available(total, committed),
+		OvercommitRatio: l.overcommitRatio,
+		Reserved:        l.reserved,
+	}
+
+	if l.overcommitRatio > 0 {
+		allowed := scale(total, l.overcommitRatio)
+		report.Allowed = &allowed
+	}
+
+	if l.cores != nil && l.cores.Size() > 0 {
+		report.Cores = &CoresReport{
+			Pinnable:    l.cores.Size(),
+			Unallocated: l.cores.Unallocated(),
+		}
+	}
+
+	return report, nil
+}
+
+func (l *Ledger) record(handle string, resource Resource, amount uint64) {
+	commitment := l.commitments[handle]
+	commitment.set(resource, amount)
+	l.commitments[handle] = commitment
+}
+
+func (l *Ledger) committed() Resources {
+	var committed Resources
+	for _, commitment := range l.commitments {
+		committed.MemoryInBytes += commitment.MemoryInBytes
+		committed.DiskInBytes += commitment.DiskInBytes
+		committed.CPUShares += commitment.CPUShares
+	}
+
+	return committed
+}
+
+func (l *Ledger) total() (Resources, error) {
+	memory, err := l.systemInfo.TotalMemory()
+	if err != nil {
+		return Resources{}, err
+	}
+
+	disk, err := l.systemInfo.TotalDisk()
+	if err != nil {
+		return Resources{}, err
+	}
+
+	return Resources{
+		MemoryInBytes: memory,
+		DiskInBytes:   disk,
+		CPUShares:     l.totalCPUShares,
+	}, nil
+}
+
+func (l *Ledger) allowed() (Resources, error) {
+	total, err := l.total()
+	if err != nil {
+		return Resources{}, err
+	}
+
+	return scale(total, l.overcommitRatio), nil
+}
+
+func scale(r Resources, ratio float64) Resources {
+	return Resources{
+		MemoryInBytes: uint64(float64(r.MemoryInBytes) * ratio),
+		DiskInBytes:   uint64(float64(r.DiskInBytes) * ratio),
+		CPUShares:     uint64(float64(r.CPUShares) * ratio),
+	}
+}
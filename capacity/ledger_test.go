@@ -0,0 +1,288 @@
+package capacity_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ledger", func() {
+	var fakeSystemInfo *fake_system_info.FakeProvider
+	var overcommitRatio float64
+	var cores capacity.CorePool
+	var ledger *capacity.Ledger
+
+	BeforeEach(func() {
+		fakeSystemInfo = fake_system_info.NewFakeProvider()
+		fakeSystemInfo.TotalMemoryResult = 1000
+		fakeSystemInfo.TotalDiskResult = 2000
+
+		overcommitRatio = 1.5
+		cores = nil
+	})
+
+	JustBeforeEach(func() {
+		ledger = capacity.NewLedger(fakeSystemInfo, 4096, overcommitRatio, cores)
+	})
+
+	committed := func() capacity.Resources {
+		report, err := ledger.Report()
+		Expect(err).ToNot(HaveOccurred())
+
+		return report.Committed
+	}
+
+	Describe("Commit", func() {
+		It("records the amount committed to each container", func() {
+			Expect(ledger.Commit("some-handle", capacity.Memory, 100)).To(Succeed())
+			Expect(ledger.Commit("some-handle", capacity.Disk, 200)).To(Succeed())
+			Expect(ledger.Commit("other-handle", capacity.Memory, 300)).To(Succeed())
+			Expect(ledger.Commit("other-handle", capacity.CPU, 1024)).To(Succeed())
+
+			Expect(committed()).To(Equal(capacity.Resources{
+				MemoryInBytes: 400,
+				DiskInBytes:   200,
+				CPUShares:     1024,
+			}))
+		})
+
+		It("replaces the container's previous commitment", func() {
+			Expect(ledger.Commit("some-handle", capacity.Memory, 100)).To(Succeed())
+			Expect(ledger.Commit("some-handle", capacity.Memory, 700)).To(Succeed())
+
+			Expect(committed().MemoryInBytes).To(Equal(uint64(700)))
+		})
+
+		Context("when the commitment would exceed the total scaled by the overcommit ratio", func() {
+			JustBeforeEach(func() {
+				Expect(ledger.Commit("other-handle", capacity.Memory, 1000)).To(Succeed())
+				Expect(ledger.Commit("some-handle", capacity.Memory, 100)).To(Succeed())
+			})
+
+			It("returns an InsufficientCapacityError", func() {
+				err := ledger.Commit("some-handle", capacity.Memory, 501)
+				Expect(err).To(Equal(capacity.InsufficientCapacityError{
+					Resource:  capacity.Memory,
+					Requested: 501,
+					Committed: 1000,
+					Allowed:   1500,
+				}))
+
+				Expect(err).To(MatchError("insufficient memory capacity: cannot commit 501 with 1000 of 1500 already committed"))
+			})
+
+			It("keeps the previous commitment", func() {
+				Expect(ledger.Commit("some-handle", capacity.Memory, 501)).ToNot(Succeed())
+
+				Expect(committed().MemoryInBytes).To(Equal(uint64(1100)))
+			})
+
+			It("allows committing up to exactly the allowed amount", func() {
+				Expect(ledger.Commit("some-handle", capacity.Memory, 500)).To(Succeed())
+			})
+		})
+
+		Context("when the cell is already overcommitted", func() {
+			JustBeforeEach(func() {
+				ledger.ForceCommit("other-handle", capacity.CPU, 8192)
+				ledger.ForceCommit("some-handle", capacity.CPU, 1024)
+			})
+
+			It("still allows a container's commitment to be reduced", func() {
+				Expect(ledger.Commit("some-handle", capacity.CPU, 512)).To(Succeed())
+				Expect(committed().CPUShares).To(Equal(uint64(8704)))
+			})
+		})
+
+		Context("when the overcommit ratio is 0", func() {
+			BeforeEach(func() {
+				overcommitRatio = 0
+			})
+
+			It("allows any amount to be committed", func() {
+				Expect(ledger.Commit("some-handle", capacity.Disk, 1000000)).To(Succeed())
+				Expect(committed().DiskInBytes).To(Equal(uint64(1000000)))
+			})
+		})
+
+		Context("when the host's totals cannot be determined", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeSystemInfo.TotalDiskError = disaster
+			})
+
+			It("returns the error", func() {
+				Expect(ledger.Commit("some-handle", capacity.Disk, 100)).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Release", func() {
+		It("forgets everything committed to the container", func() {
+			Expect(ledger.Commit("some-handle", capacity.Memory, 100)).To(Succeed())
+			Expect(ledger.Commit("some-handle", capacity.CPU, 100)).To(Succeed())
+			Expect(ledger.Commit("other-handle", capacity.Memory, 200)).To(Succeed())
+
+			ledger.Release("some-handle")
+
+			Expect(committed()).To(Equal(capacity.Resources{MemoryInBytes: 200}))
+		})
+	})
+
+	Describe("Reserve", func() {
+		It("succeeds while every resource has room left", func() {
+			ledger.ForceCommit("some-handle", capacity.Memory, 1499)
+
+			reservation, err := ledger.Reserve()
+			Expect(err).ToNot(HaveOccurred())
+
+			report, err := ledger.Report()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Reserved).To(Equal(1))
+
+			reservation.Release()
+			reservation.Release()
+
+			report, err = ledger.Report()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Reserved).To(Equal(0))
+		})
+
+		Context("when a resource is fully committed", func() {
+			JustBeforeEach(func() {
+				ledger.ForceCommit("some-handle", capacity.Disk, 3000)
+			})
+
+			It("returns a CapacityExhaustedError", func() {
+				_, err := ledger.Reserve()
+				Expect(err).To(Equal(capacity.CapacityExhaustedError{
+					Resource:  capacity.Disk,
+					Committed: 3000,
+					Allowed:   3000,
+				}))
+
+				Expect(err).To(MatchError("disk capacity exhausted: 3000 of 3000 committed"))
+			})
+
+			It("does not reserve anything", func() {
+				ledger.Reserve()
+
+				report, err := ledger.Report()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(report.Reserved).To(Equal(0))
+			})
+
+			Context("and the overcommit ratio is 0", func() {
+				BeforeEach(func() {
+					overcommitRatio = 0
+				})
+
+				It("succeeds", func() {
+					_, err := ledger.Reserve()
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	Describe("Report", func() {
+		It("includes the totals and the amounts allowed by the overcommit ratio", func() {
+			report, err := ledger.Report()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Total).To(Equal(capacity.Resources{MemoryInBytes: 1000, DiskInBytes: 2000, CPUShares: 4096}))
+			Expect(report.Allowed).To(Equal(&capacity.Resources{MemoryInBytes: 1500, DiskInBytes: 3000, CPUShares: 6144}))
+			Expect(report.OvercommitRatio).To(Equal(1.5))
+		})
+
+		It("includes what the overcommit ratio allows less what has been committed as available", func() {
+			ledger.ForceCommit("some-handle", capacity.Memory, 500)
+			ledger.ForceCommit("other-handle", capacity.Disk, 4000)
+
+			report, err := ledger.Report()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Available).To(Equal(capacity.Resources{
+				MemoryInBytes: 1000,
+				DiskInBytes:   0,
+				CPUShares:     6144,
+			}))
+		})
+
+		Context("when the overcommit ratio is 0", func() {
+			BeforeEach(func() {
+				overcommitRatio = 0
+			})
+
+			It("omits the allowed amounts", func() {
+				report, err := ledger.Report()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(report.Allowed).To(BeNil())
+			})
+
+			It("includes the totals less what has been committed as available", func() {
+				ledger.ForceCommit("some-handle", capacity.Memory, 500)
+
+				report, err := ledger.Report()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(report.Available).To(Equal(capacity.Resources{
+					MemoryInBytes: 500,
+					DiskInBytes:   2000,
+					CPUShares:     4096,
+				}))
+			})
+		})
+
+		Context("when getting the totals fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeSystemInfo.TotalMemoryError = disaster
+			})
+
+			It("returns the error", func() {
+				_, err := ledger.Report()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when there are cores for pinned containers", func() {
+			var pool *cpuset_pool.Pool
+
+			BeforeEach(func() {
+				pool = cpuset_pool.New([]cpuset_pool.Core{{ID: 2}, {ID: 3}, {ID: 4}})
+				cores = pool
+			})
+
+			It("includes the cores which are not yet pinned", func() {
+				_, err := pool.Acquire(1, cpuset_pool.AnyNode)
+				Expect(err).ToNot(HaveOccurred())
+
+				report, err := ledger.Report()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(report.Cores).To(Equal(&capacity.CoresReport{
+					Pinnable:    3,
+					Unallocated: []int{3, 4},
+				}))
+			})
+		})
+
+		Context("when there are no cores for pinned containers", func() {
+			It("omits the cores", func() {
+				report, err := ledger.Report()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(report.Cores).To(BeNil())
+			})
+		})
+	})
+})
@@ -1,7 +1,6 @@
 package container_pool
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -19,6 +18,9 @@ import (
 	"github.com/cloudfoundry/gunk/command_runner"
 	"github.com/pivotal-golang/lager"
 
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
@@ -52,6 +54,13 @@ type SubnetPool interface {
 	Capacity() int
 }
 
+//go:generate counterfeiter -o fake_cpuset_pool/FakeCPUSetPool.go . CPUSetPool
+type CPUSetPool interface {
+	Acquire(cores int, node int) (*linux_backend.CPUSet, error)
+	Release(*linux_backend.CPUSet)
+	Remove(*linux_backend.CPUSet) error
+}
+
 type LinuxContainerPool struct {
 	logger lager.Logger
 
@@ -73,6 +82,8 @@ type LinuxContainerPool struct {
 
 	portPool linux_container.PortPool
 
+	cpusetPool CPUSetPool
+
 	bridges bridgemgr.BridgeManager
 
 	filterProvider FilterProvider
@@ -82,6 +93,10 @@ type LinuxContainerPool struct {
 
 	quotaManager quota_manager.QuotaManager
 
+	eventEmitter event_hub.Emitter
+
+	committer capacity.Committer
+
 	containerIDs chan string
 }
 
@@ -98,9 +113,12 @@ func New(
 	filterProvider FilterProvider,
 	defaultChain iptables.Chain,
 	portPool linux_container.PortPool,
+	cpusetPool CPUSetPool,
 	denyNetworks, allowNetworks []string,
 	runner command_runner.CommandRunner,
 	quotaManager quota_manager.QuotaManager,
+	eventEmitter event_hub.Emitter,
+	committer capacity.Committer,
 ) *LinuxContainerPool {
 	pool := &LinuxContainerPool{
 		logger: logger.Session("pool"),
@@ -128,10 +146,16 @@ func New(
 
 		portPool: portPool,
 
+		cpusetPool: cpusetPool,
+
 		runner: runner,
 
 		quotaManager: quotaManager,
 
+		eventEmitter: eventEmitter,
+
+		committer: committer,
+
 		containerIDs: make(chan string),
 	}
 
@@ -185,13 +209,15 @@ func (p *LinuxContainerPool) setupIPTables() error {
 	return nil
 }
 
-func (p *LinuxContainerPool) Prune(keep map[string]bool) error {
+func (p *LinuxContainerPool) Prune(keep map[string]bool) ([]string, error) {
 	entries, err := ioutil.ReadDir(p.depotPath)
 	if err != nil {
 		p.logger.Error("prune-container-pool-path-error", err, lager.Data{"depotPath": p.depotPath})
-		return fmt.Errorf("Cannot read path %q: %s", p.depotPath, err)
+		return nil, fmt.Errorf("Cannot read path %q: %s", p.depotPath, err)
 	}
 
+	pruned := []string{}
+
 	for _, entry := range entries {
 		id := entry.Name()
 		if id == "tmp" { // ignore temporary directory in depotPath
@@ -204,13 +230,14 @@ func (p *LinuxContainerPool) Prune(keep map[string]bool) error {
 		}
 
 		p.pruneEntry(id)
+		pruned = append(pruned, id)
 	}
 
 	if err := p.bridges.Prune(); err != nil {
 		p.logger.Error("prune-bridges", err)
 	}
 
-	return nil
+	return pruned, nil
 }
 
 // pruneEntry does not report errors, only log them
@@ -278,13 +305,14 @@ func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.
 		process_tracker.New(containerPath, p.runner),
 		rootFSEnv.Merge(specEnv),
 		p.filterProvider.ProvideFilter(id),
+		p.eventEmitter,
+		p.committer,
+		linux_container.Timings{},
 	), nil
 }
 
-func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Container, error) {
-	var containerSnapshot linux_container.ContainerSnapshot
-
-	err := json.NewDecoder(snapshot).Decode(&containerSnapshot)
+func (p *LinuxContainerPool) Restore(snapshot io.Reader) (c linux_backend.Container, err error) {
+	containerSnapshot, err := linux_container.ReadSnapshot(snapshot)
 	if err != nil {
 		return nil, err
 	}
@@ -299,27 +327,12 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 
 	resources := containerSnapshot.Resources
 
-	if err = p.subnetPool.Remove(resources.Network); err != nil {
+	if err = p.reservePoolResources(id, resources); err != nil {
 		return nil, err
 	}
-
-	if err = p.bridges.Rereserve(resources.Bridge, resources.Network.Subnet, id); err != nil {
-		p.subnetPool.Release(resources.Network)
-		return nil, err
-	}
-
-	for _, port := range resources.Ports {
-		err = p.portPool.Remove(port)
-		if err != nil {
-			p.subnetPool.Release(resources.Network)
-
-			for _, port := range resources.Ports {
-				p.portPool.Release(port)
-			}
-
-			return nil, err
-		}
-	}
+	defer cleanup(&err, func() {
+		p.releaseReservedPoolResources(id, resources, len(resources.Ports), true)
+	})
 
 	containerPath := path.Join(p.depotPath, id)
 
@@ -334,6 +347,16 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		return nil, err
 	}
 
+	containerResources := linux_backend.NewResources(
+		resources.UserUID,
+		resources.RootUID,
+		resources.Network,
+		resources.Bridge,
+		resources.Ports,
+		p.externalIP,
+	)
+	containerResources.CPUSet = resources.CPUSet
+
 	container := linux_container.NewLinuxContainer(
 		containerLogger,
 		id,
@@ -341,14 +364,7 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		containerPath,
 		containerSnapshot.Properties,
 		containerSnapshot.GraceTime,
-		linux_backend.NewResources(
-			resources.UserUID,
-			resources.RootUID,
-			resources.Network,
-			resources.Bridge,
-			resources.Ports,
-			p.externalIP,
-		),
+		containerResources,
 		p.portPool,
 		p.runner,
 		cgroupsManager,
@@ -357,6 +373,9 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		process_tracker.New(containerPath, p.runner),
 		containerEnv,
 		p.filterProvider.ProvideFilter(id),
+		p.eventEmitter,
+		p.committer,
+		linux_container.Timings{},
 	)
 
 	err = container.Restore(containerSnapshot)
@@ -376,12 +395,20 @@ func (p *LinuxContainerPool) Destroy(container linux_backend.Container) error {
 
 	pLog.Info("destroying")
 
-	err := p.releaseSystemResources(pLog, container.ID())
+	linuxContainer := container.(*linux_container.LinuxContainer)
+
+	destroyed, err := linuxContainer.BeginDestroy()
+	if err != nil {
+		pLog.Error("failed-to-begin-destroying", err)
+		return err
+	}
+
+	err = p.releaseSystemResources(pLog, container.ID())
+	destroyed(err)
 	if err != nil {
 		return err
 	}
 
-	linuxContainer := container.(*linux_container.LinuxContainer)
 	resources := linuxContainer.Resources()
 	p.releasePoolResources(resources)
 
@@ -480,9 +507,96 @@ func (p *LinuxContainerPool) acquirePoolResources(spec garden.ContainerSpec, id
 		return nil, err
 	}
 
+	cores, node, err := requestedCPUSet(spec.Properties)
+	if err != nil {
+		p.releasePoolResources(resources)
+		return nil, err
+	}
+
+	if cores > 0 {
+		if resources.CPUSet, err = p.cpusetPool.Acquire(cores, node); err != nil {
+			p.releasePoolResources(resources)
+			return nil, err
+		}
+	}
+
 	return resources, nil
 }
 
+// requestedCPUSet returns how many cores, and from which NUMA node, the
+// container's properties ask to be pinned to.
+func requestedCPUSet(properties garden.Properties) (int, int, error) {
+	cores := 0
+	node := cpuset_pool.AnyNode
+
+	if value, found := properties[linux_container.CPUSetCoresProperty]; found {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, linux_container.InvalidLimitPropertyError{Key: linux_container.CPUSetCoresProperty, Value: value}
+		}
+
+		cores = n
+	}
+
+	if value, found := properties[linux_container.CPUSetNodeProperty]; found {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, linux_container.InvalidLimitPropertyError{Key: linux_container.CPUSetNodeProperty, Value: value}
+		}
+
+		node = n
+	}
+
+	return cores, node, nil
+}
+
+// reservePoolResources takes a restored container's network, bridge, ports
+// and pinned cores out of the pools. Restores may run concurrently, so on
+// failure only what this call reserved is handed back; anything else may
+// belong to another container.
+func (p *LinuxContainerPool) reservePoolResources(id string, resources linux_container.ResourcesSnapshot) error {
+	if err := p.subnetPool.Remove(resources.Network); err != nil {
+		return err
+	}
+
+	if err := p.bridges.Rereserve(resources.Bridge, resources.Network.Subnet, id); err != nil {
+		p.subnetPool.Release(resources.Network)
+		return err
+	}
+
+	for i, port := range resources.Ports {
+		if err := p.portPool.Remove(port); err != nil {
+			p.releaseReservedPoolResources(id, resources, i, false)
+			return err
+		}
+	}
+
+	if resources.CPUSet != nil {
+		if err := p.cpusetPool.Remove(resources.CPUSet); err != nil {
+			p.releaseReservedPoolResources(id, resources, len(resources.Ports), false)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *LinuxContainerPool) releaseReservedPoolResources(id string, resources linux_container.ResourcesSnapshot, reservedPorts int, reservedCPUSet bool) {
+	if reservedCPUSet && resources.CPUSet != nil {
+		p.cpusetPool.Release(resources.CPUSet)
+	}
+
+	for _, port := range resources.Ports[:reservedPorts] {
+		p.portPool.Release(port)
+	}
+
+	if err := p.bridges.Release(resources.Bridge, id); err != nil {
+		p.logger.Error("release-bridge", err, lager.Data{"id": id})
+	}
+
+	p.subnetPool.Release(resources.Network)
+}
+
 func (p *LinuxContainerPool) acquireUID(resources *linux_backend.Resources, privileged bool) error {
 	if !privileged {
 		resources.UserUID = vcapUid + p.uidNamespaceOffset
@@ -500,6 +614,10 @@ func (p *LinuxContainerPool) releasePoolResources(resources *linux_backend.Resou
 		p.portPool.Release(port)
 	}
 
+	if resources.CPUSet != nil {
+		p.cpusetPool.Release(resources.CPUSet)
+	}
+
 	if resources.Network != nil {
 		p.subnetPool.Release(resources.Network)
 	}
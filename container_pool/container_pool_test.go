@@ -19,9 +19,13 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/pivotal-golang/lager/lagertest"
 
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_cpuset_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_subnet_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
@@ -47,6 +51,7 @@ var _ = Describe("Container pool", func() {
 	var fakeSubnetPool *fake_subnet_pool.FakeSubnetPool
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
 	var fakePortPool *fake_port_pool.FakePortPool
+	var fakeCPUSetPool *fake_cpuset_pool.FakeCPUSetPool
 	var defaultFakeRootFSProvider *fake_rootfs_provider.FakeRootFSProvider
 	var fakeRootFSProvider *fake_rootfs_provider.FakeRootFSProvider
 	var fakeBridges *fake_bridge_manager.FakeBridgeManager
@@ -81,6 +86,7 @@ var _ = Describe("Container pool", func() {
 		fakeRunner = fake_command_runner.New()
 		fakeQuotaManager = fake_quota_manager.New()
 		fakePortPool = fake_port_pool.New(1000)
+		fakeCPUSetPool = new(fake_cpuset_pool.FakeCPUSetPool)
 		defaultFakeRootFSProvider = new(fake_rootfs_provider.FakeRootFSProvider)
 		fakeRootFSProvider = new(fake_rootfs_provider.FakeRootFSProvider)
 
@@ -108,10 +114,13 @@ var _ = Describe("Container pool", func() {
 			fakeFilterProvider,
 			iptables.NewGlobalChain("global-default-chain", fakeRunner, logger),
 			fakePortPool,
+			fakeCPUSetPool,
 			[]string{"1.1.0.0/16", "", "2.2.0.0/16"}, // empty string to test that this is ignored
 			[]string{"1.1.1.1/32", "", "2.2.2.2/32"},
 			fakeRunner,
 			fakeQuotaManager,
+			event_hub.New(logger, 100),
+			new(capacityFakes.FakeCommitter),
 		)
 	})
 
@@ -947,6 +956,120 @@ var _ = Describe("Container pool", func() {
 			itReleasesAndDestroysTheBridge()
 		})
 
+		Describe("pinning to cores", func() {
+			pinned := &linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}
+
+			BeforeEach(func() {
+				fakeCPUSetPool.AcquireReturns(pinned, nil)
+			})
+
+			It("acquires the requested number of cores from the requested node", func() {
+				container, err := pool.Create(garden.ContainerSpec{
+					Properties: garden.Properties{
+						linux_container.CPUSetCoresProperty: "2",
+						linux_container.CPUSetNodeProperty:  "1",
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCPUSetPool.AcquireCallCount()).To(Equal(1))
+				cores, node := fakeCPUSetPool.AcquireArgsForCall(0)
+				Expect(cores).To(Equal(2))
+				Expect(node).To(Equal(1))
+
+				Expect(container.(*linux_container.LinuxContainer).Resources().CPUSet).To(Equal(pinned))
+			})
+
+			Context("when no node is requested", func() {
+				It("lets the pool choose the node", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							linux_container.CPUSetCoresProperty: "2",
+						},
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					_, node := fakeCPUSetPool.AcquireArgsForCall(0)
+					Expect(node).To(Equal(cpuset_pool.AnyNode))
+				})
+			})
+
+			Context("when no cores are requested", func() {
+				It("does not pin the container", func() {
+					container, err := pool.Create(garden.ContainerSpec{})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeCPUSetPool.AcquireCallCount()).To(Equal(0))
+					Expect(container.(*linux_container.LinuxContainer).Resources().CPUSet).To(BeNil())
+				})
+			})
+
+			Context("when the number of cores is not a number", func() {
+				It("returns an InvalidLimitPropertyError and releases the network", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							linux_container.CPUSetCoresProperty: "all",
+						},
+					})
+					Expect(err).To(Equal(linux_container.InvalidLimitPropertyError{
+						Key:   linux_container.CPUSetCoresProperty,
+						Value: "all",
+					}))
+
+					Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when the pool cannot provide the cores", func() {
+				disaster := cpuset_pool.PoolExhaustedError{Requested: 2, Node: cpuset_pool.AnyNode}
+
+				BeforeEach(func() {
+					fakeCPUSetPool.AcquireReturns(nil, disaster)
+				})
+
+				It("returns the error, releases the network and does not create the container", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							linux_container.CPUSetCoresProperty: "2",
+						},
+					})
+					Expect(err).To(Equal(disaster))
+
+					Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: "/root/path/create.sh",
+						},
+					))
+				})
+			})
+
+			Context("when executing create.sh fails", func() {
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(
+						fake_command_runner.CommandSpec{
+							Path: "/root/path/create.sh",
+						}, func(cmd *exec.Cmd) error {
+							return errors.New("oh no!")
+						},
+					)
+				})
+
+				It("releases the cores", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						Properties: garden.Properties{
+							linux_container.CPUSetCoresProperty: "2",
+						},
+					})
+					Expect(err).To(HaveOccurred())
+
+					Expect(fakeCPUSetPool.ReleaseCallCount()).To(Equal(1))
+					Expect(fakeCPUSetPool.ReleaseArgsForCall(0)).To(Equal(pinned))
+				})
+			})
+		})
+
 		Context("when saving the rootfs provider fails", func() {
 			var err error
 
@@ -987,6 +1110,7 @@ var _ = Describe("Container pool", func() {
 		var containerNetwork *linux_backend.Network
 		var rootUID int
 		var bridgeName string
+		var cpuset *linux_backend.CPUSet
 
 		BeforeEach(func() {
 			rootUID = 10001
@@ -1000,6 +1124,7 @@ var _ = Describe("Container pool", func() {
 			}
 
 			bridgeName = "some-bridge"
+			cpuset = nil
 		})
 
 		JustBeforeEach(func() {
@@ -1022,6 +1147,7 @@ var _ = Describe("Container pool", func() {
 						Network: containerNetwork,
 						Bridge:  bridgeName,
 						Ports:   []uint32{61001, 61002, 61003},
+						CPUSet:  cpuset,
 					},
 
 					Properties: map[string]string{
@@ -1072,6 +1198,79 @@ var _ = Describe("Container pool", func() {
 			Expect(fakePortPool.Removed).To(ContainElement(uint32(61003)))
 		})
 
+		It("does not touch the cpuset pool when the container was not pinned", func() {
+			_, err := pool.Restore(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCPUSetPool.RemoveCallCount()).To(Equal(0))
+		})
+
+		Context("when the container was pinned to cores", func() {
+			var cgroupPath string
+
+			BeforeEach(func() {
+				cpuset = &linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}
+
+				cgroupPath = path.Join(config.CgroupPath, "cpuset", "instance-some-restored-id")
+				Expect(os.MkdirAll(cgroupPath, 0755)).To(Succeed())
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(cgroupPath)
+			})
+
+			It("removes its cores from the pool", func() {
+				container, err := pool.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCPUSetPool.RemoveCallCount()).To(Equal(1))
+				Expect(fakeCPUSetPool.RemoveArgsForCall(0)).To(Equal(cpuset))
+
+				Expect(container.(*linux_container.LinuxContainer).Resources().CPUSet).To(Equal(cpuset))
+			})
+
+			It("re-asserts the pins", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(ioutil.ReadFile(path.Join(cgroupPath, "cpuset.cpus"))).To(Equal([]byte("2,3")))
+				Expect(ioutil.ReadFile(path.Join(cgroupPath, "cpuset.mems"))).To(Equal([]byte("1")))
+			})
+
+			Context("when removing the cores from the pool fails", func() {
+				disaster := cpuset_pool.CoreTakenError{Core: 2}
+
+				BeforeEach(func() {
+					fakeCPUSetPool.RemoveReturns(disaster)
+				})
+
+				It("returns the error and releases everything else, but not the cores", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(Equal(disaster))
+
+					Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+					Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+					Expect(fakePortPool.Released).To(ConsistOf(uint32(61001), uint32(61002), uint32(61003)))
+
+					Expect(fakeCPUSetPool.ReleaseCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when restoring the container fails", func() {
+				BeforeEach(func() {
+					os.RemoveAll(cgroupPath)
+				})
+
+				It("returns the error and releases the cores", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(HaveOccurred())
+
+					Expect(fakeCPUSetPool.ReleaseCallCount()).To(Equal(1))
+					Expect(fakeCPUSetPool.ReleaseArgsForCall(0)).To(Equal(cpuset))
+				})
+			})
+		})
+
 		It("rereserves the bridge for the subnet from the pool", func() {
 			_, err := pool.Restore(snapshot)
 			Expect(err).ToNot(HaveOccurred())
@@ -1101,6 +1300,30 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when restoring the container fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: path.Join(depotPath, "some-restored-id", "net.sh"),
+				}, func(*exec.Cmd) error {
+					return disaster
+				})
+			})
+
+			It("returns the error and releases everything it reserved", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(Equal(disaster))
+
+				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+				Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(containerNetwork))
+
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+
+				Expect(fakePortPool.Released).To(ConsistOf(uint32(61001), uint32(61002), uint32(61003)))
+			})
+		})
+
 		Context("when decoding the snapshot fails", func() {
 			BeforeEach(func() {
 				snapshot = new(bytes.Buffer)
@@ -1112,6 +1335,37 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the snapshot was written with a checksum", func() {
+			var versioned *bytes.Buffer
+
+			JustBeforeEach(func() {
+				legacy, err := linux_container.ReadSnapshot(buf)
+				Expect(err).ToNot(HaveOccurred())
+
+				versioned = new(bytes.Buffer)
+				err = linux_container.WriteSnapshot(versioned, legacy)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("restores it", func() {
+				container, err := pool.Restore(versioned)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.ID()).To(Equal("some-restored-id"))
+			})
+
+			Context("and it has been corrupted", func() {
+				It("fails without reserving any resources", func() {
+					corrupt := bytes.Replace(versioned.Bytes(), []byte("some-restored-handle"), []byte("some-corrupt-handle"), 1)
+
+					_, err := pool.Restore(bytes.NewReader(corrupt))
+					Expect(err).To(BeAssignableToTypeOf(linux_container.SnapshotChecksumError{}))
+
+					Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+				})
+			})
+		})
+
 		Context("when removing the network from the pool fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -1132,16 +1386,24 @@ var _ = Describe("Container pool", func() {
 				fakePortPool.RemoveError = disaster
 			})
 
-			It("returns the error and releases the network and all ports", func() {
+			It("returns the error and releases the network and bridge", func() {
 				_, err := pool.Restore(snapshot)
 				Expect(err).To(Equal(disaster))
 
 				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
 				Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(containerNetwork))
 
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61001)))
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61002)))
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61003)))
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+				bridgeName, containerId := fakeBridges.ReleaseArgsForCall(0)
+				Expect(bridgeName).To(Equal("some-bridge"))
+				Expect(containerId).To(Equal("some-restored-id"))
+			})
+
+			It("does not release ports it did not remove, as they may belong to another container", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(Equal(disaster))
+
+				Expect(fakePortPool.Released).To(BeEmpty())
 			})
 
 			Context("when the container is privileged", func() {
@@ -1188,8 +1450,15 @@ var _ = Describe("Container pool", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("returns the IDs of the containers it pruned", func() {
+				pruned, err := pool.Prune(map[string]bool{"container-2": true})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(pruned).To(Equal([]string{"container-1", "container-3"}))
+			})
+
 			It("destroys each container", func() {
-				err := pool.Prune(map[string]bool{})
+				_, err := pool.Prune(map[string]bool{})
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
@@ -1220,7 +1489,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("cleans up each container's rootfs after destroying it", func() {
-					err := pool.Prune(map[string]bool{})
+					_, err := pool.Prune(map[string]bool{})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeRootFSProvider.CleanupRootFSCallCount()).To(Equal(2))
@@ -1235,7 +1504,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("releases the bridge", func() {
-					err := pool.Prune(map[string]bool{})
+					_, err := pool.Prune(map[string]bool{})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeBridges.ReleaseCallCount()).To(Equal(2))
@@ -1252,7 +1521,7 @@ var _ = Describe("Container pool", func() {
 
 			Context("when a container does not declare a bridge name", func() {
 				It("does nothing much", func() {
-					err := pool.Prune(map[string]bool{"container-1": true, "container-2": true})
+					_, err := pool.Prune(map[string]bool{"container-1": true, "container-2": true})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeBridges.ReleaseCallCount()).To(Equal(0))
@@ -1266,7 +1535,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("cleans it up using the default provider", func() {
-					err := pool.Prune(map[string]bool{})
+					_, err := pool.Prune(map[string]bool{})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(defaultFakeRootFSProvider.CleanupRootFSCallCount()).To(Equal(2))
@@ -1283,7 +1552,7 @@ var _ = Describe("Container pool", func() {
 					})
 
 					It("ignores the error", func() {
-						err := pool.Prune(map[string]bool{})
+						_, err := pool.Prune(map[string]bool{})
 						Expect(err).ToNot(HaveOccurred())
 					})
 				})
@@ -1297,14 +1566,14 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("ignores the error", func() {
-					err := pool.Prune(map[string]bool{})
+					_, err := pool.Prune(map[string]bool{})
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
 
 			Context("when a container to keep is specified", func() {
 				It("is not destroyed", func() {
-					err := pool.Prune(map[string]bool{"container-2": true})
+					_, err := pool.Prune(map[string]bool{"container-2": true})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeRunner).ToNot(HaveExecutedSerially(
@@ -1316,7 +1585,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("is not cleaned up", func() {
-					err := pool.Prune(map[string]bool{"container-2": true})
+					_, err := pool.Prune(map[string]bool{"container-2": true})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeRootFSProvider.CleanupRootFSCallCount()).To(Equal(1))
@@ -1325,7 +1594,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("does not release the bridge", func() {
-					err := pool.Prune(map[string]bool{"container-2": true})
+					_, err := pool.Prune(map[string]bool{"container-2": true})
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
@@ -1346,7 +1615,7 @@ var _ = Describe("Container pool", func() {
 				})
 
 				It("ignores the error", func() {
-					err := pool.Prune(map[string]bool{})
+					_, err := pool.Prune(map[string]bool{})
 					Expect(err).ToNot(HaveOccurred())
 
 					By("and does not clean up the container's rootfs")
@@ -1355,7 +1624,7 @@ var _ = Describe("Container pool", func() {
 			})
 
 			It("prunes any remaining bridges", func() {
-				err := pool.Prune(map[string]bool{})
+				_, err := pool.Prune(map[string]bool{})
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeBridges.PruneCallCount()).To(Equal(1))
@@ -1392,6 +1661,31 @@ var _ = Describe("Container pool", func() {
 			))
 		})
 
+		It("marks the container as destroyed", func() {
+			err := pool.Destroy(createdContainer)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(createdContainer.State()).To(Equal(linux_container.StateDestroyed))
+		})
+
+		Context("when the container is already being destroyed", func() {
+			BeforeEach(func() {
+				_, err := createdContainer.BeginDestroy()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an InvalidStateTransitionError without destroying it again", func() {
+				err := pool.Destroy(createdContainer)
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateTransitionError{}))
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/root/path/destroy.sh",
+					},
+				))
+			})
+		})
+
 		It("releases the container's ports and network", func() {
 			err := pool.Destroy(createdContainer)
 			Expect(err).ToNot(HaveOccurred())
@@ -1403,6 +1697,22 @@ var _ = Describe("Container pool", func() {
 			Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(createdContainer.Resources().Network))
 		})
 
+		Context("when the container is pinned to cores", func() {
+			pinned := &linux_backend.CPUSet{CPUs: []int{2}, Mems: []int{0}}
+
+			BeforeEach(func() {
+				createdContainer.Resources().CPUSet = pinned
+			})
+
+			It("releases its cores", func() {
+				err := pool.Destroy(createdContainer)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCPUSetPool.ReleaseCallCount()).To(Equal(1))
+				Expect(fakeCPUSetPool.ReleaseArgsForCall(0)).To(Equal(pinned))
+			})
+		})
+
 		Describe("bridge cleanup", func() {
 			It("releases the bridge from the pool", func() {
 				err := pool.Destroy(createdContainer)
@@ -1504,6 +1814,13 @@ var _ = Describe("Container pool", func() {
 				Expect(err).To(Equal(disaster))
 			})
 
+			It("returns the container to the state it was in", func() {
+				err := pool.Destroy(createdContainer)
+				Expect(err).To(HaveOccurred())
+
+				Expect(createdContainer.State()).To(Equal(linux_container.StateBorn))
+			})
+
 			It("does not clean up the container's rootfs", func() {
 				err := pool.Destroy(createdContainer)
 				Expect(err).To(HaveOccurred())
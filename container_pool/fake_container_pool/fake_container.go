@@ -59,6 +59,10 @@ func (c *FakeContainer) HasProperties(ps garden.Properties) bool {
 	return true
 }
 
+func (c *FakeContainer) Properties() (garden.Properties, error) {
+	return c.Spec.Properties, nil
+}
+
 func (c *FakeContainer) Start() error {
 	c.Started = true
 	return c.StartError
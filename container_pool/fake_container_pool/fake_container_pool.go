@@ -3,6 +3,7 @@ package fake_container_pool
 import (
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
@@ -14,19 +15,23 @@ type FakeContainerPool struct {
 
 	MaxContainersValue int
 
-	Pruned         bool
-	PruneError     error
-	KeptContainers map[string]bool
+	Pruned           bool
+	PruneError       error
+	KeptContainers   map[string]bool
+	PrunedContainers []string
 
 	CreateError  error
 	RestoreError error
 	DestroyError error
 
 	ContainerSetup func(*FakeContainer)
+	BeforeRestore  func()
 
 	CreatedContainers   []linux_backend.Container
 	DestroyedContainers []linux_backend.Container
 	RestoredSnapshots   []io.Reader
+
+	restoreMutex sync.Mutex
 }
 
 func New() *FakeContainerPool {
@@ -43,15 +48,15 @@ func (p *FakeContainerPool) Setup() error {
 	return nil
 }
 
-func (p *FakeContainerPool) Prune(keep map[string]bool) error {
+func (p *FakeContainerPool) Prune(keep map[string]bool) ([]string, error) {
 	if p.PruneError != nil {
-		return p.PruneError
+		return nil, p.PruneError
 	}
 
 	p.Pruned = true
 	p.KeptContainers = keep
 
-	return nil
+	return p.PrunedContainers, nil
 }
 
 func (p *FakeContainerPool) Create(spec garden.ContainerSpec) (linux_backend.Container, error) {
@@ -82,6 +87,13 @@ func (p *FakeContainerPool) Create(spec garden.ContainerSpec) (linux_backend.Con
 }
 
 func (p *FakeContainerPool) Restore(snapshot io.Reader) (linux_backend.Container, error) {
+	if p.BeforeRestore != nil {
+		p.BeforeRestore()
+	}
+
+	p.restoreMutex.Lock()
+	defer p.restoreMutex.Unlock()
+
 	if p.RestoreError != nil {
 		return nil, p.RestoreError
 	}
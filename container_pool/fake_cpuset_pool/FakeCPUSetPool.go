@@ -0,0 +1,126 @@
+// This file was generated by counterfeiter
+package fake_cpuset_pool
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+type FakeCPUSetPool struct {
+	AcquireStub        func(cores int, node int) (*linux_backend.CPUSet, error)
+	acquireMutex       sync.RWMutex
+	acquireArgsForCall []struct {
+		cores int
+		node  int
+	}
+	acquireReturns struct {
+		result1 *linux_backend.CPUSet
+		result2 error
+	}
+	ReleaseStub        func(arg1 *linux_backend.CPUSet)
+	releaseMutex       sync.RWMutex
+	releaseArgsForCall []struct {
+		arg1 *linux_backend.CPUSet
+	}
+	RemoveStub        func(arg1 *linux_backend.CPUSet) error
+	removeMutex       sync.RWMutex
+	removeArgsForCall []struct {
+		arg1 *linux_backend.CPUSet
+	}
+	removeReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeCPUSetPool) Acquire(cores int, node int) (*linux_backend.CPUSet, error) {
+	fake.acquireMutex.Lock()
+	fake.acquireArgsForCall = append(fake.acquireArgsForCall, struct {
+		cores int
+		node  int
+	}{cores, node})
+	fake.acquireMutex.Unlock()
+	if fake.AcquireStub != nil {
+		return fake.AcquireStub(cores, node)
+	} else {
+		return fake.acquireReturns.result1, fake.acquireReturns.result2
+	}
+}
+
+func (fake *FakeCPUSetPool) AcquireCallCount() int {
+	fake.acquireMutex.RLock()
+	defer fake.acquireMutex.RUnlock()
+	return len(fake.acquireArgsForCall)
+}
+
+func (fake *FakeCPUSetPool) AcquireArgsForCall(i int) (int, int) {
+	fake.acquireMutex.RLock()
+	defer fake.acquireMutex.RUnlock()
+	return fake.acquireArgsForCall[i].cores, fake.acquireArgsForCall[i].node
+}
+
+func (fake *FakeCPUSetPool) AcquireReturns(result1 *linux_backend.CPUSet, result2 error) {
+	fake.AcquireStub = nil
+	fake.acquireReturns = struct {
+		result1 *linux_backend.CPUSet
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCPUSetPool) Release(arg1 *linux_backend.CPUSet) {
+	fake.releaseMutex.Lock()
+	fake.releaseArgsForCall = append(fake.releaseArgsForCall, struct {
+		arg1 *linux_backend.CPUSet
+	}{arg1})
+	fake.releaseMutex.Unlock()
+	if fake.ReleaseStub != nil {
+		fake.ReleaseStub(arg1)
+	}
+}
+
+func (fake *FakeCPUSetPool) ReleaseCallCount() int {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return len(fake.releaseArgsForCall)
+}
+
+func (fake *FakeCPUSetPool) ReleaseArgsForCall(i int) *linux_backend.CPUSet {
+	fake.releaseMutex.RLock()
+	defer fake.releaseMutex.RUnlock()
+	return fake.releaseArgsForCall[i].arg1
+}
+
+func (fake *FakeCPUSetPool) Remove(arg1 *linux_backend.CPUSet) error {
+	fake.removeMutex.Lock()
+	fake.removeArgsForCall = append(fake.removeArgsForCall, struct {
+		arg1 *linux_backend.CPUSet
+	}{arg1})
+	fake.removeMutex.Unlock()
+	if fake.RemoveStub != nil {
+		return fake.RemoveStub(arg1)
+	} else {
+		return fake.removeReturns.result1
+	}
+}
+
+func (fake *FakeCPUSetPool) RemoveCallCount() int {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return len(fake.removeArgsForCall)
+}
+
+func (fake *FakeCPUSetPool) RemoveArgsForCall(i int) *linux_backend.CPUSet {
+	fake.removeMutex.RLock()
+	defer fake.removeMutex.RUnlock()
+	return fake.removeArgsForCall[i].arg1
+}
+
+func (fake *FakeCPUSetPool) RemoveReturns(result1 error) {
+	fake.RemoveStub = nil
+	fake.removeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ container_pool.CPUSetPool = new(FakeCPUSetPool)
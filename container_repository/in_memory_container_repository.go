@@ -7,15 +7,27 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 )
 
+type changeNotifier interface {
+	OnChange(func())
+}
+
 type InMemoryContainerRepository struct {
 	store map[string]linux_backend.Container
 	mutex *sync.RWMutex
+
+	// property key -> handles of the containers which have it
+	keyIndex map[string]map[string]bool
+	// handle -> property keys it is indexed under
+	indexedKeys map[string][]string
 }
 
 func New() *InMemoryContainerRepository {
 	return &InMemoryContainerRepository{
 		store: map[string]linux_backend.Container{},
 		mutex: &sync.RWMutex{},
+
+		keyIndex:    map[string]map[string]bool{},
+		indexedKeys: map[string][]string{},
 	}
 }
 
@@ -29,10 +41,21 @@ func (cr *InMemoryContainerRepository) All() []linux_backend.Container {
 }
 
 func (cr *InMemoryContainerRepository) Add(container linux_backend.Container) {
+	cr.add(container)
+
+	if notifier, ok := container.(changeNotifier); ok {
+		notifier.OnChange(func() {
+			cr.reindex(container)
+		})
+	}
+}
+
+func (cr *InMemoryContainerRepository) add(container linux_backend.Container) {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
 
 	cr.store[container.Handle()] = container
+	cr.index(container)
 }
 
 func (cr *InMemoryContainerRepository) FindByHandle(handle string) (linux_backend.Container, error) {
@@ -48,10 +71,19 @@ func (cr *InMemoryContainerRepository) FindByHandle(handle string) (linux_backen
 }
 
 func (cr *InMemoryContainerRepository) Delete(container linux_backend.Container) {
+	if notifier, ok := container.(changeNotifier); ok {
+		notifier.OnChange(nil)
+	}
+
+	cr.delete(container)
+}
+
+func (cr *InMemoryContainerRepository) delete(container linux_backend.Container) {
 	cr.mutex.Lock()
 	defer cr.mutex.Unlock()
 
 	delete(cr.store, container.Handle())
+	cr.unindex(container.Handle())
 }
 
 func (cr *InMemoryContainerRepository) Query(filter func(linux_backend.Container) bool) []linux_backend.Container {
@@ -67,3 +99,89 @@ func (cr *InMemoryContainerRepository) Query(filter func(linux_backend.Container
 
 	return matches
 }
+
+// Select returns the containers whose properties match the selector. Only
+// the containers with the rarest of the properties the selector requires are
+// considered, rather than every container.
+func (cr *InMemoryContainerRepository) Select(selector linux_backend.Selector) []linux_backend.Container {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	var candidates map[string]bool
+	for _, key := range selector.RequiredKeys() {
+		handles := cr.keyIndex[key]
+		if candidates == nil || len(handles) < len(candidates) {
+			candidates = handles
+		}
+
+		if len(candidates) == 0 {
+			return nil
+		}
+	}
+
+	var matches []linux_backend.Container
+	match := func(c linux_backend.Container) {
+		properties, err := c.Properties()
+		if err == nil && selector.Matches(properties) {
+			matches = append(matches, c)
+		}
+	}
+
+	if candidates == nil {
+		for _, c := range cr.store {
+			match(c)
+		}
+	} else {
+		for handle := range candidates {
+			match(cr.store[handle])
+		}
+	}
+
+	return matches
+}
+
+func (cr *InMemoryContainerRepository) reindex(container linux_backend.Container) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	// the container may have been deleted while a change was being reported
+	if cr.store[container.Handle()] != container {
+		return
+	}
+
+	cr.unindex(container.Handle())
+	cr.index(container)
+}
+
+func (cr *InMemoryContainerRepository) index(container linux_backend.Container) {
+	properties, err := container.Properties()
+	if err != nil {
+		return
+	}
+
+	handle := container.Handle()
+
+	keys := []string{}
+	for key := range properties {
+		if cr.keyIndex[key] == nil {
+			cr.keyIndex[key] = map[string]bool{}
+		}
+
+		cr.keyIndex[key][handle] = true
+		keys = append(keys, key)
+	}
+
+	cr.indexedKeys[handle] = keys
+}
+
+func (cr *InMemoryContainerRepository) unindex(handle string) {
+	for _, key := range cr.indexedKeys[handle] {
+		delete(cr.keyIndex[key], handle)
+
+		if len(cr.keyIndex[key]) == 0 {
+			delete(cr.keyIndex, key)
+		}
+	}
+
+	delete(cr.indexedKeys, handle)
+}
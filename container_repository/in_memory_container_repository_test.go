@@ -0,0 +1,103 @@
+package container_repository_test
+
+import (
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InMemoryContainerRepository", func() {
+	var repo *container_repository.InMemoryContainerRepository
+
+	newContainer := func(handle string, properties garden.Properties) *notifyingContainer {
+		container := &notifyingContainer{FakeContainer: new(fakes.FakeContainer)}
+		container.IDReturns(handle)
+		container.HandleReturns(handle)
+		container.PropertiesStub = func() (garden.Properties, error) {
+			return properties, nil
+		}
+
+		return container
+	}
+
+	selectHandles := func(properties garden.Properties) []string {
+		selector, err := linux_backend.ParseSelector(properties)
+		Expect(err).ToNot(HaveOccurred())
+
+		handles := []string{}
+		for _, container := range repo.Select(selector) {
+			handles = append(handles, container.Handle())
+		}
+
+		return handles
+	}
+
+	BeforeEach(func() {
+		repo = container_repository.New()
+
+		repo.Add(newContainer("web-1", garden.Properties{"app": "web", "zone": "z1"}))
+		repo.Add(newContainer("web-2", garden.Properties{"app": "web", "zone": "z2"}))
+		repo.Add(newContainer("worker", garden.Properties{"app": "worker"}))
+	})
+
+	Describe("selecting containers", func() {
+		It("returns every container for an empty selector", func() {
+			Expect(selectHandles(nil)).To(ConsistOf("web-1", "web-2", "worker"))
+		})
+
+		It("returns the containers matching the selector", func() {
+			Expect(selectHandles(garden.Properties{"zone?": ""})).To(ConsistOf("web-1", "web-2"))
+			Expect(selectHandles(garden.Properties{"zone?": "false"})).To(ConsistOf("worker"))
+			Expect(selectHandles(garden.Properties{"app!=": "web"})).To(ConsistOf("worker"))
+			Expect(selectHandles(garden.Properties{"app": "web", "zone[]": "z2,z3"})).To(ConsistOf("web-2"))
+		})
+
+		It("returns nothing when no container has a required property", func() {
+			Expect(selectHandles(garden.Properties{"missing?": ""})).To(BeEmpty())
+		})
+
+		Context("when a container's properties change", func() {
+			It("selects it by its new properties", func() {
+				properties := garden.Properties{}
+				container := newContainer("changing", properties)
+				repo.Add(container)
+
+				Expect(selectHandles(garden.Properties{"owner?": ""})).To(BeEmpty())
+
+				properties["owner"] = "me"
+				container.listener()
+
+				Expect(selectHandles(garden.Properties{"owner?": ""})).To(ConsistOf("changing"))
+
+				delete(properties, "owner")
+				container.listener()
+
+				Expect(selectHandles(garden.Properties{"owner?": ""})).To(BeEmpty())
+			})
+		})
+
+		Context("when a container is deleted", func() {
+			It("is no longer selected", func() {
+				worker, err := repo.FindByHandle("worker")
+				Expect(err).ToNot(HaveOccurred())
+
+				repo.Delete(worker)
+
+				Expect(selectHandles(garden.Properties{"app?": ""})).To(ConsistOf("web-1", "web-2"))
+			})
+
+			It("stops listening for its changes", func() {
+				worker, err := repo.FindByHandle("worker")
+				Expect(err).ToNot(HaveOccurred())
+
+				repo.Delete(worker)
+
+				Expect(worker.(*notifyingContainer).listener).To(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package container_repository
+
+import (
+	"os"
+	"path"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// PersistentContainerRepository keeps a snapshot of every container it holds
+// in snapshotsPath, rewriting it whenever the container reports a change, so
+// that containers can be restored after the server dies without a clean stop.
+type PersistentContainerRepository struct {
+	*InMemoryContainerRepository
+
+	logger        lager.Logger
+	snapshotsPath string
+
+	persistMutex *sync.Mutex
+}
+
+func NewPersistent(logger lager.Logger, snapshotsPath string) *PersistentContainerRepository {
+	return &PersistentContainerRepository{
+		InMemoryContainerRepository: New(),
+
+		logger:        logger.Session("container-repository"),
+		snapshotsPath: snapshotsPath,
+
+		persistMutex: &sync.Mutex{},
+	}
+}
+
+func (cr *PersistentContainerRepository) Add(container linux_backend.Container) {
+	cr.InMemoryContainerRepository.add(container)
+
+	cr.persist(container)
+
+	if notifier, ok := container.(changeNotifier); ok {
+		notifier.OnChange(func() {
+			cr.reindex(container)
+			cr.persist(container)
+		})
+	}
+}
+
+func (cr *PersistentContainerRepository) Delete(container linux_backend.Container) {
+	if notifier, ok := container.(changeNotifier); ok {
+		notifier.OnChange(nil)
+	}
+
+	cr.persistMutex.Lock()
+	defer cr.persistMutex.Unlock()
+
+	cr.InMemoryContainerRepository.delete(container)
+
+	err := os.Remove(path.Join(cr.snapshotsPath, container.ID()))
+	if err != nil && !os.IsNotExist(err) {
+		cr.logger.Error("failed-to-remove-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+}
+
+func (cr *PersistentContainerRepository) persist(container linux_backend.Container) {
+	cr.persistMutex.Lock()
+	defer cr.persistMutex.Unlock()
+
+	// the container may have been deleted while a change was being reported
+	if found, err := cr.FindByHandle(container.Handle()); err != nil || found != container {
+		return
+	}
+
+	err := linux_backend.SaveSnapshot(container, path.Join(cr.snapshotsPath, container.ID()))
+	if err != nil {
+		cr.logger.Error("failed-to-save-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package container_repository_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+)
+
+type notifyingContainer struct {
+	*fakes.FakeContainer
+
+	listener func()
+}
+
+func (c *notifyingContainer) OnChange(listener func()) {
+	c.listener = listener
+}
+
+var _ = Describe("PersistentContainerRepository", func() {
+	var snapshotsPath string
+	var repo *container_repository.PersistentContainerRepository
+	var container *notifyingContainer
+	var snapshotContents string
+
+	BeforeEach(func() {
+		var err error
+		snapshotsPath, err = ioutil.TempDir("", "snapshots")
+		Expect(err).ToNot(HaveOccurred())
+
+		repo = container_repository.NewPersistent(lagertest.NewTestLogger("test"), snapshotsPath)
+
+		snapshotContents = "snapshot-1"
+
+		container = &notifyingContainer{FakeContainer: new(fakes.FakeContainer)}
+		container.IDReturns("some-id")
+		container.HandleReturns("some-handle")
+		container.SnapshotStub = func(w io.Writer) error {
+			_, err := w.Write([]byte(snapshotContents))
+			return err
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotsPath)
+	})
+
+	readSnapshot := func() string {
+		contents, err := ioutil.ReadFile(path.Join(snapshotsPath, "some-id"))
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	Describe("adding a container", func() {
+		It("registers it", func() {
+			repo.Add(container)
+
+			found, err := repo.FindByHandle("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(Equal(container))
+		})
+
+		It("writes its snapshot", func() {
+			repo.Add(container)
+
+			Expect(readSnapshot()).To(Equal("snapshot-1"))
+		})
+
+		Context("when the container changes", func() {
+			It("rewrites its snapshot", func() {
+				repo.Add(container)
+
+				snapshotContents = "snapshot-2"
+				container.listener()
+
+				Expect(readSnapshot()).To(Equal("snapshot-2"))
+			})
+
+			It("reindexes its properties", func() {
+				repo.Add(container)
+
+				container.PropertiesReturns(garden.Properties{"owner": "me"}, nil)
+				container.listener()
+
+				selector, err := linux_backend.ParseSelector(garden.Properties{"owner?": ""})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(repo.Select(selector)).To(HaveLen(1))
+			})
+		})
+
+		Context("when taking the snapshot fails", func() {
+			BeforeEach(func() {
+				container.SnapshotStub = nil
+				container.SnapshotReturns(errors.New("oh no!"))
+			})
+
+			It("still registers the container", func() {
+				repo.Add(container)
+
+				_, err := repo.FindByHandle("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("deleting a container", func() {
+		BeforeEach(func() {
+			repo.Add(container)
+		})
+
+		It("unregisters it", func() {
+			repo.Delete(container)
+
+			_, err := repo.FindByHandle("some-handle")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("removes its snapshot", func() {
+			repo.Delete(container)
+
+			_, err := os.Stat(path.Join(snapshotsPath, "some-id"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("stops listening for changes", func() {
+			repo.Delete(container)
+
+			Expect(container.listener).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,194 @@
+// The cpuset_pool package hands out whole cores to containers which need them
+// to themselves, so that no two pinned containers ever share a core.
+package cpuset_pool
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+// AnyNode lets Acquire choose the NUMA node.
+const AnyNode = -1
+
+// Core is a core which may be handed out, and the NUMA node it belongs to.
+type Core struct {
+	ID   int
+	Node int
+}
+
+type PoolExhaustedError struct {
+	Requested int
+	Node      int
+}
+
+func (e PoolExhaustedError) Error() string {
+	if e.Node == AnyNode {
+		return fmt.Sprintf("cpuset pool cannot allocate %d cores on any single NUMA node", e.Requested)
+	}
+
+	return fmt.Sprintf("cpuset pool cannot allocate %d cores on NUMA node %d", e.Requested, e.Node)
+}
+
+type CoreTakenError struct {
+	Core int
+}
+
+func (e CoreTakenError) Error() string {
+	return fmt.Sprintf("core already allocated: %d", e.Core)
+}
+
+type CoreNotInPoolError struct {
+	Core int
+}
+
+func (e CoreNotInPoolError) Error() string {
+	return fmt.Sprintf("core is not in the cpuset pool: %d", e.Core)
+}
+
+type InvalidCoreCountError struct {
+	Count int
+}
+
+func (e InvalidCoreCountError) Error() string {
+	return fmt.Sprintf("invalid number of cores: %d", e.Count)
+}
+
+type Pool struct {
+	cores []Core
+
+	allocated map[int]bool
+	mutex     sync.Mutex
+}
+
+// New returns a pool of the given cores, none of which are allocated.
+func New(cores []Core) *Pool {
+	return &Pool{
+		cores:     cores,
+		allocated: make(map[int]bool),
+	}
+}
+
+// Acquire allocates count cores, all from the same NUMA node so that the
+// container's memory stays local to them. The returned CPUSet's memory nodes
+// are that node. If node is AnyNode, the first node with enough unallocated
+// cores is used.
+func (p *Pool) Acquire(count int, node int) (*linux_backend.CPUSet, error) {
+	if count <= 0 {
+		return nil, InvalidCoreCountError{Count: count}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	nodes := []int{node}
+	if node == AnyNode {
+		nodes = p.nodes()
+	}
+
+	for _, n := range nodes {
+		free := []int{}
+		for _, core := range p.cores {
+			if core.Node == n && !p.allocated[core.ID] {
+				free = append(free, core.ID)
+			}
+		}
+
+		if len(free) < count {
+			continue
+		}
+
+		cpus := free[:count]
+		for _, id := range cpus {
+			p.allocated[id] = true
+		}
+
+		return &linux_backend.CPUSet{
+			CPUs: cpus,
+			Mems: []int{n},
+		}, nil
+	}
+
+	return nil, PoolExhaustedError{Requested: count, Node: node}
+}
+
+// Remove marks the cores of a restored container as allocated. Either all of
+// them are, or none are and an error is returned.
+func (p *Pool) Remove(set *linux_backend.CPUSet) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, id := range set.CPUs {
+		if !p.contains(id) {
+			return CoreNotInPoolError{Core: id}
+		}
+
+		if p.allocated[id] {
+			return CoreTakenError{Core: id}
+		}
+	}
+
+	for _, id := range set.CPUs {
+		p.allocated[id] = true
+	}
+
+	return nil
+}
+
+func (p *Pool) Release(set *linux_backend.CPUSet) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, id := range set.CPUs {
+		delete(p.allocated, id)
+	}
+}
+
+// Size returns how many cores the pool hands out in total.
+func (p *Pool) Size() int {
+	return len(p.cores)
+}
+
+// Unallocated returns the cores which are not pinned by any container.
+func (p *Pool) Unallocated() []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	unallocated := []int{}
+	for _, core := range p.cores {
+		if !p.allocated[core.ID] {
+			unallocated = append(unallocated, core.ID)
+		}
+	}
+
+	sort.Ints(unallocated)
+
+	return unallocated
+}
+
+func (p *Pool) contains(id int) bool {
+	for _, core := range p.cores {
+		if core.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodes returns the pool's NUMA nodes in the order their cores were given.
+func (p *Pool) nodes() []int {
+	seen := map[int]bool{}
+	nodes := []int{}
+
+	for _, core := range p.cores {
+		if !seen[core.Node] {
+			seen[core.Node] = true
+			nodes = append(nodes, core.Node)
+		}
+	}
+
+	return nodes
+}
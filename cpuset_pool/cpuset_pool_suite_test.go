@@ -0,0 +1,13 @@
+package cpuset_pool_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCPUSetPool(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CPUSet Pool Suite")
+}
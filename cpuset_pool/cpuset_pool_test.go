@@ -0,0 +1,128 @@
+package cpuset_pool_test
+
+import (
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CPUSet pool", func() {
+	var pool *cpuset_pool.Pool
+
+	BeforeEach(func() {
+		pool = cpuset_pool.New([]cpuset_pool.Core{
+			{ID: 2, Node: 0},
+			{ID: 3, Node: 0},
+			{ID: 4, Node: 1},
+			{ID: 5, Node: 1},
+			{ID: 6, Node: 1},
+		})
+	})
+
+	Describe("acquiring cores", func() {
+		It("hands out cores from a single node, with that node's memory", func() {
+			set, err := pool.Acquire(2, cpuset_pool.AnyNode)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).To(Equal(&linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{0}}))
+		})
+
+		It("never hands out the same core twice", func() {
+			first, err := pool.Acquire(2, cpuset_pool.AnyNode)
+			Expect(err).ToNot(HaveOccurred())
+
+			second, err := pool.Acquire(2, cpuset_pool.AnyNode)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(second.CPUs).To(Equal([]int{4, 5}))
+			Expect(second.CPUs).ToNot(ContainElement(first.CPUs[0]))
+			Expect(second.CPUs).ToNot(ContainElement(first.CPUs[1]))
+		})
+
+		It("moves on to the next node when one cannot fit the request", func() {
+			set, err := pool.Acquire(3, cpuset_pool.AnyNode)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(set).To(Equal(&linux_backend.CPUSet{CPUs: []int{4, 5, 6}, Mems: []int{1}}))
+		})
+
+		Context("when a node is given", func() {
+			It("hands out cores from that node", func() {
+				set, err := pool.Acquire(1, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(set).To(Equal(&linux_backend.CPUSet{CPUs: []int{4}, Mems: []int{1}}))
+			})
+
+			Context("and it does not have enough unallocated cores", func() {
+				It("returns a PoolExhaustedError", func() {
+					_, err := pool.Acquire(3, 0)
+					Expect(err).To(Equal(cpuset_pool.PoolExhaustedError{Requested: 3, Node: 0}))
+				})
+			})
+		})
+
+		Context("when no single node has enough unallocated cores", func() {
+			It("returns a PoolExhaustedError, allocating nothing", func() {
+				_, err := pool.Acquire(4, cpuset_pool.AnyNode)
+				Expect(err).To(Equal(cpuset_pool.PoolExhaustedError{Requested: 4, Node: cpuset_pool.AnyNode}))
+
+				Expect(pool.Unallocated()).To(Equal([]int{2, 3, 4, 5, 6}))
+			})
+		})
+
+		Context("when no cores are requested", func() {
+			It("returns an InvalidCoreCountError", func() {
+				_, err := pool.Acquire(0, cpuset_pool.AnyNode)
+				Expect(err).To(Equal(cpuset_pool.InvalidCoreCountError{Count: 0}))
+			})
+		})
+	})
+
+	Describe("releasing cores", func() {
+		It("makes them available again", func() {
+			set, err := pool.Acquire(2, 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			pool.Release(set)
+
+			Expect(pool.Unallocated()).To(Equal([]int{2, 3, 4, 5, 6}))
+
+			_, err = pool.Acquire(2, 0)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("removing cores", func() {
+		It("marks them as allocated", func() {
+			err := pool.Remove(&linux_backend.CPUSet{CPUs: []int{3, 5}, Mems: []int{0, 1}})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pool.Unallocated()).To(Equal([]int{2, 4, 6}))
+		})
+
+		Context("when a core is already allocated", func() {
+			It("returns a CoreTakenError, removing nothing", func() {
+				_, err := pool.Acquire(1, 0)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = pool.Remove(&linux_backend.CPUSet{CPUs: []int{4, 2}})
+				Expect(err).To(Equal(cpuset_pool.CoreTakenError{Core: 2}))
+
+				Expect(pool.Unallocated()).To(Equal([]int{3, 4, 5, 6}))
+			})
+		})
+
+		Context("when a core is not in the pool", func() {
+			It("returns a CoreNotInPoolError", func() {
+				err := pool.Remove(&linux_backend.CPUSet{CPUs: []int{0}})
+				Expect(err).To(Equal(cpuset_pool.CoreNotInPoolError{Core: 0}))
+			})
+		})
+	})
+
+	Describe("Size", func() {
+		It("returns the number of cores in the pool", func() {
+			Expect(pool.Size()).To(Equal(5))
+		})
+	})
+})
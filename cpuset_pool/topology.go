@@ -0,0 +1,110 @@
+package cpuset_pool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type InvalidListError struct {
+	List string
+}
+
+func (e InvalidListError) Error() string {
+	return fmt.Sprintf("invalid cpu list: %q", e.List)
+}
+
+type UnknownCoreError struct {
+	Core int
+}
+
+func (e UnknownCoreError) Error() string {
+	return fmt.Sprintf("core is not on any NUMA node: %d", e.Core)
+}
+
+// ParseList parses a list of ids in the kernel's cpulist format, such as
+// "0-3,6".
+func ParseList(list string) ([]int, error) {
+	ids := []int{}
+
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return ids, nil
+	}
+
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, InvalidListError{List: list}
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, InvalidListError{List: list}
+			}
+		}
+
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// Discover finds the NUMA node of each of the given cores from the node
+// directories under nodesPath, usually /sys/devices/system/node. Cores are
+// taken to be on node 0 if the kernel does not report NUMA nodes, and are
+// otherwise an error if they are on none of them.
+func Discover(nodesPath string, ids []int) ([]Core, error) {
+	nodeDirs, err := filepath.Glob(filepath.Join(nodesPath, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	nodeOf := map[int]int{}
+
+	for _, dir := range nodeDirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+
+		cpulist, err := ioutil.ReadFile(filepath.Join(dir, "cpulist"))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		cpus, err := ParseList(string(cpulist))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cpu := range cpus {
+			nodeOf[cpu] = node
+		}
+	}
+
+	cores := make([]Core, len(ids))
+	for i, id := range ids {
+		node, found := nodeOf[id]
+		if !found && len(nodeOf) > 0 {
+			return nil, UnknownCoreError{Core: id}
+		}
+
+		cores[i] = Core{ID: id, Node: node}
+	}
+
+	return cores, nil
+}
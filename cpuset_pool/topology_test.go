@@ -0,0 +1,83 @@
+package cpuset_pool_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Topology", func() {
+	Describe("ParseList", func() {
+		It("parses single ids and ranges", func() {
+			Expect(cpuset_pool.ParseList("0-3,6\n")).To(Equal([]int{0, 1, 2, 3, 6}))
+		})
+
+		It("parses an empty list", func() {
+			Expect(cpuset_pool.ParseList("")).To(BeEmpty())
+		})
+
+		Context("when the list is malformed", func() {
+			It("returns an InvalidListError", func() {
+				for _, list := range []string{"a", "3-1", "1,,2", "-1"} {
+					_, err := cpuset_pool.ParseList(list)
+					Expect(err).To(Equal(cpuset_pool.InvalidListError{List: list}))
+				}
+			})
+		})
+	})
+
+	Describe("Discover", func() {
+		var nodesPath string
+
+		BeforeEach(func() {
+			var err error
+			nodesPath, err = ioutil.TempDir("", "nodes")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(nodesPath)
+		})
+
+		writeNode := func(name, cpulist string) {
+			Expect(os.MkdirAll(filepath.Join(nodesPath, name), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(nodesPath, name, "cpulist"), []byte(cpulist), 0644)).To(Succeed())
+		}
+
+		Context("when the kernel reports NUMA nodes", func() {
+			BeforeEach(func() {
+				writeNode("node0", "0-1\n")
+				writeNode("node1", "2-3\n")
+			})
+
+			It("finds the node of each core", func() {
+				Expect(cpuset_pool.Discover(nodesPath, []int{1, 2, 3})).To(Equal([]cpuset_pool.Core{
+					{ID: 1, Node: 0},
+					{ID: 2, Node: 1},
+					{ID: 3, Node: 1},
+				}))
+			})
+
+			Context("when a core is on none of them", func() {
+				It("returns an UnknownCoreError", func() {
+					_, err := cpuset_pool.Discover(nodesPath, []int{1, 7})
+					Expect(err).To(Equal(cpuset_pool.UnknownCoreError{Core: 7}))
+				})
+			})
+		})
+
+		Context("when the kernel does not report NUMA nodes", func() {
+			It("puts every core on node 0", func() {
+				Expect(cpuset_pool.Discover(nodesPath, []int{0, 1})).To(Equal([]cpuset_pool.Core{
+					{ID: 0, Node: 0},
+					{ID: 1, Node: 0},
+				}))
+			})
+		})
+	})
+})
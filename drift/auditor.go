@@ -0,0 +1,563 @@
+package drift
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider"
+	"github.com/cloudfoundry/gunk/command_runner"
+	"github.com/pivotal-golang/lager"
+)
+
+type Kind string
+
+const (
+	DepotEntry          Kind = "depot-entry"
+	Cgroup              Kind = "cgroup"
+	IPTablesFilterChain Kind = "iptables-filter-chain"
+	IPTablesNATChain    Kind = "iptables-nat-chain"
+	Bridge              Kind = "bridge"
+	Overlay             Kind = "overlay"
+)
+
+type Problem string
+
+const (
+	// Orphaned host state belongs to no container in the repository.
+	Orphaned Problem = "orphaned"
+
+	// Missing host state is expected by a container in the repository but
+	// cannot be found.
+	Missing Problem = "missing"
+)
+
+type Mismatch struct {
+	Kind        Kind
+	Name        string
+	ContainerID string `json:",omitempty"`
+	Problem     Problem
+}
+
+type Report struct {
+	Mismatches []Mismatch
+}
+
+type RepairFailure struct {
+	Mismatch
+	Error string
+}
+
+// RepairReport lists the orphaned host state which was removed, or could not
+// be, and whatever drift remains afterwards. Missing host state is never
+// repaired; the affected containers should be destroyed and recreated.
+type RepairReport struct {
+	Repaired  []Mismatch
+	Failed    []RepairFailure
+	Remaining []Mismatch
+}
+
+type Containers interface {
+	All() []linux_backend.Container
+}
+
+type Quarantine interface {
+	Quarantined() ([]linux_backend.QuarantinedSnapshot, error)
+}
+
+// Creates holds back operations which add containers, whose host state
+// exists before they are in the repository.
+type Creates interface {
+	HoldCreates() (release func(), err error)
+}
+
+type Pruner interface {
+	Prune(keep map[string]bool) ([]string, error)
+}
+
+// Auditor compares the containers in the repository with the state they
+// leave on the host: depot entries, cgroups, iptables chains, bridges and
+// overlay mounts.
+//
+// Containers are registered in the repository only once they have been
+// created, so depot entries younger than gracePeriod, and any host state
+// belonging to them, are assumed to be in the middle of being created and
+// are left alone. Repair goes further, and refuses to run while any
+// container is being created.
+type Auditor struct {
+	logger lager.Logger
+
+	containers Containers
+	quarantine Quarantine
+	creates    Creates
+
+	depotPath    string
+	cgroupPath   string
+	subsystems   []string
+	overlaysPath string
+
+	instanceChainPrefix string
+	bridgePrefix        string
+
+	runner          command_runner.CommandRunner
+	bridgeLister    bridgemgr.Lister
+	pruner          Pruner
+	bridges         bridgemgr.BridgeManager
+	filterProvider  container_pool.FilterProvider
+	overlayProvider rootfs_provider.RootFSProvider
+
+	gracePeriod time.Duration
+}
+
+func New(
+	logger lager.Logger,
+	containers Containers,
+	quarantine Quarantine,
+	creates Creates,
+	depotPath string,
+	cgroupPath string,
+	subsystems []string,
+	overlaysPath string,
+	instanceChainPrefix string,
+	bridgePrefix string,
+	runner command_runner.CommandRunner,
+	bridgeLister bridgemgr.Lister,
+	pruner Pruner,
+	bridges bridgemgr.BridgeManager,
+	filterProvider container_pool.FilterProvider,
+	overlayProvider rootfs_provider.RootFSProvider,
+	gracePeriod time.Duration,
+) *Auditor {
+	return &Auditor{
+		logger: logger.Session("drift"),
+
+		containers: containers,
+		quarantine: quarantine,
+		creates:    creates,
+
+		depotPath:    depotPath,
+		cgroupPath:   cgroupPath,
+		subsystems:   subsystems,
+		overlaysPath: overlaysPath,
+
+		instanceChainPrefix: instanceChainPrefix,
+		bridgePrefix:        bridgePrefix,
+
+		runner:          runner,
+		bridgeLister:    bridgeLister,
+		pruner:          pruner,
+		bridges:         bridges,
+		filterProvider:  filterProvider,
+		overlayProvider: overlayProvider,
+
+		gracePeriod: gracePeriod,
+	}
+}
+
+func (a *Auditor) Audit() (Report, error) {
+	host, err := a.readHostState()
+	if err != nil {
+		return Report{}, err
+	}
+
+	return Report{Mismatches: host.mismatches()}, nil
+}
+
+// Repair removes orphaned host state. Orphaned depot entries are pruned from
+// the container pool first, as that releases everything the container held;
+// whatever is left over is then removed piece by piece.
+//
+// Creates are held until the repair is done, and it fails if any are
+// already in flight, so that nothing a new container has set up is taken
+// for orphaned state however long it has taken.
+func (a *Auditor) Repair() (RepairReport, error) {
+	rLog := a.logger.Session("repair")
+
+	release, err := a.creates.HoldCreates()
+	if err != nil {
+		rLog.Error("failed-to-hold-creates", err)
+		return RepairReport{}, err
+	}
+
+	defer release()
+
+	host, err := a.readHostState()
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	before := host.mismatches()
+	failed := map[Mismatch]error{}
+
+	if orphans := host.orphanedDepotEntries(); len(orphans) > 0 {
+		keep := map[string]bool{}
+		for id := range host.depotEntries {
+			if !orphans[id] {
+				keep[id] = true
+			}
+		}
+
+		rLog.Info("pruning-depot-entries", lager.Data{"orphans": len(orphans)})
+
+		if _, err := a.pruner.Prune(keep); err != nil {
+			rLog.Error("failed-to-prune-depot-entries", err)
+		}
+
+		host, err = a.readHostState()
+		if err != nil {
+			return RepairReport{}, err
+		}
+	}
+
+	pruneBridges := false
+
+	for _, mismatch := range host.mismatches() {
+		if mismatch.Problem != Orphaned {
+			continue
+		}
+
+		var err error
+
+		switch mismatch.Kind {
+		case DepotEntry:
+			err = fmt.Errorf("depot entry was not pruned")
+
+		case IPTablesFilterChain:
+			if strings.HasSuffix(mismatch.Name, "-log") {
+				a.filterProvider.ProvideFilter(mismatch.ContainerID).TearDown()
+			} else {
+				err = iptables.DeleteChain(a.runner, iptables.Filter, mismatch.Name)
+			}
+
+		case IPTablesNATChain:
+			err = iptables.DeleteChain(a.runner, iptables.Nat, mismatch.Name)
+
+		case Cgroup:
+			err = cgroups_manager.New(a.cgroupPath, mismatch.ContainerID).Destroy(path.Base(path.Dir(mismatch.Name)))
+
+		case Overlay:
+			err = a.overlayProvider.CleanupRootFS(rLog, mismatch.ContainerID)
+
+		case Bridge:
+			pruneBridges = true
+		}
+
+		if err != nil {
+			rLog.Error("failed-to-repair", err, lager.Data{"mismatch": mismatch})
+			failed[mismatch] = err
+		}
+	}
+
+	if pruneBridges {
+		if err := a.bridges.Prune(); err != nil {
+			rLog.Error("failed-to-prune-bridges", err)
+		}
+	}
+
+	after, err := a.Audit()
+	if err != nil {
+		return RepairReport{}, err
+	}
+
+	remaining := map[Mismatch]bool{}
+	for _, mismatch := range after.Mismatches {
+		remaining[mismatch] = true
+	}
+
+	report := RepairReport{
+		Repaired:  []Mismatch{},
+		Failed:    []RepairFailure{},
+		Remaining: after.Mismatches,
+	}
+
+	for _, mismatch := range before {
+		if mismatch.Problem != Orphaned {
+			continue
+		}
+
+		if !remaining[mismatch] {
+			report.Repaired = append(report.Repaired, mismatch)
+			continue
+		}
+
+		failure := RepairFailure{Mismatch: mismatch, Error: "still present after repair"}
+		if err, found := failed[mismatch]; found {
+			failure.Error = err.Error()
+		}
+
+		report.Failed = append(report.Failed, failure)
+	}
+
+	rLog.Info("repaired", lager.Data{
+		"repaired":  len(report.Repaired),
+		"failed":    len(report.Failed),
+		"remaining": len(report.Remaining),
+	})
+
+	return report, nil
+}
+
+type hostState struct {
+	known    map[string]bool
+	retained map[string]bool
+
+	depotEntries map[string]bool
+	cgroups      map[string]map[string]bool // subsystem -> container id
+	filterChains map[string]bool
+	natChains    map[string]bool
+	bridges      map[string]bool
+	overlays     map[string]bool
+
+	// per depot entry, as recorded by the container pool
+	bridgeNames     map[string]string
+	rootfsProviders map[string]string
+
+	auditor *Auditor
+}
+
+func (a *Auditor) readHostState() (*hostState, error) {
+	host := &hostState{
+		known:    map[string]bool{},
+		retained: map[string]bool{},
+
+		cgroups: map[string]map[string]bool{},
+
+		bridgeNames:     map[string]string{},
+		rootfsProviders: map[string]string{},
+
+		auditor: a,
+	}
+
+	for _, container := range a.containers.All() {
+		host.known[container.ID()] = true
+	}
+
+	quarantined, err := a.quarantine.Quarantined()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range quarantined {
+		host.retained[snapshot.ID] = true
+	}
+
+	entries, err := ioutil.ReadDir(a.depotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	host.depotEntries = map[string]bool{}
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || id == "tmp" { // ignore temporary directory in depotPath
+			continue
+		}
+
+		host.depotEntries[id] = true
+
+		if time.Since(entry.ModTime()) < a.gracePeriod {
+			host.retained[id] = true
+		}
+
+		if bridgeName, err := ioutil.ReadFile(path.Join(a.depotPath, id, "bridge-name")); err == nil {
+			host.bridgeNames[id] = string(bridgeName)
+		}
+
+		if provider, err := ioutil.ReadFile(path.Join(a.depotPath, id, "rootfs-provider")); err == nil {
+			host.rootfsProviders[id] = string(provider)
+		}
+	}
+
+	for _, subsystem := range a.subsystems {
+		host.cgroups[subsystem], err = listDirs(path.Join(a.cgroupPath, subsystem), "instance-")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filterChains, err := iptables.ListChains(a.runner, iptables.Filter, a.instanceChainPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	host.filterChains = toSet(filterChains)
+
+	natChains, err := iptables.ListChains(a.runner, iptables.Nat, a.instanceChainPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	host.natChains = toSet(natChains)
+
+	bridges, err := a.bridgeLister.List()
+	if err != nil {
+		return nil, err
+	}
+
+	host.bridges = map[string]bool{}
+	for _, bridge := range bridges {
+		if strings.HasPrefix(bridge, a.bridgePrefix) {
+			host.bridges[bridge] = true
+		}
+	}
+
+	host.overlays, err = listDirs(a.overlaysPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return host, nil
+}
+
+func (h *hostState) owned(id string) bool {
+	return h.known[id] || h.retained[id]
+}
+
+func (h *hostState) orphanedDepotEntries() map[string]bool {
+	orphans := map[string]bool{}
+	for id := range h.depotEntries {
+		if !h.owned(id) {
+			orphans[id] = true
+		}
+	}
+
+	return orphans
+}
+
+func (h *hostState) mismatches() []Mismatch {
+	a := h.auditor
+	mismatches := []Mismatch{}
+
+	orphaned := func(kind Kind, name, id string) {
+		mismatches = append(mismatches, Mismatch{Kind: kind, Name: name, ContainerID: id, Problem: Orphaned})
+	}
+
+	missing := func(kind Kind, name, id string) {
+		mismatches = append(mismatches, Mismatch{Kind: kind, Name: name, ContainerID: id, Problem: Missing})
+	}
+
+	for _, id := range sortedKeys(h.depotEntries) {
+		if !h.owned(id) {
+			orphaned(DepotEntry, path.Join(a.depotPath, id), id)
+		}
+	}
+
+	for _, subsystem := range a.subsystems {
+		for _, id := range sortedKeys(h.cgroups[subsystem]) {
+			if !h.owned(id) {
+				orphaned(Cgroup, cgroups_manager.New(a.cgroupPath, id).SubsystemPath(subsystem), id)
+			}
+		}
+	}
+
+	for _, chain := range sortedKeys(h.filterChains) {
+		if id := strings.TrimSuffix(strings.TrimPrefix(chain, a.instanceChainPrefix), "-log"); !h.owned(id) {
+			orphaned(IPTablesFilterChain, chain, id)
+		}
+	}
+
+	for _, chain := range sortedKeys(h.natChains) {
+		if id := strings.TrimPrefix(chain, a.instanceChainPrefix); !h.owned(id) {
+			orphaned(IPTablesNATChain, chain, id)
+		}
+	}
+
+	expectedBridges := map[string]bool{}
+	for id, bridge := range h.bridgeNames {
+		if h.owned(id) {
+			expectedBridges[bridge] = true
+		}
+	}
+
+	for _, bridge := range sortedKeys(h.bridges) {
+		if !expectedBridges[bridge] {
+			orphaned(Bridge, bridge, "")
+		}
+	}
+
+	for _, id := range sortedKeys(h.overlays) {
+		if !h.owned(id) {
+			orphaned(Overlay, path.Join(a.overlaysPath, id), id)
+		}
+	}
+
+	for _, id := range sortedKeys(h.known) {
+		if !h.depotEntries[id] {
+			missing(DepotEntry, path.Join(a.depotPath, id), id)
+		}
+
+		for _, subsystem := range a.subsystems {
+			if !h.cgroups[subsystem][id] {
+				missing(Cgroup, cgroups_manager.New(a.cgroupPath, id).SubsystemPath(subsystem), id)
+			}
+		}
+
+		for _, chain := range []string{a.instanceChainPrefix + id, a.instanceChainPrefix + id + "-log"} {
+			if !h.filterChains[chain] {
+				missing(IPTablesFilterChain, chain, id)
+			}
+		}
+
+		if chain := a.instanceChainPrefix + id; !h.natChains[chain] {
+			missing(IPTablesNATChain, chain, id)
+		}
+
+		if bridge, found := h.bridgeNames[id]; found && !h.bridges[bridge] {
+			missing(Bridge, bridge, id)
+		}
+
+		if h.depotEntries[id] && h.rootfsProviders[id] == "" && !h.overlays[id] {
+			missing(Overlay, path.Join(a.overlaysPath, id), id)
+		}
+	}
+
+	return mismatches
+}
+
+func listDirs(dir, prefix string) (map[string]bool, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			dirs[strings.TrimPrefix(entry.Name(), prefix)] = true
+		}
+	}
+
+	return dirs, nil
+}
+
+func toSet(names []string) map[string]bool {
+	set := map[string]bool{}
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,471 @@
+package drift_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/drift"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr/fake_bridge_manager"
+	network_fakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider/fake_rootfs_provider"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	"github.com/pivotal-golang/lager"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeQuarantine struct {
+	ids []string
+	err error
+}
+
+func (q *fakeQuarantine) Quarantined() ([]linux_backend.QuarantinedSnapshot, error) {
+	quarantined := []linux_backend.QuarantinedSnapshot{}
+	for _, id := range q.ids {
+		quarantined = append(quarantined, linux_backend.QuarantinedSnapshot{ID: id})
+	}
+
+	return quarantined, q.err
+}
+
+type fakeCreates struct {
+	held     bool
+	released bool
+	err      error
+}
+
+func (c *fakeCreates) HoldCreates() (func(), error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.held = true
+
+	return func() { c.released = true }, nil
+}
+
+type fakeLister struct {
+	bridges map[string]bool
+}
+
+func (l *fakeLister) List() ([]string, error) {
+	bridges := []string{}
+	for bridge := range l.bridges {
+		bridges = append(bridges, bridge)
+	}
+
+	sort.Strings(bridges)
+	return bridges, nil
+}
+
+// fakePruner removes the depot entries it is not told to keep, as the
+// container pool does
+type fakePruner struct {
+	depotPath string
+	kept      map[string]bool
+}
+
+func (p *fakePruner) Prune(keep map[string]bool) ([]string, error) {
+	p.kept = keep
+
+	entries, err := ioutil.ReadDir(p.depotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pruned := []string{}
+	for _, entry := range entries {
+		if !keep[entry.Name()] {
+			os.RemoveAll(path.Join(p.depotPath, entry.Name()))
+			pruned = append(pruned, entry.Name())
+		}
+	}
+
+	return pruned, nil
+}
+
+var _ = Describe("Auditor", func() {
+	var (
+		depotPath    string
+		cgroupPath   string
+		overlaysPath string
+
+		repo           *container_repository.InMemoryContainerRepository
+		quarantine     *fakeQuarantine
+		creates        *fakeCreates
+		fakeRunner     *fake_command_runner.FakeCommandRunner
+		lister         *fakeLister
+		pruner         *fakePruner
+		fakeBridges    *fake_bridge_manager.FakeBridgeManager
+		fakeFilter     *network_fakes.FakeFilter
+		filterProvider *fake_container_pool.FakeFilterProvider
+		overlays       *fake_rootfs_provider.FakeRootFSProvider
+
+		chains map[string]map[string]bool // table -> chain
+
+		auditor *drift.Auditor
+	)
+
+	longAgo := time.Now().Add(-time.Hour)
+
+	addDepotEntry := func(id, bridge string, modified time.Time) {
+		entry := path.Join(depotPath, id)
+		Expect(os.MkdirAll(entry, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(entry, "bridge-name"), []byte(bridge), 0644)).To(Succeed())
+		Expect(os.Chtimes(entry, modified, modified)).To(Succeed())
+	}
+
+	addHostState := func(id string) {
+		Expect(os.MkdirAll(path.Join(cgroupPath, "memory", "instance-"+id), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(overlaysPath, id), 0755)).To(Succeed())
+
+		chains["filter"]["w-0-instance-"+id] = true
+		chains["filter"]["w-0-instance-"+id+"-log"] = true
+		chains["nat"]["w-0-instance-"+id] = true
+	}
+
+	addContainer := func(id string) {
+		container := new(fakes.FakeContainer)
+		container.IDReturns(id)
+		container.HandleReturns("handle-" + id)
+
+		repo.Add(container)
+	}
+
+	orphaned := func(kind drift.Kind, name, id string) drift.Mismatch {
+		return drift.Mismatch{Kind: kind, Name: name, ContainerID: id, Problem: drift.Orphaned}
+	}
+
+	missing := func(kind drift.Kind, name, id string) drift.Mismatch {
+		return drift.Mismatch{Kind: kind, Name: name, ContainerID: id, Problem: drift.Missing}
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		depotPath, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+
+		cgroupPath, err = ioutil.TempDir("", "cgroup")
+		Expect(err).ToNot(HaveOccurred())
+
+		overlaysPath, err = ioutil.TempDir("", "overlays")
+		Expect(err).ToNot(HaveOccurred())
+
+		repo = container_repository.New()
+		quarantine = &fakeQuarantine{}
+		creates = &fakeCreates{}
+		lister = &fakeLister{bridges: map[string]bool{"eth0": true}}
+		pruner = &fakePruner{depotPath: depotPath}
+		fakeBridges = new(fake_bridge_manager.FakeBridgeManager)
+
+		fakeFilter = new(network_fakes.FakeFilter)
+		filterProvider = new(fake_container_pool.FakeFilterProvider)
+		filterProvider.ProvideFilterStub = func(id string) network.Filter {
+			fakeFilter.TearDownStub = func() {
+				delete(chains["filter"], "w-0-instance-"+id+"-log")
+			}
+
+			return fakeFilter
+		}
+
+		overlays = new(fake_rootfs_provider.FakeRootFSProvider)
+		overlays.CleanupRootFSStub = func(logger lager.Logger, id string) error {
+			return os.RemoveAll(path.Join(overlaysPath, id))
+		}
+
+		chains = map[string]map[string]bool{"filter": {}, "nat": {}}
+
+		fakeRunner = fake_command_runner.New()
+		fakeRunner.WhenRunning(
+			fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+			func(cmd *exec.Cmd) error {
+				table := cmd.Args[3]
+
+				switch cmd.Args[4] {
+				case "-S":
+					names := []string{}
+					for chain := range chains[table] {
+						names = append(names, chain)
+					}
+
+					sort.Strings(names)
+					for _, chain := range names {
+						fmt.Fprintf(cmd.Stdout, "-N %s\n", chain)
+					}
+
+				case "-X":
+					delete(chains[table], cmd.Args[5])
+				}
+
+				return nil
+			},
+		)
+
+		auditor = drift.New(
+			lagertest.NewTestLogger("test"),
+			repo,
+			quarantine,
+			creates,
+			depotPath,
+			cgroupPath,
+			[]string{"memory"},
+			overlaysPath,
+			"w-0-instance-",
+			"w0b-",
+			fakeRunner,
+			lister,
+			pruner,
+			fakeBridges,
+			filterProvider,
+			overlays,
+			time.Minute,
+		)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(depotPath)
+		os.RemoveAll(cgroupPath)
+		os.RemoveAll(overlaysPath)
+	})
+
+	Context("when the host matches the repository", func() {
+		BeforeEach(func() {
+			addContainer("some-id")
+			addDepotEntry("some-id", "w0b-some-bridge", longAgo)
+			addHostState("some-id")
+			lister.bridges["w0b-some-bridge"] = true
+		})
+
+		It("reports no drift", func() {
+			report, err := auditor.Audit()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Mismatches).To(BeEmpty())
+		})
+	})
+
+	Context("when the host has state for containers not in the repository", func() {
+		BeforeEach(func() {
+			addDepotEntry("orphan-id", "w0b-orphan-bridge", longAgo)
+			addHostState("orphan-id")
+			lister.bridges["w0b-orphan-bridge"] = true
+
+			addHostState("no-depot-id")
+		})
+
+		It("reports the state as orphaned", func() {
+			report, err := auditor.Audit()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Mismatches).To(Equal([]drift.Mismatch{
+				orphaned(drift.DepotEntry, path.Join(depotPath, "orphan-id"), "orphan-id"),
+				orphaned(drift.Cgroup, path.Join(cgroupPath, "memory", "instance-no-depot-id"), "no-depot-id"),
+				orphaned(drift.Cgroup, path.Join(cgroupPath, "memory", "instance-orphan-id"), "orphan-id"),
+				orphaned(drift.IPTablesFilterChain, "w-0-instance-no-depot-id", "no-depot-id"),
+				orphaned(drift.IPTablesFilterChain, "w-0-instance-no-depot-id-log", "no-depot-id"),
+				orphaned(drift.IPTablesFilterChain, "w-0-instance-orphan-id", "orphan-id"),
+				orphaned(drift.IPTablesFilterChain, "w-0-instance-orphan-id-log", "orphan-id"),
+				orphaned(drift.IPTablesNATChain, "w-0-instance-no-depot-id", "no-depot-id"),
+				orphaned(drift.IPTablesNATChain, "w-0-instance-orphan-id", "orphan-id"),
+				orphaned(drift.Bridge, "w0b-orphan-bridge", ""),
+				orphaned(drift.Overlay, path.Join(overlaysPath, "no-depot-id"), "no-depot-id"),
+				orphaned(drift.Overlay, path.Join(overlaysPath, "orphan-id"), "orphan-id"),
+			}))
+		})
+
+		Context("but the container's snapshot is quarantined", func() {
+			BeforeEach(func() {
+				quarantine.ids = []string{"orphan-id", "no-depot-id"}
+			})
+
+			It("does not report its state", func() {
+				report, err := auditor.Audit()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(report.Mismatches).To(BeEmpty())
+			})
+		})
+
+		Context("but the container's depot entry was only just created", func() {
+			BeforeEach(func() {
+				addDepotEntry("creating-id", "w0b-creating-bridge", time.Now())
+				addHostState("creating-id")
+				lister.bridges["w0b-creating-bridge"] = true
+			})
+
+			It("assumes the container is still being created", func() {
+				report, err := auditor.Audit()
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, mismatch := range report.Mismatches {
+					Expect(mismatch.ContainerID).ToNot(Equal("creating-id"))
+					Expect(mismatch.Name).ToNot(Equal("w0b-creating-bridge"))
+				}
+			})
+		})
+
+		Describe("repairing", func() {
+			It("prunes orphaned depot entries through the container pool", func() {
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(pruner.kept).To(BeEmpty())
+
+				_, err = os.Stat(path.Join(depotPath, "orphan-id"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+
+			It("holds creates until it is done", func() {
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(creates.held).To(BeTrue())
+				Expect(creates.released).To(BeTrue())
+			})
+
+			Context("when containers are being created", func() {
+				BeforeEach(func() {
+					creates.err = linux_backend.CreatesInFlightError{Count: 1}
+				})
+
+				It("returns the error without repairing anything", func() {
+					_, err := auditor.Repair()
+					Expect(err).To(Equal(linux_backend.CreatesInFlightError{Count: 1}))
+
+					_, err = os.Stat(path.Join(depotPath, "orphan-id"))
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(chains["nat"]).To(HaveKey("w-0-instance-orphan-id"))
+				})
+			})
+
+			It("deletes orphaned iptables chains", func() {
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(chains["filter"]).ToNot(HaveKey("w-0-instance-orphan-id"))
+				Expect(chains["filter"]).ToNot(HaveKey("w-0-instance-orphan-id-log"))
+				Expect(chains["nat"]).ToNot(HaveKey("w-0-instance-orphan-id"))
+
+				Expect(filterProvider.ProvideFilterCallCount()).To(Equal(2))
+				Expect(fakeFilter.TearDownCallCount()).To(Equal(2))
+			})
+
+			It("destroys orphaned cgroups", func() {
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = os.Stat(path.Join(cgroupPath, "memory", "instance-orphan-id"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+
+			It("cleans up orphaned overlays through the rootfs provider", func() {
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(overlays.CleanupRootFSCallCount()).To(Equal(2))
+
+				_, ids := overlays.CleanupRootFSArgsForCall(0)
+				Expect(ids).To(Equal("no-depot-id"))
+			})
+
+			It("prunes orphaned bridges through the bridge manager", func() {
+				fakeBridges.PruneStub = func() error {
+					delete(lister.bridges, "w0b-orphan-bridge")
+					return nil
+				}
+
+				_, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeBridges.PruneCallCount()).To(Equal(1))
+			})
+
+			It("reports what was repaired, and what could not be", func() {
+				overlays.CleanupRootFSStub = func(logger lager.Logger, id string) error {
+					return errors.New("device busy")
+				}
+
+				report, err := auditor.Repair()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(report.Repaired).To(ContainElement(orphaned(drift.DepotEntry, path.Join(depotPath, "orphan-id"), "orphan-id")))
+				Expect(report.Repaired).To(ContainElement(orphaned(drift.IPTablesNATChain, "w-0-instance-orphan-id", "orphan-id")))
+
+				Expect(report.Failed).To(ContainElement(drift.RepairFailure{
+					Mismatch: orphaned(drift.Overlay, path.Join(overlaysPath, "orphan-id"), "orphan-id"),
+					Error:    "device busy",
+				}))
+
+				Expect(report.Failed).To(ContainElement(drift.RepairFailure{
+					Mismatch: orphaned(drift.Bridge, "w0b-orphan-bridge", ""),
+					Error:    "still present after repair",
+				}))
+
+				Expect(report.Remaining).To(ConsistOf(
+					orphaned(drift.Bridge, "w0b-orphan-bridge", ""),
+					orphaned(drift.Overlay, path.Join(overlaysPath, "no-depot-id"), "no-depot-id"),
+					orphaned(drift.Overlay, path.Join(overlaysPath, "orphan-id"), "orphan-id"),
+				))
+			})
+		})
+	})
+
+	Context("when a container in the repository is missing host state", func() {
+		BeforeEach(func() {
+			addContainer("some-id")
+			addDepotEntry("some-id", "w0b-some-bridge", longAgo)
+		})
+
+		It("reports the state as missing", func() {
+			report, err := auditor.Audit()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Mismatches).To(Equal([]drift.Mismatch{
+				missing(drift.Cgroup, path.Join(cgroupPath, "memory", "instance-some-id"), "some-id"),
+				missing(drift.IPTablesFilterChain, "w-0-instance-some-id", "some-id"),
+				missing(drift.IPTablesFilterChain, "w-0-instance-some-id-log", "some-id"),
+				missing(drift.IPTablesNATChain, "w-0-instance-some-id", "some-id"),
+				missing(drift.Bridge, "w0b-some-bridge", "some-id"),
+				missing(drift.Overlay, path.Join(overlaysPath, "some-id"), "some-id"),
+			}))
+		})
+
+		It("does not try to repair it", func() {
+			report, err := auditor.Repair()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Repaired).To(BeEmpty())
+			Expect(report.Failed).To(BeEmpty())
+			Expect(report.Remaining).To(HaveLen(6))
+			Expect(fakeRunner).ToNot(HaveExecutedSerially(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-t", "filter", "-X", "w-0-instance-some-id"},
+			}))
+		})
+	})
+
+	Context("when listing quarantined snapshots fails", func() {
+		BeforeEach(func() {
+			quarantine.err = errors.New("oh no")
+		})
+
+		It("returns the error", func() {
+			_, err := auditor.Audit()
+			Expect(err).To(MatchError("oh no"))
+		})
+	})
+})
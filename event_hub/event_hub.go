@@ -0,0 +1,138 @@
+package event_hub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type EventType string
+
+const (
+	Created       EventType = "create"
+	Started       EventType = "start"
+	Stopped       EventType = "stop"
+	Destroyed     EventType = "destroy"
+	OutOfMemory   EventType = "oom"
+	ProcessExited EventType = "process-exit"
+	LimitChanged  EventType = "limit-change"
+	Restored      EventType = "restore"
+	Paused        EventType = "pause"
+	Resumed       EventType = "resume"
+
+	PidsLimitReached EventType = "pids-limit-reached"
+	MemoryPressure   EventType = "memory-pressure"
+	MemoryThreshold  EventType = "memory-threshold"
+	LimitMismatch    EventType = "limit-mismatch"
+	StopPhase        EventType = "stop-phase"
+)
+
+type Event struct {
+	Type   EventType
+	Handle string
+	Time   time.Time
+	Data   map[string]string `json:",omitempty"`
+}
+
+// ErrSlowSubscriber is the reason a subscription is closed when it falls so
+// far behind that events would have to be dropped. Subscribers should
+// resubscribe and resynchronize, e.g. with BulkInfo.
+var ErrSlowSubscriber = errors.New("event_hub: subscriber fell behind and missed events")
+
+type Emitter interface {
+	Emit(Event)
+}
+
+// Hub fans events out to every current subscription. Emitting never blocks
+// on a subscriber.
+type Hub struct {
+	logger     lager.Logger
+	bufferSize int
+
+	subscriptions      map[*Subscription]struct{}
+	subscriptionsMutex sync.Mutex
+}
+
+func New(logger lager.Logger, bufferSize int) *Hub {
+	return &Hub{
+		logger:     logger.Session("event-hub"),
+		bufferSize: bufferSize,
+
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.subscriptionsMutex.Lock()
+	defer h.subscriptionsMutex.Unlock()
+
+	for subscription := range h.subscriptions {
+		select {
+		case subscription.events <- event:
+		default:
+			h.logger.Info("dropping-slow-subscriber", lager.Data{
+				"event": event.Type,
+			})
+
+			subscription.err = ErrSlowSubscriber
+			h.unsubscribe(subscription)
+		}
+	}
+}
+
+func (h *Hub) Subscribe() *Subscription {
+	subscription := &Subscription{
+		hub:    h,
+		events: make(chan Event, h.bufferSize),
+	}
+
+	h.subscriptionsMutex.Lock()
+	h.subscriptions[subscription] = struct{}{}
+	h.subscriptionsMutex.Unlock()
+
+	return subscription
+}
+
+// must be called with subscriptionsMutex held
+func (h *Hub) unsubscribe(subscription *Subscription) {
+	if _, found := h.subscriptions[subscription]; !found {
+		return
+	}
+
+	delete(h.subscriptions, subscription)
+	close(subscription.events)
+}
+
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+	err    error
+}
+
+// Events delivers events in the order they were emitted. It is closed when
+// the subscription is, after any buffered events.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns ErrSlowSubscriber if the hub closed the subscription, and nil
+// otherwise.
+func (s *Subscription) Err() error {
+	s.hub.subscriptionsMutex.Lock()
+	defer s.hub.subscriptionsMutex.Unlock()
+
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.subscriptionsMutex.Lock()
+	defer s.hub.subscriptionsMutex.Unlock()
+
+	s.hub.unsubscribe(s)
+}
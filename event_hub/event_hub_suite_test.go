@@ -0,0 +1,13 @@
+package event_hub_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEventHub(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Event Hub Suite")
+}
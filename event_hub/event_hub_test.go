@@ -0,0 +1,114 @@
+package event_hub_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hub", func() {
+	var hub *event_hub.Hub
+
+	BeforeEach(func() {
+		hub = event_hub.New(lagertest.NewTestLogger("test"), 2)
+	})
+
+	It("delivers emitted events to every subscriber, in order", func() {
+		subscription1 := hub.Subscribe()
+		subscription2 := hub.Subscribe()
+
+		hub.Emit(event_hub.Event{Type: event_hub.Created, Handle: "some-handle"})
+		hub.Emit(event_hub.Event{Type: event_hub.Started, Handle: "some-handle"})
+
+		for _, subscription := range []*event_hub.Subscription{subscription1, subscription2} {
+			var event event_hub.Event
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Created))
+			Expect(event.Handle).To(Equal("some-handle"))
+
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Started))
+		}
+	})
+
+	It("timestamps events that do not have a time", func() {
+		subscription := hub.Subscribe()
+
+		before := time.Now()
+		hub.Emit(event_hub.Event{Type: event_hub.Created})
+
+		var event event_hub.Event
+		Eventually(subscription.Events()).Should(Receive(&event))
+		Expect(event.Time).To(BeTemporally(">=", before))
+		Expect(event.Time).To(BeTemporally("<=", time.Now()))
+	})
+
+	It("keeps the time of events that have one", func() {
+		subscription := hub.Subscribe()
+
+		someTime := time.Unix(123, 0)
+		hub.Emit(event_hub.Event{Type: event_hub.Created, Time: someTime})
+
+		var event event_hub.Event
+		Eventually(subscription.Events()).Should(Receive(&event))
+		Expect(event.Time).To(Equal(someTime))
+	})
+
+	It("does not deliver events emitted before subscribing", func() {
+		hub.Emit(event_hub.Event{Type: event_hub.Created})
+
+		subscription := hub.Subscribe()
+		Consistently(subscription.Events()).ShouldNot(Receive())
+	})
+
+	Context("when a subscription is closed", func() {
+		It("closes its events and stops delivering to it", func() {
+			subscription := hub.Subscribe()
+			subscription.Close()
+
+			hub.Emit(event_hub.Event{Type: event_hub.Created})
+
+			Eventually(subscription.Events()).Should(BeClosed())
+			Expect(subscription.Err()).ToNot(HaveOccurred())
+		})
+
+		It("can be closed again", func() {
+			subscription := hub.Subscribe()
+			subscription.Close()
+			subscription.Close()
+		})
+	})
+
+	Context("when a subscriber falls behind", func() {
+		It("closes its subscription, after the events it did receive, with ErrSlowSubscriber", func() {
+			slow := hub.Subscribe()
+			fast := hub.Subscribe()
+
+			received := make(chan event_hub.Event, 3)
+			go func() {
+				for event := range fast.Events() {
+					received <- event
+				}
+			}()
+
+			hub.Emit(event_hub.Event{Type: event_hub.Created})
+			hub.Emit(event_hub.Event{Type: event_hub.Started})
+			Eventually(received).Should(HaveLen(2))
+
+			hub.Emit(event_hub.Event{Type: event_hub.Stopped})
+			Eventually(received).Should(HaveLen(3))
+
+			Expect(slow.Err()).To(Equal(event_hub.ErrSlowSubscriber))
+
+			Expect(slow.Events()).To(Receive())
+			Expect(slow.Events()).To(Receive())
+			Expect(slow.Events()).To(BeClosed())
+
+			Expect(fast.Err()).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type Check interface {
+	Check() error
+}
+
+type CheckFunc func() error
+
+func (f CheckFunc) Check() error {
+	return f()
+}
+
+type NamedCheck struct {
+	Name  string
+	Check Check
+}
+
+type Failure struct {
+	Check string
+	Err   error
+}
+
+// DegradedError lists every check that failed, in the order the checks were
+// given to the Checker.
+type DegradedError struct {
+	Failures []Failure
+}
+
+func (err DegradedError) Error() string {
+	failures := make([]string, len(err.Failures))
+	for i, failure := range err.Failures {
+		failures[i] = fmt.Sprintf("%s: %s", failure.Check, failure.Err)
+	}
+
+	return "backend is degraded: " + strings.Join(failures, "; ")
+}
+
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (err TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", err.Timeout)
+}
+
+// Checker runs a set of checks concurrently. A check that does not finish
+// within the timeout fails, so that a wedged dependency (e.g. iptables
+// waiting on its lock) is reported rather than hanging the caller.
+type Checker struct {
+	logger  lager.Logger
+	timeout time.Duration
+	checks  []NamedCheck
+}
+
+func NewChecker(logger lager.Logger, timeout time.Duration, checks ...NamedCheck) *Checker {
+	return &Checker{
+		logger:  logger.Session("health"),
+		timeout: timeout,
+		checks:  checks,
+	}
+}
+
+func (c *Checker) Check() error {
+	results := make([]chan error, len(c.checks))
+
+	for i, check := range c.checks {
+		results[i] = make(chan error, 1)
+
+		go func(check Check, result chan<- error) {
+			result <- check.Check()
+		}(check.Check, results[i])
+	}
+
+	deadline := time.NewTimer(c.timeout)
+	defer deadline.Stop()
+
+	expired := false
+
+	var failures []Failure
+	for i, check := range c.checks {
+		var err error
+
+		if !expired {
+			select {
+			case err = <-results[i]:
+			case <-deadline.C:
+				expired = true
+			}
+		}
+
+		if expired {
+			select {
+			case err = <-results[i]:
+			default:
+				err = TimeoutError{c.timeout}
+			}
+		}
+
+		if err != nil {
+			c.logger.Error("check-failed", err, lager.Data{
+				"check": check.Name,
+			})
+
+			failures = append(failures, Failure{Check: check.Name, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return DegradedError{failures}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package health_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/health"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker", func() {
+	passing := health.CheckFunc(func() error { return nil })
+
+	failing := func(message string) health.Check {
+		return health.CheckFunc(func() error { return errors.New(message) })
+	}
+
+	newChecker := func(checks ...health.NamedCheck) *health.Checker {
+		return health.NewChecker(lagertest.NewTestLogger("test"), 100*time.Millisecond, checks...)
+	}
+
+	Context("when every check passes", func() {
+		It("succeeds", func() {
+			checker := newChecker(
+				health.NamedCheck{Name: "a", Check: passing},
+				health.NamedCheck{Name: "b", Check: passing},
+			)
+
+			Expect(checker.Check()).To(Succeed())
+		})
+	})
+
+	Context("when checks fail", func() {
+		It("returns a DegradedError naming each failure, in order", func() {
+			checker := newChecker(
+				health.NamedCheck{Name: "a", Check: failing("a is broken")},
+				health.NamedCheck{Name: "b", Check: passing},
+				health.NamedCheck{Name: "c", Check: failing("c is broken")},
+			)
+
+			err := checker.Check()
+			Expect(err).To(BeAssignableToTypeOf(health.DegradedError{}))
+			Expect(err.(health.DegradedError).Failures).To(Equal([]health.Failure{
+				{Check: "a", Err: errors.New("a is broken")},
+				{Check: "c", Err: errors.New("c is broken")},
+			}))
+
+			Expect(err).To(MatchError("backend is degraded: a: a is broken; c: c is broken"))
+		})
+	})
+
+	Context("when a check does not finish in time", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			close(release)
+		})
+
+		It("fails it with a TimeoutError without waiting for it", func() {
+			hanging := health.CheckFunc(func() error {
+				<-release
+				return nil
+			})
+
+			checker := newChecker(
+				health.NamedCheck{Name: "hanging", Check: hanging},
+				health.NamedCheck{Name: "also-hanging", Check: hanging},
+				health.NamedCheck{Name: "b", Check: failing("b is broken")},
+			)
+
+			done := make(chan error)
+			go func() {
+				done <- checker.Check()
+			}()
+
+			var err error
+			Eventually(done).Should(Receive(&err))
+
+			Expect(err.(health.DegradedError).Failures).To(Equal([]health.Failure{
+				{Check: "hanging", Err: health.TimeoutError{Timeout: 100 * time.Millisecond}},
+				{Check: "also-hanging", Err: health.TimeoutError{Timeout: 100 * time.Millisecond}},
+				{Check: "b", Err: errors.New("b is broken")},
+			}))
+		})
+	})
+})
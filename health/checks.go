@@ -0,0 +1,117 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// NewDepotCheck verifies that containers can be written to the depot.
+func NewDepotCheck(depotPath string) Check {
+	return CheckFunc(func() error {
+		probe, err := ioutil.TempFile(depotPath, ".health-")
+		if err != nil {
+			return err
+		}
+
+		defer os.Remove(probe.Name())
+
+		if _, err := probe.Write([]byte("ok")); err != nil {
+			probe.Close()
+			return err
+		}
+
+		return probe.Close()
+	})
+}
+
+// NewCgroupsCheck verifies that each subsystem's hierarchy is still mounted
+// under cgroupPath. A mounted hierarchy always has a tasks file at its root.
+func NewCgroupsCheck(cgroupPath string, subsystems []string) Check {
+	return CheckFunc(func() error {
+		var unmounted []string
+		for _, subsystem := range subsystems {
+			if _, err := os.Stat(path.Join(cgroupPath, subsystem, "tasks")); err != nil {
+				unmounted = append(unmounted, subsystem)
+			}
+		}
+
+		if len(unmounted) > 0 {
+			return fmt.Errorf("cgroup subsystems not mounted under %s: %s", cgroupPath, strings.Join(unmounted, ", "))
+		}
+
+		return nil
+	})
+}
+
+type IPTablesChain struct {
+	Table string
+	Name  string
+}
+
+// NewIPTablesCheck verifies that each chain exists and that iptables is
+// responding.
+func NewIPTablesCheck(runner command_runner.CommandRunner, chains []IPTablesChain) Check {
+	return CheckFunc(func() error {
+		for _, chain := range chains {
+			var stderr bytes.Buffer
+
+			list := exec.Command("/sbin/iptables", "-w", "-t", chain.Table, "-S", chain.Name)
+			list.Stderr = &stderr
+
+			if err := runner.Run(list); err != nil {
+				return fmt.Errorf("listing %s chain %s: %s: %s", chain.Table, chain.Name, err, strings.TrimSpace(stderr.String()))
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewBridgeListerCheck verifies that the host's bridges can be listed.
+func NewBridgeListerCheck(lister bridgemgr.Lister) Check {
+	return CheckFunc(func() error {
+		_, err := lister.List()
+		return err
+	})
+}
+
+// NewRootFSProvidersCheck runs the health check of every provider that has
+// one.
+func NewRootFSProvidersCheck(providers map[string]rootfs_provider.RootFSProvider) Check {
+	return CheckFunc(func() error {
+		schemes := make([]string, 0, len(providers))
+		for scheme := range providers {
+			schemes = append(schemes, scheme)
+		}
+
+		sort.Strings(schemes)
+
+		var failures []string
+		for _, scheme := range schemes {
+			checker, ok := providers[scheme].(rootfs_provider.HealthChecker)
+			if !ok {
+				continue
+			}
+
+			if err := checker.HealthCheck(); err != nil {
+				failures = append(failures, fmt.Sprintf("provider %q: %s", scheme, err))
+			}
+		}
+
+		if len(failures) > 0 {
+			return fmt.Errorf("%s", strings.Join(failures, ", "))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,174 @@
+package health_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/cloudfoundry-incubator/garden-linux/health"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider/fake_rootfs_provider"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	"github.com/pivotal-golang/lager"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checks", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir("", "health")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Describe("the depot check", func() {
+		It("succeeds when the depot is writable, leaving nothing behind", func() {
+			Expect(health.NewDepotCheck(tmpdir).Check()).To(Succeed())
+
+			entries, err := ioutil.ReadDir(tmpdir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		Context("when the depot is missing", func() {
+			It("fails", func() {
+				Expect(health.NewDepotCheck(path.Join(tmpdir, "missing")).Check()).ToNot(Succeed())
+			})
+		})
+	})
+
+	Describe("the cgroups check", func() {
+		BeforeEach(func() {
+			for _, subsystem := range []string{"cpu", "memory"} {
+				Expect(os.MkdirAll(path.Join(tmpdir, subsystem), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(path.Join(tmpdir, subsystem, "tasks"), nil, 0644)).To(Succeed())
+			}
+		})
+
+		It("succeeds when every subsystem is mounted", func() {
+			Expect(health.NewCgroupsCheck(tmpdir, []string{"cpu", "memory"}).Check()).To(Succeed())
+		})
+
+		Context("when subsystems are not mounted", func() {
+			It("fails, naming them", func() {
+				Expect(os.MkdirAll(path.Join(tmpdir, "devices"), 0755)).To(Succeed())
+
+				err := health.NewCgroupsCheck(tmpdir, []string{"cpu", "devices", "memory", "cpuacct"}).Check()
+				Expect(err).To(MatchError("cgroup subsystems not mounted under " + tmpdir + ": devices, cpuacct"))
+			})
+		})
+	})
+
+	Describe("the iptables check", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+		var check health.Check
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+
+			check = health.NewIPTablesCheck(fakeRunner, []health.IPTablesChain{
+				{Table: "filter", Name: "w-0-default"},
+				{Table: "nat", Name: "w-0-prerouting"},
+			})
+		})
+
+		It("lists each chain", func() {
+			Expect(check.Check()).To(Succeed())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-S", "w-0-default"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-S", "w-0-prerouting"},
+				},
+			))
+		})
+
+		Context("when a chain cannot be listed", func() {
+			It("fails, naming the chain", func() {
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-S", "w-0-prerouting"},
+				}, func(cmd *exec.Cmd) error {
+					cmd.Stderr.Write([]byte("No chain/target/match by that name.\n"))
+					return errors.New("exit status 1")
+				})
+
+				Expect(check.Check()).To(MatchError("listing nat chain w-0-prerouting: exit status 1: No chain/target/match by that name."))
+			})
+		})
+	})
+
+	Describe("the bridge lister check", func() {
+		It("succeeds when bridges can be listed", func() {
+			Expect(health.NewBridgeListerCheck(fakeLister{}).Check()).To(Succeed())
+		})
+
+		Context("when listing bridges fails", func() {
+			It("fails", func() {
+				Expect(health.NewBridgeListerCheck(fakeLister{err: errors.New("oh no")}).Check()).To(MatchError("oh no"))
+			})
+		})
+	})
+
+	Describe("the rootfs providers check", func() {
+		It("checks each provider that can be checked", func() {
+			providers := map[string]rootfs_provider.RootFSProvider{
+				"":       checkableProvider{err: nil},
+				"docker": new(fake_rootfs_provider.FakeRootFSProvider),
+			}
+
+			Expect(health.NewRootFSProvidersCheck(providers).Check()).To(Succeed())
+		})
+
+		Context("when providers are unhealthy", func() {
+			It("fails, naming them", func() {
+				providers := map[string]rootfs_provider.RootFSProvider{
+					"":      checkableProvider{err: errors.New("no rootfs")},
+					"other": checkableProvider{err: errors.New("no overlays")},
+				}
+
+				Expect(health.NewRootFSProvidersCheck(providers).Check()).To(MatchError(`provider "": no rootfs, provider "other": no overlays`))
+			})
+		})
+	})
+})
+
+type fakeLister struct {
+	err error
+}
+
+func (l fakeLister) List() ([]string, error) {
+	return nil, l.err
+}
+
+type checkableProvider struct {
+	err error
+}
+
+func (p checkableProvider) ProvideRootFS(lager.Logger, string, *url.URL, bool) (string, process.Env, error) {
+	return "", nil, nil
+}
+
+func (p checkableProvider) CleanupRootFS(lager.Logger, string) error {
+	return nil
+}
+
+func (p checkableProvider) HealthCheck() error {
+	return p.err
+}
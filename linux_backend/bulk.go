@@ -0,0 +1,160 @@
+package linux_backend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type BulkOperationTimeoutError struct {
+	Operation string
+	Handle    string
+	Timeout   time.Duration
+}
+
+func (e BulkOperationTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s getting %s for container: %s", e.Timeout, e.Operation, e.Handle)
+}
+
+// BulkOperationInFlightError is returned for a container whose operation
+// timed out on an earlier call and has still not returned. It is not run
+// again until it does, so that a hung container cannot pile up operations.
+type BulkOperationInFlightError struct {
+	Operation string
+	Handle    string
+}
+
+func (e BulkOperationInFlightError) Error() string {
+	return fmt.Sprintf("still getting %s for container after an earlier attempt timed out: %s", e.Operation, e.Handle)
+}
+
+type bulkResult struct {
+	value interface{}
+	err   error
+}
+
+// bulkRunner runs an operation across many containers, such as for the bulk
+// endpoints or the metrics sampler.
+type bulkRunner struct {
+	logger lager.Logger
+
+	maxConcurrentOperations int
+	operationTimeout        time.Duration
+
+	inFlight      map[string]bool
+	inFlightMutex sync.Mutex
+}
+
+func newBulkRunner(logger lager.Logger, maxConcurrentOperations int, operationTimeout time.Duration) *bulkRunner {
+	return &bulkRunner{
+		logger: logger,
+
+		maxConcurrentOperations: maxConcurrentOperations,
+		operationTimeout:        operationTimeout,
+
+		inFlight: make(map[string]bool),
+	}
+}
+
+// forEachContainer calls operation for each container, at most
+// maxConcurrentOperations at a time, and returns the results in the same
+// order as the containers.
+func (b *bulkRunner) forEachContainer(name string, containers []Container, operation func(Container) (interface{}, error)) []bulkResult {
+	results := make([]bulkResult, len(containers))
+
+	workers := b.maxConcurrentOperations
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > len(containers) {
+		workers = len(containers)
+	}
+
+	indices := make(chan int)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				results[index] = b.withBulkTimeout(name, containers[index], operation)
+			}
+		}()
+	}
+
+	for index := range containers {
+		indices <- index
+	}
+
+	close(indices)
+	wg.Wait()
+
+	return results
+}
+
+// withBulkTimeout gives up waiting for the operation once operationTimeout
+// has passed, so that one hung container cannot hold up the rest. The
+// operation itself cannot be interrupted, and is left to finish in the
+// background; until it does, the same operation is not started again for
+// that container.
+func (b *bulkRunner) withBulkTimeout(name string, container Container, operation func(Container) (interface{}, error)) bulkResult {
+	if b.operationTimeout <= 0 {
+		value, err := operation(container)
+		return bulkResult{value: value, err: err}
+	}
+
+	key := name + "/" + container.Handle()
+
+	b.inFlightMutex.Lock()
+	if b.inFlight[key] {
+		b.inFlightMutex.Unlock()
+
+		return bulkResult{err: BulkOperationInFlightError{
+			Operation: name,
+			Handle:    container.Handle(),
+		}}
+	}
+
+	b.inFlight[key] = true
+	b.inFlightMutex.Unlock()
+
+	done := make(chan bulkResult, 1)
+
+	go func() {
+		value, err := operation(container)
+
+		b.inFlightMutex.Lock()
+		delete(b.inFlight, key)
+		b.inFlightMutex.Unlock()
+
+		done <- bulkResult{value: value, err: err}
+	}()
+
+	timer := time.NewTimer(b.operationTimeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		return result
+
+	case <-timer.C:
+		err := BulkOperationTimeoutError{
+			Operation: name,
+			Handle:    container.Handle(),
+			Timeout:   b.operationTimeout,
+		}
+
+		b.logger.Error("bulk-operation-timed-out", err, lager.Data{
+			"operation": name,
+			"handle":    container.Handle(),
+		})
+
+		return bulkResult{err: err}
+	}
+}
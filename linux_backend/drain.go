@@ -0,0 +1,175 @@
+package linux_backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type DrainingError struct{}
+
+func (e DrainingError) Error() string {
+	return "backend is draining: not accepting new containers"
+}
+
+type DrainTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e DrainTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for in-flight container operations to finish", e.Timeout)
+}
+
+type CreatesInFlightError struct {
+	Count int
+}
+
+func (e CreatesInFlightError) Error() string {
+	return fmt.Sprintf("%d container creates or restores are in flight", e.Count)
+}
+
+// Drain prepares the backend to be stopped: from now on Create fails with
+// DrainingError, and Drain waits up to timeout for any creates, destroys or
+// restores already in progress to finish. Snapshots are written by Stop, once
+// the backend has drained.
+func (b *LinuxBackend) Drain(timeout time.Duration) error {
+	dLog := b.logger.Session("drain", lager.Data{
+		"timeout": timeout.String(),
+	})
+
+	dLog.Info("rejecting-creates")
+
+	b.drainMutex.Lock()
+	if !b.draining {
+		b.draining = true
+		b.drained = make(chan struct{})
+		b.checkDrained()
+	}
+
+	drained := b.drained
+	b.drainMutex.Unlock()
+
+	dLog.Info("waiting-for-in-flight-operations")
+
+	select {
+	case <-drained:
+		dLog.Info("drained")
+		return nil
+
+	case <-time.After(timeout):
+		err := DrainTimeoutError{Timeout: timeout}
+		dLog.Error("timed-out", err)
+		return err
+	}
+}
+
+func (b *LinuxBackend) Draining() bool {
+	b.drainMutex.Lock()
+	defer b.drainMutex.Unlock()
+
+	return b.draining
+}
+
+// HoldCreates stops operations which add a container from starting until
+// release is called, so that the host can be compared with the repository
+// without racing them. It fails if any are already in progress, as the
+// containers they add are not in the repository yet.
+func (b *LinuxBackend) HoldCreates() (release func(), err error) {
+	b.drainMutex.Lock()
+	defer b.drainMutex.Unlock()
+
+	b.waitForRelease()
+
+	if b.adding > 0 {
+		return nil, CreatesInFlightError{Count: b.adding}
+	}
+
+	held := make(chan struct{})
+	b.held = held
+
+	return func() {
+		b.drainMutex.Lock()
+		defer b.drainMutex.Unlock()
+
+		if b.held == held {
+			b.held = nil
+			close(held)
+		}
+	}, nil
+}
+
+// admitOperation registers an operation which adds a container, and which
+// Drain should wait for. It waits while creates are held, and fails once the
+// backend is draining.
+func (b *LinuxBackend) admitOperation() error {
+	b.drainMutex.Lock()
+	defer b.drainMutex.Unlock()
+
+	if !b.draining {
+		b.waitForRelease()
+	}
+
+	if b.draining {
+		return DrainingError{}
+	}
+
+	b.inFlight++
+	b.adding++
+
+	return nil
+}
+
+// endAdmittedOperation ends an operation registered with admitOperation.
+func (b *LinuxBackend) endAdmittedOperation() {
+	b.drainMutex.Lock()
+	b.adding--
+	b.drainMutex.Unlock()
+
+	b.endOperation()
+}
+
+// trackOperation registers an operation which Drain should wait for but
+// which may still go ahead while draining, such as destroying a container.
+func (b *LinuxBackend) trackOperation() {
+	b.drainMutex.Lock()
+	defer b.drainMutex.Unlock()
+
+	b.inFlight++
+}
+
+func (b *LinuxBackend) endOperation() {
+	b.drainMutex.Lock()
+	defer b.drainMutex.Unlock()
+
+	b.inFlight--
+
+	if b.draining {
+		b.checkDrained()
+	}
+}
+
+// waitForRelease must be called with drainMutex held, which it gives up
+// while waiting.
+func (b *LinuxBackend) waitForRelease() {
+	for b.held != nil {
+		held := b.held
+
+		b.drainMutex.Unlock()
+		<-held
+		b.drainMutex.Lock()
+	}
+}
+
+// checkDrained must be called with drainMutex held.
+func (b *LinuxBackend) checkDrained() {
+	if b.inFlight > 0 {
+		return
+	}
+
+	select {
+	case <-b.drained:
+	default:
+		close(b.drained)
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/old/system_info"
 	"github.com/pivotal-golang/lager"
 )
@@ -32,15 +35,21 @@ type ContainerPool interface {
 	Create(garden.ContainerSpec) (Container, error)
 	Restore(io.Reader) (Container, error)
 	Destroy(Container) error
-	Prune(keep map[string]bool) error
+	Prune(keep map[string]bool) ([]string, error)
 	MaxContainers() int
 }
 
+//go:generate counterfeiter -o fakes/fake_health_checker.go . HealthChecker
+type HealthChecker interface {
+	Check() error
+}
+
 type ContainerRepository interface {
 	All() []Container
 	Add(Container)
 	FindByHandle(string) (Container, error)
 	Query(filter func(Container) bool) []Container
+	Select(selector Selector) []Container
 	Delete(Container)
 }
 
@@ -51,7 +60,29 @@ type LinuxBackend struct {
 	systemInfo    system_info.Provider
 	snapshotsPath string
 
+	maxConcurrentRestores int
+
+	bulk *bulkRunner
+
 	containerRepo ContainerRepository
+
+	eventHub *event_hub.Hub
+
+	healthChecker HealthChecker
+
+	ledger *capacity.Ledger
+
+	metricsSampler *MetricsSampler
+
+	draining   bool
+	inFlight   int
+	adding     int
+	held       chan struct{}
+	drained    chan struct{}
+	drainMutex sync.Mutex
+
+	report      RestoreReport
+	reportMutex sync.RWMutex
 }
 
 type HandleExistsError struct {
@@ -74,17 +105,38 @@ func New(
 	logger lager.Logger,
 	containerPool ContainerPool,
 	containerRepo ContainerRepository,
+	eventHub *event_hub.Hub,
+	healthChecker HealthChecker,
 	systemInfo system_info.Provider,
 	snapshotsPath string,
+	maxConcurrentRestores int,
+	maxConcurrentBulkOperations int,
+	bulkOperationTimeout time.Duration,
+	ledger *capacity.Ledger,
+	metricsSampler *MetricsSampler,
 ) *LinuxBackend {
+	logger = logger.Session("backend")
+
 	return &LinuxBackend{
-		logger: logger.Session("backend"),
+		logger: logger,
 
 		containerPool: containerPool,
 		systemInfo:    systemInfo,
 		snapshotsPath: snapshotsPath,
 
+		maxConcurrentRestores: maxConcurrentRestores,
+
+		bulk: newBulkRunner(logger, maxConcurrentBulkOperations, bulkOperationTimeout),
+
 		containerRepo: containerRepo,
+
+		eventHub: eventHub,
+
+		healthChecker: healthChecker,
+
+		ledger: ledger,
+
+		metricsSampler: metricsSampler,
 	}
 }
 
@@ -93,20 +145,36 @@ func (b *LinuxBackend) Setup() error {
 }
 
 func (b *LinuxBackend) Start() error {
+	keep := map[string]bool{}
+
 	if b.snapshotsPath != "" {
+		var restored []Container
+		var unquarantined []string
+
 		_, err := os.Stat(b.snapshotsPath)
 		if err == nil {
-			b.restoreSnapshots()
-			os.RemoveAll(b.snapshotsPath)
+			restored, unquarantined = b.restoreSnapshots()
+			b.removeSnapshots(unquarantined)
 		}
 
 		err = os.MkdirAll(b.snapshotsPath, 0755)
 		if err != nil {
 			return err
 		}
-	}
 
-	keep := map[string]bool{}
+		// registered only once the old snapshots are gone, as a persistent
+		// repository writes fresh ones into the same directory
+		for _, container := range restored {
+			b.containerRepo.Add(container)
+			b.emit(event_hub.Restored, container)
+		}
+
+		// snapshots which could not be quarantined are tried again on the
+		// next start, so their depot entries are still needed
+		for _, id := range unquarantined {
+			keep[id] = true
+		}
+	}
 
 	containers := b.containerRepo.All()
 
@@ -114,11 +182,31 @@ func (b *LinuxBackend) Start() error {
 		keep[container.ID()] = true
 	}
 
-	return b.containerPool.Prune(keep)
+	quarantined, err := b.Quarantined()
+	if err != nil {
+		b.logger.Error("failed-to-list-quarantined-snapshots", err)
+	}
+
+	// keep the depot entries of quarantined containers so they can still be
+	// restored later
+	for _, failure := range quarantined {
+		keep[failure.ID] = true
+	}
+
+	pruned, err := b.containerPool.Prune(keep)
+	if err != nil {
+		return err
+	}
+
+	b.reportMutex.Lock()
+	b.report.Pruned = pruned
+	b.reportMutex.Unlock()
+
+	return nil
 }
 
 func (b *LinuxBackend) Ping() error {
-	return nil
+	return b.healthChecker.Check()
 }
 
 func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
@@ -139,11 +227,32 @@ func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
 	}, nil
 }
 
+// CapacityReport describes how much of the cell's memory, disk and CPU
+// shares has been committed to containers through their limits, and how
+// much is still available.
+func (b *LinuxBackend) CapacityReport() (capacity.Report, error) {
+	return b.ledger.Report()
+}
+
 func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	err := b.admitOperation()
+	if err != nil {
+		return nil, err
+	}
+
+	defer b.endAdmittedOperation()
+
 	if _, err := b.containerRepo.FindByHandle(spec.Handle); spec.Handle != "" && err == nil {
 		return nil, HandleExistsError{Handle: spec.Handle}
 	}
 
+	reservation, err := b.ledger.Reserve()
+	if err != nil {
+		return nil, err
+	}
+
+	defer reservation.Release()
+
 	container, err := b.containerPool.Create(spec)
 	if err != nil {
 		return nil, err
@@ -152,15 +261,21 @@ func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, erro
 	err = container.Start()
 	if err != nil {
 		b.containerPool.Destroy(container)
+		b.ledger.Release(container.Handle())
 		return nil, err
 	}
 
 	b.containerRepo.Add(container)
 
+	b.emit(event_hub.Created, container)
+
 	return container, nil
 }
 
 func (b *LinuxBackend) Destroy(handle string) error {
+	b.trackOperation()
+	defer b.endOperation()
+
 	container, err := b.containerRepo.FindByHandle(handle)
 	if err != nil {
 		return err
@@ -173,11 +288,20 @@ func (b *LinuxBackend) Destroy(handle string) error {
 
 	b.containerRepo.Delete(container)
 
+	b.ledger.Release(container.Handle())
+
+	b.emit(event_hub.Destroyed, container)
+
 	return nil
 }
 
 func (b *LinuxBackend) Containers(props garden.Properties) ([]garden.Container, error) {
-	return toGardenContainers(b.containerRepo.Query(withProperties(props))), nil
+	selector, err := ParseSelector(props)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGardenContainers(b.containerRepo.Select(selector)), nil
 }
 
 func (b *LinuxBackend) Lookup(handle string) (garden.Container, error) {
@@ -187,16 +311,19 @@ func (b *LinuxBackend) Lookup(handle string) (garden.Container, error) {
 func (b *LinuxBackend) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
 	containers := b.containerRepo.Query(withHandles(handles))
 
+	results := b.bulk.forEachContainer("info", containers, func(container Container) (interface{}, error) {
+		return container.Info()
+	})
+
 	infos := make(map[string]garden.ContainerInfoEntry)
-	for _, container := range containers {
-		info, err := container.Info()
-		if err != nil {
+	for i, container := range containers {
+		if results[i].err != nil {
 			infos[container.Handle()] = garden.ContainerInfoEntry{
-				Err: garden.NewError(err.Error()),
+				Err: garden.NewError(results[i].err.Error()),
 			}
 		} else {
 			infos[container.Handle()] = garden.ContainerInfoEntry{
-				Info: info,
+				Info: results[i].value.(garden.ContainerInfo),
 			}
 		}
 	}
@@ -207,16 +334,19 @@ func (b *LinuxBackend) BulkInfo(handles []string) (map[string]garden.ContainerIn
 func (b *LinuxBackend) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
 	containers := b.containerRepo.Query(withHandles(handles))
 
+	results := b.bulk.forEachContainer("metrics", containers, func(container Container) (interface{}, error) {
+		return container.Metrics()
+	})
+
 	metrics := make(map[string]garden.ContainerMetricsEntry)
-	for _, container := range containers {
-		metric, err := container.Metrics()
-		if err != nil {
+	for i, container := range containers {
+		if results[i].err != nil {
 			metrics[container.Handle()] = garden.ContainerMetricsEntry{
-				Err: garden.NewError(err.Error()),
+				Err: garden.NewError(results[i].err.Error()),
 			}
 		} else {
 			metrics[container.Handle()] = garden.ContainerMetricsEntry{
-				Metrics: metric,
+				Metrics: results[i].value.(garden.Metrics),
 			}
 		}
 	}
@@ -224,6 +354,12 @@ func (b *LinuxBackend) BulkMetrics(handles []string) (map[string]garden.Containe
 	return metrics, nil
 }
 
+// Subscribe returns a subscription to events for every container, from now
+// on. It must be closed once no longer needed.
+func (b *LinuxBackend) Subscribe() *event_hub.Subscription {
+	return b.eventHub.Subscribe()
+}
+
 func (b *LinuxBackend) GraceTime(container garden.Container) time.Duration {
 	return container.(Container).GraceTime()
 }
@@ -240,35 +376,174 @@ func (b *LinuxBackend) Stop() {
 	}
 }
 
-func (b *LinuxBackend) restoreSnapshots() {
-	sLog := b.logger.Session("restore")
+// removeSnapshots removes the snapshots which have been restored, leaving
+// those which failed to restore but could not be quarantined either, so that
+// they are not lost.
+func (b *LinuxBackend) removeSnapshots(unquarantined []string) {
+	if len(unquarantined) == 0 {
+		os.RemoveAll(b.snapshotsPath)
+		return
+	}
+
+	leave := map[string]bool{}
+	for _, id := range unquarantined {
+		leave[id] = true
+	}
 
 	entries, err := ioutil.ReadDir(b.snapshotsPath)
 	if err != nil {
 		b.logger.Error("failed-to-read-snapshots", err, lager.Data{
 			"from": b.snapshotsPath,
 		})
+
+		return
 	}
 
 	for _, entry := range entries {
-		snapshot := path.Join(b.snapshotsPath, entry.Name())
+		if !leave[entry.Name()] {
+			os.RemoveAll(path.Join(b.snapshotsPath, entry.Name()))
+		}
+	}
+}
 
-		lLog := sLog.Session("load", lager.Data{
-			"snapshot": entry.Name(),
+// restoreSnapshots returns the containers it restored, and the IDs of the
+// snapshots which failed to restore but could not be quarantined.
+func (b *LinuxBackend) restoreSnapshots() ([]Container, []string) {
+	sLog := b.logger.Session("restore")
+
+	entries, err := ioutil.ReadDir(b.snapshotsPath)
+	if err != nil {
+		b.logger.Error("failed-to-read-snapshots", err, lager.Data{
+			"from": b.snapshotsPath,
 		})
+	}
 
-		lLog.Debug("loading")
+	ids := []string{}
+	for _, entry := range entries {
+		if !IsPartialSnapshot(entry.Name()) {
+			ids = append(ids, entry.Name())
+		}
+	}
 
-		file, err := os.Open(snapshot)
-		if err != nil {
-			lLog.Error("failed-to-open", err)
+	workers := b.maxConcurrentRestores
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	sLog.Info("restoring", lager.Data{
+		"snapshots": len(ids),
+		"workers":   workers,
+	})
+
+	started := time.Now()
+
+	// each snapshot's outcome is kept at its index so that the report lists
+	// containers in the same order however the restores interleave
+	results := make([]restoreResult, len(ids))
+
+	indices := make(chan int)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				results[index] = b.restoreSnapshot(sLog, ids[index])
+			}
+		}()
+	}
+
+	for index := range ids {
+		indices <- index
+	}
+
+	close(indices)
+	wg.Wait()
+
+	var restored []Container
+	var unquarantined []string
+	report := RestoreReport{
+		Restored: []string{},
+		Failed:   []QuarantinedSnapshot{},
+	}
+
+	for _, result := range results {
+		if result.container != nil {
+			restored = append(restored, result.container)
+			report.Restored = append(report.Restored, result.container.ID())
+		} else if result.failure != nil {
+			report.Failed = append(report.Failed, *result.failure)
+
+			if !result.quarantined {
+				unquarantined = append(unquarantined, result.failure.ID)
+			}
 		}
+	}
 
-		_, err = b.restore(file)
-		if err != nil {
-			lLog.Error("failed-to-restore", err)
+	sLog.Info("restored", lager.Data{
+		"restored": len(report.Restored),
+		"failed":   len(report.Failed),
+		"took":     time.Since(started).String(),
+	})
+
+	b.reportMutex.Lock()
+	b.report = report
+	b.reportMutex.Unlock()
+
+	return restored, unquarantined
+}
+
+type restoreResult struct {
+	container   Container
+	failure     *QuarantinedSnapshot
+	quarantined bool
+}
+
+func (b *LinuxBackend) restoreSnapshot(sLog lager.Logger, id string) restoreResult {
+	snapshot := path.Join(b.snapshotsPath, id)
+
+	lLog := sLog.Session("load", lager.Data{
+		"snapshot": id,
+	})
+
+	lLog.Debug("loading")
+
+	started := time.Now()
+
+	file, err := os.Open(snapshot)
+	if err != nil {
+		lLog.Error("failed-to-open", err)
+		return restoreResult{}
+	}
+
+	container, err := b.containerPool.Restore(file)
+	file.Close()
+	if err != nil {
+		lLog.Error("failed-to-restore", err, lager.Data{
+			"took": time.Since(started).String(),
+		})
+
+		failure, qErr := b.quarantine(id, snapshot, err)
+		if qErr != nil {
+			lLog.Error("failed-to-quarantine", qErr)
 		}
+
+		_, statErr := os.Stat(snapshot)
+		return restoreResult{failure: &failure, quarantined: os.IsNotExist(statErr)}
 	}
+
+	lLog.Info("restored", lager.Data{
+		"took": time.Since(started).String(),
+	})
+
+	return restoreResult{container: container}
 }
 
 func (b *LinuxBackend) saveSnapshot(container Container) error {
@@ -280,28 +555,19 @@ func (b *LinuxBackend) saveSnapshot(container Container) error {
 		"container": container.ID(),
 	})
 
-	snapshotPath := path.Join(b.snapshotsPath, container.ID())
-
-	snapshot, err := os.Create(snapshotPath)
+	err := SaveSnapshot(container, path.Join(b.snapshotsPath, container.ID()))
 	if err != nil {
 		return &FailedToSnapshotError{err}
 	}
 
-	err = container.Snapshot(snapshot)
-	if err != nil {
-		return &FailedToSnapshotError{err}
-	}
-
-	return snapshot.Close()
+	return nil
 }
 
-func (b *LinuxBackend) restore(snapshot io.Reader) (garden.Container, error) {
-	container, err := b.containerPool.Restore(snapshot)
-	if err != nil {
-		return nil, err
-	}
-	b.containerRepo.Add(container)
-	return container, nil
+func (b *LinuxBackend) emit(eventType event_hub.EventType, container Container) {
+	b.eventHub.Emit(event_hub.Event{
+		Type:   eventType,
+		Handle: container.Handle(),
+	})
 }
 
 func withHandles(handles []string) func(Container) bool {
@@ -315,12 +581,6 @@ func withHandles(handles []string) func(Container) bool {
 	}
 }
 
-func withProperties(props garden.Properties) func(Container) bool {
-	return func(c Container) bool {
-		return c.HasProperties(props)
-	}
-}
-
 func toGardenContainers(cs []Container) []garden.Container {
 	var result []garden.Container
 	for _, c := range cs {
@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -13,8 +15,11 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/health"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
@@ -27,24 +32,46 @@ var _ = Describe("LinuxBackend", func() {
 	var fakeSystemInfo *fake_system_info.FakeProvider
 	var containerRepo linux_backend.ContainerRepository
 	var linuxBackend *linux_backend.LinuxBackend
+	var eventHub *event_hub.Hub
+	var fakeHealthChecker *fakes.FakeHealthChecker
 	var snapshotsPath string
+	var maxConcurrentRestores int
+	var maxConcurrentBulkOperations int
+	var bulkOperationTimeout time.Duration
+	var overcommitRatio float64
+	var ledger *capacity.Ledger
 
 	BeforeEach(func() {
 		logger = lagertest.NewTestLogger("test")
 		fakeContainerPool = fake_container_pool.New()
 		containerRepo = container_repository.New()
 		fakeSystemInfo = fake_system_info.NewFakeProvider()
+		eventHub = event_hub.New(logger, 100)
+		fakeHealthChecker = new(fakes.FakeHealthChecker)
 
 		snapshotsPath = ""
+		maxConcurrentRestores = 4
+		maxConcurrentBulkOperations = 4
+		bulkOperationTimeout = 5 * time.Second
+		overcommitRatio = 0
 	})
 
 	JustBeforeEach(func() {
+		ledger = capacity.NewLedger(fakeSystemInfo, 4096, overcommitRatio, nil)
+
 		linuxBackend = linux_backend.New(
 			logger,
 			fakeContainerPool,
 			containerRepo,
+			eventHub,
+			fakeHealthChecker,
 			fakeSystemInfo,
 			snapshotsPath,
+			maxConcurrentRestores,
+			maxConcurrentBulkOperations,
+			bulkOperationTimeout,
+			ledger,
+			nil,
 		)
 	})
 
@@ -131,6 +158,68 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
 			})
 
+			Context("when there are more snapshots than may be restored at once", func() {
+				var maxInFlight int32
+
+				BeforeEach(func() {
+					maxConcurrentRestores = 2
+
+					for i := 0; i < 6; i++ {
+						err := ioutil.WriteFile(path.Join(snapshotsPath, fmt.Sprintf("id-%d", i)), []byte(fmt.Sprintf("handle-%d", i)), 0644)
+						Expect(err).ToNot(HaveOccurred())
+					}
+
+					var inFlight int32
+					maxInFlight = 0
+
+					fakeContainerPool.BeforeRestore = func() {
+						current := atomic.AddInt32(&inFlight, 1)
+						defer atomic.AddInt32(&inFlight, -1)
+
+						for {
+							max := atomic.LoadInt32(&maxInFlight)
+							if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+								break
+							}
+						}
+
+						time.Sleep(10 * time.Millisecond)
+					}
+				})
+
+				It("restores them in parallel, up to the limit", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(8))
+					Expect(atomic.LoadInt32(&maxInFlight)).To(Equal(int32(2)))
+				})
+
+				It("reports them in snapshot order", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(linuxBackend.RestoreReport().Restored).To(Equal([]string{
+						"handle-0", "handle-1", "handle-2", "handle-3", "handle-4", "handle-5",
+						"handle-a", "handle-b",
+					}))
+				})
+			})
+
+			Context("when a snapshot was only partially written", func() {
+				BeforeEach(func() {
+					err := ioutil.WriteFile(path.Join(snapshotsPath, ".some-id-123"), []byte("handle-"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("does not restore it", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
+				})
+			})
+
 			It("removes the snapshots", func() {
 				Expect(fakeContainerPool.RestoredSnapshots).To(BeEmpty())
 
@@ -144,6 +233,23 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(err).To(HaveOccurred())
 			})
 
+			It("emits a restore event for each of them", func() {
+				subscription := linuxBackend.Subscribe()
+				defer subscription.Close()
+
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				var event event_hub.Event
+				Expect(subscription.Events()).To(Receive(&event))
+				Expect(event.Type).To(Equal(event_hub.Restored))
+				Expect(event.Handle).To(Equal("handle-a"))
+
+				Expect(subscription.Events()).To(Receive(&event))
+				Expect(event.Type).To(Equal(event_hub.Restored))
+				Expect(event.Handle).To(Equal("handle-b"))
+			})
+
 			It("registers the containers", func() {
 				err := linuxBackend.Start()
 				Expect(err).ToNot(HaveOccurred())
@@ -165,6 +271,30 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 
+			Context("when the container repository persists snapshots", func() {
+				BeforeEach(func() {
+					containerRepo = container_repository.NewPersistent(logger, snapshotsPath)
+				})
+
+				It("keeps the snapshots written when registering the restored containers", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = os.Stat(path.Join(snapshotsPath, "handle-a"))
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = os.Stat(path.Join(snapshotsPath, "handle-b"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+
+			It("reports them as restored", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(linuxBackend.RestoreReport().Restored).To(ConsistOf("handle-a", "handle-b"))
+			})
+
 			Context("when restoring the container fails", func() {
 				disaster := errors.New("failed to restore")
 
@@ -176,6 +306,200 @@ var _ = Describe("LinuxBackend", func() {
 					err := linuxBackend.Start()
 					Expect(err).ToNot(HaveOccurred())
 				})
+
+				It("moves the snapshots into quarantine", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					contents, err := ioutil.ReadFile(path.Join(snapshotsPath+"-quarantine", "some-id", "snapshot"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(contents)).To(Equal("handle-a"))
+
+					quarantined, err := linuxBackend.Quarantined()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(quarantined).To(HaveLen(2))
+					Expect(quarantined[0].ID).To(Equal("some-id"))
+					Expect(quarantined[0].Error).To(Equal("failed to restore"))
+				})
+
+				It("reports them as failed", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					report := linuxBackend.RestoreReport()
+					Expect(report.Restored).To(BeEmpty())
+					Expect(report.Failed).To(HaveLen(2))
+					Expect(report.Failed[0].ID).To(Equal("some-id"))
+					Expect(report.Failed[0].Error).To(Equal("failed to restore"))
+				})
+
+				It("keeps them when pruning the container pool", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+						"some-id":       true,
+						"some-other-id": true,
+					}))
+				})
+
+				Context("and the snapshots cannot be quarantined", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(snapshotsPath+"-quarantine", []byte("in the way"), 0644)
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("reports them as failed", func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+
+						report := linuxBackend.RestoreReport()
+						Expect(report.Failed).To(HaveLen(2))
+						Expect(report.Failed[0].ID).To(Equal("some-id"))
+						Expect(report.Failed[0].Error).To(Equal("failed to restore"))
+						Expect(report.Failed[1].ID).To(Equal("some-other-id"))
+					})
+
+					It("leaves the snapshots where they are", func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+
+						contents, err := ioutil.ReadFile(path.Join(snapshotsPath, "some-id"))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(string(contents)).To(Equal("handle-a"))
+
+						_, err = os.Stat(path.Join(snapshotsPath, "some-other-id"))
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("keeps them when pruning the container pool", func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+							"some-id":       true,
+							"some-other-id": true,
+						}))
+					})
+				})
+
+				Context("and the restore is retried", func() {
+					JustBeforeEach(func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					Context("successfully", func() {
+						var container garden.Container
+
+						JustBeforeEach(func() {
+							fakeContainerPool.RestoreError = nil
+
+							var err error
+							container, err = linuxBackend.RestoreQuarantined("some-id")
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("registers the container", func() {
+							found, err := linuxBackend.Lookup("handle-a")
+							Expect(err).ToNot(HaveOccurred())
+							Expect(found).To(Equal(container))
+						})
+
+						It("removes it from quarantine", func() {
+							quarantined, err := linuxBackend.Quarantined()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(quarantined).To(HaveLen(1))
+							Expect(quarantined[0].ID).To(Equal("some-other-id"))
+						})
+
+						It("emits a restore event", func() {
+							subscription := linuxBackend.Subscribe()
+							defer subscription.Close()
+
+							fakeContainerPool.RestoreError = nil
+
+							_, err := linuxBackend.RestoreQuarantined("some-other-id")
+							Expect(err).ToNot(HaveOccurred())
+
+							var event event_hub.Event
+							Expect(subscription.Events()).To(Receive(&event))
+							Expect(event.Type).To(Equal(event_hub.Restored))
+							Expect(event.Handle).To(Equal("handle-b"))
+						})
+
+						It("reports it as restored", func() {
+							report := linuxBackend.RestoreReport()
+							Expect(report.Restored).To(Equal([]string{"some-id"}))
+							Expect(report.Failed).To(HaveLen(1))
+						})
+					})
+
+					Context("unsuccessfully", func() {
+						It("returns the error and leaves it in quarantine", func() {
+							fakeContainerPool.RestoreError = errors.New("still broken")
+
+							_, err := linuxBackend.RestoreQuarantined("some-id")
+							Expect(err).To(MatchError("still broken"))
+
+							quarantined, err := linuxBackend.Quarantined()
+							Expect(err).ToNot(HaveOccurred())
+							Expect(quarantined).To(HaveLen(2))
+							Expect(quarantined[0].Error).To(Equal("still broken"))
+						})
+					})
+
+					Context("for a snapshot that is not quarantined", func() {
+						It("returns SnapshotNotQuarantinedError", func() {
+							_, err := linuxBackend.RestoreQuarantined("bogus-id")
+							Expect(err).To(Equal(linux_backend.SnapshotNotQuarantinedError{ID: "bogus-id"}))
+						})
+					})
+				})
+
+				Context("and a quarantined snapshot is discarded", func() {
+					JustBeforeEach(func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+
+						err = linuxBackend.DiscardQuarantined("some-id")
+						Expect(err).ToNot(HaveOccurred())
+					})
+
+					It("removes it from quarantine", func() {
+						quarantined, err := linuxBackend.Quarantined()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(quarantined).To(HaveLen(1))
+						Expect(quarantined[0].ID).To(Equal("some-other-id"))
+					})
+
+					Context("with an ID which is not directly in quarantine", func() {
+						It("returns SnapshotNotQuarantinedError and removes nothing", func() {
+							for _, id := range []string{"", ".", "..", "../snapshots", "some-other-id/..", "some-other-id/snapshot"} {
+								err := linuxBackend.DiscardQuarantined(id)
+								Expect(err).To(Equal(linux_backend.SnapshotNotQuarantinedError{ID: id}))
+
+								_, err = linuxBackend.RestoreQuarantined(id)
+								Expect(err).To(Equal(linux_backend.SnapshotNotQuarantinedError{ID: id}))
+							}
+
+							_, err := os.Stat(snapshotsPath)
+							Expect(err).ToNot(HaveOccurred())
+
+							_, err = os.Stat(path.Join(snapshotsPath+"-quarantine", "some-other-id", "snapshot"))
+							Expect(err).ToNot(HaveOccurred())
+						})
+					})
+
+					It("no longer keeps it when next pruning the container pool", func() {
+						err := linuxBackend.Start()
+						Expect(err).ToNot(HaveOccurred())
+
+						Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+							"some-other-id": true,
+						}))
+					})
+				})
 			})
 		})
 
@@ -187,6 +511,15 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{}))
 		})
 
+		It("reports the pruned containers", func() {
+			fakeContainerPool.PrunedContainers = []string{"some-pruned-id"}
+
+			err := linuxBackend.Start()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(linuxBackend.RestoreReport().Pruned).To(Equal([]string{"some-pruned-id"}))
+		})
+
 		Context("when pruning the container pool fails", func() {
 			disaster := errors.New("failed to prune")
 
@@ -262,6 +595,192 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("Drain", func() {
+		It("makes Create fail with DrainingError", func() {
+			Expect(linuxBackend.Drain(time.Second)).To(Succeed())
+			Expect(linuxBackend.Draining()).To(BeTrue())
+
+			_, err := linuxBackend.Create(garden.ContainerSpec{})
+			Expect(err).To(Equal(linux_backend.DrainingError{}))
+
+			Expect(fakeContainerPool.CreatedContainers).To(BeEmpty())
+		})
+
+		It("still allows containers to be destroyed", func() {
+			container := fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"})
+			containerRepo.Add(container)
+
+			Expect(linuxBackend.Drain(time.Second)).To(Succeed())
+
+			Expect(linuxBackend.Destroy("some-handle")).To(Succeed())
+			Expect(fakeContainerPool.DestroyedContainers).To(ContainElement(container))
+		})
+
+		Context("when a create is in flight", func() {
+			var release chan struct{}
+			var created chan error
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				created = make(chan error, 1)
+			})
+
+			JustBeforeEach(func() {
+				starting := make(chan struct{})
+				fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+					close(starting)
+					<-release
+				}
+
+				go func() {
+					defer GinkgoRecover()
+
+					_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+					created <- err
+				}()
+
+				Eventually(starting).Should(BeClosed())
+			})
+
+			AfterEach(func() {
+				select {
+				case <-release:
+				default:
+					close(release)
+				}
+			})
+
+			It("waits for it to finish", func() {
+				drained := make(chan error, 1)
+				go func() {
+					drained <- linuxBackend.Drain(time.Second)
+				}()
+
+				Consistently(drained).ShouldNot(Receive())
+
+				close(release)
+
+				Eventually(drained).Should(Receive(BeNil()))
+				Eventually(created).Should(Receive(BeNil()))
+
+				_, err := linuxBackend.Lookup("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("and it does not finish within the timeout", func() {
+				It("returns a DrainTimeoutError", func() {
+					err := linuxBackend.Drain(50 * time.Millisecond)
+					Expect(err).To(Equal(linux_backend.DrainTimeoutError{Timeout: 50 * time.Millisecond}))
+					Expect(err).To(MatchError("timed out after 50ms waiting for in-flight container operations to finish"))
+				})
+			})
+		})
+	})
+
+	Describe("HoldCreates", func() {
+		It("makes Create wait until it is released", func() {
+			release, err := linuxBackend.HoldCreates()
+			Expect(err).ToNot(HaveOccurred())
+
+			created := make(chan error, 1)
+			go func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				created <- err
+			}()
+
+			Consistently(created).ShouldNot(Receive())
+			Expect(fakeContainerPool.CreatedContainers).To(BeEmpty())
+
+			release()
+
+			Eventually(created).Should(Receive(BeNil()))
+		})
+
+		It("does not make Destroy wait", func() {
+			container := fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"})
+			containerRepo.Add(container)
+
+			release, err := linuxBackend.HoldCreates()
+			Expect(err).ToNot(HaveOccurred())
+			defer release()
+
+			Expect(linuxBackend.Destroy("some-handle")).To(Succeed())
+		})
+
+		Context("when a create is in flight", func() {
+			var unblock chan struct{}
+			var created chan error
+
+			JustBeforeEach(func() {
+				unblock = make(chan struct{})
+				created = make(chan error, 1)
+
+				starting := make(chan struct{})
+				blocked := unblock
+				fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+					close(starting)
+					<-blocked
+				}
+
+				go func() {
+					_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+					created <- err
+				}()
+
+				Eventually(starting).Should(BeClosed())
+			})
+
+			It("returns a CreatesInFlightError", func() {
+				_, err := linuxBackend.HoldCreates()
+				Expect(err).To(Equal(linux_backend.CreatesInFlightError{Count: 1}))
+
+				close(unblock)
+				Eventually(created).Should(Receive(BeNil()))
+
+				release, err := linuxBackend.HoldCreates()
+				Expect(err).ToNot(HaveOccurred())
+				release()
+			})
+		})
+
+		Context("when draining", func() {
+			It("makes Create fail with DrainingError rather than wait", func() {
+				release, err := linuxBackend.HoldCreates()
+				Expect(err).ToNot(HaveOccurred())
+				defer release()
+
+				Expect(linuxBackend.Drain(time.Second)).To(Succeed())
+
+				_, err = linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(Equal(linux_backend.DrainingError{}))
+			})
+		})
+	})
+
+	Describe("Ping", func() {
+		It("runs the health checks", func() {
+			err := linuxBackend.Ping()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeHealthChecker.CheckCallCount()).To(Equal(1))
+		})
+
+		Context("when a health check fails", func() {
+			It("returns the error", func() {
+				degraded := health.DegradedError{
+					Failures: []health.Failure{
+						{Check: "depot", Err: errors.New("read-only file system")},
+					},
+				}
+
+				fakeHealthChecker.CheckReturns(degraded)
+
+				err := linuxBackend.Ping()
+				Expect(err).To(Equal(degraded))
+			})
+		})
+	})
+
 	Describe("Capacity", func() {
 		It("returns the right capacity values", func() {
 			fakeSystemInfo.TotalMemoryResult = 1111
@@ -276,6 +795,20 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(capacity.MaxContainers).To(Equal(uint64(42)))
 		})
 
+		It("returns the totals, whatever has been committed to containers", func() {
+			fakeSystemInfo.TotalMemoryResult = 1111
+			fakeSystemInfo.TotalDiskResult = 2222
+
+			ledger.ForceCommit("some-handle", capacity.Memory, 111)
+			ledger.ForceCommit("other-handle", capacity.Disk, 222)
+
+			gardenCapacity, err := linuxBackend.Capacity()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(gardenCapacity.MemoryInBytes).To(Equal(uint64(1111)))
+			Expect(gardenCapacity.DiskInBytes).To(Equal(uint64(2222)))
+		})
+
 		Context("when getting memory info fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -303,6 +836,31 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 
+	Describe("CapacityReport", func() {
+		BeforeEach(func() {
+			fakeSystemInfo.TotalMemoryResult = 1000
+			fakeSystemInfo.TotalDiskResult = 2000
+			overcommitRatio = 1.5
+		})
+
+		It("reports the resources committed to containers", func() {
+			ledger.ForceCommit("some-handle", capacity.Memory, 100)
+			ledger.ForceCommit("some-handle", capacity.CPU, 512)
+			ledger.ForceCommit("other-handle", capacity.Memory, 200)
+
+			report, err := linuxBackend.CapacityReport()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report).To(Equal(capacity.Report{
+				Total:           capacity.Resources{MemoryInBytes: 1000, DiskInBytes: 2000, CPUShares: 4096},
+				Committed:       capacity.Resources{MemoryInBytes: 300, CPUShares: 512},
+				Available:       capacity.Resources{MemoryInBytes: 1200, DiskInBytes: 3000, CPUShares: 5632},
+				Allowed:         &capacity.Resources{MemoryInBytes: 1500, DiskInBytes: 3000, CPUShares: 6144},
+				OvercommitRatio: 1.5,
+			}))
+		})
+	})
+
 	Describe("Create", func() {
 		It("creates a container from the pool", func() {
 			Expect(fakeContainerPool.CreatedContainers).To(BeEmpty())
@@ -331,6 +889,43 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(fakeContainerPool.DestroyedContainers).To(ContainElement(setupContainer))
 			})
+
+			It("releases what the container committed", func() {
+				fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+					c.StartError = errors.New("insufficient banana")
+					ledger.ForceCommit(c.Handle(), capacity.Memory, 100)
+				}
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(HaveOccurred())
+
+				report, err := linuxBackend.CapacityReport()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(report.Committed).To(Equal(capacity.Resources{}))
+				Expect(report.Reserved).To(Equal(0))
+			})
+		})
+
+		It("releases its reservation once the container is created", func() {
+			_, err := linuxBackend.Create(garden.ContainerSpec{})
+			Expect(err).ToNot(HaveOccurred())
+
+			report, err := linuxBackend.CapacityReport()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Reserved).To(Equal(0))
+		})
+
+		It("emits a create event", func() {
+			subscription := linuxBackend.Subscribe()
+			defer subscription.Close()
+
+			container, err := linuxBackend.Create(garden.ContainerSpec{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Created))
+			Expect(event.Handle).To(Equal(container.Handle()))
 		})
 
 		It("registers the container", func() {
@@ -359,6 +954,34 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		Context("when a resource is already fully committed", func() {
+			BeforeEach(func() {
+				fakeSystemInfo.TotalMemoryResult = 1000
+				fakeSystemInfo.TotalDiskResult = 2000
+				overcommitRatio = 2
+			})
+
+			JustBeforeEach(func() {
+				ledger.ForceCommit("some-handle", capacity.Memory, 2000)
+			})
+
+			It("returns a CapacityExhaustedError", func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(Equal(capacity.CapacityExhaustedError{
+					Resource:  capacity.Memory,
+					Committed: 2000,
+					Allowed:   2000,
+				}))
+			})
+
+			It("does not create a container", func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeContainerPool.CreatedContainers).To(BeEmpty())
+			})
+		})
+
 		Context("when a container with the given handle already exists", func() {
 			It("returns a HandleExistsError", func() {
 				container, err := linuxBackend.Create(garden.ContainerSpec{})
@@ -386,6 +1009,16 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(container).To(BeNil())
 			})
 
+			It("does not emit a create event", func() {
+				subscription := linuxBackend.Subscribe()
+				defer subscription.Close()
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(HaveOccurred())
+
+				Expect(subscription.Events()).ToNot(Receive())
+			})
+
 			It("does not register the container", func() {
 				_, err := linuxBackend.Create(garden.ContainerSpec{})
 				Expect(err).To(HaveOccurred())
@@ -424,6 +1057,30 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(err).To(MatchError(garden.ContainerNotFoundError{"some-handle"}))
 		})
 
+		It("releases the resources committed to the container", func() {
+			ledger.ForceCommit("some-handle", capacity.Memory, 100)
+
+			err := linuxBackend.Destroy("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			report, err := linuxBackend.CapacityReport()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Committed).To(Equal(capacity.Resources{}))
+		})
+
+		It("emits a destroy event", func() {
+			subscription := linuxBackend.Subscribe()
+			defer subscription.Close()
+
+			err := linuxBackend.Destroy("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Destroyed))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
 		Context("when the container does not exist", func() {
 			It("returns ContainerNotFoundError", func() {
 				err := linuxBackend.Destroy("bogus-handle")
@@ -536,6 +1193,101 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 		})
+
+		Context("when getting a container's info hangs", func() {
+			var unblock chan struct{}
+			var hung *fakes.FakeContainer
+
+			BeforeEach(func() {
+				bulkOperationTimeout = 50 * time.Millisecond
+
+				unblock = make(chan struct{})
+
+				blocked := unblock
+				hung = newContainer("handle2")
+				hung.InfoStub = func() (garden.ContainerInfo, error) {
+					<-blocked
+					return garden.ContainerInfo{}, nil
+				}
+
+				containerRepo.Add(hung)
+			})
+
+			AfterEach(func() {
+				close(unblock)
+			})
+
+			It("returns a timeout error for that container only", func() {
+				bulkInfo, err := linuxBackend.BulkInfo(handles)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkInfo[container1.Handle()]).To(Equal(garden.ContainerInfoEntry{
+					Info: garden.ContainerInfo{
+						HostIP: "hostip for handle1",
+					},
+				}))
+
+				Expect(bulkInfo[container2.Handle()].Err).To(Equal(garden.NewError(
+					"timed out after 50ms getting info for container: handle2",
+				)))
+			})
+
+			It("does not get its info again until the earlier call returns", func() {
+				for i := 0; i < 3; i++ {
+					_, err := linuxBackend.BulkInfo(handles)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				bulkInfo, err := linuxBackend.BulkInfo(handles)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkInfo[container2.Handle()].Err).To(Equal(garden.NewError(
+					"still getting info for container after an earlier attempt timed out: handle2",
+				)))
+
+				Expect(hung.InfoCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when there are more containers than workers", func() {
+			var inFlight, maxInFlight int
+			var inFlightMutex sync.Mutex
+
+			BeforeEach(func() {
+				maxConcurrentBulkOperations = 2
+				inFlight, maxInFlight = 0, 0
+
+				for i := 3; i <= 6; i++ {
+					container := newContainer(fmt.Sprintf("handle%d", i))
+					container.InfoStub = func() (garden.ContainerInfo, error) {
+						inFlightMutex.Lock()
+						inFlight++
+						if inFlight > maxInFlight {
+							maxInFlight = inFlight
+						}
+						inFlightMutex.Unlock()
+
+						time.Sleep(10 * time.Millisecond)
+
+						inFlightMutex.Lock()
+						inFlight--
+						inFlightMutex.Unlock()
+
+						return garden.ContainerInfo{}, nil
+					}
+
+					containerRepo.Add(container)
+				}
+			})
+
+			It("gets no more than the maximum number at once", func() {
+				bulkInfo, err := linuxBackend.BulkInfo([]string{"handle3", "handle4", "handle5", "handle6"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bulkInfo).To(HaveLen(4))
+
+				Expect(maxInFlight).To(Equal(2))
+			})
+		})
 	})
 
 	Describe("BulkMetrics", func() {
@@ -628,6 +1380,39 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 		})
+
+		Context("when getting a container's metrics hangs", func() {
+			var unblock chan struct{}
+
+			BeforeEach(func() {
+				bulkOperationTimeout = 50 * time.Millisecond
+
+				unblock = make(chan struct{})
+
+				blocked := unblock
+				hung := newContainer(2)
+				hung.MetricsStub = func() (garden.Metrics, error) {
+					<-blocked
+					return garden.Metrics{}, nil
+				}
+
+				containerRepo.Add(hung)
+			})
+
+			AfterEach(func() {
+				close(unblock)
+			})
+
+			It("returns a timeout error for that container only", func() {
+				bulkMetrics, err := linuxBackend.BulkMetrics(handles)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics[container1.Handle()].Metrics.DiskStat.InodesUsed).To(Equal(uint64(1)))
+				Expect(bulkMetrics[container2.Handle()].Err).To(Equal(garden.NewError(
+					"timed out after 50ms getting metrics for container: handle2",
+				)))
+			})
+		})
 	})
 
 	Describe("Lookup", func() {
@@ -693,6 +1478,169 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(containers).ToNot(ContainElement(container2))
 				Expect(containers).To(ContainElement(container3))
 			})
+
+			It("interprets the properties as a selector", func() {
+				web, err := linuxBackend.Create(garden.ContainerSpec{
+					Properties: garden.Properties{"app": "web-frontend", "zone": "z1"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				worker, err := linuxBackend.Create(garden.ContainerSpec{
+					Properties: garden.Properties{"app": "worker"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				containers, err := linuxBackend.Containers(garden.Properties{"app^=": "web-"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers).To(ConsistOf(web))
+
+				containers, err = linuxBackend.Containers(garden.Properties{"zone?": "false"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers).To(ConsistOf(worker))
+			})
+
+			Context("when the selector is invalid", func() {
+				It("returns an error", func() {
+					_, err := linuxBackend.Containers(garden.Properties{"zone?": "maybe"})
+					Expect(err).To(BeAssignableToTypeOf(linux_backend.InvalidSelectorError{}))
+				})
+			})
+		})
+	})
+
+	Describe("Pause and Resume", func() {
+		Context("when the container can be paused", func() {
+			var container *pausableContainer
+
+			BeforeEach(func() {
+				container = &pausableContainer{
+					FakeContainer: fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"}),
+				}
+
+				containerRepo.Add(container)
+			})
+
+			It("pauses and resumes it", func() {
+				Expect(linuxBackend.Pause("some-handle")).To(Succeed())
+				Expect(container.paused).To(BeTrue())
+
+				Expect(linuxBackend.Resume("some-handle")).To(Succeed())
+				Expect(container.paused).To(BeFalse())
+			})
+
+			Context("when pausing fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					container.err = disaster
+				})
+
+				It("returns the error", func() {
+					Expect(linuxBackend.Pause("some-handle")).To(Equal(disaster))
+					Expect(linuxBackend.Resume("some-handle")).To(Equal(disaster))
+				})
+			})
+		})
+
+		Context("when the container cannot be paused", func() {
+			BeforeEach(func() {
+				containerRepo.Add(fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"}))
+			})
+
+			It("returns a PauseNotSupportedError", func() {
+				Expect(linuxBackend.Pause("some-handle")).To(Equal(linux_backend.PauseNotSupportedError{Handle: "some-handle"}))
+				Expect(linuxBackend.Resume("some-handle")).To(Equal(linux_backend.PauseNotSupportedError{Handle: "some-handle"}))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ContainerNotFoundError", func() {
+				Expect(linuxBackend.Pause("bogus-handle")).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+			})
+		})
+	})
+
+	Describe("Linux limits and metrics", func() {
+		Context("when the container supports them", func() {
+			var container *extendedContainer
+
+			BeforeEach(func() {
+				container = &extendedContainer{
+					FakeContainer: fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"}),
+					metrics: linux_backend.LinuxMetrics{
+						CPUThrottlingStat: linux_backend.CPUThrottlingStat{ThrottledPeriods: 3},
+					},
+					ooms: []linux_backend.OOMRecord{
+						{Policy: linux_backend.OOMPolicyKillProcess, MemoryLimitInBytes: 1024},
+					},
+				}
+
+				containerRepo.Add(container)
+			})
+
+			It("applies and reads back the limits", func() {
+				limits := linux_backend.LinuxLimits{
+					CPUQuota: &linux_backend.CPUQuotaLimits{
+						QuotaInMicroseconds:  50000,
+						PeriodInMicroseconds: 100000,
+					},
+				}
+
+				Expect(linuxBackend.LimitLinux("some-handle", limits)).To(Succeed())
+				Expect(linuxBackend.CurrentLinuxLimits("some-handle")).To(Equal(limits))
+			})
+
+			It("returns the metrics", func() {
+				Expect(linuxBackend.LinuxMetrics("some-handle")).To(Equal(container.metrics))
+			})
+
+			It("returns the OOM records", func() {
+				Expect(linuxBackend.OOMRecords("some-handle")).To(Equal(container.ooms))
+			})
+
+			It("stops the container with the grace period", func() {
+				Expect(linuxBackend.StopContainer("some-handle", 5*time.Second)).To(Succeed())
+				Expect(container.stoppedWith).To(Equal([]time.Duration{5 * time.Second}))
+			})
+
+			It("signals the process", func() {
+				Expect(linuxBackend.SignalProcess("some-handle", 42, garden.Signal(5), linux_backend.SignalScopeTree)).To(Succeed())
+				Expect(container.signalled).To(Equal([]signalledProcess{
+					{processID: 42, signal: garden.Signal(5), scope: linux_backend.SignalScopeTree},
+				}))
+			})
+		})
+
+		Context("when the container does not support them", func() {
+			BeforeEach(func() {
+				containerRepo.Add(fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"}))
+			})
+
+			It("returns a LinuxExtensionsNotSupportedError", func() {
+				notSupported := linux_backend.LinuxExtensionsNotSupportedError{Handle: "some-handle"}
+
+				Expect(linuxBackend.LimitLinux("some-handle", linux_backend.LinuxLimits{})).To(Equal(notSupported))
+
+				_, err := linuxBackend.CurrentLinuxLimits("some-handle")
+				Expect(err).To(Equal(notSupported))
+
+				_, err = linuxBackend.LinuxMetrics("some-handle")
+				Expect(err).To(Equal(notSupported))
+
+				_, err = linuxBackend.OOMRecords("some-handle")
+				Expect(err).To(Equal(notSupported))
+
+				Expect(linuxBackend.SignalProcess("some-handle", 42, garden.SignalKill, linux_backend.SignalScopeProcess)).To(Equal(notSupported))
+
+				Expect(linuxBackend.StopContainer("some-handle", time.Second)).To(Equal(notSupported))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ContainerNotFoundError", func() {
+				_, err := linuxBackend.LinuxMetrics("bogus-handle")
+				Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+			})
 		})
 	})
 
@@ -707,3 +1655,71 @@ var _ = Describe("LinuxBackend", func() {
 		})
 	})
 })
+
+type pausableContainer struct {
+	*fake_container_pool.FakeContainer
+
+	paused bool
+	err    error
+}
+
+func (c *pausableContainer) Pause() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.paused = true
+	return nil
+}
+
+func (c *pausableContainer) Resume() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.paused = false
+	return nil
+}
+
+type extendedContainer struct {
+	*fake_container_pool.FakeContainer
+
+	limits      linux_backend.LinuxLimits
+	metrics     linux_backend.LinuxMetrics
+	ooms        []linux_backend.OOMRecord
+	signalled   []signalledProcess
+	stoppedWith []time.Duration
+}
+
+type signalledProcess struct {
+	processID uint32
+	signal    garden.Signal
+	scope     linux_backend.SignalScope
+}
+
+func (c *extendedContainer) LimitLinux(limits linux_backend.LinuxLimits) error {
+	c.limits = limits
+	return nil
+}
+
+func (c *extendedContainer) CurrentLinuxLimits() (linux_backend.LinuxLimits, error) {
+	return c.limits, nil
+}
+
+func (c *extendedContainer) LinuxMetrics() (linux_backend.LinuxMetrics, error) {
+	return c.metrics, nil
+}
+
+func (c *extendedContainer) OOMRecords() []linux_backend.OOMRecord {
+	return c.ooms
+}
+
+func (c *extendedContainer) SignalProcess(processID uint32, signal garden.Signal, scope linux_backend.SignalScope) error {
+	c.signalled = append(c.signalled, signalledProcess{processID, signal, scope})
+	return nil
+}
+
+func (c *extendedContainer) StopWithGracePeriod(gracePeriod time.Duration) error {
+	c.stoppedWith = append(c.stoppedWith, gracePeriod)
+	return nil
+}
@@ -0,0 +1,273 @@
+package linux_backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// LinuxLimits are the limits a container supports beyond those in
+// garden.Container. When applying them, nil limits are left as they are.
+type LinuxLimits struct {
+	CPUQuota *CPUQuotaLimits `json:",omitempty"`
+	Pids     *PidsLimits     `json:",omitempty"`
+	BlockIO  *BlockIOLimits  `json:",omitempty"`
+
+	MemoryPressure *MemoryPressureLimits `json:",omitempty"`
+}
+
+// CPUQuotaLimits cap the CPU time a container may use in each period,
+// regardless of how idle the rest of the cell is. A quota of 0 means the
+// container is not capped.
+type CPUQuotaLimits struct {
+	QuotaInMicroseconds  uint64
+	PeriodInMicroseconds uint64
+}
+
+// PidsLimits cap the number of processes and threads the container may have
+// at once. A max of 0 means the container is not limited.
+type PidsLimits struct {
+	Max uint64
+}
+
+// BlockIOLimits set the container's share of block I/O when the device is
+// contended, and throttle its I/O to the disk holding the depot. They replace
+// the container's throttles, so a throttle of 0 means that I/O is not
+// throttled; a weight of 0 leaves the weight as it is.
+type BlockIOLimits struct {
+	Weight uint16
+
+	ReadBytesPerSecond  uint64
+	WriteBytesPerSecond uint64
+	ReadIOPerSecond     uint64
+	WriteIOPerSecond    uint64
+}
+
+// MemoryPressureLimits give early warning of a container running short of
+// memory, before it reaches its hard limit and the kernel starts killing its
+// processes.
+//
+// Under contention for memory, the kernel reclaims from containers above
+// their soft limit first; a soft limit of 0 means there is none. Each time the
+// kernel reports memory pressure at one of PressureLevels, or the container's
+// usage crosses one of ThresholdsInBytes in either direction, a
+// MemoryPressure or MemoryThreshold event is emitted with its memory stats.
+type MemoryPressureLimits struct {
+	SoftLimitInBytes uint64
+
+	PressureLevels    []MemoryPressureLevel `json:",omitempty"`
+	ThresholdsInBytes []uint64              `json:",omitempty"`
+}
+
+// MemoryPressureLevel is how hard the kernel is working to reclaim a
+// container's memory.
+type MemoryPressureLevel string
+
+const (
+	// MemoryPressureLow means the kernel is reclaiming memory for new
+	// allocations, which is normal.
+	MemoryPressureLow MemoryPressureLevel = "low"
+
+	// MemoryPressureMedium means the container is swapping or evicting
+	// active file caches.
+	MemoryPressureMedium MemoryPressureLevel = "medium"
+
+	// MemoryPressureCritical means the container is about to run out of
+	// memory.
+	MemoryPressureCritical MemoryPressureLevel = "critical"
+)
+
+// LinuxMetrics are a container's garden.Metrics along with the statistics
+// which only make sense on Linux.
+type LinuxMetrics struct {
+	garden.Metrics
+
+	CPUThrottlingStat CPUThrottlingStat
+	PidsStat          PidsStat
+	BlockIOStat       BlockIOStat
+	NetworkStat       NetworkStat
+}
+
+type CPUThrottlingStat struct {
+	Periods          uint64
+	ThrottledPeriods uint64
+	ThrottledTime    uint64
+}
+
+type PidsStat struct {
+	Current uint64
+
+	// LimitHits counts the forks refused because the pids limit was reached.
+	LimitHits uint64
+}
+
+// BlockIOStat counts the container's I/O across every block device.
+type BlockIOStat struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadOps    uint64
+	WriteOps   uint64
+}
+
+// NetworkStat counts the container's traffic from its own point of view, so
+// Rx is what it received and Tx is what it sent. The counters never go down,
+// even across restores.
+type NetworkStat struct {
+	RxBytes   uint64
+	RxPackets uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxDropped uint64
+}
+
+// OOMPolicy is what happens to a container when it runs out of memory.
+type OOMPolicy string
+
+const (
+	// OOMPolicyStop stops the whole container.
+	OOMPolicyStop OOMPolicy = "stop"
+
+	// OOMPolicyKillProcess leaves the kernel's OOM killer to kill the
+	// offending process, and keeps the rest of the container running.
+	OOMPolicyKillProcess OOMPolicy = "kill-process"
+
+	// OOMPolicyRecord disables the kernel's OOM killer for the container, so
+	// nothing is killed straight away: its processes hang, waiting for memory
+	// to be freed or for its limit to be raised. If they are still waiting
+	// after a timeout, the OOM killer is enabled until it has freed memory.
+	OOMPolicyRecord OOMPolicy = "record"
+)
+
+// OOMRecord is a container running out of memory, with its memory usage at
+// the time.
+type OOMRecord struct {
+	Time   time.Time
+	Policy OOMPolicy
+
+	MemoryLimitInBytes uint64
+	MemoryStat         garden.ContainerMemoryStat
+}
+
+// An ExtendedContainer supports LinuxLimits and LinuxMetrics, sending its
+// processes signals other than those garden knows of, and being stopped with
+// a grace period of the caller's choosing.
+type ExtendedContainer interface {
+	LimitLinux(LinuxLimits) error
+	CurrentLinuxLimits() (LinuxLimits, error)
+	LinuxMetrics() (LinuxMetrics, error)
+	OOMRecords() []OOMRecord
+	SignalProcess(processID uint32, signal garden.Signal, scope SignalScope) error
+	StopWithGracePeriod(gracePeriod time.Duration) error
+}
+
+// SignalScope is which processes a signal sent to a process reaches.
+type SignalScope string
+
+const (
+	// SignalScopeProcess is the process alone.
+	SignalScopeProcess SignalScope = "process"
+
+	// SignalScopeGroup is every process in the process's group.
+	SignalScopeGroup SignalScope = "group"
+
+	// SignalScopeTree is the process and all of its descendants, including
+	// those which have left its group.
+	SignalScopeTree SignalScope = "tree"
+)
+
+type UnknownSignalScopeError struct {
+	Scope SignalScope
+}
+
+func (e UnknownSignalScopeError) Error() string {
+	return fmt.Sprintf("unknown signal scope: %q", e.Scope)
+}
+
+type LinuxExtensionsNotSupportedError struct {
+	Handle string
+}
+
+func (e LinuxExtensionsNotSupportedError) Error() string {
+	return fmt.Sprintf("container does not support linux limits and metrics: %s", e.Handle)
+}
+
+// LimitLinux applies the non-nil limits to the container with the given
+// handle.
+func (b *LinuxBackend) LimitLinux(handle string, limits LinuxLimits) error {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.LimitLinux(limits)
+}
+
+// CurrentLinuxLimits reads back the limits currently in effect for the
+// container with the given handle.
+func (b *LinuxBackend) CurrentLinuxLimits(handle string) (LinuxLimits, error) {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return LinuxLimits{}, err
+	}
+
+	return container.CurrentLinuxLimits()
+}
+
+func (b *LinuxBackend) LinuxMetrics(handle string) (LinuxMetrics, error) {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return LinuxMetrics{}, err
+	}
+
+	return container.LinuxMetrics()
+}
+
+// OOMRecords returns the times the container with the given handle has run
+// out of memory, oldest first.
+func (b *LinuxBackend) OOMRecords(handle string) ([]OOMRecord, error) {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return container.OOMRecords(), nil
+}
+
+// SignalProcess sends the signal to a process in the container with the
+// given handle, and to the other processes in the scope.
+func (b *LinuxBackend) SignalProcess(handle string, processID uint32, signal garden.Signal, scope SignalScope) error {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.SignalProcess(processID, signal, scope)
+}
+
+// StopContainer stops the container with the given handle, giving its
+// processes the grace period to exit after being sent TERM before they are
+// killed, rather than the container's own.
+func (b *LinuxBackend) StopContainer(handle string, gracePeriod time.Duration) error {
+	container, err := b.extendedContainer(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.StopWithGracePeriod(gracePeriod)
+}
+
+func (b *LinuxBackend) extendedContainer(handle string) (ExtendedContainer, error) {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	extended, ok := container.(ExtendedContainer)
+	if !ok {
+		return nil, LinuxExtensionsNotSupportedError{Handle: handle}
+	}
+
+	return extended, nil
+}
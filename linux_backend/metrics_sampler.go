@@ -0,0 +1,338 @@
+package linux_backend
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+// MetricsHistory is what the sampler has recorded of a container's metrics:
+// the latest sample, the rates over the interval leading up to it, and the
+// rates over each of the intervals before that.
+type MetricsHistory struct {
+	Handle string
+
+	SampledAt time.Time
+	Latest    LinuxMetrics
+
+	Rates MetricsRates
+
+	// History is oldest first, and ends with the latest interval.
+	History []MetricsPoint
+}
+
+// MetricsRates are how quickly a container's counters went up between two
+// samples. CPUPercent is of a single core, so it exceeds 100 when the
+// container keeps more than one core busy.
+type MetricsRates struct {
+	CPUPercent float64
+
+	PageFaultsPerSecond      float64
+	MajorPageFaultsPerSecond float64
+
+	NetworkRxBytesPerSecond float64
+	NetworkTxBytesPerSecond float64
+
+	BlockIOReadBytesPerSecond  float64
+	BlockIOWriteBytesPerSecond float64
+}
+
+// MetricsPoint is the rates over the interval ending at SampledAt, along
+// with the memory the container was using then.
+type MetricsPoint struct {
+	SampledAt time.Time
+
+	MetricsRates
+
+	MemoryUsageInBytes uint64
+}
+
+type NoMetricsSamplesError struct {
+	Handle string
+}
+
+func (e NoMetricsSamplesError) Error() string {
+	return fmt.Sprintf("no metrics have been sampled for container: %s", e.Handle)
+}
+
+type MetricsSamplingDisabledError struct{}
+
+func (e MetricsSamplingDisabledError) Error() string {
+	return "metrics sampling is disabled"
+}
+
+type metricsSample struct {
+	sampledAt time.Time
+	metrics   LinuxMetrics
+}
+
+// metricsRing holds the most recent samples of a container, overwriting the
+// oldest once it is full.
+type metricsRing struct {
+	samples []metricsSample
+	next    int
+	full    bool
+}
+
+func newMetricsRing(size int) *metricsRing {
+	return &metricsRing{
+		samples: make([]metricsSample, size),
+	}
+}
+
+func (r *metricsRing) add(sample metricsSample) {
+	r.samples[r.next] = sample
+
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// ordered returns the samples oldest first.
+func (r *metricsRing) ordered() []metricsSample {
+	if !r.full {
+		return append([]metricsSample{}, r.samples[:r.next]...)
+	}
+
+	return append(append([]metricsSample{}, r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// MetricsSampler samples the metrics of every container in the repository
+// each interval, keeping the last historySize samples of each. Containers are
+// sampled as the bulk endpoints are: several at a time, giving up on any
+// which take too long. It runs as an ifrit.Runner.
+type MetricsSampler struct {
+	logger lager.Logger
+
+	bulk *bulkRunner
+
+	containers  ContainerRepository
+	clock       clock.Clock
+	interval    time.Duration
+	historySize int
+
+	rings      map[string]*metricsRing
+	ringsMutex sync.RWMutex
+}
+
+func NewMetricsSampler(
+	logger lager.Logger,
+	containers ContainerRepository,
+	clock clock.Clock,
+	interval time.Duration,
+	historySize int,
+	maxConcurrentOperations int,
+	operationTimeout time.Duration,
+) *MetricsSampler {
+	if historySize < 2 {
+		// rates need at least two samples
+		historySize = 2
+	}
+
+	logger = logger.Session("metrics-sampler")
+
+	return &MetricsSampler{
+		logger: logger,
+
+		bulk: newBulkRunner(logger, maxConcurrentOperations, operationTimeout),
+
+		containers:  containers,
+		clock:       clock,
+		interval:    interval,
+		historySize: historySize,
+
+		rings: make(map[string]*metricsRing),
+	}
+}
+
+func (s *MetricsSampler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.logger.Info("started", lager.Data{
+		"interval":     s.interval.String(),
+		"history-size": s.historySize,
+	})
+
+	close(ready)
+
+	s.sample()
+
+	for {
+		select {
+		case <-signals:
+			s.logger.Info("stopped")
+			return nil
+
+		case <-ticker.C():
+			s.sample()
+		}
+	}
+}
+
+// History returns what has been sampled of the container with the given
+// handle.
+func (s *MetricsSampler) History(handle string) (MetricsHistory, error) {
+	s.ringsMutex.RLock()
+	ring, found := s.rings[handle]
+
+	var samples []metricsSample
+	if found {
+		samples = ring.ordered()
+	}
+	s.ringsMutex.RUnlock()
+
+	if len(samples) == 0 {
+		return MetricsHistory{}, NoMetricsSamplesError{Handle: handle}
+	}
+
+	latest := samples[len(samples)-1]
+
+	history := MetricsHistory{
+		Handle: handle,
+
+		SampledAt: latest.sampledAt,
+		Latest:    latest.metrics,
+
+		History: []MetricsPoint{},
+	}
+
+	for i := 1; i < len(samples); i++ {
+		history.History = append(history.History, MetricsPoint{
+			SampledAt: samples[i].sampledAt,
+
+			MetricsRates: metricsRates(samples[i-1], samples[i]),
+
+			MemoryUsageInBytes: samples[i].metrics.MemoryStat.TotalUsageTowardLimit,
+		})
+	}
+
+	if len(history.History) > 0 {
+		history.Rates = history.History[len(history.History)-1].MetricsRates
+	}
+
+	return history, nil
+}
+
+func (s *MetricsSampler) sample() {
+	containers := s.containers.All()
+
+	results := s.bulk.forEachContainer("linux-metrics", containers, func(container Container) (interface{}, error) {
+		metrics, err := sampleMetrics(container)
+		if err != nil {
+			return nil, err
+		}
+
+		return metricsSample{
+			sampledAt: s.clock.Now(),
+			metrics:   metrics,
+		}, nil
+	})
+
+	sampled := map[string]bool{}
+
+	for i, container := range containers {
+		handle := container.Handle()
+
+		if results[i].err != nil {
+			s.logger.Error("failed-to-sample", results[i].err, lager.Data{
+				"handle": handle,
+			})
+			continue
+		}
+
+		sampled[handle] = true
+
+		s.ringsMutex.Lock()
+
+		ring, found := s.rings[handle]
+		if !found {
+			ring = newMetricsRing(s.historySize)
+			s.rings[handle] = ring
+		}
+
+		ring.add(results[i].value.(metricsSample))
+
+		s.ringsMutex.Unlock()
+	}
+
+	// forget destroyed containers, but keep the history of those which only
+	// failed to be sampled this time round
+	s.ringsMutex.Lock()
+	for handle := range s.rings {
+		if !sampled[handle] && !s.exists(handle) {
+			delete(s.rings, handle)
+		}
+	}
+	s.ringsMutex.Unlock()
+}
+
+func (s *MetricsSampler) exists(handle string) bool {
+	_, err := s.containers.FindByHandle(handle)
+	return err == nil
+}
+
+func sampleMetrics(container Container) (LinuxMetrics, error) {
+	if extended, ok := container.(ExtendedContainer); ok {
+		return extended.LinuxMetrics()
+	}
+
+	metrics, err := container.Metrics()
+	if err != nil {
+		return LinuxMetrics{}, err
+	}
+
+	return LinuxMetrics{Metrics: metrics}, nil
+}
+
+func metricsRates(from, to metricsSample) MetricsRates {
+	seconds := to.sampledAt.Sub(from.sampledAt).Seconds()
+	if seconds <= 0 {
+		return MetricsRates{}
+	}
+
+	perSecond := func(from, to uint64) float64 {
+		// counters go down when a container's cgroup is recreated; there is
+		// no telling how much they went up in the meantime
+		if to < from {
+			return 0
+		}
+
+		return float64(to-from) / seconds
+	}
+
+	a, b := from.metrics, to.metrics
+
+	return MetricsRates{
+		CPUPercent: perSecond(a.CPUStat.Usage, b.CPUStat.Usage) / float64(time.Second) * 100,
+
+		PageFaultsPerSecond:      perSecond(a.MemoryStat.TotalPgfault, b.MemoryStat.TotalPgfault),
+		MajorPageFaultsPerSecond: perSecond(a.MemoryStat.TotalPgmajfault, b.MemoryStat.TotalPgmajfault),
+
+		NetworkRxBytesPerSecond: perSecond(a.NetworkStat.RxBytes, b.NetworkStat.RxBytes),
+		NetworkTxBytesPerSecond: perSecond(a.NetworkStat.TxBytes, b.NetworkStat.TxBytes),
+
+		BlockIOReadBytesPerSecond:  perSecond(a.BlockIOStat.ReadBytes, b.BlockIOStat.ReadBytes),
+		BlockIOWriteBytesPerSecond: perSecond(a.BlockIOStat.WriteBytes, b.BlockIOStat.WriteBytes),
+	}
+}
+
+// MetricsHistory returns what the sampler has recorded of the container with
+// the given handle.
+func (b *LinuxBackend) MetricsHistory(handle string) (MetricsHistory, error) {
+	if b.metricsSampler == nil {
+		return MetricsHistory{}, MetricsSamplingDisabledError{}
+	}
+
+	_, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return MetricsHistory{}, err
+	}
+
+	return b.metricsSampler.History(handle)
+}
@@ -0,0 +1,339 @@
+package linux_backend_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+var _ = Describe("MetricsSampler", func() {
+	var containerRepo linux_backend.ContainerRepository
+	var fakeClock *fakeclock.FakeClock
+	var container *sampledContainer
+	var historySize int
+	var operationTimeout time.Duration
+
+	var sampler *linux_backend.MetricsSampler
+	var process ifrit.Process
+
+	interval := 10 * time.Second
+
+	samples := func() int {
+		history, err := sampler.History("some-handle")
+		if err != nil {
+			return 0
+		}
+
+		return len(history.History) + 1
+	}
+
+	sampledAt := func() time.Time {
+		history, _ := sampler.History("some-handle")
+		return history.SampledAt
+	}
+
+	tick := func() {
+		fakeClock.Increment(interval)
+		Eventually(sampledAt).Should(Equal(fakeClock.Now()))
+	}
+
+	BeforeEach(func() {
+		containerRepo = container_repository.New()
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		historySize = 60
+		operationTimeout = 5 * time.Second
+
+		container = &sampledContainer{
+			FakeContainer: fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"}),
+		}
+
+		containerRepo.Add(container)
+	})
+
+	JustBeforeEach(func() {
+		sampler = linux_backend.NewMetricsSampler(lagertest.NewTestLogger("test"), containerRepo, fakeClock, interval, historySize, 4, operationTimeout)
+		process = ifrit.Invoke(sampler)
+
+		Eventually(samples).Should(Equal(1))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("samples every container straight away", func() {
+		history, err := sampler.History("some-handle")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(history.Handle).To(Equal("some-handle"))
+		Expect(history.SampledAt).To(Equal(time.Unix(1000, 0)))
+		Expect(history.Rates).To(BeZero())
+		Expect(history.History).To(BeEmpty())
+	})
+
+	It("computes the rates over each interval", func() {
+		metrics := linux_backend.LinuxMetrics{}
+		metrics.CPUStat.Usage = uint64(5 * time.Second)
+		metrics.MemoryStat.TotalPgfault = 1000
+		metrics.MemoryStat.TotalPgmajfault = 10
+		metrics.MemoryStat.TotalUsageTowardLimit = 4096
+		metrics.NetworkStat = linux_backend.NetworkStat{RxBytes: 2000, TxBytes: 500}
+		metrics.BlockIOStat = linux_backend.BlockIOStat{ReadBytes: 100, WriteBytes: 300}
+		container.setMetrics(metrics)
+
+		tick()
+
+		history, err := sampler.History("some-handle")
+		Expect(err).ToNot(HaveOccurred())
+
+		rates := linux_backend.MetricsRates{
+			CPUPercent: 50,
+
+			PageFaultsPerSecond:      100,
+			MajorPageFaultsPerSecond: 1,
+
+			NetworkRxBytesPerSecond: 200,
+			NetworkTxBytesPerSecond: 50,
+
+			BlockIOReadBytesPerSecond:  10,
+			BlockIOWriteBytesPerSecond: 30,
+		}
+
+		Expect(history.SampledAt).To(Equal(time.Unix(1010, 0)))
+		Expect(history.Latest).To(Equal(metrics))
+		Expect(history.Rates).To(Equal(rates))
+		Expect(history.History).To(Equal([]linux_backend.MetricsPoint{
+			{
+				SampledAt:          time.Unix(1010, 0),
+				MetricsRates:       rates,
+				MemoryUsageInBytes: 4096,
+			},
+		}))
+	})
+
+	Context("when a counter goes down", func() {
+		BeforeEach(func() {
+			metrics := linux_backend.LinuxMetrics{}
+			metrics.CPUStat.Usage = uint64(5 * time.Second)
+			container.setMetrics(metrics)
+		})
+
+		It("reports no rate for that interval", func() {
+			container.setMetrics(linux_backend.LinuxMetrics{})
+
+			tick()
+
+			history, err := sampler.History("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history.Rates.CPUPercent).To(BeZero())
+		})
+	})
+
+	Context("when the history is full", func() {
+		BeforeEach(func() {
+			historySize = 3
+		})
+
+		It("keeps only the most recent samples", func() {
+			for i := 0; i < 4; i++ {
+				tick()
+			}
+
+			history, err := sampler.History("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(history.History).To(HaveLen(2))
+			Expect(history.History[0].SampledAt).To(Equal(time.Unix(1030, 0)))
+			Expect(history.History[1].SampledAt).To(Equal(time.Unix(1040, 0)))
+		})
+	})
+
+	Context("when sampling a container fails", func() {
+		It("keeps what was sampled before", func() {
+			container.setError(errors.New("oh no!"))
+
+			fakeClock.Increment(interval)
+			Consistently(samples).Should(Equal(1))
+
+			container.setError(nil)
+
+			tick()
+
+			history, err := sampler.History("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history.History).To(HaveLen(1))
+			Expect(history.History[0].SampledAt).To(Equal(time.Unix(1020, 0)))
+		})
+	})
+
+	Context("when sampling a container hangs", func() {
+		var hung *sampledContainer
+
+		BeforeEach(func() {
+			operationTimeout = 50 * time.Millisecond
+
+			hung = &sampledContainer{
+				FakeContainer: fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "hung-handle"}),
+				hang:          make(chan struct{}),
+			}
+
+			containerRepo.Add(hung)
+		})
+
+		AfterEach(func() {
+			close(hung.hang)
+		})
+
+		It("gives up on it and carries on sampling the rest", func() {
+			tick()
+			tick()
+
+			_, err := sampler.History("hung-handle")
+			Expect(err).To(Equal(linux_backend.NoMetricsSamplesError{Handle: "hung-handle"}))
+		})
+	})
+
+	Context("when a container is destroyed", func() {
+		It("forgets it", func() {
+			containerRepo.Delete(container)
+
+			fakeClock.Increment(interval)
+
+			Eventually(func() error {
+				_, err := sampler.History("some-handle")
+				return err
+			}).Should(Equal(linux_backend.NoMetricsSamplesError{Handle: "some-handle"}))
+		})
+	})
+
+	Context("when the container does not support linux metrics", func() {
+		BeforeEach(func() {
+			plain := fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "plain-handle"})
+			plain.MetricsReturns(garden.Metrics{
+				CPUStat: garden.ContainerCPUStat{Usage: 42},
+			}, nil)
+
+			containerRepo.Add(plain)
+		})
+
+		It("samples its garden metrics", func() {
+			history, err := sampler.History("plain-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(history.Latest.CPUStat.Usage).To(Equal(uint64(42)))
+		})
+	})
+
+	Describe("the backend's metrics history", func() {
+		var linuxBackend *linux_backend.LinuxBackend
+
+		JustBeforeEach(func() {
+			linuxBackend = linux_backend.New(
+				lagertest.NewTestLogger("test"),
+				fake_container_pool.New(),
+				containerRepo,
+				nil,
+				nil,
+				nil,
+				"",
+				1,
+				1,
+				time.Second,
+				nil,
+				sampler,
+			)
+		})
+
+		It("is what the sampler has recorded", func() {
+			history, err := linuxBackend.MetricsHistory("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(history.Handle).To(Equal("some-handle"))
+			Expect(history.SampledAt).To(Equal(time.Unix(1000, 0)))
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ContainerNotFoundError", func() {
+				_, err := linuxBackend.MetricsHistory("bogus-handle")
+				Expect(err).To(Equal(garden.ContainerNotFoundError{Handle: "bogus-handle"}))
+			})
+		})
+
+		Context("when sampling is disabled", func() {
+			It("returns MetricsSamplingDisabledError", func() {
+				linuxBackend = linux_backend.New(lagertest.NewTestLogger("test"), fake_container_pool.New(), containerRepo, nil, nil, nil, "", 1, 1, time.Second, nil, nil)
+
+				_, err := linuxBackend.MetricsHistory("some-handle")
+				Expect(err).To(Equal(linux_backend.MetricsSamplingDisabledError{}))
+			})
+		})
+	})
+})
+
+type sampledContainer struct {
+	*fake_container_pool.FakeContainer
+
+	metrics linux_backend.LinuxMetrics
+	err     error
+	mutex   sync.Mutex
+
+	// hang, if set, holds up sampling until it is closed
+	hang chan struct{}
+}
+
+func (c *sampledContainer) setMetrics(metrics linux_backend.LinuxMetrics) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.metrics = metrics
+}
+
+func (c *sampledContainer) setError(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.err = err
+}
+
+func (c *sampledContainer) LimitLinux(linux_backend.LinuxLimits) error {
+	return nil
+}
+
+func (c *sampledContainer) CurrentLinuxLimits() (linux_backend.LinuxLimits, error) {
+	return linux_backend.LinuxLimits{}, nil
+}
+
+func (c *sampledContainer) LinuxMetrics() (linux_backend.LinuxMetrics, error) {
+	if c.hang != nil {
+		<-c.hang
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.metrics, c.err
+}
+
+func (c *sampledContainer) OOMRecords() []linux_backend.OOMRecord {
+	return nil
+}
+
+func (c *sampledContainer) SignalProcess(uint32, garden.Signal, linux_backend.SignalScope) error {
+	return nil
+}
+
+func (c *sampledContainer) StopWithGracePeriod(time.Duration) error {
+	return nil
+}
@@ -6,18 +6,33 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-
+	"sort"
+	"syscall"
 	"time"
 
 	"github.com/cloudfoundry/gunk/command_runner"
 )
 
 // Kills a process by invoking ./bin/wsh in the given container path using
-// a PID read from the given pidFile
+// a PID read from the given pidFile. wshd starts each process in a session of
+// its own, so the PID is also the process's group ID.
+//
+// Signalling the process's tree is done from the host instead, using the
+// container's cgroup to find its descendants.
 type NamespacedSignaller struct {
 	Runner        command_runner.CommandRunner
 	ContainerPath string
 	PidFilePath   string
+
+	// CgroupProcsPath is the cgroup.procs file of the container's cgroup.
+	CgroupProcsPath string
+
+	// ProcPath is where the host's procfs is mounted; /proc if empty.
+	ProcPath string
+
+	// Kill sends a signal to a process in the container's tree by its host
+	// PID; syscall.Kill if nil.
+	Kill func(pid int, signal syscall.Signal) error
 }
 
 func (n *NamespacedSignaller) Signal(signal os.Signal) error {
@@ -26,9 +41,139 @@ func (n *NamespacedSignaller) Signal(signal os.Signal) error {
 		return err
 	}
 
+	return n.kill(signal, fmt.Sprintf("%d", pid))
+}
+
+func (n *NamespacedSignaller) SignalGroup(signal os.Signal) error {
+	pid, err := pidFromFile(n.PidFilePath)
+	if err != nil {
+		return err
+	}
+
+	return n.kill(signal, "--", fmt.Sprintf("-%d", pid))
+}
+
+// SignalTree sends the signal to the process and all of its descendants,
+// including those which have left its group. The tree is stopped first, and
+// read again until no new children turn up, so none can be forked and left
+// behind while it is being signalled. Unless the signal is KILL or STOP, the
+// processes it stopped are then continued; any which were stopped before are
+// left stopped.
+//
+// If the process is not in the cgroup, or the kernel does not report PIDs
+// in the container's namespace, only the process is signalled.
+func (n *NamespacedSignaller) SignalTree(signal os.Signal) error {
+	pid, err := pidFromFile(n.PidFilePath)
+	if err != nil {
+		return err
+	}
+
+	hostSignal, ok := signal.(syscall.Signal)
+	if !ok {
+		return fmt.Errorf("linux_backend: can't send %s to a process tree", signal)
+	}
+
+	procPath := n.ProcPath
+	if procPath == "" {
+		procPath = "/proc"
+	}
+
+	seen := map[int]bool{}
+	pids := []int{}
+	stopped := []int{}
+
+	for {
+		tree, err := readProcessTree(n.CgroupProcsPath, procPath)
+		if err != nil {
+			return err
+		}
+
+		hostPID, found := tree.hostPIDs[pid]
+		if !found {
+			if len(pids) == 0 {
+				return n.Signal(signal)
+			}
+
+			break
+		}
+
+		unseen := 0
+		for _, descendant := range tree.descendants(hostPID) {
+			if seen[descendant] {
+				continue
+			}
+
+			seen[descendant] = true
+			unseen++
+
+			if tree.stopped[descendant] {
+				pids = append(pids, descendant)
+				continue
+			}
+
+			// processes may exit before they are stopped, and are left out
+			err := n.hostKill(descendant, syscall.SIGSTOP)
+			if err == syscall.ESRCH {
+				continue
+			}
+
+			if err != nil {
+				n.hostKillAll(stopped, syscall.SIGCONT)
+				return err
+			}
+
+			pids = append(pids, descendant)
+			stopped = append(stopped, descendant)
+		}
+
+		if unseen == 0 {
+			break
+		}
+	}
+
+	sort.Ints(pids)
+	sort.Ints(stopped)
+
+	err = n.hostKillAll(pids, hostSignal)
+	if err != nil {
+		n.hostKillAll(stopped, syscall.SIGCONT)
+		return err
+	}
+
+	if hostSignal != syscall.SIGKILL && hostSignal != syscall.SIGSTOP {
+		return n.hostKillAll(stopped, syscall.SIGCONT)
+	}
+
+	return nil
+}
+
+// hostKillAll signals each of the host PIDs, stopping at the first error.
+// Processes which have gone, e.g. killed by someone else, are skipped.
+func (n *NamespacedSignaller) hostKillAll(pids []int, signal syscall.Signal) error {
+	for _, pid := range pids {
+		err := n.hostKill(pid, signal)
+		if err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *NamespacedSignaller) hostKill(pid int, signal syscall.Signal) error {
+	if n.Kill != nil {
+		return n.Kill(pid, signal)
+	}
+
+	return syscall.Kill(pid, signal)
+}
+
+func (n *NamespacedSignaller) kill(signal os.Signal, target ...string) error {
 	return n.Runner.Run(exec.Command(filepath.Join(n.ContainerPath, "bin/wsh"),
-		"--socket", filepath.Join(n.ContainerPath, "run/wshd.sock"),
-		"kill", fmt.Sprintf("-%d", signal), fmt.Sprintf("%d", pid)))
+		append([]string{
+			"--socket", filepath.Join(n.ContainerPath, "run/wshd.sock"),
+			"kill", fmt.Sprintf("-%d", signal),
+		}, target...)...))
 }
 
 func pidFromFile(pidFilePath string) (int, error) {
@@ -1,9 +1,13 @@
 package linux_backend_test
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"syscall"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -41,6 +45,332 @@ var _ = Describe("Namespaced Signaller", func() {
 			}))
 	})
 
+	It("sends any signal", func() {
+		tmp, err := ioutil.TempDir("", "namespacedsignaller")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(tmp)
+
+		pidFile := filepath.Join(tmp, "thepid.file")
+
+		fakeRunner := fake_command_runner.New()
+		signaller := &linux_backend.NamespacedSignaller{
+			Runner:        fakeRunner,
+			ContainerPath: "/fish/finger",
+			PidFilePath:   pidFile,
+		}
+
+		Expect(ioutil.WriteFile(pidFile, []byte(" 12345\n"), 0755)).To(Succeed())
+
+		Expect(signaller.Signal(syscall.SIGUSR2)).To(Succeed())
+		Expect(fakeRunner).To(HaveExecutedSerially(
+			fake_command_runner.CommandSpec{
+				Path: "/fish/finger/bin/wsh",
+				Args: []string{
+					"--socket", "/fish/finger/run/wshd.sock",
+					"kill", "-12", "12345",
+				},
+			}))
+	})
+
+	It("signals the process's group using ./bin/wsh", func() {
+		tmp, err := ioutil.TempDir("", "namespacedsignaller")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(tmp)
+
+		pidFile := filepath.Join(tmp, "thepid.file")
+
+		fakeRunner := fake_command_runner.New()
+		signaller := &linux_backend.NamespacedSignaller{
+			Runner:        fakeRunner,
+			ContainerPath: "/fish/finger",
+			PidFilePath:   pidFile,
+		}
+
+		Expect(ioutil.WriteFile(pidFile, []byte(" 12345\n"), 0755)).To(Succeed())
+
+		Expect(signaller.SignalGroup(syscall.SIGHUP)).To(Succeed())
+		Expect(fakeRunner).To(HaveExecutedSerially(
+			fake_command_runner.CommandSpec{
+				Path: "/fish/finger/bin/wsh",
+				Args: []string{
+					"--socket", "/fish/finger/run/wshd.sock",
+					"kill", "-1", "--", "-12345",
+				},
+			}))
+	})
+
+	Describe("signalling a process's tree", func() {
+		type kill struct {
+			pid    int
+			signal syscall.Signal
+		}
+
+		var (
+			tmp        string
+			procPath   string
+			procsPath  string
+			fakeRunner *fake_command_runner.FakeCommandRunner
+			signaller  *linux_backend.NamespacedSignaller
+
+			kills    []kill
+			whenKill func(pid int, signal syscall.Signal) error
+		)
+
+		writeStatus := func(pid, ppid, nspid int, state string) {
+			Expect(os.MkdirAll(filepath.Join(procPath, strconv.Itoa(pid)), 0755)).To(Succeed())
+
+			status := fmt.Sprintf("Name:\tsh\nState:\t%s\nPid:\t%d\nPPid:\t%d\nNSpid:\t%d\t%d\n", state, pid, ppid, pid, nspid)
+			Expect(ioutil.WriteFile(filepath.Join(procPath, strconv.Itoa(pid), "status"), []byte(status), 0644)).To(Succeed())
+		}
+
+		// process writes a status file for a process in the container, whose
+		// PID in the container's namespace is nspid
+		process := func(pid, ppid, nspid int) {
+			writeStatus(pid, ppid, nspid, "S (sleeping)")
+
+			procs, err := os.OpenFile(procsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			Expect(err).ToNot(HaveOccurred())
+			defer procs.Close()
+
+			_, err = fmt.Fprintf(procs, "%d\n", pid)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmp, err = ioutil.TempDir("", "namespacedsignaller")
+			Expect(err).ToNot(HaveOccurred())
+
+			procPath = filepath.Join(tmp, "proc")
+			procsPath = filepath.Join(tmp, "cgroup.procs")
+
+			pidFile := filepath.Join(tmp, "thepid.file")
+			Expect(ioutil.WriteFile(pidFile, []byte("5\n"), 0755)).To(Succeed())
+
+			kills = []kill{}
+			whenKill = nil
+
+			fakeRunner = fake_command_runner.New()
+			signaller = &linux_backend.NamespacedSignaller{
+				Runner:          fakeRunner,
+				ContainerPath:   "/fish/finger",
+				PidFilePath:     pidFile,
+				CgroupProcsPath: procsPath,
+				ProcPath:        procPath,
+				Kill: func(pid int, signal syscall.Signal) error {
+					kills = append(kills, kill{pid, signal})
+
+					if whenKill != nil {
+						return whenKill(pid, signal)
+					}
+
+					return nil
+				},
+			}
+
+			// wshd
+			process(100, 50, 1)
+
+			// the process, a child of it, and a grandchild which has started
+			// a session of its own
+			process(101, 100, 5)
+			process(102, 101, 6)
+			process(103, 102, 7)
+
+			// another of wshd's processes
+			process(104, 100, 8)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmp)
+		})
+
+		It("stops the tree, then kills every process in it", func() {
+			Expect(signaller.SignalTree(os.Kill)).To(Succeed())
+
+			Expect(kills).To(Equal([]kill{
+				{101, syscall.SIGSTOP},
+				{102, syscall.SIGSTOP},
+				{103, syscall.SIGSTOP},
+				{101, syscall.SIGKILL},
+				{102, syscall.SIGKILL},
+				{103, syscall.SIGKILL},
+			}))
+
+			Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+		})
+
+		It("continues the tree after sending any other signal", func() {
+			Expect(signaller.SignalTree(syscall.SIGTERM)).To(Succeed())
+
+			Expect(kills).To(Equal([]kill{
+				{101, syscall.SIGSTOP},
+				{102, syscall.SIGSTOP},
+				{103, syscall.SIGSTOP},
+				{101, syscall.SIGTERM},
+				{102, syscall.SIGTERM},
+				{103, syscall.SIGTERM},
+				{101, syscall.SIGCONT},
+				{102, syscall.SIGCONT},
+				{103, syscall.SIGCONT},
+			}))
+		})
+
+		Context("when a process in the tree was already stopped", func() {
+			BeforeEach(func() {
+				writeStatus(102, 101, 6, "T (stopped)")
+			})
+
+			It("signals it without continuing it", func() {
+				Expect(signaller.SignalTree(syscall.SIGTERM)).To(Succeed())
+
+				Expect(kills).To(Equal([]kill{
+					{101, syscall.SIGSTOP},
+					{103, syscall.SIGSTOP},
+					{101, syscall.SIGTERM},
+					{102, syscall.SIGTERM},
+					{103, syscall.SIGTERM},
+					{101, syscall.SIGCONT},
+					{103, syscall.SIGCONT},
+				}))
+			})
+		})
+
+		Context("when a child is forked while the tree is being stopped", func() {
+			BeforeEach(func() {
+				forked := false
+
+				whenKill = func(pid int, signal syscall.Signal) error {
+					if !forked {
+						forked = true
+						process(105, 103, 9)
+					}
+
+					return nil
+				}
+			})
+
+			It("stops and kills it too", func() {
+				Expect(signaller.SignalTree(os.Kill)).To(Succeed())
+
+				Expect(kills).To(Equal([]kill{
+					{101, syscall.SIGSTOP},
+					{102, syscall.SIGSTOP},
+					{103, syscall.SIGSTOP},
+					{105, syscall.SIGSTOP},
+					{101, syscall.SIGKILL},
+					{102, syscall.SIGKILL},
+					{103, syscall.SIGKILL},
+					{105, syscall.SIGKILL},
+				}))
+			})
+		})
+
+		Context("when a process exits while the tree is being read", func() {
+			BeforeEach(func() {
+				Expect(os.RemoveAll(filepath.Join(procPath, "103"))).To(Succeed())
+			})
+
+			It("leaves it out", func() {
+				Expect(signaller.SignalTree(os.Kill)).To(Succeed())
+
+				Expect(kills).To(Equal([]kill{
+					{101, syscall.SIGSTOP},
+					{102, syscall.SIGSTOP},
+					{101, syscall.SIGKILL},
+					{102, syscall.SIGKILL},
+				}))
+			})
+		})
+
+		Context("when a process exits before it is stopped", func() {
+			BeforeEach(func() {
+				whenKill = func(pid int, signal syscall.Signal) error {
+					if pid == 103 {
+						return syscall.ESRCH
+					}
+
+					return nil
+				}
+			})
+
+			It("leaves it out", func() {
+				Expect(signaller.SignalTree(syscall.SIGTERM)).To(Succeed())
+
+				Expect(kills).To(Equal([]kill{
+					{101, syscall.SIGSTOP},
+					{102, syscall.SIGSTOP},
+					{103, syscall.SIGSTOP},
+					{101, syscall.SIGTERM},
+					{102, syscall.SIGTERM},
+					{101, syscall.SIGCONT},
+					{102, syscall.SIGCONT},
+				}))
+			})
+		})
+
+		Context("when sending the signal fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				whenKill = func(pid int, signal syscall.Signal) error {
+					if signal == syscall.SIGTERM {
+						return disaster
+					}
+
+					return nil
+				}
+			})
+
+			It("returns the error, and continues the processes it stopped", func() {
+				Expect(signaller.SignalTree(syscall.SIGTERM)).To(Equal(disaster))
+
+				Expect(kills).To(Equal([]kill{
+					{101, syscall.SIGSTOP},
+					{102, syscall.SIGSTOP},
+					{103, syscall.SIGSTOP},
+					{101, syscall.SIGTERM},
+					{101, syscall.SIGCONT},
+					{102, syscall.SIGCONT},
+					{103, syscall.SIGCONT},
+				}))
+			})
+		})
+
+		Context("when the process is not in the cgroup", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(procsPath, []byte("100\n104\n"), 0644)).To(Succeed())
+			})
+
+			It("signals the process using ./bin/wsh", func() {
+				Expect(signaller.SignalTree(os.Kill)).To(Succeed())
+
+				Expect(kills).To(BeEmpty())
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/fish/finger/bin/wsh",
+						Args: []string{
+							"--socket", "/fish/finger/run/wshd.sock",
+							"kill", "-9", "5",
+						},
+					}))
+			})
+		})
+
+		Context("when the cgroup cannot be read", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(procsPath)).To(Succeed())
+			})
+
+			It("returns an error without signalling anything", func() {
+				Expect(signaller.SignalTree(os.Kill)).To(MatchError(HavePrefix("linux_backend: can't read cgroup procs")))
+				Expect(kills).To(BeEmpty())
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+			})
+		})
+	})
+
 	It("returns an appropriate error when the pidfile is not present", func() {
 		fakeRunner := fake_command_runner.New()
 		signaller := &linux_backend.NamespacedSignaller{
@@ -0,0 +1,51 @@
+package linux_backend
+
+import "fmt"
+
+// A Pauser is a container whose processes can be frozen and thawed.
+type Pauser interface {
+	Pause() error
+	Resume() error
+}
+
+type PauseNotSupportedError struct {
+	Handle string
+}
+
+func (e PauseNotSupportedError) Error() string {
+	return fmt.Sprintf("container cannot be paused: %s", e.Handle)
+}
+
+// Pause freezes the processes of the container with the given handle.
+func (b *LinuxBackend) Pause(handle string) error {
+	pauser, err := b.pauser(handle)
+	if err != nil {
+		return err
+	}
+
+	return pauser.Pause()
+}
+
+// Resume thaws the processes of the paused container with the given handle.
+func (b *LinuxBackend) Resume(handle string) error {
+	pauser, err := b.pauser(handle)
+	if err != nil {
+		return err
+	}
+
+	return pauser.Resume()
+}
+
+func (b *LinuxBackend) pauser(handle string) (Pauser, error) {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	pauser, ok := container.(Pauser)
+	if !ok {
+		return nil, PauseNotSupportedError{Handle: handle}
+	}
+
+	return pauser, nil
+}
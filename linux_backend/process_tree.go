@@ -0,0 +1,120 @@
+package linux_backend
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// processTree is a snapshot of the processes in a container's cgroup, as
+// seen from the host.
+type processTree struct {
+	// children of each host PID
+	children map[int][]int
+
+	// host PID of each PID in the container's namespace
+	hostPIDs map[int]int
+
+	// host PIDs which are stopped, by a signal or a tracer
+	stopped map[int]bool
+}
+
+// readProcessTree reads the PIDs in the cgroup's procs file, and the parent
+// and namespaced PID of each from procPath. Processes which exit while it is
+// being read are left out.
+func readProcessTree(cgroupProcsPath, procPath string) (*processTree, error) {
+	procs, err := ioutil.ReadFile(cgroupProcsPath)
+	if err != nil {
+		return nil, fmt.Errorf("linux_backend: can't read cgroup procs: %s", err)
+	}
+
+	tree := &processTree{
+		children: map[int][]int{},
+		hostPIDs: map[int]int{},
+		stopped:  map[int]bool{},
+	}
+
+	for _, field := range strings.Fields(string(procs)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("linux_backend: can't parse cgroup procs: %s", err)
+		}
+
+		ppid, nspid, state, err := readProcessStatus(filepath.Join(procPath, field, "status"))
+		if err != nil {
+			continue
+		}
+
+		tree.children[ppid] = append(tree.children[ppid], pid)
+
+		if nspid != 0 {
+			tree.hostPIDs[nspid] = pid
+		}
+
+		if state == "T" || state == "t" {
+			tree.stopped[pid] = true
+		}
+	}
+
+	return tree, nil
+}
+
+// descendants returns the host PIDs of the process and everything below it,
+// in order.
+func (t *processTree) descendants(hostPID int) []int {
+	pids := []int{hostPID}
+
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, t.children[pids[i]]...)
+	}
+
+	sort.Ints(pids)
+
+	return pids
+}
+
+// readProcessStatus returns the parent of a process, its PID in the
+// innermost namespace it is in, which is 0 if the kernel does not say, and
+// the letter for its state.
+func readProcessStatus(statusPath string) (int, int, string, error) {
+	status, err := os.Open(statusPath)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer status.Close()
+
+	var ppid, nspid int
+	var state string
+
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "State:":
+			state = fields[1]
+
+		case "PPid:":
+			ppid, err = strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, 0, "", err
+			}
+
+		case "NSpid:":
+			nspid, err = strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return 0, 0, "", err
+			}
+		}
+	}
+
+	return ppid, nspid, state, scanner.Err()
+}
@@ -0,0 +1,261 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/pivotal-golang/lager"
+)
+
+// RestoreReport describes what happened to each container known to the
+// backend when it last started.
+type RestoreReport struct {
+	Restored []string
+	Failed   []QuarantinedSnapshot
+	Pruned   []string
+}
+
+// QuarantinedSnapshot is a snapshot that failed to restore. It is kept, along
+// with the container's depot entry, until it is either restored or
+// discarded.
+type QuarantinedSnapshot struct {
+	ID            string
+	Error         string
+	QuarantinedAt time.Time
+}
+
+type SnapshotNotQuarantinedError struct {
+	ID string
+}
+
+func (e SnapshotNotQuarantinedError) Error() string {
+	return fmt.Sprintf("no quarantined snapshot for container: %s", e.ID)
+}
+
+func (b *LinuxBackend) RestoreReport() RestoreReport {
+	b.reportMutex.RLock()
+	defer b.reportMutex.RUnlock()
+
+	report := RestoreReport{
+		Restored: make([]string, len(b.report.Restored)),
+		Failed:   make([]QuarantinedSnapshot, len(b.report.Failed)),
+		Pruned:   make([]string, len(b.report.Pruned)),
+	}
+
+	copy(report.Restored, b.report.Restored)
+	copy(report.Failed, b.report.Failed)
+	copy(report.Pruned, b.report.Pruned)
+
+	return report
+}
+
+// Quarantined lists every snapshot currently in quarantine, including those
+// left over from earlier starts.
+func (b *LinuxBackend) Quarantined() ([]QuarantinedSnapshot, error) {
+	quarantined := []QuarantinedSnapshot{}
+
+	if b.snapshotsPath == "" {
+		return quarantined, nil
+	}
+
+	entries, err := ioutil.ReadDir(b.quarantinePath())
+	if os.IsNotExist(err) {
+		return quarantined, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		failure, err := b.readQuarantineError(entry.Name())
+		if err != nil {
+			failure = QuarantinedSnapshot{ID: entry.Name(), Error: err.Error()}
+		}
+
+		quarantined = append(quarantined, failure)
+	}
+
+	return quarantined, nil
+}
+
+// RestoreQuarantined retries restoring a quarantined snapshot, e.g. once the
+// cause of the original failure has been fixed. If it fails again the
+// snapshot stays in quarantine with the new error.
+func (b *LinuxBackend) RestoreQuarantined(id string) (garden.Container, error) {
+	err := b.admitOperation()
+	if err != nil {
+		return nil, err
+	}
+
+	defer b.endAdmittedOperation()
+
+	rLog := b.logger.Session("restore-quarantined", lager.Data{
+		"id": id,
+	})
+
+	quarantined, err := b.quarantinedPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path.Join(quarantined, "snapshot"))
+	if os.IsNotExist(err) {
+		return nil, SnapshotNotQuarantinedError{id}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := b.containerPool.Restore(file)
+	file.Close()
+	if err != nil {
+		rLog.Error("failed-to-restore", err)
+		b.writeQuarantineError(id, err)
+		return nil, err
+	}
+
+	b.containerRepo.Add(container)
+
+	err = os.RemoveAll(quarantined)
+	if err != nil {
+		rLog.Error("failed-to-remove-quarantined-snapshot", err)
+	}
+
+	b.reportMutex.Lock()
+	b.report.Restored = append(b.report.Restored, id)
+	b.report.Failed = withoutFailure(b.report.Failed, id)
+	b.reportMutex.Unlock()
+
+	rLog.Info("restored")
+
+	b.emit(event_hub.Restored, container)
+
+	return container, nil
+}
+
+// DiscardQuarantined gives up on a quarantined snapshot. The container's
+// remaining resources are pruned the next time the backend starts.
+func (b *LinuxBackend) DiscardQuarantined(id string) error {
+	quarantined, err := b.quarantinedPath(id)
+	if err != nil {
+		return err
+	}
+
+	b.logger.Info("discard-quarantined", lager.Data{
+		"id": id,
+	})
+
+	err = os.RemoveAll(quarantined)
+	if err != nil {
+		return err
+	}
+
+	b.reportMutex.Lock()
+	b.report.Failed = withoutFailure(b.report.Failed, id)
+	b.reportMutex.Unlock()
+
+	return nil
+}
+
+func (b *LinuxBackend) quarantinePath() string {
+	return path.Clean(b.snapshotsPath) + "-quarantine"
+}
+
+// quarantinedPath returns where the snapshot with the given ID is
+// quarantined. The ID comes from the admin API, so it is only accepted if it
+// names an entry directly in the quarantine directory; anything else, such as
+// "..", could otherwise point outside it.
+func (b *LinuxBackend) quarantinedPath(id string) (string, error) {
+	if b.snapshotsPath == "" {
+		return "", SnapshotNotQuarantinedError{id}
+	}
+
+	entries, err := ioutil.ReadDir(b.quarantinePath())
+	if os.IsNotExist(err) {
+		return "", SnapshotNotQuarantinedError{id}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() == id {
+			return path.Join(b.quarantinePath(), id), nil
+		}
+	}
+
+	return "", SnapshotNotQuarantinedError{id}
+}
+
+// quarantine moves a snapshot which failed to restore into quarantine. The
+// failure is returned even if the snapshot cannot be moved, in which case it
+// is left where it is.
+func (b *LinuxBackend) quarantine(id string, snapshotPath string, cause error) (QuarantinedSnapshot, error) {
+	quarantined := path.Join(b.quarantinePath(), id)
+
+	failure := QuarantinedSnapshot{
+		ID:            id,
+		Error:         cause.Error(),
+		QuarantinedAt: time.Now(),
+	}
+
+	err := os.MkdirAll(quarantined, 0755)
+	if err != nil {
+		return failure, err
+	}
+
+	err = os.Rename(snapshotPath, path.Join(quarantined, "snapshot"))
+	if err != nil {
+		os.Remove(quarantined)
+		return failure, err
+	}
+
+	return b.writeQuarantineError(id, cause)
+}
+
+func (b *LinuxBackend) writeQuarantineError(id string, cause error) (QuarantinedSnapshot, error) {
+	failure := QuarantinedSnapshot{
+		ID:            id,
+		Error:         cause.Error(),
+		QuarantinedAt: time.Now(),
+	}
+
+	contents, err := json.Marshal(failure)
+	if err != nil {
+		return failure, err
+	}
+
+	return failure, ioutil.WriteFile(path.Join(b.quarantinePath(), id, "error"), contents, 0644)
+}
+
+func (b *LinuxBackend) readQuarantineError(id string) (QuarantinedSnapshot, error) {
+	var failure QuarantinedSnapshot
+
+	contents, err := ioutil.ReadFile(path.Join(b.quarantinePath(), id, "error"))
+	if err != nil {
+		return failure, err
+	}
+
+	err = json.Unmarshal(contents, &failure)
+	return failure, err
+}
+
+func withoutFailure(failures []QuarantinedSnapshot, id string) []QuarantinedSnapshot {
+	remaining := []QuarantinedSnapshot{}
+	for _, failure := range failures {
+		if failure.ID != id {
+			remaining = append(remaining, failure)
+		}
+	}
+
+	return remaining
+}
@@ -3,6 +3,8 @@ package linux_backend
 import (
 	"encoding/json"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -34,6 +36,32 @@ func (n *Network) UnmarshalJSON(b []byte) error {
 	return err
 }
 
+// CPUSet is the set of cores a container is pinned to, along with the NUMA
+// memory nodes it may allocate from.
+type CPUSet struct {
+	CPUs []int
+	Mems []int
+}
+
+// CPUList formats the cores as cpuset.cpus expects them.
+func (s *CPUSet) CPUList() string {
+	return formatList(s.CPUs)
+}
+
+// MemList formats the memory nodes as cpuset.mems expects them.
+func (s *CPUSet) MemList() string {
+	return formatList(s.Mems)
+}
+
+func formatList(ids []int) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.Itoa(id)
+	}
+
+	return strings.Join(strs, ",")
+}
+
 type Resources struct {
 	UserUID    int
 	RootUID    int
@@ -42,6 +70,9 @@ type Resources struct {
 	Ports      []uint32
 	ExternalIP net.IP
 
+	// CPUSet is nil unless the container is pinned.
+	CPUSet *CPUSet
+
 	portsLock *sync.Mutex
 }
 
@@ -0,0 +1,181 @@
+package linux_backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	HasPrefix    Operator = "^="
+	Exists       Operator = "?"
+	DoesNotExist Operator = "!?"
+	In           Operator = "[]"
+)
+
+// A Requirement constrains the value of a single property.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// A Selector matches containers whose properties satisfy all of its
+// requirements.
+type Selector []Requirement
+
+type InvalidSelectorError struct {
+	Key    string
+	Value  string
+	Reason string
+}
+
+func (e InvalidSelectorError) Error() string {
+	return fmt.Sprintf("invalid property selector %q=%q: %s", e.Key, e.Value, e.Reason)
+}
+
+// ParseSelector interprets the properties passed to Containers as a
+// selector. A plain key must be present with exactly the given value, as
+// before; a key may also end in an operator:
+//
+//	key==  value      the property is present with exactly the value
+//	key!=  value      the property is absent, or has a different value
+//	key^=  prefix     the property is present and starts with prefix
+//	key?   ""|true    the property is present, whatever its value
+//	key!?  ""|true    the property is absent
+//	key[]  a,b,c      the property is present with one of the values
+//
+// An existence operator given "false" requires the opposite. Operators are
+// only recognised once, at the end of the key, so a key which itself ends in
+// one of them is matched exactly with ==, as in "zone?==".
+func ParseSelector(properties garden.Properties) (Selector, error) {
+	selector := Selector{}
+
+	for key, value := range properties {
+		requirement, err := parseRequirement(key, value)
+		if err != nil {
+			return nil, err
+		}
+
+		selector = append(selector, requirement)
+	}
+
+	// keep the order stable so that selectors can be compared and logged
+	sort.Sort(byKey(selector))
+
+	return selector, nil
+}
+
+// exactly is the suffix of a key whose value must match exactly, for keys
+// which would otherwise be read as ending in another operator.
+const exactly = "=="
+
+func parseRequirement(key, value string) (Requirement, error) {
+	switch {
+	case strings.HasSuffix(key, exactly):
+		return Requirement{Key: strings.TrimSuffix(key, exactly), Operator: Equals, Values: []string{value}}, nil
+
+	case strings.HasSuffix(key, string(NotEquals)):
+		return Requirement{Key: strings.TrimSuffix(key, string(NotEquals)), Operator: NotEquals, Values: []string{value}}, nil
+
+	case strings.HasSuffix(key, string(HasPrefix)):
+		return Requirement{Key: strings.TrimSuffix(key, string(HasPrefix)), Operator: HasPrefix, Values: []string{value}}, nil
+
+	// before Exists, which it ends with
+	case strings.HasSuffix(key, string(DoesNotExist)):
+		return parseExistence(key, value, DoesNotExist, Exists)
+
+	case strings.HasSuffix(key, string(Exists)):
+		return parseExistence(key, value, Exists, DoesNotExist)
+
+	case strings.HasSuffix(key, string(In)):
+		return Requirement{Key: strings.TrimSuffix(key, string(In)), Operator: In, Values: strings.Split(value, ",")}, nil
+	}
+
+	return Requirement{Key: key, Operator: Equals, Values: []string{value}}, nil
+}
+
+// parseExistence reads the value of a key ending in operator, which is
+// negated by "false".
+func parseExistence(key, value string, operator, negated Operator) (Requirement, error) {
+	trimmed := strings.TrimSuffix(key, string(operator))
+
+	switch value {
+	case "", "true":
+		return Requirement{Key: trimmed, Operator: operator}, nil
+	case "false":
+		return Requirement{Key: trimmed, Operator: negated}, nil
+	}
+
+	return Requirement{}, InvalidSelectorError{Key: key, Value: value, Reason: `must be "", "true" or "false"`}
+}
+
+func (s Selector) Matches(properties garden.Properties) bool {
+	for _, requirement := range s {
+		if !requirement.Matches(properties) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequiredKeys lists the properties which a container must have for the
+// selector to match it.
+func (s Selector) RequiredKeys() []string {
+	keys := []string{}
+	for _, requirement := range s {
+		if requirement.Operator != NotEquals && requirement.Operator != DoesNotExist {
+			keys = append(keys, requirement.Key)
+		}
+	}
+
+	return keys
+}
+
+func (r Requirement) Matches(properties garden.Properties) bool {
+	value, found := properties[r.Key]
+
+	switch r.Operator {
+	case Equals:
+		return found && value == r.Values[0]
+	case NotEquals:
+		return !found || value != r.Values[0]
+	case HasPrefix:
+		return found && strings.HasPrefix(value, r.Values[0])
+	case Exists:
+		return found
+	case DoesNotExist:
+		return !found
+	case In:
+		if !found {
+			return false
+		}
+
+		for _, candidate := range r.Values {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type byKey Selector
+
+func (s byKey) Len() int      { return len(s) }
+func (s byKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool {
+	if s[i].Key == s[j].Key {
+		return s[i].Operator < s[j].Operator
+	}
+
+	return s[i].Key < s[j].Key
+}
@@ -0,0 +1,113 @@
+package linux_backend_test
+
+import (
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Selector", func() {
+	properties := garden.Properties{
+		"app":  "web-frontend",
+		"zone": "z1",
+	}
+
+	matches := func(selector garden.Properties) bool {
+		parsed, err := linux_backend.ParseSelector(selector)
+		Expect(err).ToNot(HaveOccurred())
+
+		return parsed.Matches(properties)
+	}
+
+	It("matches everything when empty", func() {
+		Expect(matches(nil)).To(BeTrue())
+	})
+
+	It("matches plain keys exactly", func() {
+		Expect(matches(garden.Properties{"app": "web-frontend"})).To(BeTrue())
+		Expect(matches(garden.Properties{"app": "web"})).To(BeFalse())
+		Expect(matches(garden.Properties{"missing": ""})).To(BeFalse())
+	})
+
+	It("matches keys ending in == exactly", func() {
+		Expect(matches(garden.Properties{"app==": "web-frontend"})).To(BeTrue())
+		Expect(matches(garden.Properties{"app==": "web"})).To(BeFalse())
+	})
+
+	It("matches keys which end in an operator themselves with ==", func() {
+		properties := garden.Properties{"zone?": "z1", "tier!": "db"}
+
+		for key, value := range map[string]string{"zone?==": "z1", "tier!==": "db"} {
+			selector, err := linux_backend.ParseSelector(garden.Properties{key: value})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selector.Matches(properties)).To(BeTrue())
+		}
+	})
+
+	It("matches keys ending in != when the property is absent or different", func() {
+		Expect(matches(garden.Properties{"app!=": "worker"})).To(BeTrue())
+		Expect(matches(garden.Properties{"missing!=": "worker"})).To(BeTrue())
+		Expect(matches(garden.Properties{"app!=": "web-frontend"})).To(BeFalse())
+	})
+
+	It("matches keys ending in ^= when the property has the prefix", func() {
+		Expect(matches(garden.Properties{"app^=": "web-"})).To(BeTrue())
+		Expect(matches(garden.Properties{"app^=": "worker-"})).To(BeFalse())
+		Expect(matches(garden.Properties{"missing^=": ""})).To(BeFalse())
+	})
+
+	It("matches keys ending in ? on whether the property exists", func() {
+		Expect(matches(garden.Properties{"zone?": ""})).To(BeTrue())
+		Expect(matches(garden.Properties{"zone?": "true"})).To(BeTrue())
+		Expect(matches(garden.Properties{"zone?": "false"})).To(BeFalse())
+		Expect(matches(garden.Properties{"missing?": ""})).To(BeFalse())
+		Expect(matches(garden.Properties{"missing?": "false"})).To(BeTrue())
+	})
+
+	It("matches keys ending in !? on whether the property is absent", func() {
+		Expect(matches(garden.Properties{"missing!?": ""})).To(BeTrue())
+		Expect(matches(garden.Properties{"missing!?": "true"})).To(BeTrue())
+		Expect(matches(garden.Properties{"missing!?": "false"})).To(BeFalse())
+		Expect(matches(garden.Properties{"zone!?": ""})).To(BeFalse())
+		Expect(matches(garden.Properties{"zone!?": "false"})).To(BeTrue())
+	})
+
+	It("matches keys ending in [] when the property is one of the values", func() {
+		Expect(matches(garden.Properties{"zone[]": "z1,z2"})).To(BeTrue())
+		Expect(matches(garden.Properties{"zone[]": "z2,z3"})).To(BeFalse())
+		Expect(matches(garden.Properties{"missing[]": "z1"})).To(BeFalse())
+	})
+
+	It("requires every requirement to match", func() {
+		Expect(matches(garden.Properties{"app^=": "web-", "zone[]": "z1,z2"})).To(BeTrue())
+		Expect(matches(garden.Properties{"app^=": "web-", "zone[]": "z2"})).To(BeFalse())
+	})
+
+	It("lists the keys a matching container must have", func() {
+		selector, err := linux_backend.ParseSelector(garden.Properties{
+			"a":   "b",
+			"c!=": "d",
+			"e?":  "",
+			"f?":  "false",
+			"g[]": "h,i",
+			"j!?": "",
+			"k!?": "false",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(selector.RequiredKeys()).To(Equal([]string{"a", "e", "g", "k"}))
+	})
+
+	Context("when an existence requirement has an invalid value", func() {
+		It("returns an error", func() {
+			_, err := linux_backend.ParseSelector(garden.Properties{"zone?": "maybe"})
+			Expect(err).To(Equal(linux_backend.InvalidSelectorError{
+				Key:    "zone?",
+				Value:  "maybe",
+				Reason: `must be "", "true" or "false"`,
+			}))
+		})
+	})
+})
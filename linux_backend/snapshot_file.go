@@ -0,0 +1,44 @@
+package linux_backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// SaveSnapshot writes the container's snapshot to snapshotPath atomically, so
+// that a crash part way through leaves any previous snapshot intact.
+//
+// The snapshot is first written to a hidden temporary file alongside
+// snapshotPath; see IsPartialSnapshot.
+func SaveSnapshot(container Container, snapshotPath string) error {
+	tmp, err := ioutil.TempFile(path.Dir(snapshotPath), "."+path.Base(snapshotPath)+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	err = container.Snapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if closeErr != nil {
+		return closeErr
+	}
+
+	return os.Rename(tmp.Name(), snapshotPath)
+}
+
+// IsPartialSnapshot reports whether a file in a snapshots directory is the
+// remains of an interrupted SaveSnapshot rather than a snapshot.
+func IsPartialSnapshot(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,85 @@
+package linux_backend_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+)
+
+var _ = Describe("SaveSnapshot", func() {
+	var snapshotsPath string
+	var container *fakes.FakeContainer
+
+	BeforeEach(func() {
+		var err error
+		snapshotsPath, err = ioutil.TempDir("", "snapshots")
+		Expect(err).ToNot(HaveOccurred())
+
+		container = new(fakes.FakeContainer)
+		container.SnapshotStub = func(w io.Writer) error {
+			_, err := w.Write([]byte("new-snapshot"))
+			return err
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(snapshotsPath)
+	})
+
+	It("writes the container's snapshot to the path", func() {
+		err := linux_backend.SaveSnapshot(container, path.Join(snapshotsPath, "some-id"))
+		Expect(err).ToNot(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(path.Join(snapshotsPath, "some-id"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("new-snapshot"))
+	})
+
+	It("leaves no temporary files behind", func() {
+		err := linux_backend.SaveSnapshot(container, path.Join(snapshotsPath, "some-id"))
+		Expect(err).ToNot(HaveOccurred())
+
+		entries, err := ioutil.ReadDir(snapshotsPath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+
+	Context("when taking the snapshot fails", func() {
+		disaster := errors.New("oh no!")
+
+		BeforeEach(func() {
+			err := ioutil.WriteFile(path.Join(snapshotsPath, "some-id"), []byte("old-snapshot"), 0644)
+			Expect(err).ToNot(HaveOccurred())
+
+			container.SnapshotStub = func(w io.Writer) error {
+				w.Write([]byte("half-a-snap"))
+				return disaster
+			}
+		})
+
+		It("returns the error", func() {
+			err := linux_backend.SaveSnapshot(container, path.Join(snapshotsPath, "some-id"))
+			Expect(err).To(Equal(disaster))
+		})
+
+		It("leaves the previous snapshot intact", func() {
+			linux_backend.SaveSnapshot(container, path.Join(snapshotsPath, "some-id"))
+
+			contents, err := ioutil.ReadFile(path.Join(snapshotsPath, "some-id"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("old-snapshot"))
+
+			entries, err := ioutil.ReadDir(snapshotsPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+	})
+})
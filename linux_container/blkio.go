@@ -0,0 +1,275 @@
+package linux_container
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+// the blkio throttle files, each holding a "major:minor value" rule per
+// throttled device
+const (
+	blkioReadBps   = "blkio.throttle.read_bps_device"
+	blkioWriteBps  = "blkio.throttle.write_bps_device"
+	blkioReadIOPS  = "blkio.throttle.read_iops_device"
+	blkioWriteIOPS = "blkio.throttle.write_iops_device"
+)
+
+type NoBackingDiskError struct {
+	Path   string
+	Device string
+}
+
+func (e NoBackingDiskError) Error() string {
+	return fmt.Sprintf("%s is on device %s, which is not backed by a disk that can be throttled", e.Path, e.Device)
+}
+
+// LimitBlockIO sets the container's share of block I/O and throttles its
+// reads and writes to the disk holding the depot. The throttles given replace
+// the container's current ones, so a throttle of 0 removes it; a weight of 0
+// leaves the weight as it is, as the kernel has no such weight.
+func (c *LinuxContainer) LimitBlockIO(limits linux_backend.BlockIOLimits) error {
+	if limits.Weight > 0 {
+		err := c.cgroupsManager.Set("blkio", "blkio.weight", strconv.FormatUint(uint64(limits.Weight), 10))
+		if err != nil {
+			return err
+		}
+	}
+
+	throttles := []struct {
+		file  string
+		value uint64
+	}{
+		{blkioReadBps, limits.ReadBytesPerSecond},
+		{blkioWriteBps, limits.WriteBytesPerSecond},
+		{blkioReadIOPS, limits.ReadIOPerSecond},
+		{blkioWriteIOPS, limits.WriteIOPerSecond},
+	}
+
+	throttled := false
+	for _, throttle := range throttles {
+		if throttle.value > 0 {
+			throttled = true
+		}
+	}
+
+	device, err := c.depotDevice()
+	if _, ok := err.(NoBackingDiskError); ok && !throttled {
+		// nothing can have been throttled, so there is nothing to remove
+		err = nil
+		throttles = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, throttle := range throttles {
+		// the kernel removes the device's rule when it is set to 0
+		err := c.cgroupsManager.Set("blkio", throttle.file, fmt.Sprintf("%s %d", device, throttle.value))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.blockIOMutex.Lock()
+	c.currentBlockIOLimits = mergeBlockIOLimits(c.currentBlockIOLimits, limits)
+	c.blockIOMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "blkio",
+	})
+
+	return nil
+}
+
+// mergeBlockIOLimits returns the limits in effect once the given ones have
+// been applied over the previous ones, which may be nil. Only the weight is
+// kept from the previous limits, when none is given.
+func mergeBlockIOLimits(previous *linux_backend.BlockIOLimits, given linux_backend.BlockIOLimits) *linux_backend.BlockIOLimits {
+	merged := given
+	if previous != nil && merged.Weight == 0 {
+		merged.Weight = previous.Weight
+	}
+
+	return &merged
+}
+
+func (c *LinuxContainer) CurrentBlockIOLimits() (linux_backend.BlockIOLimits, error) {
+	weight, err := c.cgroupsManager.Get("blkio", "blkio.weight")
+	if err != nil {
+		return linux_backend.BlockIOLimits{}, err
+	}
+
+	numericWeight, err := strconv.ParseUint(weight, 10, 16)
+	if err != nil {
+		return linux_backend.BlockIOLimits{}, err
+	}
+
+	limits := linux_backend.BlockIOLimits{
+		Weight: uint16(numericWeight),
+	}
+
+	device, err := c.depotDevice()
+	if _, ok := err.(NoBackingDiskError); ok {
+		// nothing can have been throttled
+		return limits, nil
+	}
+
+	if err != nil {
+		return linux_backend.BlockIOLimits{}, err
+	}
+
+	throttles := []struct {
+		file  string
+		value *uint64
+	}{
+		{blkioReadBps, &limits.ReadBytesPerSecond},
+		{blkioWriteBps, &limits.WriteBytesPerSecond},
+		{blkioReadIOPS, &limits.ReadIOPerSecond},
+		{blkioWriteIOPS, &limits.WriteIOPerSecond},
+	}
+
+	for _, throttle := range throttles {
+		rules, err := c.cgroupsManager.Get("blkio", throttle.file)
+		if err != nil {
+			return linux_backend.BlockIOLimits{}, err
+		}
+
+		*throttle.value = parseThrottleRule(rules, device)
+	}
+
+	return limits, nil
+}
+
+func (c *LinuxContainer) blockIOStat() (linux_backend.BlockIOStat, error) {
+	serviceBytes, err := c.cgroupsManager.Get("blkio", "blkio.throttle.io_service_bytes")
+	if err != nil {
+		return linux_backend.BlockIOStat{}, err
+	}
+
+	serviced, err := c.cgroupsManager.Get("blkio", "blkio.throttle.io_serviced")
+	if err != nil {
+		return linux_backend.BlockIOStat{}, err
+	}
+
+	stat := linux_backend.BlockIOStat{}
+	stat.ReadBytes, stat.WriteBytes = parseBlockIOCounts(serviceBytes)
+	stat.ReadOps, stat.WriteOps = parseBlockIOCounts(serviced)
+
+	return stat, nil
+}
+
+// depotDevice returns the "major:minor" number of the disk holding the
+// container's depot directory, for use in blkio throttle rules, which the
+// kernel only accepts for whole disks.
+func (c *LinuxContainer) depotDevice() (string, error) {
+	var stat syscall.Stat_t
+
+	err := syscall.Stat(c.path, &stat)
+	if err != nil {
+		return "", err
+	}
+
+	dev := uint64(stat.Dev)
+
+	major := ((dev >> 8) & 0xfff) | ((dev >> 32) & ^uint64(0xfff))
+	minor := (dev & 0xff) | ((dev >> 12) & ^uint64(0xff))
+
+	device := fmt.Sprintf("%d:%d", major, minor)
+
+	disk, err := ParentDisk(sysBlockDevicesPath, device)
+	if os.IsNotExist(err) {
+		return "", NoBackingDiskError{Path: c.path, Device: device}
+	}
+
+	return disk, err
+}
+
+// sysBlockDevicesPath links each block device's "major:minor" number to its
+// directory in sysfs.
+const sysBlockDevicesPath = "/sys/dev/block"
+
+// ParentDisk returns the "major:minor" number of the whole disk holding a
+// block device, which is the device itself unless it is a partition, given
+// the sysfs directory linking each device number to the device. Devices
+// which are not there, such as the anonymous devices of btrfs subvolumes
+// and overlay mounts, return an error satisfying os.IsNotExist.
+func ParentDisk(sysBlockDevicesPath, device string) (string, error) {
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(sysBlockDevicesPath, device))
+	if err != nil {
+		return "", err
+	}
+
+	// a partition's directory is inside its disk's
+	_, err = os.Stat(filepath.Join(devicePath, "partition"))
+	if err == nil {
+		devicePath = filepath.Dir(devicePath)
+	}
+
+	dev, err := ioutil.ReadFile(filepath.Join(devicePath, "dev"))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(dev)), nil
+}
+
+// parseThrottleRule returns the value of the device's rule in the contents
+// of a blkio throttle file, which is 0 if the device is not throttled.
+func parseThrottleRule(contents, device string) uint64 {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != device {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 0)
+		if err != nil {
+			continue
+		}
+
+		return value
+	}
+
+	return 0
+}
+
+// parseBlockIOCounts sums the Read and Write lines of every device in a blkio
+// stat file, such as blkio.throttle.io_service_bytes.
+func parseBlockIOCounts(contents string) (read, write uint64) {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[2], 10, 0)
+		if err != nil {
+			continue
+		}
+
+		switch fields[1] {
+		case "Read":
+			read += value
+		case "Write":
+			write += value
+		}
+	}
+
+	return
+}
@@ -0,0 +1,116 @@
+package linux_container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	freezerFrozen = "FROZEN"
+	freezerThawed = "THAWED"
+)
+
+const freezePollInterval = 10 * time.Millisecond
+
+type FreezeTimeoutError struct {
+	Handle  string
+	Timeout time.Duration
+	State   string
+}
+
+func (err FreezeTimeoutError) Error() string {
+	return fmt.Sprintf("container %s did not freeze within %s: freezer is %s", err.Handle, err.Timeout, err.State)
+}
+
+// Pause freezes every process in the container, without killing them, until
+// Resume is called. Only active containers can be paused; while paused no
+// processes can be run in them, and they must be resumed before stopping.
+func (c *LinuxContainer) Pause() error {
+	cLog := c.logger.Session("pause")
+
+	from, err := c.transitionFrom([]State{StateActive}, StatePaused)
+	if err != nil {
+		cLog.Error("failed-to-pause", err)
+		return err
+	}
+
+	err = c.freeze(cLog)
+	if err != nil {
+		cLog.Error("failed-to-freeze", err)
+
+		c.cgroupsManager.Set("freezer", "freezer.state", freezerThawed)
+		c.transition(from)
+
+		return err
+	}
+
+	c.emit(event_hub.Paused, nil)
+
+	c.notifyChanged()
+
+	cLog.Info("paused")
+
+	return nil
+}
+
+// Resume thaws the processes of a paused container.
+func (c *LinuxContainer) Resume() error {
+	cLog := c.logger.Session("resume")
+
+	_, err := c.transitionFrom([]State{StatePaused}, StateActive)
+	if err != nil {
+		cLog.Error("failed-to-resume", err)
+		return err
+	}
+
+	err = c.cgroupsManager.Set("freezer", "freezer.state", freezerThawed)
+	if err != nil {
+		cLog.Error("failed-to-thaw", err)
+		c.transition(StatePaused)
+		return err
+	}
+
+	c.emit(event_hub.Resumed, nil)
+
+	c.notifyChanged()
+
+	cLog.Info("resumed")
+
+	return nil
+}
+
+// freeze asks the kernel to freeze the container's cgroup and waits for it
+// to report that every task has been frozen, which is not immediate.
+func (c *LinuxContainer) freeze(logger lager.Logger) error {
+	err := c.cgroupsManager.Set("freezer", "freezer.state", freezerFrozen)
+	if err != nil {
+		return err
+	}
+
+	timeout := c.timings.freezeTimeout()
+	deadline := time.Now().Add(timeout)
+
+	for {
+		state, err := c.cgroupsManager.Get("freezer", "freezer.state")
+		if err != nil {
+			return err
+		}
+
+		if state == freezerFrozen {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return FreezeTimeoutError{Handle: c.handle, Timeout: timeout, State: state}
+		}
+
+		logger.Debug("waiting-to-freeze", lager.Data{
+			"state": state,
+		})
+
+		time.Sleep(freezePollInterval)
+	}
+}
@@ -2,11 +2,11 @@ package linux_container
 
 import (
 	"fmt"
-	"os/exec"
-	"path"
 	"strconv"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 )
 
 func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
@@ -18,9 +18,14 @@ func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
 	}
 
 	c.bandwidthMutex.Lock()
-	defer c.bandwidthMutex.Unlock()
-
 	c.currentBandwidthLimits = &limits
+	c.bandwidthMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "bandwidth",
+	})
 
 	return nil
 }
@@ -37,6 +42,19 @@ func (c *LinuxContainer) CurrentBandwidthLimits() (garden.BandwidthLimits, error
 }
 
 func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
+	c.diskMutex.RLock()
+	previous := uint64(0)
+	if c.currentDiskLimits != nil {
+		previous = c.currentDiskLimits.ByteHard
+	}
+	c.diskMutex.RUnlock()
+
+	return c.commit(capacity.Disk, limits.ByteHard, previous, func() error {
+		return c.limitDisk(limits)
+	})
+}
+
+func (c *LinuxContainer) limitDisk(limits garden.DiskLimits) error {
 	cLog := c.logger.Session("limit-disk")
 
 	err := c.quotaManager.SetLimits(cLog, c.resources.UserUID, limits)
@@ -45,9 +63,14 @@ func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
 	}
 
 	c.diskMutex.Lock()
-	defer c.diskMutex.Unlock()
-
 	c.currentDiskLimits = &limits
+	c.diskMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "disk",
+	})
 
 	return nil
 }
@@ -58,6 +81,19 @@ func (c *LinuxContainer) CurrentDiskLimits() (garden.DiskLimits, error) {
 }
 
 func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
+	c.memoryMutex.RLock()
+	previous := uint64(0)
+	if c.currentMemoryLimits != nil {
+		previous = c.currentMemoryLimits.LimitInBytes
+	}
+	c.memoryMutex.RUnlock()
+
+	return c.commit(capacity.Memory, limits.LimitInBytes, previous, func() error {
+		return c.limitMemory(limits)
+	})
+}
+
+func (c *LinuxContainer) limitMemory(limits garden.MemoryLimits) error {
 	err := c.startOomNotifier()
 	if err != nil {
 		return err
@@ -80,9 +116,14 @@ func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
 	}
 
 	c.memoryMutex.Lock()
-	defer c.memoryMutex.Unlock()
-
 	c.currentMemoryLimits = &limits
+	c.memoryMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "memory",
+	})
 
 	return nil
 }
@@ -102,6 +143,19 @@ func (c *LinuxContainer) CurrentMemoryLimits() (garden.MemoryLimits, error) {
 }
 
 func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
+	c.cpuMutex.RLock()
+	previous := uint64(0)
+	if c.currentCPULimits != nil {
+		previous = c.currentCPULimits.LimitInShares
+	}
+	c.cpuMutex.RUnlock()
+
+	return c.commit(capacity.CPU, limits.LimitInShares, previous, func() error {
+		return c.limitCPU(limits)
+	})
+}
+
+func (c *LinuxContainer) limitCPU(limits garden.CPULimits) error {
 	limit := fmt.Sprintf("%d", limits.LimitInShares)
 
 	err := c.cgroupsManager.Set("cpu", "cpu.shares", limit)
@@ -110,9 +164,14 @@ func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
 	}
 
 	c.cpuMutex.Lock()
-	defer c.cpuMutex.Unlock()
-
 	c.currentCPULimits = &limits
+	c.cpuMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "cpu",
+	})
 
 	return nil
 }
@@ -131,43 +190,19 @@ func (c *LinuxContainer) CurrentCPULimits() (garden.CPULimits, error) {
 	return garden.CPULimits{uint64(numericLimit)}, nil
 }
 
-func (c *LinuxContainer) startOomNotifier() error {
-	c.oomMutex.Lock()
-	defer c.oomMutex.Unlock()
-
-	if c.oomNotifier != nil {
-		return nil
+// commit admits the new amount of the resource against the cell's capacity
+// before applying it, reverting to the previous commitment if applying fails.
+func (c *LinuxContainer) commit(resource capacity.Resource, amount, previous uint64, apply func() error) error {
+	err := c.committer.Commit(c.handle, resource, amount)
+	if err != nil {
+		return err
 	}
 
-	oomPath := path.Join(c.path, "bin", "oom")
-
-	c.oomNotifier = exec.Command(oomPath, c.cgroupsManager.SubsystemPath("memory"))
-
-	err := c.runner.Start(c.oomNotifier)
+	err = apply()
 	if err != nil {
+		c.committer.ForceCommit(c.handle, resource, previous)
 		return err
 	}
 
-	go c.watchForOom(c.oomNotifier)
-
 	return nil
 }
-
-func (c *LinuxContainer) stopOomNotifier() {
-	c.oomMutex.RLock()
-	defer c.oomMutex.RUnlock()
-
-	if c.oomNotifier != nil {
-		c.runner.Kill(c.oomNotifier)
-	}
-}
-
-func (c *LinuxContainer) watchForOom(oom *exec.Cmd) {
-	err := c.runner.Wait(oom)
-	if err == nil {
-		c.registerEvent("out of memory")
-		c.Stop(false)
-	}
-
-	// TODO: handle case where oom notifier itself failed? kill container?
-}
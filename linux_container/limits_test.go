@@ -1,11 +1,15 @@
 package linux_container_test
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"math"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -13,6 +17,9 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
@@ -27,6 +34,8 @@ import (
 )
 
 var _ = Describe("Linux containers", func() {
+	var eventHub *event_hub.Hub
+	var fakeCommitter *capacityFakes.FakeCommitter
 	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
 	var fakeBandwidthManager *fake_bandwidth_manager.FakeBandwidthManager
@@ -34,8 +43,13 @@ var _ = Describe("Linux containers", func() {
 	var containerResources *linux_backend.Resources
 	var container *linux_container.LinuxContainer
 	var containerDir string
+	var timings linux_container.Timings
 
 	BeforeEach(func() {
+		eventHub = event_hub.New(lagertest.NewTestLogger("test"), 100)
+		timings = linux_container.Timings{}
+		fakeCommitter = new(capacityFakes.FakeCommitter)
+
 		fakeRunner = fake_command_runner.New()
 
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
@@ -78,9 +92,127 @@ var _ = Describe("Linux containers", func() {
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			eventHub,
+			fakeCommitter,
+			timings,
 		)
 	})
 
+	Describe("emitting limit-change events", func() {
+		var subscription *event_hub.Subscription
+
+		JustBeforeEach(func() {
+			subscription = eventHub.Subscribe()
+		})
+
+		AfterEach(func() {
+			subscription.Close()
+		})
+
+		itEmitsALimitChange := func(limit string) {
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.LimitChanged))
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.Data).To(Equal(map[string]string{"limit": limit}))
+		}
+
+		It("emits one when bandwidth is limited", func() {
+			Expect(container.LimitBandwidth(garden.BandwidthLimits{})).To(Succeed())
+			itEmitsALimitChange("bandwidth")
+		})
+
+		It("emits one when disk is limited", func() {
+			Expect(container.LimitDisk(garden.DiskLimits{})).To(Succeed())
+			itEmitsALimitChange("disk")
+		})
+
+		It("emits one when memory is limited", func() {
+			Expect(container.LimitMemory(garden.MemoryLimits{LimitInBytes: 1024})).To(Succeed())
+			itEmitsALimitChange("memory")
+		})
+
+		It("emits one when cpu is limited", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 1024})).To(Succeed())
+			itEmitsALimitChange("cpu")
+		})
+
+		Context("when limiting fails", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
+					return errors.New("oh no!")
+				})
+			})
+
+			It("does not emit one", func() {
+				Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 1024})).ToNot(Succeed())
+				Consistently(subscription.Events()).ShouldNot(Receive())
+			})
+		})
+	})
+
+	Describe("committing limits against the cell's capacity", func() {
+		It("commits memory, disk and cpu limits for the container", func() {
+			Expect(container.LimitMemory(garden.MemoryLimits{LimitInBytes: 1024})).To(Succeed())
+			Expect(container.LimitDisk(garden.DiskLimits{ByteHard: 2048})).To(Succeed())
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 512})).To(Succeed())
+
+			Expect(fakeCommitter.CommitCallCount()).To(Equal(3))
+
+			handle, resource, amount := fakeCommitter.CommitArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(resource).To(Equal(capacity.Memory))
+			Expect(amount).To(Equal(uint64(1024)))
+
+			_, resource, amount = fakeCommitter.CommitArgsForCall(1)
+			Expect(resource).To(Equal(capacity.Disk))
+			Expect(amount).To(Equal(uint64(2048)))
+
+			_, resource, amount = fakeCommitter.CommitArgsForCall(2)
+			Expect(resource).To(Equal(capacity.CPU))
+			Expect(amount).To(Equal(uint64(512)))
+		})
+
+		Context("when the limit would oversubscribe the cell", func() {
+			insufficient := capacity.InsufficientCapacityError{
+				Resource:  capacity.CPU,
+				Requested: 512,
+				Committed: 4096,
+				Allowed:   4096,
+			}
+
+			BeforeEach(func() {
+				fakeCommitter.CommitReturns(insufficient)
+			})
+
+			It("returns the error without applying the limit", func() {
+				err := container.LimitCPU(garden.CPULimits{LimitInShares: 512})
+				Expect(err).To(Equal(insufficient))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when applying the limit fails", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
+					return errors.New("oh no!")
+				})
+			})
+
+			It("reverts to the previous commitment", func() {
+				Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 512})).ToNot(Succeed())
+
+				Expect(fakeCommitter.ForceCommitCallCount()).To(Equal(1))
+
+				handle, resource, amount := fakeCommitter.ForceCommitArgsForCall(0)
+				Expect(handle).To(Equal("some-handle"))
+				Expect(resource).To(Equal(capacity.CPU))
+				Expect(amount).To(Equal(uint64(0)))
+			})
+		})
+	})
+
 	Describe("Limiting bandwidth", func() {
 		limits := garden.BandwidthLimits{
 			RateInBytesPerSecond:      128,
@@ -239,11 +371,28 @@ var _ = Describe("Linux containers", func() {
 				err := container.LimitMemory(limits)
 				Expect(err).ToNot(HaveOccurred())
 
-				Eventually(fakeRunner).Should(HaveExecutedSerially(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-					},
-				))
+				Eventually(container.State).Should(Equal(linux_container.StateStopped))
+			})
+
+			It("emits an oom event", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				received := []event_hub.EventType{}
+				Eventually(func() []event_hub.EventType {
+					select {
+					case event := <-subscription.Events():
+						received = append(received, event.Type)
+					default:
+					}
+
+					return received
+				}).Should(ContainElement(event_hub.OutOfMemory))
 			})
 
 			It("registers an 'out of memory' event", func() {
@@ -258,6 +407,371 @@ var _ = Describe("Linux containers", func() {
 					return container.Events()
 				}).Should(ContainElement("out of memory"))
 			})
+
+			It("records the oom with the container's memory usage", func() {
+				fakeCgroups.WhenGetting("memory", "memory.stat", func() (string, error) {
+					return "rss 2\ntotal_rss 42\ntotal_inactive_file 1\n", nil
+				})
+
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(container.OOMRecords).Should(HaveLen(1))
+
+				record := container.OOMRecords()[0]
+				Expect(record.Policy).To(Equal(linux_backend.OOMPolicyStop))
+				Expect(record.MemoryLimitInBytes).To(Equal(uint64(102400)))
+				Expect(record.MemoryStat.TotalRss).To(Equal(uint64(42)))
+				Expect(record.Time).ToNot(BeZero())
+			})
+
+			It("includes the policy in the oom event", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(func() map[string]string {
+					select {
+					case event := <-subscription.Events():
+						if event.Type == event_hub.OutOfMemory {
+							return event.Data
+						}
+					default:
+					}
+
+					return nil
+				}).Should(Equal(map[string]string{"policy": "stop"}))
+			})
+		})
+
+		Context("when the oom notifier exits 0 and the oom policy is kill-process", func() {
+			var blockWaiting chan struct{}
+
+			BeforeEach(func() {
+				blockWaiting = make(chan struct{})
+			})
+
+			JustBeforeEach(func() {
+				Expect(container.SetProperty(linux_container.OOMPolicyProperty, "kill-process")).To(Succeed())
+
+				// the first notifier reports an oom; the next waits for
+				// the next one
+				blocked := blockWaiting
+
+				waited := 0
+				fakeRunner.WhenWaitingFor(fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/oom",
+				}, func(cmd *exec.Cmd) error {
+					waited++
+					if waited > 1 {
+						<-blocked
+					}
+
+					return nil
+				})
+			})
+
+			AfterEach(func() {
+				container.Stop(false)
+				close(blockWaiting)
+			})
+
+			It("keeps the container running and watches for the next oom", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(container.OOMRecords).Should(HaveLen(1))
+				Expect(container.OOMRecords()[0].Policy).To(Equal(linux_backend.OOMPolicyKillProcess))
+
+				Eventually(func() int {
+					return len(fakeRunner.StartedCommands())
+				}).Should(Equal(2))
+
+				Consistently(container.State).ShouldNot(Equal(linux_container.StateStopped))
+			})
+
+			It("does not disable the kernel's oom killer", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).ToNot(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.oom_control",
+					Value:     "1",
+				}))
+			})
+		})
+
+		Context("when the oom notifier exits 0 and the oom policy is record", func() {
+			var blockWaiting chan struct{}
+
+			BeforeEach(func() {
+				blockWaiting = make(chan struct{})
+			})
+
+			JustBeforeEach(func() {
+				Expect(container.SetProperty(linux_container.OOMPolicyProperty, "record")).To(Succeed())
+
+				blocked := blockWaiting
+
+				waited := 0
+				fakeRunner.WhenWaitingFor(fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/oom",
+				}, func(cmd *exec.Cmd) error {
+					waited++
+					if waited > 1 {
+						<-blocked
+					}
+
+					return nil
+				})
+			})
+
+			AfterEach(func() {
+				container.Stop(false)
+				close(blockWaiting)
+			})
+
+			It("disables the kernel's oom killer before watching for ooms", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.oom_control",
+					Value:     "1",
+				}))
+			})
+
+			It("records the oom and keeps the container running", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(container.OOMRecords).Should(HaveLen(1))
+				Expect(container.OOMRecords()[0].Policy).To(Equal(linux_backend.OOMPolicyRecord))
+
+				Consistently(container.State).ShouldNot(Equal(linux_container.StateStopped))
+			})
+
+			Context("when the container's processes are left waiting for memory", func() {
+				var oomControlValues func() []string
+
+				BeforeEach(func() {
+					timings.OomRecordTimeout = 50 * time.Millisecond
+					timings.OomPollInterval = 10 * time.Millisecond
+
+					oomControlValues = func() []string {
+						values := []string{}
+						for _, value := range fakeCgroups.SetValues() {
+							if value.Name == "memory.oom_control" {
+								values = append(values, value.Value)
+							}
+						}
+
+						return values
+					}
+				})
+
+				Context("and no memory is freed before the timeout", func() {
+					BeforeEach(func() {
+						// the processes wait until the oom killer is enabled
+						fakeCgroups.WhenGetting("memory", "memory.oom_control", func() (string, error) {
+							values := oomControlValues()
+							if len(values) > 0 && values[len(values)-1] == "0" {
+								return "oom_kill_disable 0\nunder_oom 0\n", nil
+							}
+
+							return "oom_kill_disable 1\nunder_oom 1\n", nil
+						})
+					})
+
+					It("enables the oom killer until the oom is resolved, then disables it again", func() {
+						err := container.LimitMemory(garden.MemoryLimits{
+							LimitInBytes: 102400,
+						})
+						Expect(err).ToNot(HaveOccurred())
+
+						Eventually(oomControlValues).Should(Equal([]string{"1", "0", "1"}))
+					})
+
+					It("watches for the next oom once it is resolved", func() {
+						err := container.LimitMemory(garden.MemoryLimits{
+							LimitInBytes: 102400,
+						})
+						Expect(err).ToNot(HaveOccurred())
+
+						Eventually(func() int {
+							return len(fakeRunner.StartedCommands())
+						}).Should(Equal(2))
+
+						Expect(oomControlValues()).To(Equal([]string{"1", "0", "1"}))
+					})
+				})
+
+				Context("and memory is freed before the timeout", func() {
+					BeforeEach(func() {
+						polled := 0
+						fakeCgroups.WhenGetting("memory", "memory.oom_control", func() (string, error) {
+							polled++
+							if polled > 2 {
+								return "oom_kill_disable 1\nunder_oom 0\n", nil
+							}
+
+							return "oom_kill_disable 1\nunder_oom 1\n", nil
+						})
+					})
+
+					It("leaves the oom killer disabled and watches for the next oom", func() {
+						err := container.LimitMemory(garden.MemoryLimits{
+							LimitInBytes: 102400,
+						})
+						Expect(err).ToNot(HaveOccurred())
+
+						Eventually(func() int {
+							return len(fakeRunner.StartedCommands())
+						}).Should(Equal(2))
+
+						Consistently(oomControlValues).Should(Equal([]string{"1"}))
+					})
+				})
+			})
+		})
+
+		Context("when the oom notifier dies", func() {
+			var blockWaiting chan struct{}
+
+			BeforeEach(func() {
+				blockWaiting = make(chan struct{})
+
+				timings.OomNotifierRestartInterval = 10 * time.Millisecond
+
+				blocked := blockWaiting
+
+				waited := 0
+				fakeRunner.WhenWaitingFor(fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/oom",
+				}, func(cmd *exec.Cmd) error {
+					waited++
+					if waited > 1 {
+						<-blocked
+						return errors.New("killed")
+					}
+
+					return errors.New("oh no!")
+				})
+			})
+
+			AfterEach(func() {
+				container.Stop(false)
+				close(blockWaiting)
+			})
+
+			It("restarts it, without recording an oom or stopping the container", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(func() int {
+					return len(fakeRunner.StartedCommands())
+				}).Should(Equal(2))
+
+				Expect(container.OOMRecords()).To(BeEmpty())
+				Expect(container.State()).ToNot(Equal(linux_container.StateStopped))
+			})
+
+			Context("after the container has been stopped", func() {
+				It("does not restart it", func() {
+					err := container.LimitMemory(garden.MemoryLimits{
+						LimitInBytes: 102400,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(container.Stop(false)).To(Succeed())
+
+					Consistently(func() []*exec.Cmd {
+						started := []*exec.Cmd{}
+						for _, cmd := range fakeRunner.StartedCommands() {
+							if cmd.Path == containerDir+"/bin/oom" {
+								started = append(started, cmd)
+							}
+						}
+
+						return started
+					}).Should(HaveLen(1))
+				})
+			})
+		})
+
+		Describe("setting the oom policy property", func() {
+			Context("when the policy is invalid", func() {
+				It("returns an InvalidLimitPropertyError and leaves the property unset", func() {
+					err := container.SetProperty(linux_container.OOMPolicyProperty, "panic")
+					Expect(err).To(Equal(linux_container.InvalidLimitPropertyError{
+						Key:   linux_container.OOMPolicyProperty,
+						Value: "panic",
+					}))
+
+					_, err = container.Property(linux_container.OOMPolicyProperty)
+					Expect(err).To(HaveOccurred())
+				})
+			})
+
+			Context("when the memory is already limited", func() {
+				var blockWaiting chan struct{}
+
+				BeforeEach(func() {
+					blockWaiting = make(chan struct{})
+					blocked := blockWaiting
+
+					fakeRunner.WhenWaitingFor(fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/oom",
+					}, func(cmd *exec.Cmd) error {
+						<-blocked
+						return errors.New("killed")
+					})
+				})
+
+				AfterEach(func() {
+					container.Stop(false)
+					close(blockWaiting)
+				})
+
+				It("applies the new policy straight away", func() {
+					err := container.LimitMemory(garden.MemoryLimits{
+						LimitInBytes: 102400,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(container.SetProperty(linux_container.OOMPolicyProperty, "record")).To(Succeed())
+					Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+						Subsystem: "memory",
+						Name:      "memory.oom_control",
+						Value:     "1",
+					}))
+
+					Expect(container.RemoveProperty(linux_container.OOMPolicyProperty)).To(Succeed())
+					Expect(fakeCgroups.SetValues()[len(fakeCgroups.SetValues())-1]).To(Equal(fake_cgroups_manager.SetValue{
+						Subsystem: "memory",
+						Name:      "memory.oom_control",
+						Value:     "0",
+					}))
+				})
+			})
 		})
 
 		Context("when setting memory.memsw.limit_in_bytes fails", func() {
@@ -391,49 +905,364 @@ var _ = Describe("Linux containers", func() {
 
 	})
 
-	Describe("Limiting CPU", func() {
-		It("sets cpu.shares", func() {
-			limits := garden.CPULimits{
-				LimitInShares: 512,
-			}
+	Describe("Limiting memory pressure", func() {
+		var blockWaiting chan struct{}
+		var firstWaitFails bool
 
-			err := container.LimitCPU(limits)
-			Expect(err).ToNot(HaveOccurred())
+		BeforeEach(func() {
+			blockWaiting = make(chan struct{})
+			firstWaitFails = false
+		})
 
-			Expect(fakeCgroups.SetValues()).To(Equal(
-				[]fake_cgroups_manager.SetValue{
-					{
-						Subsystem: "cpu",
-						Name:      "cpu.shares",
-						Value:     "512",
-					},
-				},
-			))
+		JustBeforeEach(func() {
+			blocked := blockWaiting
+			failFirst := firstWaitFails
+
+			var waited int32
+			fakeRunner.WhenWaitingFor(fake_command_runner.CommandSpec{
+				Path: containerDir + "/bin/memevents",
+			}, func(cmd *exec.Cmd) error {
+				if atomic.AddInt32(&waited, 1) == 1 && failFirst {
+					return errors.New("oh no!")
+				}
 
+				<-blocked
+				return errors.New("killed")
+			})
 		})
 
-		Context("when setting cpu.shares fails", func() {
-			disaster := errors.New("oh no!")
+		AfterEach(func() {
+			container.Stop(false)
+			close(blockWaiting)
+		})
 
-			BeforeEach(func() {
-				fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
-					return disaster
-				})
-			})
+		memoryEventsHelpers := func() []*exec.Cmd {
+			started := []*exec.Cmd{}
+			for _, cmd := range fakeRunner.StartedCommands() {
+				if cmd.Path == containerDir+"/bin/memevents" {
+					started = append(started, cmd)
+				}
+			}
 
-			It("returns the error", func() {
-				err := container.LimitCPU(garden.CPULimits{
-					LimitInShares: 512,
-				})
+			return started
+		}
 
-				Expect(err).To(Equal(disaster))
+		It("sets memory.soft_limit_in_bytes", func() {
+			err := container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{
+				SoftLimitInBytes: 102400,
 			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "memory", Name: "memory.soft_limit_in_bytes", Value: "102400"},
+			}))
 		})
-	})
 
-	Describe("Getting the current CPU limits", func() {
-		It("returns the CPU limits", func() {
-			fakeCgroups.WhenGetting("cpu", "cpu.shares", func() (string, error) {
+		It("emits a limit-change event", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			Expect(container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{})).To(Succeed())
+
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.LimitChanged))
+			Expect(event.Data).To(Equal(map[string]string{"limit": "memory-pressure"}))
+		})
+
+		Context("when the soft limit is 0", func() {
+			It("removes the soft limit", func() {
+				err := container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+					{Subsystem: "memory", Name: "memory.soft_limit_in_bytes", Value: "-1"},
+				}))
+			})
+
+			It("does not watch for anything", func() {
+				err := container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(memoryEventsHelpers()).To(BeEmpty())
+			})
+		})
+
+		Context("when setting memory.soft_limit_in_bytes fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("memory", "memory.soft_limit_in_bytes", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{
+					SoftLimitInBytes: 102400,
+				})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when a pressure level is unknown", func() {
+			It("returns an UnknownMemoryPressureLevelError, setting nothing", func() {
+				err := container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{
+					SoftLimitInBytes: 102400,
+					PressureLevels:   []linux_backend.MemoryPressureLevel{"dire"},
+				})
+				Expect(err).To(Equal(linux_container.UnknownMemoryPressureLevelError{Level: "dire"}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+				Expect(memoryEventsHelpers()).To(BeEmpty())
+			})
+		})
+
+		Context("when pressure levels and thresholds are given", func() {
+			limits := linux_backend.MemoryPressureLimits{
+				SoftLimitInBytes:  102400,
+				PressureLevels:    []linux_backend.MemoryPressureLevel{linux_backend.MemoryPressureMedium, linux_backend.MemoryPressureCritical},
+				ThresholdsInBytes: []uint64{1048576},
+			}
+
+			It("watches for them", func() {
+				err := container.LimitMemoryPressure(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveStartedExecuting(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/memevents",
+						Args: []string{
+							"/cgroups/memory/instance-some-id",
+							"pressure:medium",
+							"pressure:critical",
+							"threshold:1048576",
+						},
+					},
+				))
+			})
+
+			It("stops watching for the previous ones when limited again", func() {
+				err := container.LimitMemoryPressure(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				previous := memoryEventsHelpers()[0]
+
+				err = container.LimitMemoryPressure(linux_backend.MemoryPressureLimits{
+					ThresholdsInBytes: []uint64{2097152},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.KilledCommands()).To(ContainElement(previous))
+
+				helpers := memoryEventsHelpers()
+				Expect(helpers).To(HaveLen(2))
+				Expect(helpers[1].Args[2:]).To(Equal([]string{"threshold:2097152"}))
+			})
+
+			It("stops watching when the container is stopped", func() {
+				err := container.LimitMemoryPressure(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.Stop(false)).To(Succeed())
+
+				Expect(fakeRunner.KilledCommands()).To(ContainElement(memoryEventsHelpers()[0]))
+			})
+
+			Context("when the kernel reports pressure", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("memory", "memory.stat", func() (string, error) {
+						return "total_rss 42\ntotal_cache 24\ntotal_swap 2\ntotal_inactive_file 4\n", nil
+					})
+
+					fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/memevents",
+					}, func(cmd *exec.Cmd) error {
+						go cmd.Stdout.Write([]byte("pressure critical\n"))
+						return nil
+					})
+				})
+
+				It("emits a memory-pressure event with the memory stats", func() {
+					subscription := eventHub.Subscribe()
+					defer subscription.Close()
+
+					err := container.LimitMemoryPressure(limits)
+					Expect(err).ToNot(HaveOccurred())
+
+					var event event_hub.Event
+					Eventually(func() event_hub.EventType {
+						Eventually(subscription.Events()).Should(Receive(&event))
+						return event.Type
+					}).Should(Equal(event_hub.MemoryPressure))
+
+					Expect(event.Handle).To(Equal("some-handle"))
+					Expect(event.Data).To(Equal(map[string]string{
+						"level": "critical",
+
+						"total_usage_toward_limit": "62",
+						"total_rss":                "42",
+						"total_cache":              "24",
+						"total_swap":               "2",
+						"total_mapped_file":        "0",
+						"total_inactive_file":      "4",
+						"total_pgmajfault":         "0",
+					}))
+				})
+			})
+
+			Context("when usage crosses a threshold", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("memory", "memory.usage_in_bytes", func() (string, error) {
+						return "1048600\n", nil
+					})
+
+					fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/memevents",
+					}, func(cmd *exec.Cmd) error {
+						go cmd.Stdout.Write([]byte("threshold 1048576\n"))
+						return nil
+					})
+				})
+
+				It("emits a memory-threshold event saying which way it was crossed", func() {
+					subscription := eventHub.Subscribe()
+					defer subscription.Close()
+
+					err := container.LimitMemoryPressure(limits)
+					Expect(err).ToNot(HaveOccurred())
+
+					var event event_hub.Event
+					Eventually(func() event_hub.EventType {
+						Eventually(subscription.Events()).Should(Receive(&event))
+						return event.Type
+					}).Should(Equal(event_hub.MemoryThreshold))
+
+					Expect(event.Data).To(HaveKeyWithValue("threshold", "1048576"))
+					Expect(event.Data).To(HaveKeyWithValue("direction", "above"))
+					Expect(event.Data).To(HaveKeyWithValue("usage_in_bytes", "1048600"))
+					Expect(event.Data).To(HaveKey("total_rss"))
+				})
+			})
+
+			Context("when the helper dies", func() {
+				BeforeEach(func() {
+					timings.MemoryEventsRestartInterval = 10 * time.Millisecond
+
+					firstWaitFails = true
+				})
+
+				It("restarts it", func() {
+					err := container.LimitMemoryPressure(limits)
+					Expect(err).ToNot(HaveOccurred())
+
+					Eventually(memoryEventsHelpers).Should(HaveLen(2))
+					Expect(memoryEventsHelpers()[1].Args).To(Equal(memoryEventsHelpers()[0].Args))
+				})
+			})
+
+			Context("when starting the helper fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+						Path: containerDir + "/bin/memevents",
+					}, func(*exec.Cmd) error {
+						return disaster
+					})
+				})
+
+				It("returns the error", func() {
+					err := container.LimitMemoryPressure(limits)
+					Expect(err).To(Equal(disaster))
+				})
+			})
+		})
+	})
+
+	Describe("Getting the current memory pressure limits", func() {
+		It("returns the soft limit, and what is being watched for", func() {
+			fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+				return "102400\n", nil
+			})
+
+			limits := linux_backend.MemoryPressureLimits{
+				SoftLimitInBytes: 102400,
+				PressureLevels:   []linux_backend.MemoryPressureLevel{linux_backend.MemoryPressureLow},
+			}
+
+			Expect(container.LimitMemoryPressure(limits)).To(Succeed())
+			defer container.Stop(false)
+
+			Expect(container.CurrentMemoryPressureLimits()).To(Equal(limits))
+		})
+
+		Context("when there is no soft limit", func() {
+			It("returns a soft limit of 0", func() {
+				fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+					return "9223372036854771712\n", nil
+				})
+
+				Expect(container.CurrentMemoryPressureLimits()).To(Equal(linux_backend.MemoryPressureLimits{}))
+			})
+		})
+
+		Context("when getting the soft limit fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+					return "", disaster
+				})
+
+				_, err := container.CurrentMemoryPressureLimits()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Limiting CPU", func() {
+		It("sets cpu.shares", func() {
+			limits := garden.CPULimits{
+				LimitInShares: 512,
+			}
+
+			err := container.LimitCPU(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "cpu",
+						Name:      "cpu.shares",
+						Value:     "512",
+					},
+				},
+			))
+
+		})
+
+		Context("when setting cpu.shares fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitCPU(garden.CPULimits{
+					LimitInShares: 512,
+				})
+
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current CPU limits", func() {
+		It("returns the CPU limits", func() {
+			fakeCgroups.WhenGetting("cpu", "cpu.shares", func() (string, error) {
 				return "512", nil
 			})
 
@@ -466,6 +1295,523 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Capping CPU with a quota", func() {
+		It("sets cpu.cfs_period_us and then cpu.cfs_quota_us", func() {
+			err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 200000,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "cpu",
+						Name:      "cpu.cfs_period_us",
+						Value:     "200000",
+					},
+					{
+						Subsystem: "cpu",
+						Name:      "cpu.cfs_quota_us",
+						Value:     "50000",
+					},
+				},
+			))
+		})
+
+		Context("when no period is given", func() {
+			It("uses the default period", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.cfs_period_us",
+					Value:     "100000",
+				}))
+			})
+		})
+
+		Context("when the quota is 0", func() {
+			It("lifts the cap", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.cfs_quota_us",
+					Value:     "-1",
+				}))
+			})
+		})
+
+		It("can be applied as a linux limit", func() {
+			err := container.LimitLinux(linux_backend.LinuxLimits{
+				CPUQuota: &linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "cpu",
+				Name:      "cpu.cfs_quota_us",
+				Value:     "50000",
+			}))
+		})
+
+		Context("when setting cpu.cfs_quota_us fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.cfs_quota_us", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitCPUQuota(linux_backend.CPUQuotaLimits{
+					QuotaInMicroseconds: 50000,
+				})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Getting the current CPU quota", func() {
+		BeforeEach(func() {
+			fakeCgroups.WhenGetting("cpu", "cpu.cfs_period_us", func() (string, error) {
+				return "100000", nil
+			})
+		})
+
+		It("returns the quota and period", func() {
+			fakeCgroups.WhenGetting("cpu", "cpu.cfs_quota_us", func() (string, error) {
+				return "50000", nil
+			})
+
+			limits, err := container.CurrentCPUQuotaLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.CPUQuotaLimits{
+				QuotaInMicroseconds:  50000,
+				PeriodInMicroseconds: 100000,
+			}))
+
+			fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+				return "max", nil
+			})
+
+			fakeCgroups.WhenGetting("blkio", "blkio.weight", func() (string, error) {
+				return "500", nil
+			})
+
+			fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+				return "9223372036854771712", nil
+			})
+
+			linuxLimits, err := container.CurrentLinuxLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(linuxLimits.CPUQuota).To(Equal(&limits))
+		})
+
+		Context("when the container is not capped", func() {
+			It("returns a quota of 0", func() {
+				fakeCgroups.WhenGetting("cpu", "cpu.cfs_quota_us", func() (string, error) {
+					return "-1", nil
+				})
+
+				limits, err := container.CurrentCPUQuotaLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.QuotaInMicroseconds).To(BeZero())
+			})
+		})
+
+		Context("when getting the quota fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeCgroups.WhenGetting("cpu", "cpu.cfs_quota_us", func() (string, error) {
+					return "", disaster
+				})
+
+				_, err := container.CurrentCPUQuotaLimits()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Limiting pids", func() {
+		It("sets pids.max", func() {
+			err := container.LimitPids(linux_backend.PidsLimits{Max: 256})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal(
+				[]fake_cgroups_manager.SetValue{
+					{
+						Subsystem: "pids",
+						Name:      "pids.max",
+						Value:     "256",
+					},
+				},
+			))
+		})
+
+		Context("when the max is 0", func() {
+			It("lifts the limit", func() {
+				err := container.LimitPids(linux_backend.PidsLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "pids",
+					Name:      "pids.max",
+					Value:     "max",
+				}))
+			})
+		})
+
+		Context("when setting pids.max fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("pids", "pids.max", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitPids(linux_backend.PidsLimits{Max: 256})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when the container has no pids cgroup", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("pids", "pids.max", func() error {
+					return &os.PathError{Op: "open", Path: "pids.max", Err: os.ErrNotExist}
+				})
+			})
+
+			It("fails to set a limit", func() {
+				err := container.LimitPids(linux_backend.PidsLimits{Max: 256})
+				Expect(err).To(Equal(linux_container.PidsUnsupportedError{}))
+			})
+
+			It("succeeds in lifting the limit", func() {
+				err := container.LimitPids(linux_backend.PidsLimits{})
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Describe("when the limit is hit", func() {
+			var pidsEvents chan string
+
+			BeforeEach(func() {
+				timings.PidsEventsPollInterval = 10 * time.Millisecond
+
+				pidsEvents = make(chan string, 10)
+				pidsEvents <- "max 2\n"
+
+				lastEvents := "max 2\n"
+				fakeCgroups.WhenGetting("pids", "pids.events", func() (string, error) {
+					select {
+					case lastEvents = <-pidsEvents:
+					default:
+					}
+
+					return lastEvents, nil
+				})
+			})
+
+			AfterEach(func() {
+				container.Cleanup()
+			})
+
+			It("emits an event for the forks refused since it was limited", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.LimitPids(linux_backend.PidsLimits{Max: 256})
+				Expect(err).ToNot(HaveOccurred())
+
+				var event event_hub.Event
+				Expect(subscription.Events()).To(Receive(&event))
+				Expect(event.Type).To(Equal(event_hub.LimitChanged))
+
+				Consistently(subscription.Events()).ShouldNot(Receive())
+
+				pidsEvents <- "max 5\n"
+
+				Eventually(subscription.Events()).Should(Receive(&event))
+				Expect(event.Type).To(Equal(event_hub.PidsLimitReached))
+				Expect(event.Data).To(Equal(map[string]string{
+					"refused": "3",
+					"total":   "5",
+				}))
+			})
+		})
+	})
+
+	Describe("Getting the current pids limit", func() {
+		It("returns the pids limit", func() {
+			fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+				return "256", nil
+			})
+
+			limits, err := container.CurrentPidsLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits.Max).To(Equal(uint64(256)))
+		})
+
+		Context("when there is no limit", func() {
+			It("returns a max of 0", func() {
+				fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+					return "max", nil
+				})
+
+				limits, err := container.CurrentPidsLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.Max).To(BeZero())
+			})
+		})
+
+		Context("when getting the limit fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+					return "", disaster
+				})
+
+				_, err := container.CurrentPidsLimits()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when the container has no pids cgroup", func() {
+			It("returns a max of 0", func() {
+				fakeCgroups.WhenGetting("pids", "pids.max", func() (string, error) {
+					return "", &os.PathError{Op: "open", Path: "pids.max", Err: os.ErrNotExist}
+				})
+
+				limits, err := container.CurrentPidsLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.Max).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Limiting block I/O", func() {
+		limits := linux_backend.BlockIOLimits{
+			Weight:              200,
+			ReadBytesPerSecond:  1048576,
+			WriteBytesPerSecond: 524288,
+			ReadIOPerSecond:     100,
+			WriteIOPerSecond:    50,
+		}
+
+		It("sets the weight and throttles I/O to the depot device", func() {
+			err := container.LimitBlockIO(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			setValues := fakeCgroups.SetValues()
+			Expect(setValues).To(HaveLen(5))
+
+			Expect(setValues[0]).To(Equal(fake_cgroups_manager.SetValue{
+				Subsystem: "blkio",
+				Name:      "blkio.weight",
+				Value:     "200",
+			}))
+
+			throttles := map[string]string{}
+			for _, value := range setValues[1:] {
+				Expect(value.Subsystem).To(Equal("blkio"))
+				throttles[value.Name] = value.Value
+			}
+
+			Expect(throttles["blkio.throttle.read_bps_device"]).To(MatchRegexp(`^\d+:\d+ 1048576$`))
+			Expect(throttles["blkio.throttle.write_bps_device"]).To(MatchRegexp(`^\d+:\d+ 524288$`))
+			Expect(throttles["blkio.throttle.read_iops_device"]).To(MatchRegexp(`^\d+:\d+ 100$`))
+			Expect(throttles["blkio.throttle.write_iops_device"]).To(MatchRegexp(`^\d+:\d+ 50$`))
+		})
+
+		It("reads back the limits for the depot device", func() {
+			err := container.LimitBlockIO(limits)
+			Expect(err).ToNot(HaveOccurred())
+
+			current, err := container.CurrentBlockIOLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(current).To(Equal(limits))
+		})
+
+		Context("when the weight is 0", func() {
+			It("leaves the weight as it is", func() {
+				err := container.LimitBlockIO(linux_backend.BlockIOLimits{
+					ReadBytesPerSecond: 1048576,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				for _, value := range fakeCgroups.SetValues() {
+					Expect(value.Name).ToNot(Equal("blkio.weight"))
+				}
+			})
+		})
+
+		Context("when only some throttles are given", func() {
+			It("removes the others", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitBlockIO(linux_backend.BlockIOLimits{
+					WriteIOPerSecond: 75,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				setValues := fakeCgroups.SetValues()
+				Expect(setValues).To(HaveLen(9))
+
+				throttles := map[string]string{}
+				for _, value := range setValues[5:] {
+					throttles[value.Name] = value.Value
+				}
+
+				Expect(throttles["blkio.throttle.read_bps_device"]).To(MatchRegexp(`^\d+:\d+ 0$`))
+				Expect(throttles["blkio.throttle.write_bps_device"]).To(MatchRegexp(`^\d+:\d+ 0$`))
+				Expect(throttles["blkio.throttle.read_iops_device"]).To(MatchRegexp(`^\d+:\d+ 0$`))
+				Expect(throttles["blkio.throttle.write_iops_device"]).To(MatchRegexp(`^\d+:\d+ 75$`))
+			})
+
+			It("snapshots the limits in effect", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitBlockIO(linux_backend.BlockIOLimits{
+					WriteIOPerSecond: 75,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				expected := linux_backend.BlockIOLimits{
+					Weight:           200,
+					WriteIOPerSecond: 75,
+				}
+
+				snapshot := new(bytes.Buffer)
+				Expect(container.Snapshot(snapshot)).To(Succeed())
+
+				containerSnapshot, err := linux_container.ReadSnapshot(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containerSnapshot.Limits.BlockIO).To(Equal(&expected))
+			})
+		})
+
+		Context("when no throttles are given", func() {
+			It("removes every throttle", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitBlockIO(linux_backend.BlockIOLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				setValues := fakeCgroups.SetValues()
+				Expect(setValues).To(HaveLen(9))
+
+				for _, value := range setValues[5:] {
+					Expect(value.Name).To(HavePrefix("blkio.throttle."))
+					Expect(value.Value).To(MatchRegexp(`^\d+:\d+ 0$`))
+				}
+
+				snapshot := new(bytes.Buffer)
+				Expect(container.Snapshot(snapshot)).To(Succeed())
+
+				containerSnapshot, err := linux_container.ReadSnapshot(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containerSnapshot.Limits.BlockIO).To(Equal(&linux_backend.BlockIOLimits{Weight: 200}))
+			})
+		})
+
+		Context("when setting a throttle fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("blkio", "blkio.throttle.write_bps_device", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitBlockIO(limits)
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Finding the disk holding a block device", func() {
+		var sysBlockDevices string
+
+		BeforeEach(func() {
+			var err error
+			sysBlockDevices, err = ioutil.TempDir("", "sys-dev-block")
+			Expect(err).ToNot(HaveOccurred())
+
+			devices := filepath.Join(sysBlockDevices, "devices")
+
+			disk := filepath.Join(devices, "sda")
+			Expect(os.MkdirAll(filepath.Join(disk, "sda1"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(disk, "dev"), []byte("8:0\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(disk, "sda1", "dev"), []byte("8:1\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(disk, "sda1", "partition"), []byte("1\n"), 0644)).To(Succeed())
+
+			Expect(os.Symlink(disk, filepath.Join(sysBlockDevices, "8:0"))).To(Succeed())
+			Expect(os.Symlink(filepath.Join(disk, "sda1"), filepath.Join(sysBlockDevices, "8:1"))).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sysBlockDevices)
+		})
+
+		It("returns a whole disk as it is", func() {
+			Expect(linux_container.ParentDisk(sysBlockDevices, "8:0")).To(Equal("8:0"))
+		})
+
+		It("returns the disk holding a partition", func() {
+			Expect(linux_container.ParentDisk(sysBlockDevices, "8:1")).To(Equal("8:0"))
+		})
+
+		It("rejects devices which are not backed by a disk", func() {
+			_, err := linux_container.ParentDisk(sysBlockDevices, "0:42")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Getting the current block I/O limits", func() {
+		Context("when the depot device is not throttled", func() {
+			It("returns throttles of 0", func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.weight", func() (string, error) {
+					return "500", nil
+				})
+
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.read_bps_device", func() (string, error) {
+					return "253:7 1024\n", nil
+				})
+
+				limits, err := container.CurrentBlockIOLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits).To(Equal(linux_backend.BlockIOLimits{Weight: 500}))
+			})
+		})
+
+		Context("when getting the weight fails", func() {
+			It("returns the error", func() {
+				disaster := errors.New("oh no!")
+				fakeCgroups.WhenGetting("blkio", "blkio.weight", func() (string, error) {
+					return "", disaster
+				})
+
+				_, err := container.CurrentBlockIOLimits()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+
 	Describe("Limiting disk", func() {
 		limits := garden.DiskLimits{
 			BlockSoft: 3,
@@ -1,7 +1,6 @@
 package linux_container
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -14,6 +13,8 @@ import (
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
@@ -48,6 +49,7 @@ type LinuxContainer struct {
 	graceTime time.Duration
 
 	state      State
+	stateTimes map[State]time.Time
 	stateMutex sync.RWMutex
 
 	events      []string
@@ -67,8 +69,12 @@ type LinuxContainer struct {
 
 	filter network.Filter
 
-	oomMutex    sync.RWMutex
-	oomNotifier *exec.Cmd
+	oomMutex        sync.RWMutex
+	oomNotifier     *exec.Cmd
+	oomNotifierStop chan struct{}
+
+	oomRecords      []linux_backend.OOMRecord
+	oomRecordsMutex sync.RWMutex
 
 	currentBandwidthLimits *garden.BandwidthLimits
 	bandwidthMutex         sync.RWMutex
@@ -76,11 +82,29 @@ type LinuxContainer struct {
 	currentDiskLimits *garden.DiskLimits
 	diskMutex         sync.RWMutex
 
-	currentMemoryLimits *garden.MemoryLimits
-	memoryMutex         sync.RWMutex
+	currentMemoryLimits         *garden.MemoryLimits
+	currentMemoryPressureLimits *linux_backend.MemoryPressureLimits
+	memoryMutex                 sync.RWMutex
+
+	currentCPULimits      *garden.CPULimits
+	currentCPUQuotaLimits *linux_backend.CPUQuotaLimits
+	cpuMutex              sync.RWMutex
+
+	currentPidsLimits *linux_backend.PidsLimits
+	pidsMutex         sync.RWMutex
+
+	currentBlockIOLimits *linux_backend.BlockIOLimits
+	blockIOMutex         sync.RWMutex
+
+	pidsWatcherStop  chan struct{}
+	pidsWatcherMutex sync.Mutex
+
+	memoryEvents      *memoryEventsHelper
+	memoryEventsStop  chan struct{}
+	memoryEventsMutex sync.Mutex
 
-	currentCPULimits *garden.CPULimits
-	cpuMutex         sync.RWMutex
+	networkCounters      networkCounters
+	networkCountersMutex sync.Mutex
 
 	netIns      []NetInSpec
 	netInsMutex sync.RWMutex
@@ -93,6 +117,102 @@ type LinuxContainer struct {
 	env process.Env
 
 	processIDPool *ProcessIDPool
+
+	changeListener      func()
+	changeListenerMutex sync.RWMutex
+
+	eventEmitter event_hub.Emitter
+
+	committer capacity.Committer
+
+	timings Timings
+}
+
+// Timings are how long the container waits for things to happen. Zero
+// values take the defaults.
+type Timings struct {
+	// FreezeTimeout is how long to wait for every process in the container
+	// to be frozen before giving up and thawing them again.
+	FreezeTimeout time.Duration
+
+	// OomNotifierRestartInterval is how long to wait before restarting an
+	// oom notifier which died, rather than exiting because the container ran
+	// out of memory.
+	OomNotifierRestartInterval time.Duration
+
+	// MemoryEventsRestartInterval is how long to wait before restarting the
+	// memory events helper if it dies while the container is still watched.
+	MemoryEventsRestartInterval time.Duration
+
+	// StopGracePeriod is the grace period of containers which do not set
+	// one.
+	StopGracePeriod time.Duration
+
+	// StopKillTimeout is how long killed processes are given to leave the
+	// container's cgroup before stopping fails.
+	StopKillTimeout time.Duration
+
+	// StopPollInterval is how often the container's cgroup is read while
+	// waiting for its processes to exit.
+	StopPollInterval time.Duration
+
+	// OomRecordTimeout is how long the record oom policy leaves the
+	// container's processes waiting for memory before letting the kernel's
+	// oom killer free some.
+	OomRecordTimeout time.Duration
+
+	// OomPollInterval is how often memory.oom_control is read while waiting
+	// for an oom to be resolved.
+	OomPollInterval time.Duration
+
+	// PidsEventsPollInterval is how often pids.events is checked for forks
+	// which the pids limit refused, as the kernel does not notify anyone of
+	// them.
+	PidsEventsPollInterval time.Duration
+}
+
+func (t Timings) freezeTimeout() time.Duration {
+	return orDefault(t.FreezeTimeout, 5*time.Second)
+}
+
+func (t Timings) oomNotifierRestartInterval() time.Duration {
+	return orDefault(t.OomNotifierRestartInterval, time.Second)
+}
+
+func (t Timings) memoryEventsRestartInterval() time.Duration {
+	return orDefault(t.MemoryEventsRestartInterval, time.Second)
+}
+
+func (t Timings) stopGracePeriod() time.Duration {
+	return orDefault(t.StopGracePeriod, 10*time.Second)
+}
+
+func (t Timings) stopKillTimeout() time.Duration {
+	return orDefault(t.StopKillTimeout, 10*time.Second)
+}
+
+func (t Timings) stopPollInterval() time.Duration {
+	return orDefault(t.StopPollInterval, 100*time.Millisecond)
+}
+
+func (t Timings) oomRecordTimeout() time.Duration {
+	return orDefault(t.OomRecordTimeout, time.Minute)
+}
+
+func (t Timings) oomPollInterval() time.Duration {
+	return orDefault(t.OomPollInterval, 100*time.Millisecond)
+}
+
+func (t Timings) pidsEventsPollInterval() time.Duration {
+	return orDefault(t.PidsEventsPollInterval, time.Second)
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+
+	return d
 }
 
 type ProcessIDPool struct {
@@ -128,14 +248,6 @@ type PortPool interface {
 	Release(uint32)
 }
 
-type State string
-
-const (
-	StateBorn    = State("born")
-	StateActive  = State("active")
-	StateStopped = State("stopped")
-)
-
 func NewLinuxContainer(
 	logger lager.Logger,
 	id, handle, path string,
@@ -150,6 +262,9 @@ func NewLinuxContainer(
 	processTracker process_tracker.ProcessTracker,
 	env process.Env,
 	filter network.Filter,
+	eventEmitter event_hub.Emitter,
+	committer capacity.Committer,
+	timings Timings,
 ) *LinuxContainer {
 	return &LinuxContainer{
 		logger: logger,
@@ -162,8 +277,9 @@ func NewLinuxContainer(
 
 		graceTime: graceTime,
 
-		state:  StateBorn,
-		events: []string{},
+		state:      StateBorn,
+		stateTimes: map[State]time.Time{StateBorn: time.Now()},
+		events:     []string{},
 
 		resources: resources,
 
@@ -181,6 +297,12 @@ func NewLinuxContainer(
 
 		env:           env,
 		processIDPool: &ProcessIDPool{},
+
+		eventEmitter: eventEmitter,
+
+		committer: committer,
+
+		timings: timings,
 	}
 }
 
@@ -196,13 +318,6 @@ func (c *LinuxContainer) GraceTime() time.Duration {
 	return c.graceTime
 }
 
-func (c *LinuxContainer) State() State {
-	c.stateMutex.RLock()
-	defer c.stateMutex.RUnlock()
-
-	return c.state
-}
-
 func (c *LinuxContainer) Events() []string {
 	c.eventsMutex.RLock()
 	defer c.eventsMutex.RUnlock()
@@ -218,6 +333,16 @@ func (c *LinuxContainer) Resources() *linux_backend.Resources {
 	return c.resources
 }
 
+// OnChange registers a function to be called whenever state captured by
+// Snapshot changes, e.g. limits, port mappings, properties or processes.
+// Passing nil removes the current listener.
+func (c *LinuxContainer) OnChange(listener func()) {
+	c.changeListenerMutex.Lock()
+	defer c.changeListenerMutex.Unlock()
+
+	c.changeListener = listener
+}
+
 func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	cLog := c.logger.Session("snapshot")
 
@@ -235,6 +360,12 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	c.memoryMutex.RLock()
 	defer c.memoryMutex.RUnlock()
 
+	c.pidsMutex.RLock()
+	defer c.pidsMutex.RUnlock()
+
+	c.blockIOMutex.RLock()
+	defer c.blockIOMutex.RUnlock()
+
 	c.netInsMutex.RLock()
 	defer c.netInsMutex.RUnlock()
 
@@ -260,14 +391,20 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 
 		GraceTime: c.graceTime,
 
-		State:  string(c.State()),
-		Events: c.Events(),
+		State:      string(c.State()),
+		StateTimes: c.StateTimes(),
+		Events:     c.Events(),
 
 		Limits: LimitsSnapshot{
 			Bandwidth: c.currentBandwidthLimits,
 			CPU:       c.currentCPULimits,
+			CPUQuota:  c.currentCPUQuotaLimits,
+			Pids:      c.currentPidsLimits,
+			BlockIO:   c.currentBlockIOLimits,
 			Disk:      c.currentDiskLimits,
 			Memory:    c.currentMemoryLimits,
+
+			MemoryPressure: c.currentMemoryPressureLimits,
 		},
 
 		Resources: ResourcesSnapshot{
@@ -276,6 +413,7 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 			Network: c.resources.Network,
 			Bridge:  c.resources.Bridge,
 			Ports:   c.resources.Ports,
+			CPUSet:  c.resources.CPUSet,
 		},
 
 		NetIns:  c.netIns,
@@ -286,11 +424,15 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 		Properties: properties,
 
 		EnvVars: c.env.Array(),
+
+		NetworkCounters: c.networkCountersSnapshot(),
+
+		OOMRecords: c.OOMRecords(),
 	}
 
 	var err error
 
-	err = json.NewEncoder(out).Encode(snapshot)
+	err = WriteSnapshot(out, snapshot)
 	if err != nil {
 		cLog.Error("failed-to-save", err, lager.Data{
 			"snapshot": snapshot,
@@ -315,7 +457,7 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 		Logger:        cLog,
 	}
 
-	c.setState(State(snapshot.State))
+	c.restoreState(State(snapshot.State), snapshot.StateTimes)
 
 	snapshotEnv, err := process.NewEnv(snapshot.EnvVars)
 	if err != nil {
@@ -330,10 +472,18 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 		c.registerEvent(ev)
 	}
 
-	if snapshot.Limits.Memory != nil {
-		err := c.LimitMemory(*snapshot.Limits.Memory)
+	if snapshot.NetworkCounters != nil {
+		c.restoreNetworkCounters(*snapshot.NetworkCounters)
+	}
+
+	c.oomRecordsMutex.Lock()
+	c.oomRecords = snapshot.OOMRecords
+	c.oomRecordsMutex.Unlock()
+
+	if c.resources.CPUSet != nil {
+		err := c.pinCPUSet()
 		if err != nil {
-			cLog.Error("failed-to-limit-memory", err)
+			cLog.Error("failed-to-pin-cpuset", err)
 			return err
 		}
 	}
@@ -347,13 +497,15 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 
 		pidfile := path.Join(c.path, "processes", fmt.Sprintf("%d.pid", process.ID))
 
-		signaller := &linux_backend.NamespacedSignaller{
-			Runner:        c.runner,
-			ContainerPath: c.path,
-			PidFilePath:   pidfile,
+		c.processTracker.Restore(process.ID, c.signaller(pidfile))
+
+		restored, err := c.processTracker.Attach(process.ID, garden.ProcessIO{})
+		if err != nil {
+			cLog.Error("failed-to-attach-to-restored-process", err)
+			continue
 		}
 
-		c.processTracker.Restore(process.ID, signaller)
+		go c.watchForExit(restored)
 	}
 
 	net := exec.Command(path.Join(c.path, "net.sh"), "setup")
@@ -365,7 +517,7 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 
 	for _, in := range snapshot.NetIns {
-		_, _, err = c.NetIn(in.HostPort, in.ContainerPort)
+		_, _, err = c.netIn(in.HostPort, in.ContainerPort)
 		if err != nil {
 			cLog.Error("failed-to-reenforce-port-mapping", err)
 			return err
@@ -373,12 +525,39 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 
 	for _, out := range snapshot.NetOuts {
-		if err := c.NetOut(out); err != nil {
+		if err := c.netOut(out); err != nil {
 			cLog.Error("failed-to-reenforce-net-out", err)
 			return err
 		}
 	}
 
+	err = c.reconcileLimits(cLog, snapshot.Limits)
+	if err != nil {
+		return err
+	}
+
+	if c.State() == StatePaused {
+		err := c.freeze(cLog)
+		if err != nil {
+			cLog.Error("failed-to-refreeze", err)
+			return err
+		}
+	}
+
+	// the restored container already holds these resources, whether or not
+	// they would be admitted now
+	if snapshot.Limits.Memory != nil {
+		c.committer.ForceCommit(c.handle, capacity.Memory, snapshot.Limits.Memory.LimitInBytes)
+	}
+
+	if snapshot.Limits.Disk != nil {
+		c.committer.ForceCommit(c.handle, capacity.Disk, snapshot.Limits.Disk.ByteHard)
+	}
+
+	if snapshot.Limits.CPU != nil {
+		c.committer.ForceCommit(c.handle, capacity.CPU, snapshot.Limits.CPU.LimitInShares)
+	}
+
 	cLog.Info("restored")
 
 	return nil
@@ -389,24 +568,64 @@ func (c *LinuxContainer) Start() error {
 
 	cLog.Debug("starting")
 
+	from, err := c.transition(StateStarting)
+	if err != nil {
+		cLog.Error("failed-to-start", err)
+		return err
+	}
+
+	_, err = c.oomPolicy()
+	if err != nil {
+		cLog.Error("invalid-oom-policy", err)
+		c.transition(from)
+		return err
+	}
+
+	limits, err := c.propertyLimits()
+	if err != nil {
+		cLog.Error("invalid-property-limits", err)
+		c.transition(from)
+		return err
+	}
+
 	start := exec.Command(path.Join(c.path, "start.sh"))
 	start.Env = []string{
 		"id=" + c.id,
 		"PATH=" + os.Getenv("PATH"),
 	}
 
+	// the cpuset cgroup is created by a hook as wshd starts, which pins it
+	// to these rather than copying the whole of its parent's
+	if c.resources.CPUSet != nil {
+		start.Env = append(start.Env,
+			"cpuset_cpus="+c.resources.CPUSet.CPUList(),
+			"cpuset_mems="+c.resources.CPUSet.MemList(),
+		)
+	}
+
 	cRunner := logging.Runner{
 		CommandRunner: c.runner,
 		Logger:        cLog,
 	}
 
-	err := cRunner.Run(start)
+	err = cRunner.Run(start)
 	if err != nil {
 		cLog.Error("failed-to-start", err)
+		c.transition(from)
 		return fmt.Errorf("container: start: %v", err)
 	}
 
-	c.setState(StateActive)
+	// the container's cgroups are only created once start.sh runs wshd
+	err = c.LimitLinux(limits)
+	if err != nil {
+		cLog.Error("failed-to-apply-property-limits", err)
+		c.transition(from)
+		return err
+	}
+
+	c.transition(StateActive)
+
+	c.emit(event_hub.Started, nil)
 
 	cLog.Info("started")
 
@@ -419,28 +638,18 @@ func (c *LinuxContainer) Cleanup() {
 	cLog.Debug("stopping-oom-notifier")
 	c.stopOomNotifier()
 
-	cLog.Info("done")
-}
-
-func (c *LinuxContainer) Stop(kill bool) error {
-	stop := exec.Command(path.Join(c.path, "stop.sh"))
-
-	if kill {
-		stop.Args = append(stop.Args, "-w", "0")
-	}
-
-	err := c.runner.Run(stop)
-	if err != nil {
-		return err
-	}
-
-	c.stopOomNotifier()
+	cLog.Debug("stopping-pids-watcher")
+	c.stopPidsWatcher()
 
-	c.setState(StateStopped)
+	cLog.Debug("stopping-memory-events")
+	c.stopMemoryEvents()
 
-	return nil
+	cLog.Info("done")
 }
 
+// Properties returns the container's properties. The map is replaced rather
+// than changed whenever a property is set or removed, so it is safe to read
+// without holding any lock, but must not be changed.
 func (c *LinuxContainer) Properties() (garden.Properties, error) {
 	c.propertiesMutex.RLock()
 	defer c.propertiesMutex.RUnlock()
@@ -461,8 +670,21 @@ func (c *LinuxContainer) Property(key string) (string, error) {
 }
 
 func (c *LinuxContainer) SetProperty(key string, value string) error {
+	if key == OOMPolicyProperty {
+		err := c.setOomPolicy(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if key == StopGracePeriodProperty {
+		_, err := parseStopGracePeriod(value)
+		if err != nil {
+			return err
+		}
+	}
+
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	props := garden.Properties{}
 	for k, v := range c.properties {
@@ -472,34 +694,57 @@ func (c *LinuxContainer) SetProperty(key string, value string) error {
 	props[key] = value
 
 	c.properties = props
+	c.propertiesMutex.Unlock()
+
+	c.notifyChanged()
 
 	return nil
 }
 
 func (c *LinuxContainer) RemoveProperty(key string) error {
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	if _, found := c.properties[key]; !found {
+		c.propertiesMutex.Unlock()
 		return UndefinedPropertyError{key}
 	}
 
-	delete(c.properties, key)
+	// copied rather than changed in place, as the map returned by
+	// Properties may still be being read
+	props := garden.Properties{}
+	for k, v := range c.properties {
+		if k != key {
+			props[k] = v
+		}
+	}
+
+	c.properties = props
+	c.propertiesMutex.Unlock()
+
+	if key == OOMPolicyProperty {
+		err := c.setOomPolicy(string(linux_backend.OOMPolicyStop))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.notifyChanged()
 
 	return nil
 }
 
+// HasProperties reports whether the container's properties match the
+// selector described by properties; see linux_backend.ParseSelector.
 func (c *LinuxContainer) HasProperties(properties garden.Properties) bool {
+	selector, err := linux_backend.ParseSelector(properties)
+	if err != nil {
+		return false
+	}
+
 	c.propertiesMutex.RLock()
 	defer c.propertiesMutex.RUnlock()
 
-	for k, v := range properties {
-		if value, ok := c.properties[k]; !ok || (ok && value != v) {
-			return false
-		}
-	}
-
-	return true
+	return selector.Matches(c.properties)
 }
 
 func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
@@ -540,6 +785,10 @@ func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
 }
 
 func (c *LinuxContainer) StreamIn(dstPath string, tarStream io.Reader) error {
+	if err := c.checkState("stream in", StateActive); err != nil {
+		return err
+	}
+
 	nsTarPath := path.Join(c.path, "bin", "nstar")
 	pidPath := path.Join(c.path, "run", "wshd.pid")
 
@@ -574,6 +823,10 @@ func (c *LinuxContainer) StreamIn(dstPath string, tarStream io.Reader) error {
 }
 
 func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
+	if err := c.checkState("stream out", StateActive); err != nil {
+		return nil, err
+	}
+
 	workingDir := filepath.Dir(srcPath)
 	compressArg := filepath.Base(srcPath)
 	if strings.HasSuffix(srcPath, "/") {
@@ -624,6 +877,14 @@ func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
 }
 
 func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
+	if err := c.checkState("map a port", StateBorn, StateActive); err != nil {
+		return 0, 0, err
+	}
+
+	return c.netIn(hostPort, containerPort)
+}
+
+func (c *LinuxContainer) netIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
 	if hostPort == 0 {
 		randomPort, err := c.portPool.Acquire()
 		if err != nil {
@@ -652,23 +913,33 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 	}
 
 	c.netInsMutex.Lock()
-	defer c.netInsMutex.Unlock()
-
 	c.netIns = append(c.netIns, NetInSpec{hostPort, containerPort})
+	c.netInsMutex.Unlock()
+
+	c.notifyChanged()
 
 	return hostPort, containerPort, nil
 }
 
 func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
+	if err := c.checkState("allow outbound traffic", StateBorn, StateActive); err != nil {
+		return err
+	}
+
+	return c.netOut(r)
+}
+
+func (c *LinuxContainer) netOut(r garden.NetOutRule) error {
 	err := c.filter.NetOut(r)
 	if err != nil {
 		return err
 	}
 
 	c.netOutsMutex.Lock()
-	defer c.netOutsMutex.Unlock()
-
 	c.netOuts = append(c.netOuts, r)
+	c.netOutsMutex.Unlock()
+
+	c.notifyChanged()
 
 	return nil
 }
@@ -677,16 +948,27 @@ func (c *LinuxContainer) CurrentEnvVars() process.Env {
 	return c.env
 }
 
-func (c *LinuxContainer) setState(state State) {
-	c.stateMutex.Lock()
-	defer c.stateMutex.Unlock()
-
-	c.state = state
-}
-
 func (c *LinuxContainer) registerEvent(event string) {
 	c.eventsMutex.Lock()
 	defer c.eventsMutex.Unlock()
 
 	c.events = append(c.events, event)
 }
+
+func (c *LinuxContainer) emit(eventType event_hub.EventType, data map[string]string) {
+	c.eventEmitter.Emit(event_hub.Event{
+		Type:   eventType,
+		Handle: c.handle,
+		Data:   data,
+	})
+}
+
+func (c *LinuxContainer) notifyChanged() {
+	c.changeListenerMutex.RLock()
+	listener := c.changeListener
+	c.changeListenerMutex.RUnlock()
+
+	if listener != nil {
+		listener()
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -16,10 +17,13 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
 	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
@@ -31,6 +35,7 @@ import (
 )
 
 var _ = Describe("Linux containers", func() {
+	var eventHub *event_hub.Hub
 	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
 	var fakeBandwidthManager *fake_bandwidth_manager.FakeBandwidthManager
@@ -43,8 +48,12 @@ var _ = Describe("Linux containers", func() {
 	var containerDir string
 	var containerProps map[string]string
 	var mtu uint32
+	var timings linux_container.Timings
 
 	BeforeEach(func() {
+		eventHub = event_hub.New(lagertest.NewTestLogger("test"), 100)
+		timings = linux_container.Timings{}
+
 		fakeRunner = fake_command_runner.New()
 
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
@@ -102,6 +111,9 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			eventHub,
+			new(capacityFakes.FakeCommitter),
+			timings,
 		)
 	})
 
@@ -137,6 +149,19 @@ var _ = Describe("Linux containers", func() {
 			))
 		})
 
+		It("emits a start event", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			err := container.Start()
+			Expect(err).ToNot(HaveOccurred())
+
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Started))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
 		It("changes the container's state to active", func() {
 			Expect(container.State()).To(Equal(linux_container.StateBorn))
 
@@ -173,18 +198,294 @@ var _ = Describe("Linux containers", func() {
 				Expect(container.State()).To(Equal(linux_container.StateBorn))
 			})
 		})
+
+		Context("when the container was allocated cores of its own", func() {
+			BeforeEach(func() {
+				containerResources.CPUSet = &linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}
+			})
+
+			It("passes them to start.sh, for the hook which creates its cpuset cgroup", func() {
+				err := container.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/start.sh",
+						Env: []string{
+							"id=some-id",
+							"PATH=" + os.Getenv("PATH"),
+							"cpuset_cpus=2,3",
+							"cpuset_mems=1",
+						},
+					},
+				))
+			})
+
+			It("does not write the cpuset cgroup itself, as it does not exist yet", func() {
+				err := container.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when the container has cpu quota properties", func() {
+			BeforeEach(func() {
+				containerProps[linux_container.CPUQuotaProperty] = "25000"
+				containerProps[linux_container.CPUPeriodProperty] = "50000"
+			})
+
+			It("caps the container's CPU once start.sh has created its cgroups", func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/start.sh",
+					}, func(*exec.Cmd) error {
+						Expect(fakeCgroups.SetValues()).To(BeEmpty())
+						return nil
+					},
+				)
+
+				err := container.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+					{Subsystem: "cpu", Name: "cpu.cfs_period_us", Value: "50000"},
+					{Subsystem: "cpu", Name: "cpu.cfs_quota_us", Value: "25000"},
+				}))
+			})
+
+			Context("when capping fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeCgroups.WhenSetting("cpu", "cpu.cfs_quota_us", func() error {
+						return disaster
+					})
+				})
+
+				It("fails to start", func() {
+					err := container.Start()
+					Expect(err).To(Equal(disaster))
+
+					Expect(container.State()).To(Equal(linux_container.StateBorn))
+				})
+			})
+
+			Context("with a real cgroup layout, where start.sh creates the container's cgroups", func() {
+				var cgroupsPath string
+
+				BeforeEach(func() {
+					containerProps[linux_container.PidsMaxProperty] = "256"
+
+					var err error
+					cgroupsPath, err = ioutil.TempDir("", "cgroups")
+					Expect(err).ToNot(HaveOccurred())
+
+					for _, subsystem := range []string{"cpu", "pids"} {
+						Expect(os.MkdirAll(filepath.Join(cgroupsPath, subsystem), 0755)).To(Succeed())
+					}
+
+					fakeRunner.WhenRunning(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/start.sh",
+						}, func(*exec.Cmd) error {
+							for _, subsystem := range []string{"cpu", "pids"} {
+								err := os.Mkdir(filepath.Join(cgroupsPath, subsystem, "instance-some-id"), 0755)
+								if err != nil {
+									return err
+								}
+							}
+
+							return nil
+						},
+					)
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(cgroupsPath)
+				})
+
+				It("writes the limits into them", func() {
+					container := linux_container.NewLinuxContainer(
+						lagertest.NewTestLogger("test"),
+						"some-id",
+						"some-handle",
+						containerDir,
+						containerProps,
+						1*time.Second,
+						containerResources,
+						fakePortPool,
+						fakeRunner,
+						cgroups_manager.New(cgroupsPath, "some-id"),
+						fakeQuotaManager,
+						fakeBandwidthManager,
+						fakeProcessTracker,
+						process.Env{},
+						fakeFilter,
+						eventHub,
+						new(capacityFakes.FakeCommitter),
+						timings,
+					)
+
+					Expect(container.Start()).To(Succeed())
+
+					quota, err := ioutil.ReadFile(filepath.Join(cgroupsPath, "cpu", "instance-some-id", "cpu.cfs_quota_us"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(quota)).To(Equal("25000"))
+
+					pidsMax, err := ioutil.ReadFile(filepath.Join(cgroupsPath, "pids", "instance-some-id", "pids.max"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(pidsMax)).To(Equal("256"))
+				})
+			})
+
+			Context("and a pids max property", func() {
+				BeforeEach(func() {
+					containerProps[linux_container.PidsMaxProperty] = "256"
+				})
+
+				It("limits the container's pids too", func() {
+					err := container.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+						Subsystem: "pids", Name: "pids.max", Value: "256",
+					}))
+				})
+			})
+
+			Context("when a property is not a number", func() {
+				BeforeEach(func() {
+					containerProps[linux_container.CPUQuotaProperty] = "lots"
+				})
+
+				It("fails to start, without running start.sh", func() {
+					err := container.Start()
+					Expect(err).To(Equal(linux_container.InvalidLimitPropertyError{
+						Key:   linux_container.CPUQuotaProperty,
+						Value: "lots",
+					}))
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/start.sh",
+						},
+					))
+
+					Expect(container.State()).To(Equal(linux_container.StateBorn))
+				})
+			})
+
+			Context("when the oom policy is unknown", func() {
+				BeforeEach(func() {
+					containerProps[linux_container.OOMPolicyProperty] = "panic"
+				})
+
+				It("fails to start", func() {
+					err := container.Start()
+					Expect(err).To(Equal(linux_container.InvalidLimitPropertyError{
+						Key:   linux_container.OOMPolicyProperty,
+						Value: "panic",
+					}))
+
+					Expect(container.State()).To(Equal(linux_container.StateBorn))
+				})
+			})
+		})
 	})
 
 	Describe("Stopping", func() {
-		It("executes the container's stop.sh with the appropriate arguments", func() {
+		var (
+			procs        string
+			procsMutex   sync.Mutex
+			exitOnTerm   bool
+			survivesKill bool
+			procsErr     error
+		)
+
+		setProcs := func(value string) {
+			procsMutex.Lock()
+			defer procsMutex.Unlock()
+
+			procs = value
+		}
+
+		BeforeEach(func() {
+			// wshd, and two processes
+			procs = "12345\n200\n201\n"
+			exitOnTerm = true
+			survivesKill = false
+			procsErr = nil
+
+			timings.StopPollInterval = 10 * time.Millisecond
+			timings.StopKillTimeout = 100 * time.Millisecond
+			timings.StopGracePeriod = 100 * time.Millisecond
+		})
+
+		JustBeforeEach(func() {
+			err := procsErr
+
+			fakeCgroups.WhenGetting("cpu", "cgroup.procs", func() (string, error) {
+				procsMutex.Lock()
+				defer procsMutex.Unlock()
+
+				return procs, err
+			})
+
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "kill",
+			}, func(cmd *exec.Cmd) error {
+				if (cmd.Args[1] == "-15" && exitOnTerm) || (cmd.Args[1] == "-9" && !survivesKill) {
+					setProcs("12345\n")
+				}
+
+				return nil
+			})
+		})
+
+		stopPhases := func(subscription *event_hub.Subscription) []string {
+			phases := []string{}
+
+			for {
+				var event event_hub.Event
+				Expect(subscription.Events()).To(Receive(&event))
+
+				if event.Type != event_hub.StopPhase {
+					Expect(event.Type).To(Equal(event_hub.Stopped))
+					return phases
+				}
+
+				phases = append(phases, event.Data["phase"])
+			}
+		}
+
+		It("sends TERM to every process but wshd", func() {
 			err := container.Stop(false)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeRunner).To(HaveExecutedSerially(
 				fake_command_runner.CommandSpec{
-					Path: containerDir + "/stop.sh",
+					Path: "kill",
+					Args: []string{"-15", "200", "201"},
 				},
 			))
+
+			Expect(fakeRunner).ToNot(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "kill",
+					Args: []string{"-9", "200", "201"},
+				},
+			))
+		})
+
+		It("emits an event for each phase, and then a stop event", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			err := container.Stop(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stopPhases(subscription)).To(Equal([]string{"terminate", "empty"}))
 		})
 
 		It("sets the container's state to stopped", func() {
@@ -194,35 +495,211 @@ var _ = Describe("Linux containers", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(container.State()).To(Equal(linux_container.StateStopped))
+		})
+
+		Context("when the container is paused", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+				Expect(container.Pause()).To(Succeed())
+			})
+
+			It("thaws it before sending TERM", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{Subsystem: "freezer", Name: "freezer.state", Value: "THAWED"},
+				))
+
+				Expect(stopPhases(subscription)).To(Equal([]string{"thaw", "terminate", "empty"}))
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+			})
+
+			Context("and stopping fails", func() {
+				BeforeEach(func() {
+					survivesKill = true
+					exitOnTerm = false
+				})
+
+				It("leaves it active, as it has been thawed", func() {
+					Expect(container.Stop(true)).ToNot(Succeed())
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+			})
+		})
+
+		Context("when processes are still running after the grace period", func() {
+			BeforeEach(func() {
+				exitOnTerm = false
+			})
+
+			It("kills them", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "kill",
+						Args: []string{"-15", "200", "201"},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "kill",
+						Args: []string{"-9", "200", "201"},
+					},
+				))
+			})
+
+			It("waits for the grace period first", func() {
+				before := time.Now()
+
+				err := container.StopWithGracePeriod(300 * time.Millisecond)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(time.Since(before)).To(BeNumerically(">=", 300*time.Millisecond))
+			})
+
+			It("emits an event for each phase", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stopPhases(subscription)).To(Equal([]string{"terminate", "kill", "empty"}))
+			})
+
+			Context("and the container has a grace period of its own", func() {
+				BeforeEach(func() {
+					containerProps[linux_container.StopGracePeriodProperty] = "1"
+				})
+
+				It("waits for it instead", func() {
+					before := time.Now()
+
+					err := container.Stop(false)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(time.Since(before)).To(BeNumerically(">=", time.Second))
+				})
+			})
+
+			Context("and they survive being killed", func() {
+				BeforeEach(func() {
+					survivesKill = true
+				})
 
+				It("returns CgroupNotEmptyError", func() {
+					err := container.Stop(false)
+					Expect(err).To(Equal(linux_container.CgroupNotEmptyError{PIDs: []int{200, 201}}))
+				})
+
+				It("emits a not-empty phase event", func() {
+					subscription := eventHub.Subscribe()
+					defer subscription.Close()
+
+					container.Stop(false)
+
+					var event event_hub.Event
+					Expect(subscription.Events()).To(Receive(&event))
+					Expect(subscription.Events()).To(Receive(&event))
+					Expect(subscription.Events()).To(Receive(&event))
+					Expect(event.Type).To(Equal(event_hub.StopPhase))
+					Expect(event.Data).To(Equal(map[string]string{
+						"phase":     "not-empty",
+						"processes": "2",
+					}))
+				})
+
+				It("does not change the container's state", func() {
+					err := container.Stop(false)
+					Expect(err).To(HaveOccurred())
+
+					Expect(container.State()).To(Equal(linux_container.StateBorn))
+				})
+			})
 		})
 
 		Context("when kill is true", func() {
-			It("executes stop.sh with -w 0", func() {
+			It("kills every process but wshd straight away", func() {
 				err := container.Stop(true)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-						Args: []string{"-w", "0"},
+						Path: "kill",
+						Args: []string{"-9", "200", "201"},
 					},
 				))
 
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "kill",
+						Args: []string{"-15", "200", "201"},
+					},
+				))
+			})
+
+			It("emits a stop event saying so", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.Stop(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				var event event_hub.Event
+				for event.Type != event_hub.Stopped {
+					Expect(subscription.Events()).To(Receive(&event))
+				}
+
+				Expect(event.Handle).To(Equal("some-handle"))
+				Expect(event.Data).To(Equal(map[string]string{"kill": "true"}))
 			})
 		})
 
-		Context("when stop.sh fails", func() {
-			nastyError := errors.New("oh no!")
+		Context("when the container's grace period is 0", func() {
+			BeforeEach(func() {
+				containerProps[linux_container.StopGracePeriodProperty] = "0"
+			})
 
-			JustBeforeEach(func() {
-				fakeRunner.WhenRunning(
+			It("kills every process straight away", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.ExecutedCommands()).To(HaveLen(1))
+				Expect(fakeRunner).To(HaveExecutedSerially(
 					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-					}, func(*exec.Cmd) error {
-						return nastyError
+						Path: "kill",
+						Args: []string{"-9", "200", "201"},
 					},
-				)
+				))
+			})
+		})
+
+		Context("when nothing but wshd is running", func() {
+			BeforeEach(func() {
+				procs = "12345\n"
+			})
+
+			It("signals nothing", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+				Expect(stopPhases(subscription)).To(Equal([]string{"empty"}))
+			})
+		})
+
+		Context("when the cgroup cannot be read", func() {
+			nastyError := errors.New("oh no!")
+
+			BeforeEach(func() {
+				procsErr = nastyError
 			})
 
 			It("returns the error", func() {
@@ -259,6 +736,173 @@ var _ = Describe("Linux containers", func() {
 
 			})
 		})
+
+		Describe("setting the grace period property", func() {
+			It("rejects anything but a number of seconds", func() {
+				err := container.SetProperty(linux_container.StopGracePeriodProperty, "10s")
+				Expect(err).To(Equal(linux_container.InvalidLimitPropertyError{
+					Key:   linux_container.StopGracePeriodProperty,
+					Value: "10s",
+				}))
+
+				Expect(container.Properties()).ToNot(HaveKey(linux_container.StopGracePeriodProperty))
+			})
+		})
+	})
+
+	Describe("State transitions", func() {
+		It("is starting while start.sh runs", func() {
+			var stateDuringStart linux_container.State
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/start.sh",
+				}, func(*exec.Cmd) error {
+					stateDuringStart = container.State()
+					return nil
+				},
+			)
+
+			Expect(container.Start()).To(Succeed())
+			Expect(stateDuringStart).To(Equal(linux_container.StateStarting))
+		})
+
+		It("is stopping while its processes are stopped", func() {
+			var stateDuringStop linux_container.State
+			fakeCgroups.WhenGetting("cpu", "cgroup.procs", func() (string, error) {
+				stateDuringStop = container.State()
+				return "", nil
+			})
+
+			Expect(container.Stop(false)).To(Succeed())
+			Expect(stateDuringStop).To(Equal(linux_container.StateStopping))
+		})
+
+		It("records when each state was entered", func() {
+			before := time.Now()
+
+			Expect(container.Start()).To(Succeed())
+			Expect(container.Stop(false)).To(Succeed())
+
+			times := container.StateTimes()
+			Expect(times).To(HaveKey(linux_container.StateBorn))
+
+			for _, state := range []linux_container.State{
+				linux_container.StateStarting,
+				linux_container.StateActive,
+				linux_container.StateStopping,
+				linux_container.StateStopped,
+			} {
+				Expect(times).To(HaveKey(state))
+				Expect(times[state]).ToNot(BeTemporally("<", before))
+			}
+
+			Expect(times[linux_container.StateStopped]).ToNot(BeTemporally("<", times[linux_container.StateActive]))
+		})
+
+		Context("when the container is already active", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+			})
+
+			It("cannot be started again", func() {
+				err := container.Start()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateActive,
+					To:     linux_container.StateStarting,
+				}))
+
+				Expect(err).To(MatchError("container some-handle cannot become starting while active"))
+			})
+		})
+
+		Context("when the container is stopped", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+				Expect(container.Stop(false)).To(Succeed())
+			})
+
+			It("rejects running processes", func() {
+				_, err := container.Run(garden.ProcessSpec{User: "vcap", Path: "ls"}, garden.ProcessIO{})
+				Expect(err).To(Equal(linux_container.InvalidStateError{
+					Handle:    "some-handle",
+					Operation: "run a process",
+					State:     linux_container.StateStopped,
+				}))
+
+				Expect(err).To(MatchError("cannot run a process in container some-handle while it is stopped"))
+			})
+
+			It("rejects streaming in", func() {
+				err := container.StreamIn("/some/path", nil)
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+			})
+
+			It("rejects streaming out", func() {
+				_, err := container.StreamOut("/some/path")
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+			})
+
+			It("rejects mapping ports", func() {
+				_, _, err := container.NetIn(1, 2)
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+			})
+
+			It("rejects allowing outbound traffic", func() {
+				err := container.NetOut(garden.NetOutRule{})
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+			})
+
+			It("can still be stopped again", func() {
+				Expect(container.Stop(true)).To(Succeed())
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+			})
+		})
+
+		Describe("destroying", func() {
+			It("is destroying until finished, and then destroyed", func() {
+				destroyed, err := container.BeginDestroy()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(container.State()).To(Equal(linux_container.StateDestroying))
+
+				destroyed(nil)
+				Expect(container.State()).To(Equal(linux_container.StateDestroyed))
+			})
+
+			It("cannot begin while already destroying", func() {
+				_, err := container.BeginDestroy()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = container.BeginDestroy()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateDestroying,
+					To:     linux_container.StateDestroying,
+				}))
+			})
+
+			Context("when destroying fails", func() {
+				It("returns to the previous state", func() {
+					Expect(container.Start()).To(Succeed())
+
+					destroyed, err := container.BeginDestroy()
+					Expect(err).ToNot(HaveOccurred())
+
+					destroyed(errors.New("oh no!"))
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+			})
+
+			Context("once destroyed", func() {
+				It("cannot be started", func() {
+					destroyed, err := container.BeginDestroy()
+					Expect(err).ToNot(HaveOccurred())
+					destroyed(nil)
+
+					Expect(container.Start()).To(BeAssignableToTypeOf(linux_container.InvalidStateTransitionError{}))
+				})
+			})
+		})
 	})
 
 	Describe("Cleaning up", func() {
@@ -283,6 +927,10 @@ var _ = Describe("Linux containers", func() {
 	})
 
 	Describe("Streaming data in", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+		})
+
 		It("streams the input to tar xf in the container", func() {
 			fakeRunner.WhenRunning(
 				fake_command_runner.CommandSpec{
@@ -329,6 +977,10 @@ var _ = Describe("Linux containers", func() {
 	})
 
 	Describe("Streaming out", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+		})
+
 		It("streams the output of tar cf to the destination", func() {
 			fakeRunner.WhenRunning(
 				fake_command_runner.CommandSpec{
@@ -586,6 +1238,21 @@ var _ = Describe("Linux containers", func() {
 				})).To(BeTrue())
 			})
 
+			It("can test for properties with a selector", func() {
+				Expect(container.HasProperties(garden.Properties{
+					"property-name^=": "property-",
+					"other-property?": "false",
+				})).To(BeTrue())
+
+				Expect(container.HasProperties(garden.Properties{
+					"property-name!=": "property-value",
+				})).To(BeFalse())
+
+				Expect(container.HasProperties(garden.Properties{
+					"property-name?": "maybe",
+				})).To(BeFalse())
+			})
+
 			It("returns an error when the property is undefined", func() {
 				_, err := container.Property("some-other-property")
 				Expect(err).To(Equal(linux_container.UndefinedPropertyError{"some-other-property"}))
@@ -661,6 +1328,50 @@ var _ = Describe("Linux containers", func() {
 			Expect(properties["some-property"]).To(Equal("some-value"))
 		})
 
+		It("is not changed by removing a property", func() {
+			properties, err := container.Properties()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.RemoveProperty("property-name")).To(Succeed())
+
+			Expect(properties).To(Equal(garden.Properties{"property-name": "property-value"}))
+		})
+
+		It("can be read while properties are being set and removed", func() {
+			done := make(chan struct{})
+
+			wg := new(sync.WaitGroup)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for i := 0; i < 100; i++ {
+					container.SetProperty("some-property", "some-value")
+					container.RemoveProperty("some-property")
+				}
+
+				close(done)
+			}()
+
+			for {
+				select {
+				case <-done:
+					wg.Wait()
+					return
+				default:
+				}
+
+				properties, err := container.Properties()
+				Expect(err).ToNot(HaveOccurred())
+
+				for key, value := range properties {
+					_ = key + value
+				}
+
+				container.HasProperties(garden.Properties{"property-name": "property-value"})
+			}
+		})
+
 		Context("with a nil map of properties at container creation", func() {
 			BeforeEach(func() {
 				containerProps = nil
@@ -682,6 +1393,66 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Change notifications", func() {
+		var changes int
+
+		JustBeforeEach(func() {
+			changes = 0
+			container.OnChange(func() {
+				changes++
+			})
+		})
+
+		It("notifies when a property is set or removed", func() {
+			Expect(container.SetProperty("some-property", "some-value")).To(Succeed())
+			Expect(changes).To(Equal(1))
+
+			Expect(container.RemoveProperty("some-property")).To(Succeed())
+			Expect(changes).To(Equal(2))
+		})
+
+		It("notifies when a port is mapped", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(changes).To(Equal(1))
+		})
+
+		It("notifies when a net out rule is added", func() {
+			Expect(container.NetOut(garden.NetOutRule{})).To(Succeed())
+
+			Expect(changes).To(Equal(1))
+		})
+
+		It("notifies when a limit is changed", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 1})).To(Succeed())
+
+			Expect(changes).To(Equal(1))
+		})
+
+		Context("when the change fails", func() {
+			BeforeEach(func() {
+				fakeFilter.NetOutReturns(errors.New("oh no!"))
+			})
+
+			It("does not notify", func() {
+				Expect(container.NetOut(garden.NetOutRule{})).ToNot(Succeed())
+				Expect(container.RemoveProperty("bogus-property")).ToNot(Succeed())
+
+				Expect(changes).To(Equal(0))
+			})
+		})
+
+		Context("when the listener is removed", func() {
+			It("stops notifying", func() {
+				container.OnChange(nil)
+
+				Expect(container.SetProperty("some-property", "some-value")).To(Succeed())
+				Expect(changes).To(Equal(0))
+			})
+		})
+	})
+
 	Describe("Info", func() {
 		It("returns the container's state", func() {
 			info, err := container.Info()
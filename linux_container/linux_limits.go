@@ -0,0 +1,227 @@
+package linux_container
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+// Properties which set the container's Linux limits when it starts, for
+// clients which can only pass a garden.ContainerSpec.
+const (
+	CPUQuotaProperty  = "garden.linux.cpu-quota-us"
+	CPUPeriodProperty = "garden.linux.cpu-period-us"
+	PidsMaxProperty   = "garden.linux.pids-max"
+)
+
+// Properties which pin the container to cores of its own when it is created.
+const (
+	CPUSetCoresProperty = "garden.linux.cpuset-cores"
+	CPUSetNodeProperty  = "garden.linux.cpuset-node"
+)
+
+// DefaultCPUPeriod is the CFS period used when a quota is given without one,
+// and is the kernel's own default.
+const DefaultCPUPeriod = 100000
+
+type InvalidLimitPropertyError struct {
+	Key   string
+	Value string
+}
+
+func (err InvalidLimitPropertyError) Error() string {
+	return fmt.Sprintf("invalid value for limit property %s: %q", err.Key, err.Value)
+}
+
+func (c *LinuxContainer) LimitLinux(limits linux_backend.LinuxLimits) error {
+	if limits.CPUQuota != nil {
+		err := c.LimitCPUQuota(*limits.CPUQuota)
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.Pids != nil {
+		err := c.LimitPids(*limits.Pids)
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.BlockIO != nil {
+		err := c.LimitBlockIO(*limits.BlockIO)
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.MemoryPressure != nil {
+		err := c.LimitMemoryPressure(*limits.MemoryPressure)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentLinuxLimits() (linux_backend.LinuxLimits, error) {
+	cpuQuota, err := c.CurrentCPUQuotaLimits()
+	if err != nil {
+		return linux_backend.LinuxLimits{}, err
+	}
+
+	pids, err := c.CurrentPidsLimits()
+	if err != nil {
+		return linux_backend.LinuxLimits{}, err
+	}
+
+	blockIO, err := c.CurrentBlockIOLimits()
+	if err != nil {
+		return linux_backend.LinuxLimits{}, err
+	}
+
+	memoryPressure, err := c.CurrentMemoryPressureLimits()
+	if err != nil {
+		return linux_backend.LinuxLimits{}, err
+	}
+
+	return linux_backend.LinuxLimits{
+		CPUQuota: &cpuQuota,
+		Pids:     &pids,
+		BlockIO:  &blockIO,
+
+		MemoryPressure: &memoryPressure,
+	}, nil
+}
+
+func (c *LinuxContainer) LimitCPUQuota(limits linux_backend.CPUQuotaLimits) error {
+	if limits.PeriodInMicroseconds == 0 {
+		limits.PeriodInMicroseconds = DefaultCPUPeriod
+	}
+
+	err := c.cgroupsManager.Set("cpu", "cpu.cfs_period_us", strconv.FormatUint(limits.PeriodInMicroseconds, 10))
+	if err != nil {
+		return err
+	}
+
+	// a quota of -1 lifts the cap
+	quota := "-1"
+	if limits.QuotaInMicroseconds > 0 {
+		quota = strconv.FormatUint(limits.QuotaInMicroseconds, 10)
+	}
+
+	err = c.cgroupsManager.Set("cpu", "cpu.cfs_quota_us", quota)
+	if err != nil {
+		return err
+	}
+
+	c.cpuMutex.Lock()
+	c.currentCPUQuotaLimits = &limits
+	c.cpuMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "cpu-quota",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentCPUQuotaLimits() (linux_backend.CPUQuotaLimits, error) {
+	period, err := c.cgroupsManager.Get("cpu", "cpu.cfs_period_us")
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	numericPeriod, err := strconv.ParseUint(period, 10, 0)
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	quota, err := c.cgroupsManager.Get("cpu", "cpu.cfs_quota_us")
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	numericQuota, err := strconv.ParseInt(quota, 10, 0)
+	if err != nil {
+		return linux_backend.CPUQuotaLimits{}, err
+	}
+
+	limits := linux_backend.CPUQuotaLimits{
+		PeriodInMicroseconds: numericPeriod,
+	}
+
+	if numericQuota > 0 {
+		limits.QuotaInMicroseconds = uint64(numericQuota)
+	}
+
+	return limits, nil
+}
+
+// propertyLimits returns the limits set by the container's properties.
+func (c *LinuxContainer) propertyLimits() (linux_backend.LinuxLimits, error) {
+	properties, _ := c.Properties()
+
+	limits := linux_backend.LinuxLimits{}
+
+	quota, found, err := uintProperty(properties, CPUQuotaProperty)
+	if err != nil {
+		return limits, err
+	}
+
+	if found {
+		period, _, err := uintProperty(properties, CPUPeriodProperty)
+		if err != nil {
+			return limits, err
+		}
+
+		limits.CPUQuota = &linux_backend.CPUQuotaLimits{
+			QuotaInMicroseconds:  quota,
+			PeriodInMicroseconds: period,
+		}
+	}
+
+	pidsMax, found, err := uintProperty(properties, PidsMaxProperty)
+	if err != nil {
+		return limits, err
+	}
+
+	if found {
+		limits.Pids = &linux_backend.PidsLimits{
+			Max: pidsMax,
+		}
+	}
+
+	return limits, nil
+}
+
+func uintProperty(properties map[string]string, key string) (uint64, bool, error) {
+	value, found := properties[key]
+	if !found {
+		return 0, false, nil
+	}
+
+	numeric, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, InvalidLimitPropertyError{Key: key, Value: value}
+	}
+
+	return numeric, true, nil
+}
+
+// pinCPUSet confines the container to the cores and memory nodes it was
+// allocated. A starting container is pinned by the hook which creates its
+// cgroup, so this only reapplies the pin, e.g. on restore.
+func (c *LinuxContainer) pinCPUSet() error {
+	err := c.cgroupsManager.Set("cpuset", "cpuset.mems", c.resources.CPUSet.MemList())
+	if err != nil {
+		return err
+	}
+
+	return c.cgroupsManager.Set("cpuset", "cpuset.cpus", c.resources.CPUSet.CPUList())
+}
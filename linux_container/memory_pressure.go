@@ -0,0 +1,332 @@
+package linux_container
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// memorySoftUnlimited is the largest soft limit the kernel reports; it rounds
+// "-1" down to a whole number of pages, so anything this close to the maximum
+// means there is no soft limit.
+const memorySoftUnlimited = math.MaxInt64 - 1<<16
+
+type UnknownMemoryPressureLevelError struct {
+	Level linux_backend.MemoryPressureLevel
+}
+
+func (err UnknownMemoryPressureLevelError) Error() string {
+	return fmt.Sprintf("unknown memory pressure level: %q", err.Level)
+}
+
+// LimitMemoryPressure sets the container's memory soft limit, and watches for
+// the pressure levels and usage thresholds it is to be warned of, replacing
+// any it was watching for before.
+func (c *LinuxContainer) LimitMemoryPressure(limits linux_backend.MemoryPressureLimits) error {
+	for _, level := range limits.PressureLevels {
+		switch level {
+		case linux_backend.MemoryPressureLow, linux_backend.MemoryPressureMedium, linux_backend.MemoryPressureCritical:
+		default:
+			return UnknownMemoryPressureLevelError{Level: level}
+		}
+	}
+
+	softLimit := "-1"
+	if limits.SoftLimitInBytes > 0 {
+		softLimit = strconv.FormatUint(limits.SoftLimitInBytes, 10)
+	}
+
+	err := c.cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", softLimit)
+	if err != nil {
+		return err
+	}
+
+	c.stopMemoryEvents()
+
+	if len(limits.PressureLevels) > 0 || len(limits.ThresholdsInBytes) > 0 {
+		err := c.startMemoryEvents(limits)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.memoryMutex.Lock()
+	c.currentMemoryPressureLimits = &limits
+	c.memoryMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "memory-pressure",
+	})
+
+	return nil
+}
+
+// CurrentMemoryPressureLimits reads back the soft limit in effect. The kernel
+// does not say what is being watched for, so the pressure levels and
+// thresholds are those last set.
+func (c *LinuxContainer) CurrentMemoryPressureLimits() (linux_backend.MemoryPressureLimits, error) {
+	softLimit, err := c.cgroupsManager.Get("memory", "memory.soft_limit_in_bytes")
+	if err != nil {
+		return linux_backend.MemoryPressureLimits{}, err
+	}
+
+	numericSoftLimit, err := strconv.ParseInt(strings.TrimSpace(softLimit), 10, 64)
+	if err != nil {
+		return linux_backend.MemoryPressureLimits{}, err
+	}
+
+	limits := linux_backend.MemoryPressureLimits{}
+
+	if numericSoftLimit > 0 && numericSoftLimit < memorySoftUnlimited {
+		limits.SoftLimitInBytes = uint64(numericSoftLimit)
+	}
+
+	c.memoryMutex.RLock()
+	if c.currentMemoryPressureLimits != nil {
+		limits.PressureLevels = c.currentMemoryPressureLimits.PressureLevels
+		limits.ThresholdsInBytes = c.currentMemoryPressureLimits.ThresholdsInBytes
+	}
+	c.memoryMutex.RUnlock()
+
+	return limits, nil
+}
+
+func (c *LinuxContainer) startMemoryEvents(limits linux_backend.MemoryPressureLimits) error {
+	c.memoryEventsMutex.Lock()
+	defer c.memoryEventsMutex.Unlock()
+
+	helper, err := c.runMemoryEvents(limits)
+	if err != nil {
+		return err
+	}
+
+	c.memoryEvents = helper
+	c.memoryEventsStop = make(chan struct{})
+
+	go c.superviseMemoryEvents(limits, helper, c.memoryEventsStop, c.timings.memoryEventsRestartInterval())
+
+	return nil
+}
+
+func (c *LinuxContainer) stopMemoryEvents() {
+	c.memoryEventsMutex.Lock()
+	defer c.memoryEventsMutex.Unlock()
+
+	if c.memoryEventsStop == nil {
+		return
+	}
+
+	close(c.memoryEventsStop)
+	c.memoryEventsStop = nil
+
+	c.runner.Kill(c.memoryEvents.cmd)
+}
+
+// memoryEventsHelper is a running bin/memevents, which prints a line for each
+// notification until it exits.
+type memoryEventsHelper struct {
+	cmd    *exec.Cmd
+	output *io.PipeWriter
+	done   <-chan struct{}
+}
+
+func (c *LinuxContainer) runMemoryEvents(limits linux_backend.MemoryPressureLimits) (*memoryEventsHelper, error) {
+	args := []string{c.cgroupsManager.SubsystemPath("memory")}
+
+	for _, level := range limits.PressureLevels {
+		args = append(args, "pressure:"+string(level))
+	}
+
+	for _, threshold := range limits.ThresholdsInBytes {
+		args = append(args, "threshold:"+strconv.FormatUint(threshold, 10))
+	}
+
+	cmd := exec.Command(path.Join(c.path, "bin", "memevents"), args...)
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			c.handleMemoryEvent(scanner.Text())
+		}
+
+		// drain anything left so the helper is never blocked writing
+		io.Copy(ioutil.Discard, reader)
+	}()
+
+	err := c.runner.Start(cmd)
+	if err != nil {
+		writer.Close()
+		<-done
+		return nil, err
+	}
+
+	return &memoryEventsHelper{
+		cmd:    cmd,
+		output: writer,
+		done:   done,
+	}, nil
+}
+
+// restartMemoryEvents starts a new helper in place of one which has exited,
+// unless watching has been stopped, in which case it returns nil.
+func (c *LinuxContainer) restartMemoryEvents(limits linux_backend.MemoryPressureLimits, stop <-chan struct{}) (*memoryEventsHelper, error) {
+	c.memoryEventsMutex.Lock()
+	defer c.memoryEventsMutex.Unlock()
+
+	select {
+	case <-stop:
+		return nil, nil
+	default:
+	}
+
+	helper, err := c.runMemoryEvents(limits)
+	if err != nil {
+		return nil, err
+	}
+
+	c.memoryEvents = helper
+
+	return helper, nil
+}
+
+// superviseMemoryEvents starts the helper again whenever it exits, until
+// watching is stopped, so that the container is never left unwatched.
+func (c *LinuxContainer) superviseMemoryEvents(limits linux_backend.MemoryPressureLimits, helper *memoryEventsHelper, stop <-chan struct{}, restartInterval time.Duration) {
+	sLog := c.logger.Session("memory-events")
+
+	for {
+		err := c.runner.Wait(helper.cmd)
+
+		helper.output.Close()
+		<-helper.done
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err != nil {
+			sLog.Error("died", err)
+		} else {
+			sLog.Info("exited")
+		}
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(restartInterval):
+			}
+
+			helper, err = c.restartMemoryEvents(limits, stop)
+			if err == nil {
+				break
+			}
+
+			sLog.Error("failed-to-restart", err)
+		}
+
+		if helper == nil {
+			return
+		}
+
+		sLog.Info("restarted")
+	}
+}
+
+// handleMemoryEvent emits an event for a line printed by the helper, which
+// is either "pressure <level>" or "threshold <bytes>".
+func (c *LinuxContainer) handleMemoryEvent(line string) {
+	hLog := c.logger.Session("memory-event")
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		hLog.Info("ignoring-malformed-line", lager.Data{"line": line})
+		return
+	}
+
+	data := map[string]string{}
+
+	memoryStat, err := c.cgroupsManager.Get("memory", "memory.stat")
+	if err != nil {
+		hLog.Error("failed-to-get-memory-stat", err)
+	} else {
+		data = memoryStatData(parseMemoryStat(memoryStat))
+	}
+
+	switch kind, argument := fields[0], fields[1]; kind {
+	case "pressure":
+		data["level"] = argument
+
+		hLog.Info("memory-pressure", lager.Data{"level": argument})
+
+		c.emit(event_hub.MemoryPressure, data)
+
+	case "threshold":
+		data["threshold"] = argument
+
+		// the kernel notifies of crossings in either direction
+		usage, err := c.cgroupsManager.Get("memory", "memory.usage_in_bytes")
+		if err != nil {
+			hLog.Error("failed-to-get-memory-usage", err)
+		} else {
+			usage = strings.TrimSpace(usage)
+			data["usage_in_bytes"] = usage
+
+			numericUsage, _ := strconv.ParseUint(usage, 10, 64)
+			numericThreshold, _ := strconv.ParseUint(argument, 10, 64)
+
+			if numericUsage >= numericThreshold {
+				data["direction"] = "above"
+			} else {
+				data["direction"] = "below"
+			}
+		}
+
+		hLog.Info("memory-threshold-crossed", lager.Data{
+			"threshold": argument,
+			"direction": data["direction"],
+		})
+
+		c.emit(event_hub.MemoryThreshold, data)
+
+	default:
+		hLog.Info("ignoring-unknown-event", lager.Data{"line": line})
+	}
+}
+
+// memoryStatData is the memory stats included in memory pressure and
+// threshold events, named as in memory.stat.
+func memoryStatData(stat garden.ContainerMemoryStat) map[string]string {
+	return map[string]string{
+		"total_usage_toward_limit": strconv.FormatUint(stat.TotalUsageTowardLimit, 10),
+		"total_rss":                strconv.FormatUint(stat.TotalRss, 10),
+		"total_cache":              strconv.FormatUint(stat.TotalCache, 10),
+		"total_swap":               strconv.FormatUint(stat.TotalSwap, 10),
+		"total_mapped_file":        strconv.FormatUint(stat.TotalMappedFile, 10),
+		"total_inactive_file":      strconv.FormatUint(stat.TotalInactiveFile, 10),
+		"total_pgmajfault":         strconv.FormatUint(stat.TotalPgmajfault, 10),
+	}
+}
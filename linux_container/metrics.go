@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 )
 
 func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
@@ -38,6 +39,41 @@ func (c *LinuxContainer) Metrics() (garden.Metrics, error) {
 	}, nil
 }
 
+func (c *LinuxContainer) LinuxMetrics() (linux_backend.LinuxMetrics, error) {
+	metrics, err := c.Metrics()
+	if err != nil {
+		return linux_backend.LinuxMetrics{}, err
+	}
+
+	throttlingStat, err := c.cgroupsManager.Get("cpu", "cpu.stat")
+	if err != nil {
+		return linux_backend.LinuxMetrics{}, err
+	}
+
+	pidsStat, err := c.pidsStat()
+	if err != nil {
+		return linux_backend.LinuxMetrics{}, err
+	}
+
+	blockIOStat, err := c.blockIOStat()
+	if err != nil {
+		return linux_backend.LinuxMetrics{}, err
+	}
+
+	networkStat, err := c.networkStat()
+	if err != nil {
+		return linux_backend.LinuxMetrics{}, err
+	}
+
+	return linux_backend.LinuxMetrics{
+		Metrics:           metrics,
+		CPUThrottlingStat: parseCPUThrottlingStat(throttlingStat),
+		PidsStat:          pidsStat,
+		BlockIOStat:       blockIOStat,
+		NetworkStat:       networkStat,
+	}, nil
+}
+
 func parseMemoryStat(contents string) (stat garden.ContainerMemoryStat) {
 	scanner := bufio.NewScanner(strings.NewReader(contents))
 
@@ -154,3 +190,33 @@ func parseCPUStat(usage, statContents string) (stat garden.ContainerCPUStat) {
 
 	return
 }
+
+func parseCPUThrottlingStat(contents string) (stat linux_backend.CPUThrottlingStat) {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	scanner.Split(bufio.ScanWords)
+
+	for scanner.Scan() {
+		field := scanner.Text()
+
+		if !scanner.Scan() {
+			break
+		}
+
+		value, err := strconv.ParseUint(scanner.Text(), 10, 0)
+		if err != nil {
+			continue
+		}
+
+		switch field {
+		case "nr_periods":
+			stat.Periods = value
+		case "nr_throttled":
+			stat.ThrottledPeriods = value
+		case "throttled_time":
+			stat.ThrottledTime = value
+		}
+	}
+
+	return
+}
@@ -3,6 +3,8 @@ package linux_container_test
 import (
 	"errors"
 	"net"
+	"os"
+	"os/exec"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -10,6 +12,8 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
@@ -20,11 +24,13 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/process"
 	"github.com/cloudfoundry-incubator/garden-linux/process_tracker/fake_process_tracker"
 	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
 )
 
 var _ = Describe("Linux containers", func() {
 	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
+	var fakeRunner *fake_command_runner.FakeCommandRunner
 	var container *linux_container.LinuxContainer
 	var containerDir string
 
@@ -32,6 +38,10 @@ var _ = Describe("Linux containers", func() {
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
 
 		fakeQuotaManager = fake_quota_manager.New()
+
+		fakeRunner = fake_command_runner.New()
+
+		containerDir = "/depot/some-id"
 	})
 
 	JustBeforeEach(func() {
@@ -56,17 +66,25 @@ var _ = Describe("Linux containers", func() {
 			1*time.Second,
 			containerResources,
 			fake_port_pool.New(1000),
-			fake_command_runner.New(),
+			fakeRunner,
 			fakeCgroups,
 			fakeQuotaManager,
 			fake_bandwidth_manager.New(),
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			event_hub.New(lagertest.NewTestLogger("test"), 100),
+			new(capacityFakes.FakeCommitter),
+			linux_container.Timings{},
 		)
 	})
 
 	Describe("Metrics", func() {
+		BeforeEach(func() {
+			// the kernel never leaves pids.current empty
+			fakeCgroups.Set("pids", "pids.current", "0")
+		})
+
 		Describe("memory info", func() {
 			BeforeEach(func() {
 				fakeCgroups.WhenGetting("memory", "memory.stat", func() (string, error) {
@@ -211,6 +229,270 @@ system 2
 			})
 		})
 
+		Describe("cpu throttling info", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("cpu", "cpu.stat", func() (string, error) {
+					return `nr_periods 10
+nr_throttled 3
+throttled_time 4500
+`, nil
+				})
+			})
+
+			It("is returned in the linux metrics", func() {
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.CPUThrottlingStat).To(Equal(linux_backend.CPUThrottlingStat{
+					Periods:          10,
+					ThrottledPeriods: 3,
+					ThrottledTime:    4500,
+				}))
+			})
+		})
+
+		Context("when getting cpu/cpu.stat fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("cpu", "cpu.stat", func() (string, error) {
+					return "", disaster
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Describe("pids info", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "12\n", nil
+				})
+
+				fakeCgroups.WhenGetting("pids", "pids.events", func() (string, error) {
+					return "max 7\n", nil
+				})
+			})
+
+			It("is returned in the linux metrics", func() {
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.PidsStat).To(Equal(linux_backend.PidsStat{
+					Current:   12,
+					LimitHits: 7,
+				}))
+			})
+		})
+
+		Context("when getting pids/pids.current fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "", disaster
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when pids/pids.current is malformed", func() {
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "lots\n", nil
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when getting pids/pids.events fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "12\n", nil
+				})
+
+				fakeCgroups.WhenGetting("pids", "pids.events", func() (string, error) {
+					return "", disaster
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when the container has no pids cgroup", func() {
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("pids", "pids.current", func() (string, error) {
+					return "", &os.PathError{Op: "open", Path: "pids.current", Err: os.ErrNotExist}
+				})
+			})
+
+			It("returns empty pids info", func() {
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.PidsStat).To(Equal(linux_backend.PidsStat{}))
+			})
+		})
+
+		Describe("block I/O info", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_service_bytes", func() (string, error) {
+					return `8:0 Read 100
+8:0 Write 200
+8:0 Sync 250
+8:0 Async 50
+8:0 Total 300
+8:16 Read 10
+8:16 Write 20
+8:16 Sync 25
+8:16 Async 5
+8:16 Total 30
+Total 330
+`, nil
+				})
+
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_serviced", func() (string, error) {
+					return `8:0 Read 3
+8:0 Write 4
+8:0 Total 7
+Total 7
+`, nil
+				})
+			})
+
+			It("is returned in the linux metrics, summed across devices", func() {
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.BlockIOStat).To(Equal(linux_backend.BlockIOStat{
+					ReadBytes:  110,
+					WriteBytes: 220,
+					ReadOps:    3,
+					WriteOps:   4,
+				}))
+			})
+		})
+
+		Context("when getting blkio/blkio.throttle.io_service_bytes fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenGetting("blkio", "blkio.throttle.io_service_bytes", func() (string, error) {
+					return "", disaster
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Describe("network info", func() {
+			var hostStats string
+
+			BeforeEach(func() {
+				hostStats = `rx_bytes 100
+rx_packets 10
+rx_dropped 1
+tx_bytes 2000
+tx_packets 20
+tx_dropped 2
+`
+			})
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"get_stats_info"},
+					}, func(cmd *exec.Cmd) error {
+						cmd.Stdout.Write([]byte(hostStats))
+						return nil
+					},
+				)
+			})
+
+			It("is returned in the linux metrics, from the container's point of view", func() {
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(metrics.NetworkStat).To(Equal(linux_backend.NetworkStat{
+					RxBytes:   2000,
+					RxPackets: 20,
+					RxDropped: 2,
+					TxBytes:   100,
+					TxPackets: 10,
+					TxDropped: 1,
+				}))
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"get_stats_info"},
+						Env:  []string{"ID=some-id", "PATH=" + os.Getenv("PATH")},
+					},
+				))
+			})
+
+			Context("when the host interface's counters start again from 0", func() {
+				It("carries on counting from where they were", func() {
+					_, err := container.LinuxMetrics()
+					Expect(err).ToNot(HaveOccurred())
+
+					hostStats = `rx_bytes 5
+rx_packets 1
+rx_dropped 0
+tx_bytes 3000
+tx_packets 30
+tx_dropped 2
+`
+
+					metrics, err := container.LinuxMetrics()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(metrics.NetworkStat).To(Equal(linux_backend.NetworkStat{
+						RxBytes:   3000,
+						RxPackets: 30,
+						RxDropped: 2,
+						TxBytes:   105,
+						TxPackets: 11,
+						TxDropped: 1,
+					}))
+				})
+			})
+		})
+
+		Context("when reading the network counters fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"get_stats_info"},
+					}, func(*exec.Cmd) error {
+						return disaster
+					},
+				)
+			})
+
+			It("returns an error", func() {
+				_, err := container.LinuxMetrics()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
 		Describe("disk usage info", func() {
 			It("is returned in the response", func() {
 				fakeQuotaManager.GetUsageResult = garden.ContainerDiskStat{
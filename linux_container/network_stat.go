@@ -0,0 +1,138 @@
+package linux_container
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+// networkCounters turns the counters of the container's host interface,
+// which start again from 0 whenever the interface is recreated, into
+// counters which only ever go up.
+type networkCounters struct {
+	// what had been counted before the interface's counters last went down
+	base linux_backend.NetworkStat
+
+	// what was last read from the interface
+	last linux_backend.NetworkStat
+}
+
+// observe records a reading of the interface's counters and returns the
+// container's totals.
+func (n *networkCounters) observe(raw linux_backend.NetworkStat) linux_backend.NetworkStat {
+	bases := networkStatFields(&n.base)
+	lasts := networkStatFields(&n.last)
+	raws := networkStatFields(&raw)
+
+	totals := linux_backend.NetworkStat{}
+
+	for i, total := range networkStatFields(&totals) {
+		if *raws[i] < *lasts[i] {
+			*bases[i] += *lasts[i]
+		}
+
+		*lasts[i] = *raws[i]
+		*total = *bases[i] + *raws[i]
+	}
+
+	return totals
+}
+
+func networkStatFields(stat *linux_backend.NetworkStat) []*uint64 {
+	return []*uint64{
+		&stat.RxBytes,
+		&stat.RxPackets,
+		&stat.RxDropped,
+		&stat.TxBytes,
+		&stat.TxPackets,
+		&stat.TxDropped,
+	}
+}
+
+func (c *LinuxContainer) networkStat() (linux_backend.NetworkStat, error) {
+	// readings are serialized so that a slow one is never observed after a
+	// later one, which would look like the counters going down
+	c.networkCountersMutex.Lock()
+	defer c.networkCountersMutex.Unlock()
+
+	out := new(bytes.Buffer)
+
+	stats := exec.Command(path.Join(c.path, "net.sh"), "get_stats_info")
+	stats.Env = []string{
+		"ID=" + c.id,
+		"PATH=" + os.Getenv("PATH"),
+	}
+	stats.Stdout = out
+
+	err := c.runner.Run(stats)
+	if err != nil {
+		return linux_backend.NetworkStat{}, err
+	}
+
+	return c.networkCounters.observe(parseNetworkStat(out.String())), nil
+}
+
+func (c *LinuxContainer) networkCountersSnapshot() *NetworkCountersSnapshot {
+	c.networkCountersMutex.Lock()
+	defer c.networkCountersMutex.Unlock()
+
+	return &NetworkCountersSnapshot{
+		Base: c.networkCounters.base,
+		Last: c.networkCounters.last,
+	}
+}
+
+func (c *LinuxContainer) restoreNetworkCounters(snapshot NetworkCountersSnapshot) {
+	c.networkCountersMutex.Lock()
+	defer c.networkCountersMutex.Unlock()
+
+	c.networkCounters = networkCounters{
+		base: snapshot.Base,
+		last: snapshot.Last,
+	}
+}
+
+// parseNetworkStat reads the statistics of the container's host interface,
+// as printed by net.sh. What the host side receives is what the container
+// sent, and vice versa.
+func parseNetworkStat(contents string) (stat linux_backend.NetworkStat) {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	scanner.Split(bufio.ScanWords)
+
+	for scanner.Scan() {
+		field := scanner.Text()
+
+		if !scanner.Scan() {
+			break
+		}
+
+		value, err := strconv.ParseUint(scanner.Text(), 10, 0)
+		if err != nil {
+			continue
+		}
+
+		switch field {
+		case "rx_bytes":
+			stat.TxBytes = value
+		case "rx_packets":
+			stat.TxPackets = value
+		case "rx_dropped":
+			stat.TxDropped = value
+		case "tx_bytes":
+			stat.RxBytes = value
+		case "tx_packets":
+			stat.RxPackets = value
+		case "tx_dropped":
+			stat.RxDropped = value
+		}
+	}
+
+	return
+}
@@ -0,0 +1,380 @@
+package linux_container
+
+import (
+	"bufio"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// OOMPolicyProperty sets what happens to the container when it runs out of
+// memory; see linux_backend.OOMPolicy. Containers are stopped by default.
+const OOMPolicyProperty = "garden.linux.oom-policy"
+
+// maxOOMRecords is how many OOMs are remembered for each container.
+const maxOOMRecords = 10
+
+// OOMRecords returns the last times the container ran out of memory, oldest
+// first.
+func (c *LinuxContainer) OOMRecords() []linux_backend.OOMRecord {
+	c.oomRecordsMutex.RLock()
+	defer c.oomRecordsMutex.RUnlock()
+
+	records := make([]linux_backend.OOMRecord, len(c.oomRecords))
+	copy(records, c.oomRecords)
+
+	return records
+}
+
+func (c *LinuxContainer) startOomNotifier() error {
+	c.oomMutex.Lock()
+	defer c.oomMutex.Unlock()
+
+	if c.oomNotifier != nil {
+		return nil
+	}
+
+	policy, err := c.oomPolicy()
+	if err != nil {
+		return err
+	}
+
+	// the kernel's OOM killer is enabled unless it has been disabled, which
+	// only a previous record policy would have done
+	if policy == linux_backend.OOMPolicyRecord {
+		err := c.applyOomPolicy(policy)
+		if err != nil {
+			return err
+		}
+	}
+
+	notifier, err := c.runOomNotifier()
+	if err != nil {
+		return err
+	}
+
+	c.oomNotifier = notifier
+	c.oomNotifierStop = make(chan struct{})
+
+	go c.superviseOomNotifier(notifier, c.oomNotifierStop, c.timings.oomNotifierRestartInterval())
+
+	return nil
+}
+
+func (c *LinuxContainer) stopOomNotifier() {
+	c.oomMutex.Lock()
+	defer c.oomMutex.Unlock()
+
+	if c.oomNotifierStop == nil {
+		return
+	}
+
+	close(c.oomNotifierStop)
+	c.oomNotifierStop = nil
+
+	c.runner.Kill(c.oomNotifier)
+}
+
+func (c *LinuxContainer) runOomNotifier() (*exec.Cmd, error) {
+	notifier := exec.Command(path.Join(c.path, "bin", "oom"), c.cgroupsManager.SubsystemPath("memory"))
+
+	err := c.runner.Start(notifier)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifier, nil
+}
+
+// restartOomNotifier starts a new notifier in place of one which has exited,
+// unless the notifier has been stopped, in which case it returns nil.
+func (c *LinuxContainer) restartOomNotifier(stop <-chan struct{}) (*exec.Cmd, error) {
+	c.oomMutex.Lock()
+	defer c.oomMutex.Unlock()
+
+	select {
+	case <-stop:
+		return nil, nil
+	default:
+	}
+
+	notifier, err := c.runOomNotifier()
+	if err != nil {
+		return nil, err
+	}
+
+	c.oomNotifier = notifier
+
+	return notifier, nil
+}
+
+// superviseOomNotifier handles each OOM the notifier reports according to
+// the container's policy. The notifier exits on the first OOM, so unless the
+// container is stopped because of it, the notifier is started again to catch
+// the next one, once any OOM left waiting by the record policy has been
+// resolved. It is also started again if it dies, so that the container is
+// never left unwatched.
+func (c *LinuxContainer) superviseOomNotifier(notifier *exec.Cmd, stop <-chan struct{}, restartInterval time.Duration) {
+	sLog := c.logger.Session("oom-notifier")
+
+	for {
+		err := c.runner.Wait(notifier)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err == nil {
+			switch c.handleOom() {
+			case linux_backend.OOMPolicyStop:
+				return
+			case linux_backend.OOMPolicyRecord:
+				c.resolveOom(stop)
+			}
+		} else {
+			sLog.Error("died", err)
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(restartInterval):
+			}
+		}
+
+		for {
+			notifier, err = c.restartOomNotifier(stop)
+			if err == nil {
+				break
+			}
+
+			sLog.Error("failed-to-restart", err)
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(restartInterval):
+			}
+		}
+
+		if notifier == nil {
+			return
+		}
+
+		sLog.Info("restarted")
+	}
+}
+
+// handleOom records the OOM along with the container's memory usage, and
+// stops the container if its policy says to. It returns the policy it
+// followed.
+func (c *LinuxContainer) handleOom() linux_backend.OOMPolicy {
+	oLog := c.logger.Session("oom")
+
+	policy, err := c.oomPolicy()
+	if err != nil {
+		oLog.Error("invalid-policy", err)
+		policy = linux_backend.OOMPolicyStop
+	}
+
+	record := linux_backend.OOMRecord{
+		Time:   time.Now(),
+		Policy: policy,
+	}
+
+	memoryStat, err := c.cgroupsManager.Get("memory", "memory.stat")
+	if err != nil {
+		oLog.Error("failed-to-get-memory-stat", err)
+	} else {
+		record.MemoryStat = parseMemoryStat(memoryStat)
+	}
+
+	limit, err := c.cgroupsManager.Get("memory", "memory.limit_in_bytes")
+	if err != nil {
+		oLog.Error("failed-to-get-memory-limit", err)
+	} else {
+		record.MemoryLimitInBytes, _ = strconv.ParseUint(limit, 10, 64)
+	}
+
+	c.oomRecordsMutex.Lock()
+	c.oomRecords = append(c.oomRecords, record)
+	if len(c.oomRecords) > maxOOMRecords {
+		c.oomRecords = c.oomRecords[len(c.oomRecords)-maxOOMRecords:]
+	}
+	c.oomRecordsMutex.Unlock()
+
+	c.notifyChanged()
+
+	oLog.Info("out-of-memory", lager.Data{
+		"policy": policy,
+		"limit":  record.MemoryLimitInBytes,
+		"usage":  record.MemoryStat.TotalUsageTowardLimit,
+	})
+
+	c.registerEvent("out of memory")
+
+	c.emit(event_hub.OutOfMemory, map[string]string{
+		"policy": string(policy),
+	})
+
+	if policy == linux_backend.OOMPolicyStop {
+		c.Stop(false)
+	}
+
+	return policy
+}
+
+// resolveOom waits for the container's processes to stop waiting for
+// memory, which they do under the record policy until some is freed or the
+// limit is raised. If they are still waiting after the timeout, the kernel's
+// OOM killer is enabled until it has freed some, and the policy is then
+// applied again.
+func (c *LinuxContainer) resolveOom(stop <-chan struct{}) {
+	rLog := c.logger.Session("resolve-oom")
+
+	timeout := time.After(c.timings.oomRecordTimeout())
+
+	resolved := c.awaitOomResolution(rLog, stop, timeout)
+	if resolved {
+		return
+	}
+
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	rLog.Info("enabling-oom-killer")
+
+	err := c.cgroupsManager.Set("memory", "memory.oom_control", "0")
+	if err != nil {
+		rLog.Error("failed-to-enable-oom-killer", err)
+		return
+	}
+
+	c.awaitOomResolution(rLog, stop, nil)
+
+	c.oomMutex.RLock()
+	defer c.oomMutex.RUnlock()
+
+	policy, err := c.oomPolicy()
+	if err != nil {
+		rLog.Error("invalid-policy", err)
+		return
+	}
+
+	err = c.applyOomPolicy(policy)
+	if err != nil {
+		rLog.Error("failed-to-apply-policy", err)
+	}
+}
+
+// awaitOomResolution polls memory.oom_control until the container is no
+// longer under OOM, returning true, or until stopped or timed out, returning
+// false. Failing to read it counts as resolved, so that the notifier is not
+// held up.
+func (c *LinuxContainer) awaitOomResolution(rLog lager.Logger, stop <-chan struct{}, timeout <-chan time.Time) bool {
+	ticker := time.NewTicker(c.timings.oomPollInterval())
+	defer ticker.Stop()
+
+	for {
+		oomControl, err := c.cgroupsManager.Get("memory", "memory.oom_control")
+		if err != nil {
+			rLog.Error("failed-to-get-oom-control", err)
+			return true
+		}
+
+		if !parseUnderOom(oomControl) {
+			return true
+		}
+
+		select {
+		case <-stop:
+			return false
+		case <-timeout:
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseUnderOom reads the under_oom field of memory.oom_control, which is 1
+// while the cgroup's processes are waiting for memory.
+func parseUnderOom(contents string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	scanner.Split(bufio.ScanWords)
+
+	for scanner.Scan() {
+		field := scanner.Text()
+
+		if !scanner.Scan() {
+			break
+		}
+
+		if field == "under_oom" {
+			return scanner.Text() == "1"
+		}
+	}
+
+	return false
+}
+
+func (c *LinuxContainer) oomPolicy() (linux_backend.OOMPolicy, error) {
+	c.propertiesMutex.RLock()
+	value, found := c.properties[OOMPolicyProperty]
+	c.propertiesMutex.RUnlock()
+
+	if !found {
+		return linux_backend.OOMPolicyStop, nil
+	}
+
+	return parseOomPolicy(value)
+}
+
+// setOomPolicy applies a change to the policy property straight away if the
+// container's memory is already limited.
+func (c *LinuxContainer) setOomPolicy(value string) error {
+	policy, err := parseOomPolicy(value)
+	if err != nil {
+		return err
+	}
+
+	c.oomMutex.RLock()
+	defer c.oomMutex.RUnlock()
+
+	if c.oomNotifier == nil {
+		return nil
+	}
+
+	return c.applyOomPolicy(policy)
+}
+
+// applyOomPolicy disables the kernel's OOM killer for the record policy, and
+// enables it for the others. With it disabled, a container which runs out of
+// memory hangs until the OOM is resolved; see resolveOom.
+func (c *LinuxContainer) applyOomPolicy(policy linux_backend.OOMPolicy) error {
+	disabled := "0"
+	if policy == linux_backend.OOMPolicyRecord {
+		disabled = "1"
+	}
+
+	return c.cgroupsManager.Set("memory", "memory.oom_control", disabled)
+}
+
+func parseOomPolicy(value string) (linux_backend.OOMPolicy, error) {
+	switch policy := linux_backend.OOMPolicy(value); policy {
+	case linux_backend.OOMPolicyStop, linux_backend.OOMPolicyKillProcess, linux_backend.OOMPolicyRecord:
+		return policy, nil
+	}
+
+	return "", InvalidLimitPropertyError{Key: OOMPolicyProperty, Value: value}
+}
@@ -0,0 +1,382 @@
+package linux_container_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker/fake_process_tracker"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+)
+
+var _ = Describe("Linux containers", func() {
+	var eventHub *event_hub.Hub
+	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
+	var container *linux_container.LinuxContainer
+	var containerDir string
+	var timings linux_container.Timings
+
+	BeforeEach(func() {
+		eventHub = event_hub.New(lagertest.NewTestLogger("test"), 100)
+
+		timings = linux_container.Timings{}
+
+		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
+
+		var err error
+		containerDir, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		_, subnet, _ := net.ParseCIDR("2.3.4.0/30")
+
+		container = linux_container.NewLinuxContainer(
+			lagertest.NewTestLogger("test"),
+			"some-id",
+			"some-handle",
+			containerDir,
+			nil,
+			1*time.Second,
+			linux_backend.NewResources(
+				1234,
+				1235,
+				&linux_backend.Network{
+					IP:     net.ParseIP("1.2.3.4"),
+					Subnet: subnet,
+				},
+				"some-bridge",
+				[]uint32{},
+				nil,
+			),
+			fake_port_pool.New(1000),
+			fake_command_runner.New(),
+			fakeCgroups,
+			fake_quota_manager.New(),
+			fake_bandwidth_manager.New(),
+			new(fake_process_tracker.FakeProcessTracker),
+			process.Env{},
+			new(networkFakes.FakeFilter),
+			eventHub,
+			new(capacityFakes.FakeCommitter),
+			timings,
+		)
+	})
+
+	Describe("Pausing", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+		})
+
+		It("freezes the container's cgroup", func() {
+			Expect(container.Pause()).To(Succeed())
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "freezer", Name: "freezer.state", Value: "FROZEN"},
+			}))
+		})
+
+		It("reports the container as paused", func() {
+			Expect(container.Pause()).To(Succeed())
+
+			info, err := container.Info()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.State).To(Equal("paused"))
+		})
+
+		It("emits a pause event", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			Expect(container.Pause()).To(Succeed())
+
+			var event event_hub.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.Paused))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
+		It("notifies the change listener, so that the paused state is persisted", func() {
+			changed := 0
+			container.OnChange(func() { changed++ })
+
+			Expect(container.Pause()).To(Succeed())
+			Expect(changed).To(Equal(1))
+		})
+
+		It("rejects running processes while paused", func() {
+			Expect(container.Pause()).To(Succeed())
+
+			_, err := container.Run(garden.ProcessSpec{User: "vcap", Path: "ls"}, garden.ProcessIO{})
+			Expect(err).To(Equal(linux_container.InvalidStateError{
+				Handle:    "some-handle",
+				Operation: "run a process",
+				State:     linux_container.StatePaused,
+			}))
+		})
+
+		It("is thawed when stopped while paused", func() {
+			Expect(container.Pause()).To(Succeed())
+
+			Expect(container.Stop(true)).To(Succeed())
+			Expect(container.State()).To(Equal(linux_container.StateStopped))
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{Subsystem: "freezer", Name: "freezer.state", Value: "THAWED"},
+			))
+		})
+
+		Context("when the freezer takes a while to freeze every process", func() {
+			BeforeEach(func() {
+				polls := 0
+				fakeCgroups.WhenGetting("freezer", "freezer.state", func() (string, error) {
+					polls++
+					if polls < 3 {
+						return "FREEZING", nil
+					}
+
+					return "FROZEN", nil
+				})
+			})
+
+			It("waits for it to finish", func() {
+				Expect(container.Pause()).To(Succeed())
+				Expect(container.State()).To(Equal(linux_container.StatePaused))
+			})
+		})
+
+		Context("when the freezer never finishes freezing", func() {
+			BeforeEach(func() {
+				timings.FreezeTimeout = 50 * time.Millisecond
+
+				fakeCgroups.WhenGetting("freezer", "freezer.state", func() (string, error) {
+					return "FREEZING", nil
+				})
+			})
+
+			It("returns a FreezeTimeoutError", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.FreezeTimeoutError{
+					Handle:  "some-handle",
+					Timeout: 50 * time.Millisecond,
+					State:   "FREEZING",
+				}))
+			})
+
+			It("thaws the container and leaves it active", func() {
+				Expect(container.Pause()).ToNot(Succeed())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{Subsystem: "freezer", Name: "freezer.state", Value: "THAWED"},
+				))
+
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+		})
+
+		Context("when freezing fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error and leaves the container active", func() {
+				Expect(container.Pause()).To(Equal(disaster))
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+		})
+
+		Context("when the container is not active", func() {
+			JustBeforeEach(func() {
+				Expect(container.Stop(false)).To(Succeed())
+			})
+
+			It("returns an InvalidStateTransitionError", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateStopped,
+					To:     linux_container.StatePaused,
+				}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when the container is being stopped", func() {
+			var unblock chan struct{}
+			var stopped chan error
+
+			BeforeEach(func() {
+				unblock = make(chan struct{})
+
+				blocked := unblock
+				fakeCgroups.WhenGetting("cpu", "cgroup.procs", func() (string, error) {
+					<-blocked
+					return "", nil
+				})
+			})
+
+			JustBeforeEach(func() {
+				stopped = make(chan error, 1)
+				go func() {
+					stopped <- container.Stop(false)
+				}()
+
+				Eventually(container.State).Should(Equal(linux_container.StateStopping))
+			})
+
+			AfterEach(func() {
+				close(unblock)
+				Eventually(stopped).Should(Receive(BeNil()))
+			})
+
+			It("returns an InvalidStateTransitionError without freezing it", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateStopping,
+					To:     linux_container.StatePaused,
+				}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when the container is being destroyed", func() {
+			var destroyed func(error)
+
+			JustBeforeEach(func() {
+				var err error
+				destroyed, err = container.BeginDestroy()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an InvalidStateTransitionError without freezing it", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateDestroying,
+					To:     linux_container.StatePaused,
+				}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+
+				destroyed(nil)
+			})
+		})
+
+		Context("when destroying a paused container fails", func() {
+			It("leaves it paused", func() {
+				Expect(container.Pause()).To(Succeed())
+
+				destroyed, err := container.BeginDestroy()
+				Expect(err).ToNot(HaveOccurred())
+
+				destroyed(errors.New("oh no!"))
+
+				Expect(container.State()).To(Equal(linux_container.StatePaused))
+			})
+		})
+	})
+
+	Describe("Resuming", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+		})
+
+		Context("when the container is paused", func() {
+			JustBeforeEach(func() {
+				Expect(container.Pause()).To(Succeed())
+			})
+
+			It("thaws the container's cgroup", func() {
+				Expect(container.Resume()).To(Succeed())
+
+				Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+					{Subsystem: "freezer", Name: "freezer.state", Value: "FROZEN"},
+					{Subsystem: "freezer", Name: "freezer.state", Value: "THAWED"},
+				}))
+			})
+
+			It("makes the container active again", func() {
+				Expect(container.Resume()).To(Succeed())
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+			})
+
+			It("emits a resume event", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				Expect(container.Resume()).To(Succeed())
+
+				var event event_hub.Event
+				Expect(subscription.Events()).To(Receive(&event))
+				Expect(event.Type).To(Equal(event_hub.Resumed))
+			})
+		})
+
+		Context("when the container is not paused", func() {
+			It("returns an InvalidStateTransitionError", func() {
+				err := container.Resume()
+				Expect(err).To(Equal(linux_container.InvalidStateTransitionError{
+					Handle: "some-handle",
+					From:   linux_container.StateActive,
+					To:     linux_container.StateActive,
+				}))
+			})
+		})
+	})
+
+	Describe("Restoring a paused container", func() {
+		It("freezes it again", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "paused",
+				Events: []string{},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StatePaused))
+			Expect(fakeCgroups.SetValues()).To(ContainElement(
+				fake_cgroups_manager.SetValue{Subsystem: "freezer", Name: "freezer.state", Value: "FROZEN"},
+			))
+		})
+
+		Context("when freezing fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "paused",
+					Events: []string{},
+				})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+	})
+})
@@ -0,0 +1,219 @@
+package linux_container
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// pidsUnlimited is what pids.max holds when no limit is set.
+const pidsUnlimited = "max"
+
+type PidsUnsupportedError struct{}
+
+func (e PidsUnsupportedError) Error() string {
+	return "pids cannot be limited: the kernel has no pids cgroup subsystem"
+}
+
+// pidsUnsupported reports whether err came from the container having no pids
+// cgroup, as kernels before Linux 4.3 have no pids subsystem.
+func pidsUnsupported(err error) bool {
+	return os.IsNotExist(err)
+}
+
+// LimitPids caps the number of processes in the container. Without a pids
+// cgroup there is no cap to lift, so lifting it succeeds, but setting one
+// fails with a PidsUnsupportedError.
+func (c *LinuxContainer) LimitPids(limits linux_backend.PidsLimits) error {
+	max := pidsUnlimited
+	if limits.Max > 0 {
+		max = strconv.FormatUint(limits.Max, 10)
+	}
+
+	err := c.cgroupsManager.Set("pids", "pids.max", max)
+	if pidsUnsupported(err) {
+		if limits.Max > 0 {
+			return PidsUnsupportedError{}
+		}
+
+		err = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.pidsMutex.Lock()
+	c.currentPidsLimits = &limits
+	c.pidsMutex.Unlock()
+
+	if limits.Max > 0 {
+		c.startPidsWatcher()
+	} else {
+		c.stopPidsWatcher()
+	}
+
+	c.notifyChanged()
+
+	c.emit(event_hub.LimitChanged, map[string]string{
+		"limit": "pids",
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) CurrentPidsLimits() (linux_backend.PidsLimits, error) {
+	max, err := c.cgroupsManager.Get("pids", "pids.max")
+	if pidsUnsupported(err) {
+		return linux_backend.PidsLimits{}, nil
+	}
+
+	if err != nil {
+		return linux_backend.PidsLimits{}, err
+	}
+
+	if max == pidsUnlimited {
+		return linux_backend.PidsLimits{}, nil
+	}
+
+	numericMax, err := strconv.ParseUint(max, 10, 0)
+	if err != nil {
+		return linux_backend.PidsLimits{}, err
+	}
+
+	return linux_backend.PidsLimits{Max: numericMax}, nil
+}
+
+// pidsStat is empty when the container has no pids cgroup.
+func (c *LinuxContainer) pidsStat() (linux_backend.PidsStat, error) {
+	current, err := c.cgroupsManager.Get("pids", "pids.current")
+	if pidsUnsupported(err) {
+		return linux_backend.PidsStat{}, nil
+	}
+
+	if err != nil {
+		return linux_backend.PidsStat{}, err
+	}
+
+	numericCurrent, err := strconv.ParseUint(strings.Trim(current, "\n"), 10, 0)
+	if err != nil {
+		return linux_backend.PidsStat{}, err
+	}
+
+	limitHits, err := c.pidsLimitHits()
+	if err != nil {
+		return linux_backend.PidsStat{}, err
+	}
+
+	return linux_backend.PidsStat{
+		Current:   numericCurrent,
+		LimitHits: limitHits,
+	}, nil
+}
+
+// pidsLimitHits returns how many forks the pids limit has refused so far.
+func (c *LinuxContainer) pidsLimitHits() (uint64, error) {
+	events, err := c.cgroupsManager.Get("pids", "pids.events")
+	if err != nil {
+		return 0, err
+	}
+
+	return parsePidsLimitHits(events), nil
+}
+
+// parsePidsLimitHits reads the "max" field of pids.events, which counts the
+// forks refused because of the pids limit.
+func parsePidsLimitHits(contents string) uint64 {
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+
+	scanner.Split(bufio.ScanWords)
+
+	for scanner.Scan() {
+		field := scanner.Text()
+
+		if !scanner.Scan() {
+			break
+		}
+
+		if field == "max" {
+			value, _ := strconv.ParseUint(scanner.Text(), 10, 0)
+			return value
+		}
+	}
+
+	return 0
+}
+
+func (c *LinuxContainer) startPidsWatcher() {
+	c.pidsWatcherMutex.Lock()
+	defer c.pidsWatcherMutex.Unlock()
+
+	if c.pidsWatcherStop != nil {
+		return
+	}
+
+	c.pidsWatcherStop = make(chan struct{})
+
+	go c.watchPidsEvents(c.timings.pidsEventsPollInterval(), c.pidsWatcherStop)
+}
+
+func (c *LinuxContainer) stopPidsWatcher() {
+	c.pidsWatcherMutex.Lock()
+	defer c.pidsWatcherMutex.Unlock()
+
+	if c.pidsWatcherStop != nil {
+		close(c.pidsWatcherStop)
+		c.pidsWatcherStop = nil
+	}
+}
+
+// watchPidsEvents emits a PidsLimitReached event whenever the pids limit has
+// refused more forks since it last looked. Forks refused before it started,
+// such as before the container was restored, are not reported again.
+func (c *LinuxContainer) watchPidsEvents(interval time.Duration, stop <-chan struct{}) {
+	wLog := c.logger.Session("watch-pids-events")
+
+	seen, err := c.pidsLimitHits()
+	if err != nil {
+		wLog.Error("failed-to-read-pids-events", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			hits, err := c.pidsLimitHits()
+			if err != nil {
+				wLog.Error("failed-to-read-pids-events", err)
+				continue
+			}
+
+			if hits <= seen {
+				continue
+			}
+
+			wLog.Info("pids-limit-reached", lager.Data{
+				"refused": hits - seen,
+				"total":   hits,
+			})
+
+			c.emit(event_hub.PidsLimitReached, map[string]string{
+				"refused": strconv.FormatUint(hits-seen, 10),
+				"total":   strconv.FormatUint(hits, 10),
+			})
+
+			seen = hits
+		}
+	}
+}
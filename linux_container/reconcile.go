@@ -0,0 +1,245 @@
+package linux_container
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager"
+	"github.com/pivotal-golang/lager"
+)
+
+// the range the kernel clamps cpu.shares to
+const (
+	minCPUShares = 2
+	maxCPUShares = 262144
+)
+
+// limitMismatch is a limit which did not read back as it was applied.
+type limitMismatch struct {
+	limit    string
+	expected interface{}
+	actual   interface{}
+	err      error
+}
+
+// reconcileLimits re-applies every limit in the snapshot, without admitting
+// them against the cell's capacity again, and then reads each back to check
+// it took effect. It must run after net.sh setup, which rebuilds the qdiscs
+// that enforce the bandwidth limits.
+//
+// Failing to apply a limit fails the restore. A limit which reads back
+// differently is logged and reported as a LimitMismatch event instead, as
+// the container is usable even so.
+func (c *LinuxContainer) reconcileLimits(logger lager.Logger, limits LimitsSnapshot) error {
+	rLog := logger.Session("reconcile-limits")
+
+	err := c.reapplyLimits(rLog, limits)
+	if err != nil {
+		return err
+	}
+
+	for _, mismatch := range c.verifyLimits(limits) {
+		data := map[string]string{
+			"limit": mismatch.limit,
+		}
+
+		if mismatch.err != nil {
+			data["error"] = mismatch.err.Error()
+		} else {
+			data["expected"] = fmt.Sprintf("%+v", mismatch.expected)
+			data["actual"] = fmt.Sprintf("%+v", mismatch.actual)
+		}
+
+		rLog.Info("limit-mismatch", lager.Data{"mismatch": data})
+
+		c.registerEvent("limit mismatch: " + mismatch.limit)
+
+		c.emit(event_hub.LimitMismatch, data)
+	}
+
+	rLog.Info("reconciled")
+
+	return nil
+}
+
+func (c *LinuxContainer) reapplyLimits(logger lager.Logger, limits LimitsSnapshot) error {
+	if limits.Memory != nil {
+		err := c.limitMemory(*limits.Memory)
+		if err != nil {
+			logger.Error("failed-to-limit-memory", err)
+			return err
+		}
+	}
+
+	if limits.MemoryPressure != nil {
+		err := c.LimitMemoryPressure(*limits.MemoryPressure)
+		if err != nil {
+			logger.Error("failed-to-limit-memory-pressure", err)
+			return err
+		}
+	}
+
+	if limits.CPU != nil {
+		err := c.limitCPU(*limits.CPU)
+		if err != nil {
+			logger.Error("failed-to-limit-cpu", err)
+			return err
+		}
+	}
+
+	if limits.CPUQuota != nil {
+		err := c.LimitCPUQuota(*limits.CPUQuota)
+		if err != nil {
+			logger.Error("failed-to-limit-cpu-quota", err)
+			return err
+		}
+	}
+
+	if limits.Pids != nil {
+		err := c.LimitPids(*limits.Pids)
+		if err != nil {
+			logger.Error("failed-to-limit-pids", err)
+			return err
+		}
+	}
+
+	if limits.BlockIO != nil {
+		err := c.LimitBlockIO(*limits.BlockIO)
+		if err != nil {
+			logger.Error("failed-to-limit-block-io", err)
+			return err
+		}
+	}
+
+	if limits.Bandwidth != nil {
+		err := c.LimitBandwidth(*limits.Bandwidth)
+		if err != nil {
+			logger.Error("failed-to-limit-bandwidth", err)
+			return err
+		}
+	}
+
+	if limits.Disk != nil {
+		err := c.limitDisk(*limits.Disk)
+		if err != nil {
+			logger.Error("failed-to-limit-disk", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyLimits compares each limit in the snapshot with what reads back,
+// allowing for the rounding the kernel and quota tools do.
+func (c *LinuxContainer) verifyLimits(limits LimitsSnapshot) []limitMismatch {
+	mismatches := []limitMismatch{}
+
+	check := func(limit string, expected, actual interface{}, err error) {
+		if err != nil {
+			mismatches = append(mismatches, limitMismatch{limit: limit, err: err})
+			return
+		}
+
+		if expected != actual {
+			mismatches = append(mismatches, limitMismatch{limit: limit, expected: expected, actual: actual})
+		}
+	}
+
+	if limits.Memory != nil {
+		current, err := c.CurrentMemoryLimits()
+		check("memory", pageAligned(limits.Memory.LimitInBytes), pageAligned(current.LimitInBytes), err)
+	}
+
+	if limits.MemoryPressure != nil {
+		current, err := c.CurrentMemoryPressureLimits()
+		check("memory-pressure", pageAligned(limits.MemoryPressure.SoftLimitInBytes), pageAligned(current.SoftLimitInBytes), err)
+	}
+
+	if limits.CPU != nil {
+		current, err := c.CurrentCPULimits()
+		check("cpu", clampCPUShares(limits.CPU.LimitInShares), current.LimitInShares, err)
+	}
+
+	if limits.CPUQuota != nil {
+		expected := *limits.CPUQuota
+		if expected.PeriodInMicroseconds == 0 {
+			expected.PeriodInMicroseconds = DefaultCPUPeriod
+		}
+
+		current, err := c.CurrentCPUQuotaLimits()
+		check("cpu-quota", expected, current, err)
+	}
+
+	if limits.Pids != nil {
+		current, err := c.CurrentPidsLimits()
+		check("pids", *limits.Pids, current, err)
+	}
+
+	if limits.BlockIO != nil {
+		expected := *limits.BlockIO
+
+		current, err := c.CurrentBlockIOLimits()
+		if expected.Weight == 0 {
+			// a weight of 0 left the weight as it was
+			current.Weight = 0
+		}
+
+		check("blkio", expected, current, err)
+	}
+
+	if limits.Bandwidth != nil && limits.Bandwidth.RateInBytesPerSecond > 0 {
+		// tc reports rates rounded to its own units, so all that can be
+		// checked is that the qdiscs enforcing them are there
+		current, err := c.bandwidthManager.GetLimits(c.logger.Session("verify-bandwidth"))
+		check("bandwidth", true, current.InRate > 0 && current.OutRate > 0, err)
+	}
+
+	if limits.Disk != nil && c.quotaManager.IsEnabled() {
+		current, err := c.CurrentDiskLimits()
+		check("disk", diskQuota(*limits.Disk), diskQuota(current), err)
+	}
+
+	return mismatches
+}
+
+// pageAligned rounds down to a whole number of pages, as the kernel does
+// with memory limits.
+func pageAligned(bytes uint64) uint64 {
+	pageSize := uint64(os.Getpagesize())
+	return bytes / pageSize * pageSize
+}
+
+func clampCPUShares(shares uint64) uint64 {
+	if shares < minCPUShares {
+		return minCPUShares
+	}
+
+	if shares > maxCPUShares {
+		return maxCPUShares
+	}
+
+	return shares
+}
+
+// diskQuota is what setquota is given for the limits, in blocks and inodes.
+func diskQuota(limits garden.DiskLimits) garden.DiskLimits {
+	quota := garden.DiskLimits{
+		BlockSoft: limits.BlockSoft,
+		BlockHard: limits.BlockHard,
+		InodeSoft: limits.InodeSoft,
+		InodeHard: limits.InodeHard,
+	}
+
+	if limits.ByteSoft != 0 {
+		quota.BlockSoft = (limits.ByteSoft + quota_manager.QUOTA_BLOCK_SIZE - 1) / quota_manager.QUOTA_BLOCK_SIZE
+	}
+
+	if limits.ByteHard != 0 {
+		quota.BlockHard = (limits.ByteHard + quota_manager.QUOTA_BLOCK_SIZE - 1) / quota_manager.QUOTA_BLOCK_SIZE
+	}
+
+	return quota
+}
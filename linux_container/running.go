@@ -5,14 +5,21 @@ import (
 	"fmt"
 	"os/exec"
 	"path"
+	"strconv"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
 	"github.com/pivotal-golang/lager"
 )
 
 func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	if err := c.checkState("run a process", StateActive); err != nil {
+		return nil, err
+	}
+
 	wshPath := path.Join(c.path, "bin", "wsh")
 	sockPath := path.Join(c.path, "run", "wshd.sock")
 
@@ -48,11 +55,7 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 	pidfile := path.Join(c.path, "processes", fmt.Sprintf("%d.pid", processID))
 	args = append(args, "--pidfile", pidfile)
 
-	signaller := &linux_backend.NamespacedSignaller{
-		Runner:        c.runner,
-		ContainerPath: c.path,
-		PidFilePath:   pidfile,
-	}
+	signaller := c.signaller(pidfile)
 
 	args = append(args, spec.Path)
 
@@ -60,13 +63,109 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 
 	setRLimitsEnv(wsh, spec.Limits)
 
-	return c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	process, err := c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	if err != nil {
+		return nil, err
+	}
+
+	c.notifyChanged()
+
+	go c.watchForExit(process)
+
+	return process, nil
+}
+
+// signaller signals the process whose PID is written to the pidfile, and
+// finds its descendants through the container's cgroup.
+func (c *LinuxContainer) signaller(pidfile string) *linux_backend.NamespacedSignaller {
+	return &linux_backend.NamespacedSignaller{
+		Runner:          c.runner,
+		ContainerPath:   c.path,
+		PidFilePath:     pidfile,
+		CgroupProcsPath: path.Join(c.cgroupsManager.SubsystemPath("cpu"), "cgroup.procs"),
+	}
 }
 
 func (c *LinuxContainer) Attach(processID uint32, processIO garden.ProcessIO) (garden.Process, error) {
 	return c.processTracker.Attach(processID, processIO)
 }
 
+// scopedSignaller is a process which can be signalled along with the rest
+// of its process group, or with all of its descendants.
+type scopedSignaller interface {
+	SignalGroup(garden.Signal) error
+	SignalTree(garden.Signal) error
+}
+
+// SignalProcess sends the signal to the process with the given ID, and to
+// the other processes in the scope; an empty scope is the process alone.
+// Unlike garden.Process.Signal, it takes any of the signals process_tracker
+// names.
+func (c *LinuxContainer) SignalProcess(processID uint32, signal garden.Signal, scope linux_backend.SignalScope) error {
+	if scope == "" {
+		scope = linux_backend.SignalScopeProcess
+	}
+
+	sLog := c.logger.Session("signal-process", lager.Data{
+		"process": processID,
+		"signal":  signal,
+		"scope":   scope,
+	})
+
+	switch scope {
+	case linux_backend.SignalScopeProcess, linux_backend.SignalScopeGroup, linux_backend.SignalScopeTree:
+	default:
+		return linux_backend.UnknownSignalScopeError{Scope: scope}
+	}
+
+	for _, process := range c.processTracker.ActiveProcesses() {
+		if process.ID() != processID {
+			continue
+		}
+
+		var err error
+		if scope == linux_backend.SignalScopeProcess {
+			err = process.Signal(signal)
+		} else if scoped, ok := process.(scopedSignaller); !ok {
+			err = fmt.Errorf("linux_container: process cannot be signalled with scope %s: %d", scope, processID)
+		} else if scope == linux_backend.SignalScopeGroup {
+			err = scoped.SignalGroup(signal)
+		} else {
+			err = scoped.SignalTree(signal)
+		}
+
+		if err != nil {
+			sLog.Error("failed", err)
+			return err
+		}
+
+		sLog.Info("signalled")
+
+		return nil
+	}
+
+	return process_tracker.UnknownProcessError{ProcessID: processID}
+}
+
+// watchForExit reports the process's exit. The tracker has stopped listing it
+// by the time Wait returns, so the change leaves it out of snapshots.
+func (c *LinuxContainer) watchForExit(process garden.Process) {
+	exitStatus, err := process.Wait()
+
+	c.notifyChanged()
+
+	data := map[string]string{
+		"process_id":  strconv.FormatUint(uint64(process.ID()), 10),
+		"exit_status": strconv.Itoa(exitStatus),
+	}
+
+	if err != nil {
+		data["error"] = err.Error()
+	}
+
+	c.emit(event_hub.ProcessExited, data)
+}
+
 func setRLimitsEnv(cmd *exec.Cmd, rlimits garden.ResourceLimits) {
 	if rlimits.As != nil {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("RLIMIT_AS=%d", *rlimits.As))
@@ -15,6 +15,8 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
@@ -30,6 +32,7 @@ import (
 )
 
 var _ = Describe("Linux containers", func() {
+	var eventHub *event_hub.Hub
 	var fakeRunner *fake_command_runner.FakeCommandRunner
 	var containerResources *linux_backend.Resources
 	var container *linux_container.LinuxContainer
@@ -37,9 +40,12 @@ var _ = Describe("Linux containers", func() {
 	var containerDir string
 
 	BeforeEach(func() {
+		eventHub = event_hub.New(lagertest.NewTestLogger("test"), 100)
+
 		fakeRunner = fake_command_runner.New()
 
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
 
 		var err error
 		containerDir, err = ioutil.TempDir("", "depot")
@@ -76,7 +82,12 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			eventHub,
+			new(capacityFakes.FakeCommitter),
+			linux_container.Timings{},
 		)
+
+		Expect(container.Start()).To(Succeed())
 	})
 
 	Describe("Running", func() {
@@ -159,7 +170,7 @@ var _ = Describe("Linux containers", func() {
 			}))
 		})
 
-		It("configures a signaller with the same pid as the pidfile parameter", func() {
+		It("configures a signaller with the same pid as the pidfile parameter, and the container's cgroup", func() {
 			_, err := container.Run(garden.ProcessSpec{
 				User: "vcap",
 				Path: "/some/script",
@@ -168,9 +179,10 @@ var _ = Describe("Linux containers", func() {
 
 			_, _, _, _, signaller := fakeProcessTracker.RunArgsForCall(0)
 			Expect(signaller).To(Equal(&linux_backend.NamespacedSignaller{
-				ContainerPath: containerDir,
-				Runner:        fakeRunner,
-				PidFilePath:   containerDir + "/processes/1.pid",
+				ContainerPath:   containerDir,
+				Runner:          fakeRunner,
+				PidFilePath:     containerDir + "/processes/1.pid",
+				CgroupProcsPath: "/cgroups/cpu/instance-some-id/cgroup.procs",
 			}))
 		})
 
@@ -290,6 +302,86 @@ var _ = Describe("Linux containers", func() {
 			Expect(tty).To(Equal(ttySpec))
 		})
 
+		It("emits a process-exit event when the process exits", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			process := new(wfakes.FakeProcess)
+			process.IDReturns(42)
+			process.WaitReturns(123, nil)
+
+			fakeProcessTracker.RunReturns(process, nil)
+
+			_, err := container.Run(garden.ProcessSpec{
+				User: "vcap",
+				Path: "/some/script",
+			}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var event event_hub.Event
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.ProcessExited))
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.Data).To(Equal(map[string]string{
+				"process_id":  "42",
+				"exit_status": "123",
+			}))
+		})
+
+		It("notifies of a change when the process exits", func() {
+			changed := make(chan struct{}, 2)
+			container.OnChange(func() {
+				changed <- struct{}{}
+			})
+
+			exit := make(chan struct{})
+
+			process := new(wfakes.FakeProcess)
+			process.WaitStub = func() (int, error) {
+				<-exit
+				return 0, nil
+			}
+
+			fakeProcessTracker.RunReturns(process, nil)
+
+			_, err := container.Run(garden.ProcessSpec{
+				User: "vcap",
+				Path: "/some/script",
+			}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(changed).To(Receive())
+			Consistently(changed).ShouldNot(Receive())
+
+			close(exit)
+
+			Eventually(changed).Should(Receive())
+		})
+
+		Context("when waiting for the process fails", func() {
+			It("includes the error in the process-exit event", func() {
+				subscription := eventHub.Subscribe()
+				defer subscription.Close()
+
+				process := new(wfakes.FakeProcess)
+				process.IDReturns(42)
+				process.WaitReturns(-1, errors.New("lost the link"))
+
+				fakeProcessTracker.RunReturns(process, nil)
+
+				_, err := container.Run(garden.ProcessSpec{
+					User: "vcap",
+					Path: "/some/script",
+				}, garden.ProcessIO{})
+				Expect(err).ToNot(HaveOccurred())
+
+				var event event_hub.Event
+				Eventually(subscription.Events()).Should(Receive(&event))
+				Expect(event.Data["exit_status"]).To(Equal("-1"))
+				Expect(event.Data["error"]).To(Equal("lost the link"))
+			})
+		})
+
 		Describe("streaming", func() {
 			JustBeforeEach(func() {
 				fakeProcessTracker.RunStub = func(processID uint32, cmd *exec.Cmd, io garden.ProcessIO, tty *garden.TTYSpec, _ process_tracker.Signaller) (garden.Process, error) {
@@ -479,8 +571,108 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Signalling a process", func() {
+		var process *scopedProcess
+
+		BeforeEach(func() {
+			other := new(wfakes.FakeProcess)
+			other.IDReturns(1)
+
+			process = &scopedProcess{FakeProcess: new(wfakes.FakeProcess)}
+			process.IDReturns(42)
+
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{other, process})
+		})
+
+		It("sends the signal to the process", func() {
+			Expect(container.SignalProcess(42, process_tracker.SignalHangup, linux_backend.SignalScopeProcess)).To(Succeed())
+
+			Expect(process.SignalCallCount()).To(Equal(1))
+			Expect(process.SignalArgsForCall(0)).To(Equal(process_tracker.SignalHangup))
+			Expect(process.signalledGroup).To(BeEmpty())
+			Expect(process.signalledTree).To(BeEmpty())
+		})
+
+		Context("when no scope is given", func() {
+			It("sends the signal to the process", func() {
+				Expect(container.SignalProcess(42, process_tracker.SignalHangup, "")).To(Succeed())
+				Expect(process.SignalCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the whole group is to be signalled", func() {
+			It("sends the signal to the process's group", func() {
+				Expect(container.SignalProcess(42, process_tracker.SignalUser1, linux_backend.SignalScopeGroup)).To(Succeed())
+
+				Expect(process.signalledGroup).To(Equal([]garden.Signal{process_tracker.SignalUser1}))
+				Expect(process.SignalCallCount()).To(Equal(0))
+			})
+
+			Context("and the process cannot be signalled as a group", func() {
+				It("returns an error", func() {
+					err := container.SignalProcess(1, process_tracker.SignalUser1, linux_backend.SignalScopeGroup)
+					Expect(err).To(MatchError("linux_container: process cannot be signalled with scope group: 1"))
+				})
+			})
+		})
+
+		Context("when the whole tree is to be signalled", func() {
+			It("sends the signal to the process and its descendants", func() {
+				Expect(container.SignalProcess(42, garden.SignalTerminate, linux_backend.SignalScopeTree)).To(Succeed())
+
+				Expect(process.signalledTree).To(Equal([]garden.Signal{garden.SignalTerminate}))
+				Expect(process.SignalCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the scope is unknown", func() {
+			It("returns UnknownSignalScopeError without signalling anything", func() {
+				err := container.SignalProcess(42, garden.SignalKill, "universe")
+				Expect(err).To(Equal(linux_backend.UnknownSignalScopeError{Scope: "universe"}))
+
+				Expect(process.SignalCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when signalling fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				process.SignalReturns(disaster)
+			})
+
+			It("returns the error", func() {
+				Expect(container.SignalProcess(42, garden.SignalKill, linux_backend.SignalScopeProcess)).To(Equal(disaster))
+			})
+		})
+
+		Context("when the process does not exist", func() {
+			It("returns UnknownProcessError", func() {
+				err := container.SignalProcess(7, garden.SignalKill, linux_backend.SignalScopeProcess)
+				Expect(err).To(Equal(process_tracker.UnknownProcessError{ProcessID: 7}))
+			})
+		})
+	})
+
 })
 
+type scopedProcess struct {
+	*wfakes.FakeProcess
+
+	signalledGroup []garden.Signal
+	signalledTree  []garden.Signal
+}
+
+func (p *scopedProcess) SignalGroup(signal garden.Signal) error {
+	p.signalledGroup = append(p.signalledGroup, signal)
+	return nil
+}
+
+func (p *scopedProcess) SignalTree(signal garden.Signal) error {
+	p.signalledTree = append(p.signalledTree, signal)
+	return nil
+}
+
 func uint64ptr(n uint64) *uint64 {
 	return &n
 }
@@ -13,8 +13,9 @@ type ContainerSnapshot struct {
 
 	GraceTime time.Duration
 
-	State  string
-	Events []string
+	State      string
+	StateTimes map[State]time.Time `json:",omitempty"`
+	Events     []string
 
 	Limits LimitsSnapshot
 
@@ -28,6 +29,10 @@ type ContainerSnapshot struct {
 	Properties garden.Properties
 
 	EnvVars []string
+
+	NetworkCounters *NetworkCountersSnapshot `json:",omitempty"`
+
+	OOMRecords []linux_backend.OOMRecord `json:",omitempty"`
 }
 
 type LimitsSnapshot struct {
@@ -35,6 +40,11 @@ type LimitsSnapshot struct {
 	Disk      *garden.DiskLimits
 	Bandwidth *garden.BandwidthLimits
 	CPU       *garden.CPULimits
+	CPUQuota  *linux_backend.CPUQuotaLimits `json:",omitempty"`
+	Pids      *linux_backend.PidsLimits     `json:",omitempty"`
+	BlockIO   *linux_backend.BlockIOLimits  `json:",omitempty"`
+
+	MemoryPressure *linux_backend.MemoryPressureLimits `json:",omitempty"`
 }
 
 type ResourcesSnapshot struct {
@@ -43,6 +53,15 @@ type ResourcesSnapshot struct {
 	Network *linux_backend.Network
 	Bridge  string
 	Ports   []uint32
+	CPUSet  *linux_backend.CPUSet `json:",omitempty"`
+}
+
+// NetworkCountersSnapshot is what was last read from the container's host
+// interface, and what had been counted before the interface's counters last
+// started again from 0.
+type NetworkCountersSnapshot struct {
+	Base linux_backend.NetworkStat
+	Last linux_backend.NetworkStat
 }
 
 type ProcessSnapshot struct {
@@ -0,0 +1,138 @@
+package linux_container
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// SnapshotVersion is the version of the ContainerSnapshot schema written by
+// this release. Bump it, and register a migration from the previous version,
+// whenever a change to the schema cannot be read as-is by the new code.
+const SnapshotVersion = 1
+
+// A SnapshotMigration upgrades a decoded snapshot from the version it is
+// registered under to the next version, in place.
+type SnapshotMigration func(snapshot map[string]interface{}) error
+
+var snapshotMigrations = map[int]SnapshotMigration{
+	// version 0 snapshots are bare ContainerSnapshots written before the
+	// envelope existed; the schema itself is unchanged
+	0: func(map[string]interface{}) error { return nil },
+}
+
+type UnsupportedSnapshotVersionError struct {
+	Version int
+}
+
+func (err UnsupportedSnapshotVersionError) Error() string {
+	return fmt.Sprintf("snapshot version %d is not supported (current version is %d)", err.Version, SnapshotVersion)
+}
+
+type SnapshotChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (err SnapshotChecksumError) Error() string {
+	return fmt.Sprintf("snapshot is corrupt: checksum %s does not match expected %s", err.Actual, err.Expected)
+}
+
+type snapshotEnvelope struct {
+	Version  int
+	Checksum string
+	Snapshot json.RawMessage
+}
+
+// WriteSnapshot encodes the snapshot along with its schema version and a
+// checksum of its contents.
+func WriteSnapshot(out io.Writer, snapshot ContainerSnapshot) error {
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(out).Encode(snapshotEnvelope{
+		Version:  SnapshotVersion,
+		Checksum: checksum(payload),
+		Snapshot: payload,
+	})
+}
+
+// ReadSnapshot decodes a snapshot written by WriteSnapshot, or by a release
+// predating it, verifying its checksum and migrating it to the current
+// schema version.
+func ReadSnapshot(in io.Reader) (ContainerSnapshot, error) {
+	var snapshot ContainerSnapshot
+
+	contents, err := ioutil.ReadAll(in)
+	if err != nil {
+		return snapshot, err
+	}
+
+	var envelope snapshotEnvelope
+	err = json.Unmarshal(contents, &envelope)
+	if err != nil {
+		return snapshot, fmt.Errorf("snapshot is malformed: %s", err)
+	}
+
+	payload := []byte(envelope.Snapshot)
+	if envelope.Snapshot == nil {
+		// no envelope; this is a version 0 snapshot
+		payload = contents
+	} else if sum := checksum(payload); sum != envelope.Checksum {
+		return snapshot, SnapshotChecksumError{Expected: envelope.Checksum, Actual: sum}
+	}
+
+	if envelope.Version > SnapshotVersion {
+		return snapshot, UnsupportedSnapshotVersionError{envelope.Version}
+	}
+
+	if envelope.Version < SnapshotVersion {
+		payload, err = migrateSnapshot(envelope.Version, payload)
+		if err != nil {
+			return snapshot, err
+		}
+	}
+
+	err = json.Unmarshal(payload, &snapshot)
+	if err != nil {
+		return snapshot, fmt.Errorf("snapshot is malformed: %s", err)
+	}
+
+	return snapshot, nil
+}
+
+func migrateSnapshot(version int, payload []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var snapshot map[string]interface{}
+	err := decoder.Decode(&snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot is malformed: %s", err)
+	}
+
+	for ; version < SnapshotVersion; version++ {
+		migration, found := snapshotMigrations[version]
+		if !found {
+			return nil, UnsupportedSnapshotVersionError{version}
+		}
+
+		err := migration(snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate snapshot from version %d: %s", version, err)
+		}
+	}
+
+	return json.Marshal(snapshot)
+}
+
+func checksum(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
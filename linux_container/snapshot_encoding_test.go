@@ -0,0 +1,98 @@
+package linux_container_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+)
+
+var _ = Describe("Snapshot encoding", func() {
+	var snapshot linux_container.ContainerSnapshot
+
+	BeforeEach(func() {
+		snapshot = linux_container.ContainerSnapshot{
+			ID:        "some-id",
+			Handle:    "some-handle",
+			GraceTime: time.Second,
+			State:     "active",
+			Events:    []string{"some-event"},
+		}
+	})
+
+	It("round-trips a snapshot", func() {
+		out := new(bytes.Buffer)
+
+		err := linux_container.WriteSnapshot(out, snapshot)
+		Expect(err).ToNot(HaveOccurred())
+
+		restored, err := linux_container.ReadSnapshot(out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restored).To(Equal(snapshot))
+	})
+
+	It("records the current snapshot version", func() {
+		out := new(bytes.Buffer)
+
+		err := linux_container.WriteSnapshot(out, snapshot)
+		Expect(err).ToNot(HaveOccurred())
+
+		var envelope struct{ Version int }
+		err = json.NewDecoder(out).Decode(&envelope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(envelope.Version).To(Equal(linux_container.SnapshotVersion))
+	})
+
+	Context("when the snapshot predates versioning", func() {
+		It("reads it", func() {
+			out := new(bytes.Buffer)
+
+			err := json.NewEncoder(out).Encode(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			restored, err := linux_container.ReadSnapshot(out)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(restored).To(Equal(snapshot))
+		})
+	})
+
+	Context("when the snapshot has been tampered with", func() {
+		It("returns a SnapshotChecksumError", func() {
+			out := new(bytes.Buffer)
+
+			err := linux_container.WriteSnapshot(out, snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			corrupt := strings.Replace(out.String(), "some-handle", "some-other-handle", 1)
+
+			_, err = linux_container.ReadSnapshot(strings.NewReader(corrupt))
+			Expect(err).To(BeAssignableToTypeOf(linux_container.SnapshotChecksumError{}))
+		})
+	})
+
+	Context("when the snapshot is truncated", func() {
+		It("returns an error", func() {
+			out := new(bytes.Buffer)
+
+			err := linux_container.WriteSnapshot(out, snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = linux_container.ReadSnapshot(bytes.NewReader(out.Bytes()[:out.Len()/2]))
+			Expect(err).To(MatchError(ContainSubstring("snapshot is malformed")))
+		})
+	})
+
+	Context("when the snapshot is from a newer release", func() {
+		It("returns an UnsupportedSnapshotVersionError", func() {
+			_, err := linux_container.ReadSnapshot(strings.NewReader(
+				`{"Version":9999,"Checksum":"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","Snapshot":{}}`,
+			))
+			Expect(err).To(Equal(linux_container.UnsupportedSnapshotVersionError{9999}))
+		})
+	})
+})
@@ -2,7 +2,6 @@ package linux_container_test
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net"
@@ -14,6 +13,9 @@ import (
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
+	capacityFakes "github.com/cloudfoundry-incubator/garden-linux/capacity/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
 	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
@@ -29,6 +31,8 @@ import (
 )
 
 var _ = Describe("Linux containers", func() {
+	var eventHub *event_hub.Hub
+	var fakeCommitter *capacityFakes.FakeCommitter
 	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
 	var fakeQuotaManager *fake_quota_manager.FakeQuotaManager
 	var fakeBandwidthManager *fake_bandwidth_manager.FakeBandwidthManager
@@ -58,6 +62,9 @@ var _ = Describe("Linux containers", func() {
 	}
 
 	BeforeEach(func() {
+		eventHub = event_hub.New(lagertest.NewTestLogger("test"), 100)
+		fakeCommitter = new(capacityFakes.FakeCommitter)
+
 		fakeRunner = fake_command_runner.New()
 
 		fakeCgroups = fake_cgroups_manager.New("/cgroups", "some-id")
@@ -65,6 +72,8 @@ var _ = Describe("Linux containers", func() {
 		fakeQuotaManager = fake_quota_manager.New()
 		fakeBandwidthManager = fake_bandwidth_manager.New()
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
+		fakeProcessTracker.AttachReturns(new(wfakes.FakeProcess), nil)
 		fakeFilter = new(networkFakes.FakeFilter)
 
 		fakePortPool = fake_port_pool.New(1000)
@@ -108,6 +117,9 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			eventHub,
+			fakeCommitter,
+			linux_container.Timings{},
 		)
 	})
 
@@ -136,6 +148,24 @@ var _ = Describe("Linux containers", func() {
 			LimitInShares: 1,
 		}
 
+		cpuQuotaLimits := linux_backend.CPUQuotaLimits{
+			QuotaInMicroseconds:  50000,
+			PeriodInMicroseconds: 100000,
+		}
+
+		pidsLimits := linux_backend.PidsLimits{
+			Max: 256,
+		}
+
+		blockIOLimits := linux_backend.BlockIOLimits{
+			Weight:             200,
+			ReadBytesPerSecond: 1048576,
+		}
+
+		memoryPressureLimits := linux_backend.MemoryPressureLimits{
+			SoftLimitInBytes: 512,
+		}
+
 		JustBeforeEach(func() {
 			var err error
 
@@ -163,15 +193,13 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{p1, p2, p3})
 		})
 
-		It("writes a JSON ContainerSnapshot", func() {
+		It("writes a versioned ContainerSnapshot", func() {
 			out := new(bytes.Buffer)
 
 			err := container.Snapshot(out)
 			Expect(err).ToNot(HaveOccurred())
 
-			var snapshot linux_container.ContainerSnapshot
-
-			err = json.NewDecoder(out).Decode(&snapshot)
+			snapshot, err := linux_container.ReadSnapshot(out)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(snapshot.ID).To(Equal("some-id"))
@@ -180,6 +208,8 @@ var _ = Describe("Linux containers", func() {
 			Expect(snapshot.GraceTime).To(Equal(1 * time.Second))
 
 			Expect(snapshot.State).To(Equal("active"))
+			Expect(snapshot.StateTimes).To(HaveKey(linux_container.StateActive))
+			Expect(snapshot.StateTimes[linux_container.StateActive]).To(BeTemporally("==", container.StateTimes()[linux_container.StateActive]))
 
 			_, subnet, err := net.ParseCIDR("2.3.4.0/30")
 			Expect(snapshot.Resources).To(Equal(
@@ -255,6 +285,18 @@ var _ = Describe("Linux containers", func() {
 
 				err = container.LimitCPU(cpuLimits)
 				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitCPUQuota(cpuQuotaLimits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitPids(pidsLimits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitBlockIO(blockIOLimits)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = container.LimitMemoryPressure(memoryPressureLimits)
+				Expect(err).ToNot(HaveOccurred())
 			})
 
 			It("saves them", func() {
@@ -263,25 +305,78 @@ var _ = Describe("Linux containers", func() {
 				err := container.Snapshot(out)
 				Expect(err).ToNot(HaveOccurred())
 
-				var snapshot linux_container.ContainerSnapshot
-
-				err = json.NewDecoder(out).Decode(&snapshot)
+				snapshot, err := linux_container.ReadSnapshot(out)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(snapshot.State).To(Equal("stopped"))
 				Expect(snapshot.Events).To(Equal([]string{"out of memory"}))
 
+				Expect(snapshot.OOMRecords).To(HaveLen(1))
+				Expect(snapshot.OOMRecords[0].Policy).To(Equal(linux_backend.OOMPolicyStop))
+
 				Expect(snapshot.Limits).To(Equal(
 					linux_container.LimitsSnapshot{
 						Memory:    &memoryLimits,
 						Disk:      &diskLimits,
 						Bandwidth: &bandwidthLimits,
 						CPU:       &cpuLimits,
+						CPUQuota:  &cpuQuotaLimits,
+						Pids:      &pidsLimits,
+						BlockIO:   &blockIOLimits,
+
+						MemoryPressure: &memoryPressureLimits,
 					},
 				))
 			})
 		})
 
+		It("saves the network counters", func() {
+			fakeCgroups.Set("pids", "pids.current", "0")
+
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/net.sh",
+					Args: []string{"get_stats_info"},
+				}, func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte("rx_bytes 100\ntx_bytes 2000\n"))
+					return nil
+				},
+			)
+
+			_, err := container.LinuxMetrics()
+			Expect(err).ToNot(HaveOccurred())
+
+			out := new(bytes.Buffer)
+
+			err = container.Snapshot(out)
+			Expect(err).ToNot(HaveOccurred())
+
+			snapshot, err := linux_container.ReadSnapshot(out)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.NetworkCounters).To(Equal(&linux_container.NetworkCountersSnapshot{
+				Last: linux_backend.NetworkStat{RxBytes: 2000, TxBytes: 100},
+			}))
+		})
+
+		Context("when the container was allocated cores of its own", func() {
+			BeforeEach(func() {
+				containerResources.CPUSet = &linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}
+			})
+
+			It("saves them with its resources", func() {
+				out := new(bytes.Buffer)
+
+				err := container.Snapshot(out)
+				Expect(err).ToNot(HaveOccurred())
+
+				snapshot, err := linux_container.ReadSnapshot(out)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(snapshot.Resources.CPUSet).To(Equal(&linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}))
+			})
+		})
+
 		Context("with no limits set", func() {
 			It("saves them as nil, not zero values", func() {
 				out := new(bytes.Buffer)
@@ -289,9 +384,7 @@ var _ = Describe("Linux containers", func() {
 				err := container.Snapshot(out)
 				Expect(err).ToNot(HaveOccurred())
 
-				var snapshot linux_container.ContainerSnapshot
-
-				err = json.NewDecoder(out).Decode(&snapshot)
+				snapshot, err := linux_container.ReadSnapshot(out)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(snapshot.Limits).To(Equal(
@@ -323,6 +416,31 @@ var _ = Describe("Linux containers", func() {
 
 		})
 
+		It("restores when each state was entered", func() {
+			activeAt := time.Now().Add(-time.Hour)
+
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:      "active",
+				StateTimes: map[linux_container.State]time.Time{linux_container.StateActive: activeAt},
+				Events:     []string{},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.StateTimes()[linux_container.StateActive]).To(Equal(activeAt))
+		})
+
+		Context("when the snapshot was taken part way through a transition", func() {
+			It("restores the state the transition would have settled in", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "stopping",
+					Events: []string{},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+			})
+		})
+
 		It("restores process state", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
@@ -348,6 +466,35 @@ var _ = Describe("Linux containers", func() {
 			Expect(pid).To(Equal(uint32(1)))
 		})
 
+		It("emits process-exit events when restored processes exit", func() {
+			subscription := eventHub.Subscribe()
+			defer subscription.Close()
+
+			process := new(wfakes.FakeProcess)
+			process.IDReturns(1)
+			process.WaitReturns(2, nil)
+
+			fakeProcessTracker.AttachReturns(process, nil)
+
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State: "active",
+				Processes: []linux_container.ProcessSnapshot{
+					{ID: 1},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeProcessTracker.AttachCallCount()).To(Equal(1))
+			id, _ := fakeProcessTracker.AttachArgsForCall(0)
+			Expect(id).To(Equal(uint32(1)))
+
+			var event event_hub.Event
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Type).To(Equal(event_hub.ProcessExited))
+			Expect(event.Data["process_id"]).To(Equal("1"))
+			Expect(event.Data["exit_status"]).To(Equal("2"))
+		})
+
 		It("makes the next process ID be higher than the highest restored ID", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
@@ -392,9 +539,10 @@ var _ = Describe("Linux containers", func() {
 
 			_, signaller := fakeProcessTracker.RestoreArgsForCall(0)
 			Expect(signaller).To(Equal(&linux_backend.NamespacedSignaller{
-				ContainerPath: containerDir,
-				Runner:        fakeRunner,
-				PidFilePath:   containerDir + "/processes/456.pid",
+				ContainerPath:   containerDir,
+				Runner:          fakeRunner,
+				PidFilePath:     containerDir + "/processes/456.pid",
+				CgroupProcsPath: "/cgroups/cpu/instance-some-id/cgroup.procs",
 			}))
 		})
 
@@ -502,6 +650,116 @@ var _ = Describe("Linux containers", func() {
 			})
 		}
 
+		It("restores the oom records", func() {
+			records := []linux_backend.OOMRecord{
+				{
+					Time:               time.Unix(1000, 0),
+					Policy:             linux_backend.OOMPolicyKillProcess,
+					MemoryLimitInBytes: 102400,
+				},
+			}
+
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				OOMRecords: records,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.OOMRecords()).To(Equal(records))
+		})
+
+		Describe("network counters", func() {
+			BeforeEach(func() {
+				fakeCgroups.Set("pids", "pids.current", "0")
+			})
+
+			restore := func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []string{},
+
+					NetworkCounters: &linux_container.NetworkCountersSnapshot{
+						Base: linux_backend.NetworkStat{RxBytes: 500, TxBytes: 50},
+						Last: linux_backend.NetworkStat{RxBytes: 2000, TxBytes: 100},
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			hostStats := func(stats string) {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"get_stats_info"},
+					}, func(cmd *exec.Cmd) error {
+						cmd.Stdout.Write([]byte(stats))
+						return nil
+					},
+				)
+			}
+
+			It("carry on from where they were when the host interface survived", func() {
+				restore()
+				hostStats("rx_bytes 150\ntx_bytes 2500\n")
+
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(metrics.NetworkStat.RxBytes).To(Equal(uint64(3000)))
+				Expect(metrics.NetworkStat.TxBytes).To(Equal(uint64(200)))
+			})
+
+			It("carry on from where they were when the host interface was recreated", func() {
+				restore()
+				hostStats("rx_bytes 10\ntx_bytes 20\n")
+
+				metrics, err := container.LinuxMetrics()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(metrics.NetworkStat.RxBytes).To(Equal(uint64(2520)))
+				Expect(metrics.NetworkStat.TxBytes).To(Equal(uint64(160)))
+			})
+		})
+
+		Context("when the container was allocated cores of its own", func() {
+			BeforeEach(func() {
+				containerResources.CPUSet = &linux_backend.CPUSet{CPUs: []int{2, 3}, Mems: []int{1}}
+			})
+
+			It("re-pins it to them", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []string{},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+					{Subsystem: "cpuset", Name: "cpuset.mems", Value: "1"},
+					{Subsystem: "cpuset", Name: "cpuset.cpus", Value: "2,3"},
+				}))
+			})
+
+			Context("when re-pinning fails", func() {
+				disaster := errors.New("oh no!")
+
+				JustBeforeEach(func() {
+					fakeCgroups.WhenSetting("cpuset", "cpuset.mems", func() error {
+						return disaster
+					})
+				})
+
+				It("returns the error", func() {
+					err := container.Restore(linux_container.ContainerSnapshot{
+						State:  "active",
+						Events: []string{},
+					})
+					Expect(err).To(Equal(disaster))
+				})
+			})
+		})
+
 		It("re-enforces the memory limit", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
@@ -570,5 +828,297 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).To(Equal(disaster))
 			})
 		})
+
+		It("re-enforces the cpu quota", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				Limits: linux_container.LimitsSnapshot{
+					CPUQuota: &linux_backend.CPUQuotaLimits{
+						QuotaInMicroseconds:  50000,
+						PeriodInMicroseconds: 100000,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "cpu", Name: "cpu.cfs_period_us", Value: "100000"},
+				{Subsystem: "cpu", Name: "cpu.cfs_quota_us", Value: "50000"},
+			}))
+		})
+
+		Context("when re-enforcing the cpu quota fails", func() {
+			disaster := errors.New("oh no!")
+
+			JustBeforeEach(func() {
+				fakeCgroups.WhenSetting("cpu", "cpu.cfs_quota_us", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []string{},
+
+					Limits: linux_container.LimitsSnapshot{
+						CPUQuota: &linux_backend.CPUQuotaLimits{
+							QuotaInMicroseconds: 50000,
+						},
+					},
+				})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		It("re-enforces the pids limit", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				Limits: linux_container.LimitsSnapshot{
+					Pids: &linux_backend.PidsLimits{
+						Max: 256,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "pids", Name: "pids.max", Value: "256"},
+			}))
+		})
+
+		It("re-enforces the memory soft limit and watches for pressure again", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				Limits: linux_container.LimitsSnapshot{
+					MemoryPressure: &linux_backend.MemoryPressureLimits{
+						SoftLimitInBytes: 102400,
+						PressureLevels:   []linux_backend.MemoryPressureLevel{linux_backend.MemoryPressureMedium},
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			defer container.Stop(false)
+
+			Expect(fakeCgroups.SetValues()).To(Equal([]fake_cgroups_manager.SetValue{
+				{Subsystem: "memory", Name: "memory.soft_limit_in_bytes", Value: "102400"},
+			}))
+
+			Expect(fakeRunner).To(HaveStartedExecuting(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/bin/memevents",
+					Args: []string{"/cgroups/memory/instance-some-id", "pressure:medium"},
+				},
+			))
+		})
+
+		It("re-enforces the block I/O limits", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				Limits: linux_container.LimitsSnapshot{
+					BlockIO: &linux_backend.BlockIOLimits{
+						Weight: 200,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "blkio",
+				Name:      "blkio.weight",
+				Value:     "200",
+			}))
+		})
+
+		Describe("reconciling limits", func() {
+			var limits linux_container.LimitsSnapshot
+			var subscription *event_hub.Subscription
+
+			BeforeEach(func() {
+				limits = linux_container.LimitsSnapshot{
+					CPU:       &garden.CPULimits{LimitInShares: 512},
+					Bandwidth: &garden.BandwidthLimits{RateInBytesPerSecond: 1024, BurstRateInBytesPerSecond: 2048},
+					Disk:      &garden.DiskLimits{ByteHard: 2048},
+					Pids:      &linux_backend.PidsLimits{Max: 256},
+				}
+
+				fakeBandwidthManager.GetLimitsResult = garden.ContainerBandwidthStat{
+					InRate:   1024,
+					InBurst:  2048,
+					OutRate:  1024,
+					OutBurst: 2048,
+				}
+
+				fakeQuotaManager.GetLimitsResult = garden.DiskLimits{BlockHard: 2}
+			})
+
+			JustBeforeEach(func() {
+				subscription = eventHub.Subscribe()
+			})
+
+			AfterEach(func() {
+				subscription.Close()
+			})
+
+			restore := func() error {
+				return container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []string{},
+
+					Limits: limits,
+				})
+			}
+
+			mismatches := func() []map[string]string {
+				found := []map[string]string{}
+
+				for {
+					select {
+					case event := <-subscription.Events():
+						if event.Type == event_hub.LimitMismatch {
+							found = append(found, event.Data)
+						}
+					default:
+						return found
+					}
+				}
+			}
+
+			It("re-applies the cpu shares, bandwidth and disk limits", func() {
+				Expect(restore()).To(Succeed())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "cpu",
+					Name:      "cpu.shares",
+					Value:     "512",
+				}))
+
+				Expect(fakeBandwidthManager.EnforcedLimits).To(Equal([]garden.BandwidthLimits{*limits.Bandwidth}))
+				Expect(fakeQuotaManager.Limited[containerResources.UserUID]).To(Equal(*limits.Disk))
+			})
+
+			It("re-applies the bandwidth limits after net.sh has rebuilt the qdiscs", func() {
+				enforcedAtSetup := -1
+
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"setup"},
+					}, func(*exec.Cmd) error {
+						enforcedAtSetup = len(fakeBandwidthManager.EnforcedLimits)
+						return nil
+					},
+				)
+
+				Expect(restore()).To(Succeed())
+
+				Expect(enforcedAtSetup).To(Equal(0))
+				Expect(fakeBandwidthManager.EnforcedLimits).To(HaveLen(1))
+			})
+
+			It("reports nothing when every limit reads back as it was applied", func() {
+				Expect(restore()).To(Succeed())
+
+				Expect(mismatches()).To(BeEmpty())
+				Expect(container.Events()).To(BeEmpty())
+			})
+
+			Context("when a limit reads back differently", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("cpu", "cpu.shares", func() (string, error) {
+						return "1024", nil
+					})
+				})
+
+				It("restores the container, reporting the mismatch", func() {
+					Expect(restore()).To(Succeed())
+
+					Expect(mismatches()).To(Equal([]map[string]string{
+						{"limit": "cpu", "expected": "512", "actual": "1024"},
+					}))
+
+					Expect(container.Events()).To(Equal([]string{"limit mismatch: cpu"}))
+				})
+			})
+
+			Context("when the bandwidth qdiscs are missing", func() {
+				BeforeEach(func() {
+					fakeBandwidthManager.GetLimitsResult = garden.ContainerBandwidthStat{}
+				})
+
+				It("reports a bandwidth mismatch", func() {
+					Expect(restore()).To(Succeed())
+
+					Expect(mismatches()).To(Equal([]map[string]string{
+						{"limit": "bandwidth", "expected": "true", "actual": "false"},
+					}))
+				})
+			})
+
+			Context("when a limit cannot be read back", func() {
+				BeforeEach(func() {
+					fakeQuotaManager.GetLimitsError = errors.New("oh no!")
+				})
+
+				It("reports the error", func() {
+					Expect(restore()).To(Succeed())
+
+					Expect(mismatches()).To(Equal([]map[string]string{
+						{"limit": "disk", "error": "oh no!"},
+					}))
+				})
+			})
+
+			Context("when re-applying a limit fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeBandwidthManager.SetLimitsError = disaster
+				})
+
+				It("returns the error", func() {
+					Expect(restore()).To(Equal(disaster))
+				})
+			})
+		})
+
+		It("commits the restored limits regardless of the cell's capacity", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []string{},
+
+				Limits: linux_container.LimitsSnapshot{
+					Memory: &garden.MemoryLimits{LimitInBytes: 1024},
+					Disk:   &garden.DiskLimits{ByteHard: 2048},
+					CPU:    &garden.CPULimits{LimitInShares: 512},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCommitter.CommitCallCount()).To(BeZero())
+			Expect(fakeCommitter.ForceCommitCallCount()).To(Equal(3))
+
+			handle, resource, amount := fakeCommitter.ForceCommitArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(resource).To(Equal(capacity.Memory))
+			Expect(amount).To(Equal(uint64(1024)))
+
+			_, resource, amount = fakeCommitter.ForceCommitArgsForCall(1)
+			Expect(resource).To(Equal(capacity.Disk))
+			Expect(amount).To(Equal(uint64(2048)))
+
+			_, resource, amount = fakeCommitter.ForceCommitArgsForCall(2)
+			Expect(resource).To(Equal(capacity.CPU))
+			Expect(amount).To(Equal(uint64(512)))
+		})
 	})
 })
@@ -0,0 +1,201 @@
+package linux_container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type State string
+
+const (
+	StateBorn       = State("born")
+	StateStarting   = State("starting")
+	StateActive     = State("active")
+	StatePaused     = State("paused")
+	StateStopping   = State("stopping")
+	StateStopped    = State("stopped")
+	StateDestroying = State("destroying")
+	StateDestroyed  = State("destroyed")
+)
+
+// transitions lists the states each state may move to. Every transitional
+// state may also fall back to the state it was entered from, should the
+// operation behind it fail. Paused is the exception: nothing may freeze a
+// container which is being stopped or destroyed, so falling back to it is
+// done with revert instead.
+var transitions = map[State][]State{
+	StateBorn:       {StateStarting, StateStopping, StateDestroying},
+	StateStarting:   {StateActive, StateBorn},
+	StateActive:     {StateStopping, StateDestroying, StatePaused},
+	StatePaused:     {StateActive, StateStopping, StateDestroying},
+	StateStopping:   {StateStopped, StateBorn, StateActive},
+	StateStopped:    {StateStopping, StateDestroying},
+	StateDestroying: {StateDestroyed, StateBorn, StateActive, StateStopped},
+	StateDestroyed:  {},
+}
+
+// settledStates maps each transitional state to the state a container is
+// restored in if it was snapshotted part way through the transition.
+var settledStates = map[State]State{
+	StateStarting:   StateBorn,
+	StateStopping:   StateStopped,
+	StateDestroying: StateStopped,
+}
+
+type InvalidStateTransitionError struct {
+	Handle string
+	From   State
+	To     State
+}
+
+func (err InvalidStateTransitionError) Error() string {
+	return fmt.Sprintf("container %s cannot become %s while %s", err.Handle, err.To, err.From)
+}
+
+type InvalidStateError struct {
+	Handle    string
+	Operation string
+	State     State
+}
+
+func (err InvalidStateError) Error() string {
+	return fmt.Sprintf("cannot %s in container %s while it is %s", err.Operation, err.Handle, err.State)
+}
+
+func (c *LinuxContainer) State() State {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	return c.state
+}
+
+// StateTimes returns when the container last entered each of the states it
+// has been in.
+func (c *LinuxContainer) StateTimes() map[State]time.Time {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	times := make(map[State]time.Time, len(c.stateTimes))
+	for state, at := range c.stateTimes {
+		times[state] = at
+	}
+
+	return times
+}
+
+// BeginDestroy moves the container into the destroying state, failing if it
+// is already being destroyed. The returned function must be called once
+// destroying has finished, with its outcome.
+func (c *LinuxContainer) BeginDestroy() (func(error), error) {
+	from, err := c.transition(StateDestroying)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(err error) {
+		if err != nil {
+			c.revert(StateDestroying, from)
+			return
+		}
+
+		c.transition(StateDestroyed)
+	}, nil
+}
+
+// transition moves the container to the given state if that is valid from
+// its current state, returning the state it was in.
+func (c *LinuxContainer) transition(to State) (State, error) {
+	return c.transitionFrom(nil, to)
+}
+
+// transitionFrom is like transition, but the container must also currently
+// be in one of the given states, if any are given.
+func (c *LinuxContainer) transitionFrom(allowed []State, to State) (State, error) {
+	c.stateMutex.Lock()
+
+	from := c.state
+	if !canTransition(from, to) || (len(allowed) > 0 && !containsState(allowed, from)) {
+		c.stateMutex.Unlock()
+		return from, InvalidStateTransitionError{Handle: c.handle, From: from, To: to}
+	}
+
+	at := time.Now()
+
+	c.state = to
+	c.stateTimes[to] = at
+
+	c.stateMutex.Unlock()
+
+	c.logger.Debug("state-changed", lager.Data{
+		"from": from,
+		"to":   to,
+		"at":   at,
+	})
+
+	return from, nil
+}
+
+// revert puts the container back in the state it was in before a transition
+// to the given state whose operation failed, as long as it has not moved on
+// since.
+func (c *LinuxContainer) revert(to, from State) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if c.state != to {
+		return
+	}
+
+	c.state = from
+	c.stateTimes[from] = time.Now()
+
+	c.logger.Debug("state-reverted", lager.Data{
+		"from": to,
+		"to":   from,
+	})
+}
+
+// restoreState puts the container back in the state it was snapshotted in,
+// or in the state a transition under way at the time would have settled in.
+func (c *LinuxContainer) restoreState(state State, times map[State]time.Time) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if settled, found := settledStates[state]; found {
+		state = settled
+	}
+
+	c.state = state
+
+	for s, at := range times {
+		c.stateTimes[s] = at
+	}
+}
+
+// checkState returns an InvalidStateError unless the container is in one of
+// the given states.
+func (c *LinuxContainer) checkState(operation string, allowed ...State) error {
+	state := c.State()
+
+	if containsState(allowed, state) {
+		return nil
+	}
+
+	return InvalidStateError{Handle: c.handle, Operation: operation, State: state}
+}
+
+func canTransition(from, to State) bool {
+	return containsState(transitions[from], to)
+}
+
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,267 @@
+package linux_container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/pivotal-golang/lager"
+)
+
+// StopGracePeriodProperty is how many seconds the container's processes are
+// given to exit after being sent TERM when it is stopped, before they are
+// killed.
+const StopGracePeriodProperty = "garden.linux.stop-grace-period-s"
+
+type CgroupNotEmptyError struct {
+	PIDs []int
+}
+
+func (e CgroupNotEmptyError) Error() string {
+	return fmt.Sprintf("processes are still in the container's cgroup after being killed: %v", e.PIDs)
+}
+
+// Stop stops every process in the container, giving them the container's
+// grace period to exit after being sent TERM, or killing them straight away
+// if kill is true.
+func (c *LinuxContainer) Stop(kill bool) error {
+	gracePeriod := time.Duration(0)
+	if !kill {
+		gracePeriod = c.stopGracePeriod()
+	}
+
+	return c.StopWithGracePeriod(gracePeriod)
+}
+
+// StopWithGracePeriod stops every process in the container, giving them the
+// grace period to exit after being sent TERM before they are killed. A grace
+// period of 0 kills them straight away.
+//
+// The processes are found in the container's cgroup, rather than through
+// the process tracker, so that any which have left the trees of the tracked
+// processes are stopped too. wshd is left running. A paused container is
+// thawed first, as frozen processes would never handle TERM.
+func (c *LinuxContainer) StopWithGracePeriod(gracePeriod time.Duration) error {
+	from, err := c.transition(StateStopping)
+	if err != nil {
+		return err
+	}
+
+	sLog := c.logger.Session("stop", lager.Data{
+		"grace-period": gracePeriod.String(),
+	})
+
+	sLog.Info("stopping")
+
+	if from == StatePaused {
+		err := c.cgroupsManager.Set("freezer", "freezer.state", freezerThawed)
+		if err != nil {
+			sLog.Error("failed-to-thaw", err)
+			c.revert(StateStopping, from)
+			return err
+		}
+
+		c.stopPhase(sLog, "thaw", map[string]string{})
+
+		// it is no longer paused, whether or not stopping succeeds
+		from = StateActive
+	}
+
+	err = c.stopProcesses(sLog, gracePeriod)
+	if err != nil {
+		sLog.Error("failed", err)
+		c.transition(from)
+		return err
+	}
+
+	c.stopOomNotifier()
+	c.stopPidsWatcher()
+	c.stopMemoryEvents()
+
+	c.transition(StateStopped)
+
+	sLog.Info("stopped")
+
+	c.emit(event_hub.Stopped, map[string]string{
+		"kill": strconv.FormatBool(gracePeriod == 0),
+	})
+
+	return nil
+}
+
+func (c *LinuxContainer) stopProcesses(logger lager.Logger, gracePeriod time.Duration) error {
+	pids, err := c.containerPIDs()
+	if err != nil {
+		return err
+	}
+
+	if gracePeriod > 0 && len(pids) > 0 {
+		c.stopPhase(logger, "terminate", map[string]string{
+			"processes":    strconv.Itoa(len(pids)),
+			"grace_period": gracePeriod.String(),
+		})
+
+		c.hostKill(logger, syscall.SIGTERM, pids)
+
+		pids, err = c.waitForExit(gracePeriod, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(pids) > 0 {
+		c.stopPhase(logger, "kill", map[string]string{
+			"processes": strconv.Itoa(len(pids)),
+		})
+
+		// anything forked before the KILL landed is killed on the next round
+		pids, err = c.waitForExit(c.timings.stopKillTimeout(), func(pids []int) {
+			c.hostKill(logger, syscall.SIGKILL, pids)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(pids) > 0 {
+		c.stopPhase(logger, "not-empty", map[string]string{
+			"processes": strconv.Itoa(len(pids)),
+		})
+
+		return CgroupNotEmptyError{PIDs: pids}
+	}
+
+	c.stopPhase(logger, "empty", map[string]string{})
+
+	return nil
+}
+
+// waitForExit reads the container's cgroup until there is nothing left in it
+// or the timeout passes, calling each with the processes still there, and
+// returns those left at the end.
+func (c *LinuxContainer) waitForExit(timeout time.Duration, each func([]int)) ([]int, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		pids, err := c.containerPIDs()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pids) == 0 || !time.Now().Before(deadline) {
+			return pids, nil
+		}
+
+		if each != nil {
+			each(pids)
+		}
+
+		time.Sleep(c.timings.stopPollInterval())
+	}
+}
+
+func (c *LinuxContainer) stopPhase(logger lager.Logger, phase string, data map[string]string) {
+	logData := lager.Data{}
+	for key, value := range data {
+		logData[key] = value
+	}
+
+	logger.Info(phase, logData)
+
+	data["phase"] = phase
+
+	c.emit(event_hub.StopPhase, data)
+}
+
+// containerPIDs returns the host PIDs of the processes in the container's
+// cgroup, other than wshd.
+func (c *LinuxContainer) containerPIDs() ([]int, error) {
+	procs, err := c.cgroupsManager.Get("cpu", "cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+
+	wshdPID := c.wshdPID()
+
+	pids := []int{}
+	for _, field := range strings.Fields(procs) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if pid != wshdPID {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// wshdPID is the host PID of the container's wshd, or 0 if it is not
+// running.
+func (c *LinuxContainer) wshdPID() int {
+	contents, err := ioutil.ReadFile(path.Join(c.path, "run", "wshd.pid"))
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// hostKill signals the processes from the host. Some may have exited since
+// the cgroup was read, so failing is only logged.
+func (c *LinuxContainer) hostKill(logger lager.Logger, signal syscall.Signal, pids []int) {
+	args := []string{fmt.Sprintf("-%d", signal)}
+	for _, pid := range pids {
+		args = append(args, strconv.Itoa(pid))
+	}
+
+	err := c.runner.Run(exec.Command("kill", args...))
+	if err != nil {
+		logger.Info("failed-to-signal", lager.Data{
+			"signal": signal.String(),
+			"error":  err.Error(),
+		})
+	}
+}
+
+// stopGracePeriod is the container's grace period property, or the default
+// if it has none or it is invalid.
+func (c *LinuxContainer) stopGracePeriod() time.Duration {
+	c.propertiesMutex.RLock()
+	value, found := c.properties[StopGracePeriodProperty]
+	c.propertiesMutex.RUnlock()
+
+	if !found {
+		return c.timings.stopGracePeriod()
+	}
+
+	gracePeriod, err := parseStopGracePeriod(value)
+	if err != nil {
+		c.logger.Error("invalid-stop-grace-period", err)
+		return c.timings.stopGracePeriod()
+	}
+
+	return gracePeriod
+}
+
+func parseStopGracePeriod(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, InvalidLimitPropertyError{Key: StopGracePeriodProperty, Value: value}
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
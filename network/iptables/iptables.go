@@ -327,5 +327,93 @@ const (
 type Type string
 
 const (
-	Nat Type = "nat"
+	Nat    Type = "nat"
+	Filter Type = "filter"
 )
+
+// ListChains lists the user-defined chains in table whose names start with
+// prefix.
+func ListChains(runner command_runner.CommandRunner, table Type, prefix string) ([]string, error) {
+	rules, err := listRules(runner, table)
+	if err != nil {
+		return nil, err
+	}
+
+	chains := []string{}
+	for _, rule := range rules {
+		if len(rule) == 2 && rule[0] == "-N" && strings.HasPrefix(rule[1], prefix) {
+			chains = append(chains, rule[1])
+		}
+	}
+
+	return chains, nil
+}
+
+// DeleteChain removes every rule in table which jumps to the named chain, and
+// then flushes and deletes the chain itself.
+func DeleteChain(runner command_runner.CommandRunner, table Type, name string) error {
+	rules, err := listRules(runner, table)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if len(rule) < 2 || rule[0] != "-A" || !jumpsTo(rule, name) {
+			continue
+		}
+
+		args := append([]string{"-w", "-t", string(table), "-D"}, rule[1:]...)
+		if err := runIptables(runner, args...); err != nil {
+			return err
+		}
+	}
+
+	if err := runIptables(runner, "-w", "-t", string(table), "-F", name); err != nil {
+		return err
+	}
+
+	return runIptables(runner, "-w", "-t", string(table), "-X", name)
+}
+
+func listRules(runner command_runner.CommandRunner, table Type) ([][]string, error) {
+	var stdout bytes.Buffer
+
+	cmd := exec.Command("/sbin/iptables", "-w", "-t", string(table), "-S")
+	cmd.Stdout = &stdout
+
+	if err := runner.Run(cmd); err != nil {
+		return nil, fmt.Errorf("iptables: listing rules in table %s: %v", table, err)
+	}
+
+	rules := [][]string{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			rules = append(rules, fields)
+		}
+	}
+
+	return rules, nil
+}
+
+func jumpsTo(rule []string, chain string) bool {
+	for i := 0; i < len(rule)-1; i++ {
+		if (rule[i] == "-j" || rule[i] == "-g") && rule[i+1] == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runIptables(runner command_runner.CommandRunner, args ...string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("/sbin/iptables", args...)
+	cmd.Stderr = &stderr
+
+	if err := runner.Run(cmd); err != nil {
+		return fmt.Errorf("iptables: %v, %v", err, stderr.String())
+	}
+
+	return nil
+}
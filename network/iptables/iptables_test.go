@@ -699,4 +699,103 @@ var _ = Describe("Iptables", func() {
 			})
 		})
 	})
+
+	Describe("ListChains", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "filter", "-S"},
+				},
+				func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte("-P INPUT ACCEPT\n-N w-0-instance-abc\n-N w-0-instance-abc-log\n-N w-0-default\n-A w-0-forward -i w0b-1 -g w-0-instance-abc\n"))
+					return nil
+				})
+		})
+
+		It("lists the chains in the table with the given prefix", func() {
+			Expect(ListChains(fakeRunner, Filter, "w-0-instance-")).To(Equal([]string{"w-0-instance-abc", "w-0-instance-abc-log"}))
+		})
+
+		Context("when listing the rules fails", func() {
+			BeforeEach(func() {
+				fakeRunner = fake_command_runner.New()
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{Path: "/sbin/iptables"},
+					func(cmd *exec.Cmd) error {
+						return errors.New("oh no")
+					})
+			})
+
+			It("returns an error", func() {
+				_, err := ListChains(fakeRunner, Filter, "w-0-instance-")
+				Expect(err).To(MatchError("iptables: listing rules in table filter: oh no"))
+			})
+		})
+	})
+
+	Describe("DeleteChain", func() {
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+
+		BeforeEach(func() {
+			fakeRunner = fake_command_runner.New()
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-S"},
+				},
+				func(cmd *exec.Cmd) error {
+					cmd.Stdout.Write([]byte("-N w-0-prerouting\n-N w-0-instance-abc\n-A w-0-prerouting -j w-0-instance-abc\n-A w-0-prerouting -j w-0-instance-abcd\n-A w-0-instance-abc -p tcp -j DNAT --to-destination 10.0.0.2:8080\n"))
+					return nil
+				})
+		})
+
+		It("removes the jumps to the chain before flushing and deleting it", func() {
+			Expect(DeleteChain(fakeRunner, Nat, "w-0-instance-abc")).To(Succeed())
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-S"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-D", "w-0-prerouting", "-j", "w-0-instance-abc"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-F", "w-0-instance-abc"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-X", "w-0-instance-abc"},
+				}))
+
+			Expect(fakeRunner).ToNot(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-D", "w-0-prerouting", "-j", "w-0-instance-abcd"},
+				}))
+		})
+
+		Context("when deleting the chain fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "nat", "-X", "w-0-instance-abc"},
+					},
+					func(cmd *exec.Cmd) error {
+						cmd.Stderr.Write([]byte("chain is busy"))
+						return errors.New("exit status 1")
+					})
+			})
+
+			It("returns a wrapped error, including stderr", func() {
+				Expect(DeleteChain(fakeRunner, Nat, "w-0-instance-abc")).To(MatchError("iptables: exit status 1, chain is busy"))
+			})
+		})
+	})
 })
@@ -2,7 +2,9 @@ package cgroups_manager
 
 import (
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -31,3 +33,38 @@ func (m *ContainerCgroupsManager) Get(subsystem, name string) (string, error) {
 func (m *ContainerCgroupsManager) SubsystemPath(subsystem string) string {
 	return path.Join(m.cgroupsPath, subsystem, "instance-"+m.containerID)
 }
+
+// Destroy removes the container's cgroup in the subsystem, along with any
+// cgroups nested beneath it. Cgroups can only be removed once they have no
+// tasks, so this is only useful once the container's processes are gone.
+func (m *ContainerCgroupsManager) Destroy(subsystem string) error {
+	dirs := []string{}
+
+	err := filepath.Walk(m.SubsystemPath(subsystem), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		}
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// deepest first, as a cgroup cannot be removed while it has children
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
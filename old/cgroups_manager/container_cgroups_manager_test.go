@@ -75,4 +75,27 @@ var _ = Describe("Container cgroups", func() {
 
 		})
 	})
+
+	Describe("destroying", func() {
+		It("removes the container's cgroup and any nested cgroups", func() {
+			containerMemoryCgroupsPath := path.Join(cgroupsPath, "memory", "instance-some-container-id")
+
+			err := os.MkdirAll(path.Join(containerMemoryCgroupsPath, "nested", "deeper"), 0755)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cgroupsManager.Destroy("memory")).To(Succeed())
+
+			_, err = os.Stat(containerMemoryCgroupsPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			_, err = os.Stat(path.Join(cgroupsPath, "memory"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when the cgroup does not exist", func() {
+			It("succeeds", func() {
+				Expect(cgroupsManager.Destroy("memory")).To(Succeed())
+			})
+		})
+	})
 })
@@ -2,6 +2,7 @@ package fake_cgroups_manager
 
 import (
 	"path"
+	"sync"
 )
 
 type FakeCgroupsManager struct {
@@ -13,6 +14,8 @@ type FakeCgroupsManager struct {
 	setValues    []SetValue
 	getCallbacks []GetCallback
 	setCallbacks []SetCallback
+
+	sync.RWMutex
 }
 
 type SetValue struct {
@@ -41,29 +44,41 @@ func New(cgroupsPath, id string) *FakeCgroupsManager {
 }
 
 func (m *FakeCgroupsManager) Set(subsystem, name, value string) error {
-	if m.SetError != nil {
-		return m.SetError
+	m.RLock()
+	setError := m.SetError
+	callbacks := m.setCallbacks
+	m.RUnlock()
+
+	if setError != nil {
+		return setError
 	}
 
-	for _, cb := range m.setCallbacks {
+	for _, cb := range callbacks {
 		if cb.Subsystem == subsystem && cb.Name == name {
 			return cb.Callback()
 		}
 	}
 
+	m.Lock()
 	m.setValues = append(m.setValues, SetValue{subsystem, name, value})
+	m.Unlock()
 
 	return nil
 }
 
 func (m *FakeCgroupsManager) Get(subsytem, name string) (string, error) {
-	for _, cb := range m.getCallbacks {
+	m.RLock()
+	callbacks := m.getCallbacks
+	setValues := m.setValues
+	m.RUnlock()
+
+	for _, cb := range callbacks {
 		if cb.Subsystem == subsytem && cb.Name == name {
 			return cb.Callback()
 		}
 	}
 
-	for _, val := range m.setValues {
+	for _, val := range setValues {
 		if val.Subsystem == subsytem && val.Name == name {
 			return val.Value, nil
 		}
@@ -77,13 +92,22 @@ func (m *FakeCgroupsManager) SubsystemPath(subsystem string) string {
 }
 
 func (m *FakeCgroupsManager) SetValues() []SetValue {
+	m.RLock()
+	defer m.RUnlock()
+
 	return m.setValues
 }
 
 func (m *FakeCgroupsManager) WhenGetting(subsystem, name string, callback func() (string, error)) {
+	m.Lock()
+	defer m.Unlock()
+
 	m.getCallbacks = append(m.getCallbacks, GetCallback{subsystem, name, callback})
 }
 
 func (m *FakeCgroupsManager) WhenSetting(subsystem, name string, callback func() error) {
+	m.Lock()
+	defer m.Unlock()
+
 	m.setCallbacks = append(m.setCallbacks, SetCallback{subsystem, name, callback})
 }
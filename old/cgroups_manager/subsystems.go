@@ -0,0 +1,32 @@
+package cgroups_manager
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// SubsystemEnabled reports whether the kernel has the cgroup subsystem and
+// it is enabled, according to procCgroupsPath, which is normally
+// /proc/cgroups. Some subsystems, such as pids before Linux 4.3, are not
+// always there.
+func SubsystemEnabled(procCgroupsPath, subsystem string) (bool, error) {
+	procCgroups, err := os.Open(procCgroupsPath)
+	if err != nil {
+		return false, err
+	}
+	defer procCgroups.Close()
+
+	// each line reads: subsys_name hierarchy num_cgroups enabled
+	scanner := bufio.NewScanner(procCgroups)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != subsystem {
+			continue
+		}
+
+		return fields[3] == "1", nil
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package cgroups_manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+)
+
+var _ = Describe("Checking for a subsystem", func() {
+	var tmpdir string
+	var procCgroupsPath string
+
+	BeforeEach(func() {
+		var err error
+		tmpdir, err = ioutil.TempDir(os.TempDir(), "proc-cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		procCgroupsPath = path.Join(tmpdir, "cgroups")
+
+		err = ioutil.WriteFile(procCgroupsPath, []byte(
+			"#subsys_name\thierarchy\tnum_cgroups\tenabled\n"+
+				"cpuset\t2\t4\t1\n"+
+				"memory\t3\t12\t1\n"+
+				"pids\t4\t1\t0\n",
+		), 0644)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	It("is enabled when the kernel lists it as enabled", func() {
+		Expect(cgroups_manager.SubsystemEnabled(procCgroupsPath, "memory")).To(BeTrue())
+	})
+
+	It("is not enabled when the kernel lists it as disabled", func() {
+		Expect(cgroups_manager.SubsystemEnabled(procCgroupsPath, "pids")).To(BeFalse())
+	})
+
+	It("is not enabled when the kernel does not list it", func() {
+		Expect(cgroups_manager.SubsystemEnabled(procCgroupsPath, "net_cls")).To(BeFalse())
+	})
+
+	Context("when the file cannot be read", func() {
+		It("returns an error", func() {
+			_, err := cgroups_manager.SubsystemEnabled(path.Join(tmpdir, "missing"), "memory")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
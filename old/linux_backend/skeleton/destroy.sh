@@ -23,6 +23,13 @@ then
 
   if [ -d $path ]
   then
+    # Thaw a paused container first, as frozen tasks cannot be reaped.
+    freezer_state=${cgroup_path}/freezer/instance-$id/freezer.state
+    if [ -f $freezer_state ]
+    then
+      echo THAWED > $freezer_state
+    fi
+
     # Kill the container's init pid; the kernel will reap all tasks.
     kill -9 $pid
 
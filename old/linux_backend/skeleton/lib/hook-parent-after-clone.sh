@@ -24,17 +24,37 @@ fi
 # Add new group for every subsystem
 
 # cpuset must be set up first, so that cpuset.cpus and cpuset.mems is assigned
-# otherwise adding the process to the subsystem's tasks will fail with ENOSPC
-for system_path in ${GARDEN_CGROUP_PATH}/{cpuset,cpu,cpuacct,devices,memory}
+# otherwise adding the process to the subsystem's tasks will fail with ENOSPC.
+# Containers pinned to their own cores are given them in cpuset_cpus and
+# cpuset_mems; the rest share the parent's.
+# Subsystems the kernel does not have, such as pids before Linux 4.3, are not
+# mounted, and are skipped.
+for system_path in ${GARDEN_CGROUP_PATH}/{cpuset,blkio,cpu,cpuacct,devices,freezer,memory,pids}
 do
+  if [ ! -d $system_path ]
+  then
+    continue
+  fi
+
   instance_path=$system_path/instance-$id
 
   mkdir -p $instance_path
 
   if [ $(basename $system_path) == "cpuset" ]
   then
-    cat $system_path/cpuset.cpus > $instance_path/cpuset.cpus
-    cat $system_path/cpuset.mems > $instance_path/cpuset.mems
+    if [ -n "${cpuset_mems:-}" ]
+    then
+      echo $cpuset_mems > $instance_path/cpuset.mems
+    else
+      cat $system_path/cpuset.mems > $instance_path/cpuset.mems
+    fi
+
+    if [ -n "${cpuset_cpus:-}" ]
+    then
+      echo $cpuset_cpus > $instance_path/cpuset.cpus
+    else
+      cat $system_path/cpuset.cpus > $instance_path/cpuset.cpus
+    fi
   fi
 
   if [ $(basename $system_path) == "devices" ]
@@ -129,6 +129,17 @@ case "${1}" in
     fi
     tc qdisc show dev ${network_host_iface}
 
+    ;;
+  "get_stats_info")
+    if [ -z "${ID:-}" ]; then
+      echo "Please specify container ID..." 1>&2
+      exit 1
+    fi
+
+    for stat in rx_bytes rx_packets rx_dropped tx_bytes tx_packets tx_dropped; do
+      echo "${stat} $(cat /sys/class/net/${network_host_iface}/statistics/${stat})"
+    done
+
     ;;
   *)
     echo "Unknown command: ${1}" 1>&2
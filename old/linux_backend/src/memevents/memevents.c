@@ -0,0 +1,178 @@
+#include <assert.h>
+#include <errno.h>
+#include <fcntl.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/eventfd.h>
+#include <sys/param.h>
+#include <sys/prctl.h>
+#include <sys/select.h>
+#include <sys/stat.h>
+#include <sys/types.h>
+#include <unistd.h>
+
+#define MAX_EVENTS 32
+
+/*
+ * Each event is registered with cgroup.event_control as
+ * "<event fd> <fd of the watched file> <argument>", where the watched file is
+ * memory.pressure_level for pressure events, with the level as argument, and
+ * memory.usage_in_bytes for threshold events, with the threshold as argument.
+ */
+struct event {
+  const char *kind;
+  const char *argument;
+  int event_fd;
+};
+
+int register_event(struct event *event, const char *cgroup_path, int event_control_fd) {
+  char watched_path[PATH_MAX];
+  size_t watched_path_len;
+  const char *watched_file;
+  int watched_fd;
+  char line[LINE_MAX];
+  size_t line_len;
+  int rv;
+
+  if (strcmp(event->kind, "pressure") == 0) {
+    watched_file = "memory.pressure_level";
+  } else if (strcmp(event->kind, "threshold") == 0) {
+    watched_file = "memory.usage_in_bytes";
+  } else {
+    fprintf(stderr, "unknown event: %s\n", event->kind);
+    return -1;
+  }
+
+  event->event_fd = eventfd(0, 0);
+  if (event->event_fd == -1) {
+    perror("eventfd");
+    return -1;
+  }
+
+  watched_path_len = snprintf(watched_path, sizeof(watched_path), "%s/%s", cgroup_path, watched_file);
+  assert(watched_path_len < sizeof(watched_path));
+
+  watched_fd = open(watched_path, O_RDONLY);
+  if (watched_fd == -1) {
+    perror("open");
+    return -1;
+  }
+
+  line_len = snprintf(line, sizeof(line), "%d %d %s\n", event->event_fd, watched_fd, event->argument);
+  assert(line_len < sizeof(line));
+
+  rv = write(event_control_fd, line, line_len);
+  if (rv == -1) {
+    perror("write");
+    return -1;
+  }
+
+  return 0;
+}
+
+int main(int argc, char **argv) {
+  struct event events[MAX_EVENTS];
+  int num_events = 0;
+  char event_control_path[PATH_MAX];
+  size_t event_control_path_len;
+  int event_control_fd = -1;
+  fd_set rfds;
+  int max_fd = -1;
+  uint64_t result;
+  char *separator;
+  int rv;
+  int i;
+
+  if (argc < 3 || argc - 2 > MAX_EVENTS) {
+    fprintf(stderr, "Usage: %s <path to cgroup> <pressure:level|threshold:bytes>...\n", argv[0]);
+    return 1;
+  }
+
+  /* Die when parent dies */
+  rv = prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
+  if (rv == -1) {
+    perror("prctl");
+    return 1;
+  }
+
+  /* Open event control file */
+  event_control_path_len = snprintf(event_control_path, sizeof(event_control_path), "%s/cgroup.event_control", argv[1]);
+  assert(event_control_path_len < sizeof(event_control_path));
+
+  event_control_fd = open(event_control_path, O_WRONLY);
+  if (event_control_fd == -1) {
+    perror("open");
+    return 1;
+  }
+
+  for (i = 2; i < argc; i++) {
+    separator = strchr(argv[i], ':');
+    if (separator == NULL) {
+      fprintf(stderr, "malformed event: %s\n", argv[i]);
+      return 1;
+    }
+
+    *separator = '\0';
+
+    events[num_events].kind = argv[i];
+    events[num_events].argument = separator + 1;
+
+    rv = register_event(&events[num_events], argv[1], event_control_fd);
+    if (rv == -1) {
+      return 1;
+    }
+
+    if (events[num_events].event_fd > max_fd) {
+      max_fd = events[num_events].event_fd;
+    }
+
+    num_events++;
+  }
+
+  /* Report each notification on its own line until the cgroup goes away */
+  for (;;) {
+    FD_ZERO(&rfds);
+
+    for (i = 0; i < num_events; i++) {
+      FD_SET(events[i].event_fd, &rfds);
+    }
+
+    do {
+      rv = select(max_fd + 1, &rfds, NULL, NULL, NULL);
+    } while (rv == -1 && errno == EINTR);
+
+    if (rv == -1) {
+      perror("select");
+      return 1;
+    }
+
+    for (i = 0; i < num_events; i++) {
+      if (!FD_ISSET(events[i].event_fd, &rfds)) {
+        continue;
+      }
+
+      do {
+        rv = read(events[i].event_fd, &result, sizeof(result));
+      } while (rv == -1 && errno == EINTR);
+
+      if (rv == -1) {
+        perror("read");
+        return 1;
+      }
+
+      assert(rv == sizeof(result));
+
+      /* Check if the event_fd triggered because the cgroup was removed */
+      rv = access(event_control_path, W_OK);
+      if (rv == -1) {
+        perror("access");
+        return 1;
+      }
+
+      printf("%s %s\n", events[i].kind, events[i].argument);
+      fflush(stdout);
+    }
+  }
+}
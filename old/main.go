@@ -5,12 +5,14 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/gunk/command_runner"
 	"github.com/cloudfoundry/gunk/localip"
@@ -21,17 +23,25 @@ import (
 	"github.com/docker/docker/registry"
 	"github.com/pivotal-golang/clock"
 	"github.com/pivotal-golang/lager"
+	"github.com/tedsuo/ifrit"
 
 	"github.com/cloudfoundry-incubator/cf-debug-server"
 	"github.com/cloudfoundry-incubator/cf-lager"
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	"github.com/cloudfoundry-incubator/garden-linux/capacity"
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/cpuset_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/drift"
+	"github.com/cloudfoundry-incubator/garden-linux/event_hub"
+	"github.com/cloudfoundry-incubator/garden-linux/health"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
 	"github.com/cloudfoundry-incubator/garden-linux/network/devices"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
 	"github.com/cloudfoundry-incubator/garden-linux/network/subnets"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager"
 	"github.com/cloudfoundry-incubator/garden-linux/old/repository_fetcher"
@@ -60,12 +70,84 @@ var listenAddr = flag.String(
 	"address to listen on",
 )
 
+var adminListenNetwork = flag.String(
+	"adminListenNetwork",
+	"unix",
+	"how to listen on the admin address (unix, tcp, etc.)",
+)
+
+var adminListenAddr = flag.String(
+	"adminListenAddr",
+	"",
+	"address to serve the admin API on (disabled if empty)",
+)
+
 var snapshotsPath = flag.String(
 	"snapshots",
 	"",
 	"directory in which to store container state to persist through restarts",
 )
 
+var maxConcurrentRestores = flag.Int(
+	"maxConcurrentRestores",
+	8,
+	"maximum number of containers to restore from snapshots at once on start",
+)
+
+var maxConcurrentBulkOperations = flag.Int(
+	"maxConcurrentBulkOperations",
+	8,
+	"maximum number of containers queried at once when getting info or metrics in bulk, or sampling metrics",
+)
+
+var bulkOperationTimeout = flag.Duration(
+	"bulkOperationTimeout",
+	10*time.Second,
+	"time after which getting a single container's info or metrics in bulk, or sampling its metrics, is reported as failed",
+)
+
+var overcommitRatio = flag.Float64(
+	"overcommitRatio",
+	0,
+	"multiple of the host's memory, disk and CPU shares that may be committed to containers through their limits (0 to allow any amount)",
+)
+
+var drainTimeout = flag.Duration(
+	"drainTimeout",
+	30*time.Second,
+	"time to wait on shutdown for in-flight container creates and destroys to finish before saving snapshots",
+)
+
+var eventBufferSize = flag.Int(
+	"eventBufferSize",
+	1024,
+	"number of container events buffered for each subscriber before it is dropped for falling behind",
+)
+
+var metricsSampleInterval = flag.Duration(
+	"metricsSampleInterval",
+	10*time.Second,
+	"interval at which every container's metrics are sampled to compute rates (0 to disable sampling)",
+)
+
+var metricsHistorySize = flag.Int(
+	"metricsHistorySize",
+	60,
+	"number of metrics samples kept for each container",
+)
+
+var healthCheckTimeout = flag.Duration(
+	"healthCheckTimeout",
+	5*time.Second,
+	"time after which a health check that has not finished is considered failed",
+)
+
+var driftGracePeriod = flag.Duration(
+	"driftGracePeriod",
+	time.Minute,
+	"age below which a depot entry missing from the container repository is assumed to be still being created, rather than orphaned",
+)
+
 var binPath = flag.String(
 	"bin",
 	"",
@@ -114,6 +196,12 @@ var portPoolSize = flag.Uint(
 	"size of port pool used for mapped container ports",
 )
 
+var cpusetPoolCores = flag.String(
+	"cpusetPoolCores",
+	"",
+	"cores, e.g. 2-7, handed out exclusively to containers created with the garden.linux.cpuset-cores property (empty to disable pinning)",
+)
+
 var uidMappingOffset = flag.Int(
 	"uidMappingOffset",
 	600000,
@@ -234,6 +322,18 @@ func Main() {
 	// TODO: use /proc/sys/net/ipv4/ip_local_port_range by default (end + 1)
 	portPool := port_pool.New(uint32(*portPoolStart), uint32(*portPoolSize))
 
+	pinnableCores, err := cpuset_pool.ParseList(*cpusetPoolCores)
+	if err != nil {
+		logger.Fatal("invalid-cpuset-pool-cores", err)
+	}
+
+	cpusetCores, err := cpuset_pool.Discover("/sys/devices/system/node", pinnableCores)
+	if err != nil {
+		logger.Fatal("failed-to-discover-cpuset-pool-numa-nodes", err)
+	}
+
+	cpusetPool := cpuset_pool.New(cpusetCores)
+
 	useKernelLogging := true
 	switch *iptablesLogMethod {
 	case "nflog":
@@ -301,8 +401,10 @@ func Main() {
 		logger.Fatal("failed-to-construct-docker-rootfs-provider", err)
 	}
 
+	overlayRootFSProvider := rootfs_provider.NewOverlay(*binPath, *overlaysPath, *rootFSPath, runner)
+
 	rootFSProviders := map[string]rootfs_provider.RootFSProvider{
-		"":       rootfs_provider.NewOverlay(*binPath, *overlaysPath, *rootFSPath, runner),
+		"":       overlayRootFSProvider,
 		"docker": dockerRootFSProvider,
 	}
 
@@ -327,6 +429,15 @@ func Main() {
 		panic(fmt.Sprintf("Value of -externalIP %s could not be converted to an IP", *externalIP))
 	}
 
+	eventHub := event_hub.New(logger, *eventBufferSize)
+
+	systemInfo := system_info.NewProvider(*depotPath)
+
+	ledger := capacity.NewLedger(systemInfo, uint64(runtime.NumCPU())*1024, *overcommitRatio, cpusetPool)
+
+	bridgePrefix := "w" + config.Tag + "b-"
+	bridges := bridgemgr.New(bridgePrefix, &devices.Bridge{}, &devices.Link{})
+
 	pool := container_pool.New(
 		logger,
 		*binPath,
@@ -337,19 +448,80 @@ func Main() {
 		parsedExternalIP,
 		*mtu,
 		subnetPool,
-		bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{}),
+		bridges,
 		filterProvider,
 		iptables.NewGlobalChain(config.IPTables.Filter.DefaultChain, runner, logger.Session("global-chain")),
 		portPool,
+		cpusetPool,
 		strings.Split(*denyNetworks, ","),
 		strings.Split(*allowNetworks, ","),
 		runner,
 		quotaManager,
+		eventHub,
+		ledger,
 	)
 
-	systemInfo := system_info.NewProvider(*depotPath)
+	var containerRepo linux_backend.ContainerRepository = container_repository.New()
+	if *snapshotsPath != "" {
+		containerRepo = container_repository.NewPersistent(logger, *snapshotsPath)
+	}
+
+	cgroupSubsystems := []string{"blkio", "cpu", "cpuacct", "cpuset", "devices", "freezer", "memory"}
+
+	// the pids subsystem only arrived in Linux 4.3
+	pidsEnabled, err := cgroups_manager.SubsystemEnabled("/proc/cgroups", "pids")
+	if err != nil {
+		logger.Fatal("failed-to-check-cgroup-subsystems", err)
+	}
+
+	if pidsEnabled {
+		cgroupSubsystems = append(cgroupSubsystems, "pids")
+	}
+
+	healthChecker := health.NewChecker(logger, *healthCheckTimeout,
+		health.NamedCheck{Name: "depot", Check: health.NewDepotCheck(*depotPath)},
+		health.NamedCheck{Name: "cgroups", Check: health.NewCgroupsCheck(config.CgroupPath, cgroupSubsystems)},
+		health.NamedCheck{Name: "iptables", Check: health.NewIPTablesCheck(runner, []health.IPTablesChain{
+			{Table: "filter", Name: config.IPTables.Filter.InputChain},
+			{Table: "filter", Name: config.IPTables.Filter.ForwardChain},
+			{Table: "filter", Name: config.IPTables.Filter.DefaultChain},
+			{Table: "nat", Name: config.IPTables.NAT.PreroutingChain},
+			{Table: "nat", Name: config.IPTables.NAT.PostroutingChain},
+		})},
+		health.NamedCheck{Name: "bridges", Check: health.NewBridgeListerCheck(&devices.Link{})},
+		health.NamedCheck{Name: "rootfs-providers", Check: health.NewRootFSProvidersCheck(rootFSProviders)},
+	)
+
+	var metricsSampler *linux_backend.MetricsSampler
+	if *metricsSampleInterval > 0 {
+		metricsSampler = linux_backend.NewMetricsSampler(logger, containerRepo, clock.NewClock(), *metricsSampleInterval, *metricsHistorySize, *maxConcurrentBulkOperations, *bulkOperationTimeout)
+	}
 
-	backend := linux_backend.New(logger, pool, container_repository.New(), systemInfo, *snapshotsPath)
+	backend := linux_backend.New(logger, pool, containerRepo, eventHub, healthChecker, systemInfo, *snapshotsPath, *maxConcurrentRestores, *maxConcurrentBulkOperations, *bulkOperationTimeout, ledger, metricsSampler)
+
+	driftAuditor := drift.New(
+		logger,
+		containerRepo,
+		backend,
+		backend,
+		*depotPath,
+		config.CgroupPath,
+		cgroupSubsystems,
+		*overlaysPath,
+		config.IPTables.Filter.InstancePrefix,
+		bridgePrefix,
+		runner,
+		&devices.Link{},
+		pool,
+		bridges,
+		filterProvider,
+		overlayRootFSProvider,
+		*driftGracePeriod,
+	)
+
+	if *adminListenAddr != "" {
+		runAdminServer(logger, *adminListenNetwork, *adminListenAddr, backend, driftAuditor)
+	}
 
 	err = backend.Setup()
 	if err != nil {
@@ -365,10 +537,25 @@ func Main() {
 		logger.Fatal("failed-to-start-server", err)
 	}
 
+	// started once the server has restored the snapshotted containers
+	if metricsSampler != nil {
+		ifrit.Invoke(metricsSampler)
+	}
+
 	signals := make(chan os.Signal, 1)
 
 	go func() {
 		<-signals
+
+		err := backend.Drain(*drainTimeout)
+		if err != nil {
+			// stopping the server would wait for the stuck operations, so
+			// just save what can be saved
+			logger.Error("failed-to-drain", err)
+			backend.Stop()
+			os.Exit(1)
+		}
+
 		gardenServer.Stop()
 		os.Exit(0)
 	}()
@@ -400,6 +587,40 @@ func getMountPoint(logger lager.Logger, depotPath string) string {
 	return strings.Trim(dfOutputWords[len(dfOutputWords)-1], "\n")
 }
 
+// runAdminServer serves the admin API on its own listener, so that it is
+// only reachable by whoever can reach that address; with the default unix
+// network that is whoever can write to the socket.
+func runAdminServer(logger lager.Logger, network, address string, backend *linux_backend.LinuxBackend, auditor *drift.Auditor) {
+	adminHandler, err := admin.New(logger, backend, auditor)
+	if err != nil {
+		logger.Fatal("failed-to-construct-admin-handler", err)
+	}
+
+	if network == "unix" {
+		err := os.Remove(address)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Fatal("failed-to-remove-stale-admin-socket", err)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		logger.Fatal("failed-to-listen-for-admin-api", err)
+	}
+
+	if network == "unix" {
+		err := os.Chmod(address, 0700)
+		if err != nil {
+			logger.Fatal("failed-to-restrict-admin-socket", err)
+		}
+	}
+
+	go func() {
+		err := http.Serve(listener, adminHandler)
+		logger.Error("admin-server-stopped", err)
+	}()
+}
+
 func missing(flagName string) {
 	println("missing " + flagName)
 	println()
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"os"
 	"os/exec"
 	"path"
 	"strings"
@@ -80,3 +81,23 @@ func (provider *overlayRootFSProvider) CleanupRootFS(logger lager.Logger, id str
 
 	return pRunner.Run(destroyOverlay)
 }
+
+func (provider *overlayRootFSProvider) HealthCheck() error {
+	paths := []string{provider.overlaysPath}
+	if provider.defaultRootFS != "" {
+		paths = append(paths, provider.defaultRootFS)
+	}
+
+	for _, dir := range paths {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+
+	return nil
+}
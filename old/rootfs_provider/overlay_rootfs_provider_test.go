@@ -2,7 +2,10 @@ package rootfs_provider_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path"
 
 	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
 	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
@@ -122,4 +125,49 @@ var _ = Describe("OverlayRootfsProvider", func() {
 			})
 		})
 	})
+
+	Describe("HealthCheck", func() {
+		var overlaysPath, defaultRootFS string
+
+		BeforeEach(func() {
+			var err error
+
+			overlaysPath, err = ioutil.TempDir("", "overlays")
+			Expect(err).ToNot(HaveOccurred())
+
+			defaultRootFS, err = ioutil.TempDir("", "rootfs")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(overlaysPath)
+			os.RemoveAll(defaultRootFS)
+		})
+
+		It("succeeds when the overlays path and default rootfs are present", func() {
+			provider := NewOverlay("/some/bin/path", overlaysPath, defaultRootFS, fakeRunner)
+			Expect(provider.(HealthChecker).HealthCheck()).To(Succeed())
+		})
+
+		Context("when the overlays path is missing", func() {
+			It("fails", func() {
+				provider := NewOverlay("/some/bin/path", path.Join(overlaysPath, "missing"), defaultRootFS, fakeRunner)
+				Expect(provider.(HealthChecker).HealthCheck()).ToNot(Succeed())
+			})
+		})
+
+		Context("when the default rootfs is missing", func() {
+			It("fails", func() {
+				provider := NewOverlay("/some/bin/path", overlaysPath, path.Join(defaultRootFS, "missing"), fakeRunner)
+				Expect(provider.(HealthChecker).HealthCheck()).ToNot(Succeed())
+			})
+		})
+
+		Context("when there is no default rootfs", func() {
+			It("succeeds", func() {
+				provider := NewOverlay("/some/bin/path", overlaysPath, "", fakeRunner)
+				Expect(provider.(HealthChecker).HealthCheck()).To(Succeed())
+			})
+		})
+	})
 })
@@ -12,3 +12,9 @@ type RootFSProvider interface {
 	ProvideRootFS(logger lager.Logger, id string, rootfs *url.URL, namespaced bool) (mountpoint string, envvar process.Env, err error)
 	CleanupRootFS(logger lager.Logger, id string) error
 }
+
+// A HealthChecker is a RootFSProvider that can tell whether it is currently
+// able to provide root filesystems.
+type HealthChecker interface {
+	HealthCheck() error
+}
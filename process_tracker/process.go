@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"path"
 	"sync"
-	"syscall"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry/gunk/command_runner"
@@ -30,6 +29,10 @@ type Process struct {
 	exitStatus int
 	exitErr    error
 
+	// untrack is called once the process has exited, before anything waiting
+	// on it is woken
+	untrack func()
+
 	stdin  writer.FanIn
 	stdout writer.FanOut
 	stderr writer.FanOut
@@ -39,6 +42,13 @@ type Process struct {
 
 type Signaller interface {
 	Signal(os.Signal) error
+
+	// SignalGroup sends the signal to every process in the process's group.
+	SignalGroup(os.Signal) error
+
+	// SignalTree sends the signal to the process and all of its
+	// descendants, wherever they are.
+	SignalTree(os.Signal) error
 }
 
 func NewProcess(
@@ -86,15 +96,41 @@ func (p *Process) SetTTY(tty garden.TTYSpec) error {
 	return nil
 }
 
+// Signal sends the signal to the process. Kill is sent to its descendants
+// too, so that none are left behind.
 func (p *Process) Signal(s garden.Signal) error {
-	switch s {
-	case garden.SignalKill:
-		return p.signaller.Signal(os.Kill)
-	case garden.SignalTerminate:
-		return p.signaller.Signal(syscall.SIGTERM)
-	default:
-		return fmt.Errorf("process_tracker: failed to send signal: unknown signal: %d", s)
+	signal, err := osSignal(s)
+	if err != nil {
+		return err
+	}
+
+	if s == garden.SignalKill {
+		return p.signaller.SignalTree(signal)
+	}
+
+	return p.signaller.Signal(signal)
+}
+
+// SignalGroup sends the signal to the process and every other process in
+// its group, such as the children of a shell which has not exec'd.
+func (p *Process) SignalGroup(s garden.Signal) error {
+	signal, err := osSignal(s)
+	if err != nil {
+		return err
+	}
+
+	return p.signaller.SignalGroup(signal)
+}
+
+// SignalTree sends the signal to the process and all of its descendants,
+// including those which have started groups or sessions of their own.
+func (p *Process) SignalTree(s garden.Signal) error {
+	signal, err := osSignal(s)
+	if err != nil {
+		return err
 	}
+
+	return p.signaller.SignalTree(signal)
 }
 
 func (p *Process) Spawn(cmd *exec.Cmd, tty *garden.TTYSpec) (ready, active chan error) {
@@ -207,5 +243,10 @@ func (p *Process) runLinker() {
 func (p *Process) completed(exitStatus int, err error) {
 	p.exitStatus = exitStatus
 	p.exitErr = err
+
+	if p.untrack != nil {
+		p.untrack()
+	}
+
 	close(p.exited)
 }
@@ -44,8 +44,7 @@ func New(containerPath string, runner command_runner.CommandRunner) ProcessTrack
 
 func (t *processTracker) Run(processID uint32, cmd *exec.Cmd, processIO garden.ProcessIO, tty *garden.TTYSpec, signaller Signaller) (garden.Process, error) {
 	t.processesMutex.Lock()
-	process := NewProcess(processID, t.containerPath, t.runner, signaller)
-	t.processes[processID] = process
+	process := t.track(processID, signaller)
 	t.processesMutex.Unlock()
 
 	ready, active := process.Spawn(cmd, tty)
@@ -86,9 +85,7 @@ func (t *processTracker) Attach(processID uint32, processIO garden.ProcessIO) (g
 func (t *processTracker) Restore(processID uint32, signaller Signaller) {
 	t.processesMutex.Lock()
 
-	process := NewProcess(processID, t.containerPath, t.runner, signaller)
-
-	t.processes[processID] = process
+	t.track(processID, signaller)
 
 	go t.link(processID)
 
@@ -119,16 +116,25 @@ func (t *processTracker) link(processID uint32) {
 		return
 	}
 
-	defer t.unregister(processID)
-
 	process.Link()
+}
+
+// track registers a new process, which is unregistered as soon as it exits
+// so that it is never listed as active by the time Wait returns.
+func (t *processTracker) track(processID uint32, signaller Signaller) *Process {
+	process := NewProcess(processID, t.containerPath, t.runner, signaller)
+	process.untrack = func() { t.unregister(process) }
 
-	return
+	t.processes[processID] = process
+
+	return process
 }
 
-func (t *processTracker) unregister(processID uint32) {
+func (t *processTracker) unregister(process *Process) {
 	t.processesMutex.Lock()
 	defer t.processesMutex.Unlock()
 
-	delete(t.processes, processID)
+	if t.processes[process.ID()] == process {
+		delete(t.processes, process.ID())
+	}
 }
@@ -57,6 +57,16 @@ var _ = Describe("Running processes", func() {
 		Expect(process.Wait()).To(Equal(42))
 	})
 
+	It("stops tracking the process by the time waiting for it returns", func() {
+		cmd := exec.Command("bash", "-c", "exit 0")
+
+		process, err := processTracker.Run(55, cmd, garden.ProcessIO{}, nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(process.Wait()).To(Equal(0))
+		Expect(processTracker.ActiveProcesses()).To(BeEmpty())
+	})
+
 	Describe("signalling a running process", func() {
 		var (
 			process   garden.Process
@@ -72,9 +82,10 @@ var _ = Describe("Running processes", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("kills the process with a kill signal", func() {
+		It("kills the process and its descendants with a kill signal", func() {
 			Expect(process.Signal(garden.SignalKill)).To(Succeed())
-			Expect(signaller.sent).To(Equal([]os.Signal{os.Kill}))
+			Expect(signaller.sentToTree).To(Equal([]os.Signal{os.Kill}))
+			Expect(signaller.sent).To(BeNil())
 		})
 
 		It("kills the process with a terminate signal", func() {
@@ -82,10 +93,84 @@ var _ = Describe("Running processes", func() {
 			Expect(signaller.sent).To(Equal([]os.Signal{syscall.SIGTERM}))
 		})
 
+		It("sends the other standard signals", func() {
+			Expect(process.Signal(process_tracker.SignalHangup)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalUser1)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalUser2)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalInterrupt)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalQuit)).To(Succeed())
+			Expect(process.Signal(process_tracker.SignalBadSystemCall)).To(Succeed())
+
+			Expect(signaller.sent).To(Equal([]os.Signal{
+				syscall.SIGHUP,
+				syscall.SIGUSR1,
+				syscall.SIGUSR2,
+				syscall.SIGINT,
+				syscall.SIGQUIT,
+				syscall.SIGSYS,
+			}))
+		})
+
 		It("errors when an unsupported signal is sent", func() {
 			Expect(process.Signal(garden.Signal(999))).To(MatchError(HaveSuffix("failed to send signal: unknown signal: 999")))
 			Expect(signaller.sent).To(BeNil())
 		})
+
+		Describe("signalling its process group", func() {
+			It("sends the signal to the group", func() {
+				Expect(process.(*process_tracker.Process).SignalGroup(process_tracker.SignalHangup)).To(Succeed())
+				Expect(signaller.sentToGroup).To(Equal([]os.Signal{syscall.SIGHUP}))
+				Expect(signaller.sent).To(BeNil())
+			})
+
+			It("errors when an unsupported signal is sent", func() {
+				Expect(process.(*process_tracker.Process).SignalGroup(garden.Signal(999))).To(MatchError(HaveSuffix("failed to send signal: unknown signal: 999")))
+				Expect(signaller.sentToGroup).To(BeNil())
+			})
+		})
+
+		Describe("signalling its process tree", func() {
+			It("sends the signal to the tree", func() {
+				Expect(process.(*process_tracker.Process).SignalTree(process_tracker.SignalQuit)).To(Succeed())
+				Expect(signaller.sentToTree).To(Equal([]os.Signal{syscall.SIGQUIT}))
+				Expect(signaller.sent).To(BeNil())
+			})
+
+			It("errors when an unsupported signal is sent", func() {
+				Expect(process.(*process_tracker.Process).SignalTree(garden.Signal(999))).To(MatchError(HaveSuffix("failed to send signal: unknown signal: 999")))
+				Expect(signaller.sentToTree).To(BeNil())
+			})
+		})
+	})
+
+	Describe("parsing signals", func() {
+		It("accepts names, with or without the SIG prefix and in any case", func() {
+			Expect(process_tracker.ParseSignal("HUP")).To(Equal(process_tracker.SignalHangup))
+			Expect(process_tracker.ParseSignal("SIGUSR1")).To(Equal(process_tracker.SignalUser1))
+			Expect(process_tracker.ParseSignal("sigterm")).To(Equal(garden.SignalTerminate))
+			Expect(process_tracker.ParseSignal("kill")).To(Equal(garden.SignalKill))
+		})
+
+		It("accepts numbers", func() {
+			Expect(process_tracker.ParseSignal("3")).To(Equal(process_tracker.SignalQuit))
+			Expect(process_tracker.ParseSignal("9")).To(Equal(garden.SignalKill))
+		})
+
+		It("rejects anything else", func() {
+			_, err := process_tracker.ParseSignal("SIGBOGUS")
+			Expect(err).To(Equal(process_tracker.UnknownSignalError{Signal: "SIGBOGUS"}))
+
+			_, err = process_tracker.ParseSignal("64")
+			Expect(err).To(Equal(process_tracker.UnknownSignalError{Signal: "64"}))
+		})
+
+		It("names each signal", func() {
+			Expect(process_tracker.SignalName(process_tracker.SignalUser2)).To(Equal("USR2"))
+			Expect(process_tracker.SignalName(garden.SignalTerminate)).To(Equal("TERM"))
+
+			_, err := process_tracker.SignalName(garden.Signal(999))
+			Expect(err).To(Equal(process_tracker.UnknownSignalError{Signal: "999"}))
+		})
 	})
 
 	It("streams the process's stdout and stderr", func() {
@@ -268,8 +353,8 @@ var _ = Describe("Restoring processes", func() {
 		activeProcesses := processTracker.ActiveProcesses()
 		Expect(activeProcesses).To(HaveLen(1))
 
-		Expect(activeProcesses[0].Signal(garden.SignalKill)).To(Succeed())
-		Expect(signaller.sent).To(Equal([]os.Signal{os.Kill}))
+		Expect(activeProcesses[0].Signal(garden.SignalTerminate)).To(Succeed())
+		Expect(signaller.sent).To(Equal([]os.Signal{syscall.SIGTERM}))
 	})
 })
 
@@ -337,10 +422,22 @@ var _ = Describe("Listing active process IDs", func() {
 })
 
 type FakeSignaller struct {
-	sent []os.Signal
+	sent        []os.Signal
+	sentToGroup []os.Signal
+	sentToTree  []os.Signal
 }
 
 func (f *FakeSignaller) Signal(s os.Signal) error {
 	f.sent = append(f.sent, s)
 	return nil
 }
+
+func (f *FakeSignaller) SignalGroup(s os.Signal) error {
+	f.sentToGroup = append(f.sentToGroup, s)
+	return nil
+}
+
+func (f *FakeSignaller) SignalTree(s os.Signal) error {
+	f.sentToTree = append(f.sentToTree, s)
+	return nil
+}
@@ -0,0 +1,130 @@
+package process_tracker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// Garden only names SIGTERM and SIGKILL. The rest of the standard signals are
+// numbered after them, so that they can be sent as a garden.Signal too.
+const (
+	SignalHangup garden.Signal = garden.SignalKill + 1 + iota
+	SignalInterrupt
+	SignalQuit
+	SignalIllegalInstruction
+	SignalTrap
+	SignalAbort
+	SignalBus
+	SignalFloatingPoint
+	SignalUser1
+	SignalSegmentationFault
+	SignalUser2
+	SignalPipe
+	SignalAlarm
+	SignalStackFault
+	SignalChild
+	SignalContinue
+	SignalStop
+	SignalTerminalStop
+	SignalTerminalInput
+	SignalTerminalOutput
+	SignalUrgent
+	SignalCPULimit
+	SignalFileSizeLimit
+	SignalVirtualAlarm
+	SignalProfilingAlarm
+	SignalWindowChange
+	SignalIO
+	SignalPower
+	SignalBadSystemCall
+)
+
+type signalName struct {
+	name   string
+	signal syscall.Signal
+}
+
+// signals are what each garden.Signal is sent as, along with its name
+// without the SIG prefix.
+var signals = map[garden.Signal]signalName{
+	garden.SignalTerminate: {"TERM", syscall.SIGTERM},
+	garden.SignalKill:      {"KILL", syscall.SIGKILL},
+
+	SignalHangup:             {"HUP", syscall.SIGHUP},
+	SignalInterrupt:          {"INT", syscall.SIGINT},
+	SignalQuit:               {"QUIT", syscall.SIGQUIT},
+	SignalIllegalInstruction: {"ILL", syscall.SIGILL},
+	SignalTrap:               {"TRAP", syscall.SIGTRAP},
+	SignalAbort:              {"ABRT", syscall.SIGABRT},
+	SignalBus:                {"BUS", syscall.SIGBUS},
+	SignalFloatingPoint:      {"FPE", syscall.SIGFPE},
+	SignalUser1:              {"USR1", syscall.SIGUSR1},
+	SignalSegmentationFault:  {"SEGV", syscall.SIGSEGV},
+	SignalUser2:              {"USR2", syscall.SIGUSR2},
+	SignalPipe:               {"PIPE", syscall.SIGPIPE},
+	SignalAlarm:              {"ALRM", syscall.SIGALRM},
+	SignalStackFault:         {"STKFLT", syscall.SIGSTKFLT},
+	SignalChild:              {"CHLD", syscall.SIGCHLD},
+	SignalContinue:           {"CONT", syscall.SIGCONT},
+	SignalStop:               {"STOP", syscall.SIGSTOP},
+	SignalTerminalStop:       {"TSTP", syscall.SIGTSTP},
+	SignalTerminalInput:      {"TTIN", syscall.SIGTTIN},
+	SignalTerminalOutput:     {"TTOU", syscall.SIGTTOU},
+	SignalUrgent:             {"URG", syscall.SIGURG},
+	SignalCPULimit:           {"XCPU", syscall.SIGXCPU},
+	SignalFileSizeLimit:      {"XFSZ", syscall.SIGXFSZ},
+	SignalVirtualAlarm:       {"VTALRM", syscall.SIGVTALRM},
+	SignalProfilingAlarm:     {"PROF", syscall.SIGPROF},
+	SignalWindowChange:       {"WINCH", syscall.SIGWINCH},
+	SignalIO:                 {"IO", syscall.SIGIO},
+	SignalPower:              {"PWR", syscall.SIGPWR},
+	SignalBadSystemCall:      {"SYS", syscall.SIGSYS},
+}
+
+type UnknownSignalError struct {
+	Signal string
+}
+
+func (e UnknownSignalError) Error() string {
+	return fmt.Sprintf("process_tracker: unknown signal: %s", e.Signal)
+}
+
+// ParseSignal returns the garden.Signal for a signal given by name, with or
+// without the SIG prefix and in any case, or by its number.
+func ParseSignal(signal string) (garden.Signal, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signal)), "SIG")
+
+	number, err := strconv.Atoi(name)
+
+	for s, n := range signals {
+		if n.name == name || (err == nil && int(n.signal) == number) {
+			return s, nil
+		}
+	}
+
+	return 0, UnknownSignalError{Signal: signal}
+}
+
+// SignalName returns the name of the signal without the SIG prefix.
+func SignalName(s garden.Signal) (string, error) {
+	n, found := signals[s]
+	if !found {
+		return "", UnknownSignalError{Signal: strconv.Itoa(int(s))}
+	}
+
+	return n.name, nil
+}
+
+func osSignal(s garden.Signal) (os.Signal, error) {
+	n, found := signals[s]
+	if !found {
+		return nil, fmt.Errorf("process_tracker: failed to send signal: unknown signal: %d", s)
+	}
+
+	return n.signal, nil
+}